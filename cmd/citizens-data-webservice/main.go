@@ -3,17 +3,49 @@ package main
 
 import (
 	"citizen_webservice/internal/config"
+	"citizen_webservice/internal/http-server/handlers/addresses"
+	"citizen_webservice/internal/http-server/handlers/attribute_schemas"
+	"citizen_webservice/internal/http-server/handlers/batch"
+	"citizen_webservice/internal/http-server/handlers/cache_stats"
+	"citizen_webservice/internal/http-server/handlers/consents"
 	handlerDelete "citizen_webservice/internal/http-server/handlers/delete"
+	"citizen_webservice/internal/http-server/handlers/documents"
+	"citizen_webservice/internal/http-server/handlers/duplicates"
+	"citizen_webservice/internal/http-server/handlers/employments"
+	"citizen_webservice/internal/http-server/handlers/events"
 	"citizen_webservice/internal/http-server/handlers/get"
+	"citizen_webservice/internal/http-server/handlers/guardians"
 	"citizen_webservice/internal/http-server/handlers/iin_validate"
+	"citizen_webservice/internal/http-server/handlers/legal_holds"
+	"citizen_webservice/internal/http-server/handlers/merge"
+	"citizen_webservice/internal/http-server/handlers/organizations"
+	"citizen_webservice/internal/http-server/handlers/photo"
+	"citizen_webservice/internal/http-server/handlers/relationships"
+	handlerRetention "citizen_webservice/internal/http-server/handlers/retention"
 	"citizen_webservice/internal/http-server/handlers/save"
+	"citizen_webservice/internal/http-server/handlers/status"
+	"citizen_webservice/internal/http-server/handlers/stream"
+	"citizen_webservice/internal/http-server/handlers/subject_report"
+	"citizen_webservice/internal/http-server/handlers/tenants"
+	"citizen_webservice/internal/http-server/handlers/update"
+	handlerWebhooks "citizen_webservice/internal/http-server/handlers/webhooks"
+	"citizen_webservice/internal/storage/cache"
+	"citizen_webservice/internal/storage/rediscache"
 	"citizen_webservice/internal/storage/sqlite"
+	"citizen_webservice/internal/webhooks"
 
+	"citizen_webservice/internal/http-server/middleware/auth"
 	mwLogger "citizen_webservice/internal/http-server/middleware/logger"
+	"citizen_webservice/internal/http-server/middleware/requestid"
+	"citizen_webservice/internal/natspub"
+	"citizen_webservice/internal/photos"
+	"citizen_webservice/internal/retention"
 	"context"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
 	"log/slog"
 	"net/http"
 	"os"
@@ -46,31 +78,308 @@ func main() {
 	log.Debug("debug messages are enabled")
 
 	// 3. Storage
-	storage, err := sqlite.New(cfg.StoragePath)
+	storage, err := sqlite.New(cfg.StoragePath, sqlite.Options{
+		JournalMode:         cfg.SQLite.JournalMode,
+		BusyTimeout:         cfg.SQLite.BusyTimeout,
+		Synchronous:         cfg.SQLite.Synchronous,
+		MaxOpenConns:        cfg.SQLite.MaxOpenConns,
+		MaxIdleConns:        cfg.SQLite.MaxIdleConns,
+		ConnMaxLifetime:     cfg.SQLite.ConnMaxLifetime,
+		ConnMaxIdleTime:     cfg.SQLite.ConnMaxIdleTime,
+		WriteQueueDepth:     cfg.SQLite.WriteQueueDepth,
+		WriteBatchSize:      cfg.SQLite.WriteBatchSize,
+		AccessBufferSize:    cfg.SQLite.AccessBufferSize,
+		AccessFlushInterval: cfg.SQLite.AccessFlushInterval,
+	})
+	if err != nil {
+		log.Error("failed to initialize storage", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	// Background tasks run until the server has stopped
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// 4. Cache
+	var people cache.Backend = storage
+	var peopleCache *cache.Storage
+	var iinCheckCache iin_validate.ResultCache
+	var redisClient *redis.Client
+	if cfg.Cache.Enabled {
+		cacheOptions := cache.Options{
+			Size:        cfg.Cache.Size,
+			TTL:         cfg.Cache.TTL,
+			NegativeTTL: cfg.Cache.NegativeTTL,
+		}
+
+		var shared *rediscache.Cache
+		if cfg.Cache.Redis.Enabled {
+			redisClient = redis.NewClient(&redis.Options{
+				Addr:         cfg.Cache.Redis.Address,
+				Password:     cfg.Cache.Redis.Password,
+				DB:           cfg.Cache.Redis.DB,
+				DialTimeout:  cfg.Cache.Redis.DialTimeout,
+				ReadTimeout:  cfg.Cache.Redis.ReadTimeout,
+				WriteTimeout: cfg.Cache.Redis.WriteTimeout,
+			})
+			shared, err = rediscache.New(redisClient, rediscache.Options{
+				KeyPrefix:   cfg.Cache.Redis.KeyPrefix,
+				Channel:     cfg.Cache.Redis.Channel,
+				IINCheckTTL: cfg.Cache.Redis.IINCheckTTL,
+			})
+			if err != nil {
+				log.Error("failed to initialize shared cache", slog.String("error", err.Error()))
+				os.Exit(1)
+			}
+			// The service still works without Redis, lookups fall back to the storage
+			if err = shared.Ping(background); err != nil {
+				log.Error("shared cache is unavailable", slog.String("error", err.Error()))
+			}
+			cacheOptions.Shared = shared
+			iinCheckCache = shared
+		}
+
+		peopleCache = cache.New(storage, cacheOptions)
+		people = peopleCache
+
+		if shared != nil {
+			// Drop the local copies of the IINs written by the other replicas, resubscribing while Redis is down
+			go func() {
+				for {
+					err := shared.Subscribe(background, peopleCache.InvalidateLocal)
+					if background.Err() != nil {
+						return
+					}
+					if err != nil {
+						log.Error("failed to subscribe to cache invalidations", slog.String("error", err.Error()))
+					}
+					select {
+					case <-background.Done():
+						return
+					case <-time.After(time.Second):
+					}
+				}
+			}()
+		}
+	}
+
+	// 5. Webhooks
+	webhooksDone := make(chan struct{})
+	if cfg.Webhooks.Enabled {
+		worker := webhooks.NewWorker(log, storage, webhooks.Options{
+			PollInterval: cfg.Webhooks.PollInterval,
+			Timeout:      cfg.Webhooks.Timeout,
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			BaseBackoff:  cfg.Webhooks.BaseBackoff,
+			MaxBackoff:   cfg.Webhooks.MaxBackoff,
+			BatchSize:    cfg.Webhooks.BatchSize,
+			Concurrency:  cfg.Webhooks.Concurrency,
+			AllowPrivate: cfg.Webhooks.AllowPrivate,
+		})
+		go func() {
+			defer close(webhooksDone)
+			worker.Run(background)
+		}()
+	} else {
+		close(webhooksDone)
+	}
+
+	// 6. NATS
+	publisherDone := make(chan struct{})
+	var natsConn *nats.Conn
+	if cfg.NATS.Enabled {
+		// The service starts while the server is unavailable, the outbox is published once it connects
+		natsConn, err = nats.Connect(cfg.NATS.URL,
+			nats.Name("citizens-data-webservice"),
+			nats.RetryOnFailedConnect(true),
+			nats.MaxReconnects(-1),
+		)
+		if err != nil {
+			log.Error("failed to connect to NATS", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		publisher, err := natspub.New(log, natsConn, storage, natspub.Options{
+			Subjects: natspub.Subjects{
+				Created: cfg.NATS.CreatedSubject,
+				Updated: cfg.NATS.UpdatedSubject,
+				Deleted: cfg.NATS.DeletedSubject,
+			},
+			JetStream:    cfg.NATS.JetStream,
+			PollInterval: cfg.NATS.PollInterval,
+			Timeout:      cfg.NATS.Timeout,
+			BatchSize:    cfg.NATS.BatchSize,
+		})
+		if err != nil {
+			log.Error("failed to initialize NATS publisher", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		go func() {
+			defer close(publisherDone)
+			publisher.Run(background)
+		}()
+	} else {
+		close(publisherDone)
+	}
+
+	// 7. Retention
+	rules := make([]retention.Rule, 0, len(cfg.Retention.Rules))
+	for _, rule := range cfg.Retention.Rules {
+		rules = append(rules, retention.Rule{Target: rule.Target, MaxAge: rule.MaxAge})
+	}
+	scheduler, err := retention.NewScheduler(log, storage, rules, cfg.Retention.Interval)
 	if err != nil {
-		log.Error("failed to initialize storage", err)
+		log.Error("failed to initialize retention rules", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	retentionDone := make(chan struct{})
+	if cfg.Retention.Enabled {
+		go func() {
+			defer close(retentionDone)
+			scheduler.Run(background)
+		}()
+	} else {
+		close(retentionDone)
+	}
+
+	// 8. Photos
+	var photoStore photos.Store = storage
+	switch cfg.Photos.Storage {
+	case "sqlite":
+	case "directory":
+		dir, err := photos.NewDirectory(cfg.Photos.Directory, storage)
+		if err != nil {
+			log.Error("failed to initialize photo directory", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		// The database removes and moves the photos it keeps along with the people, the directory follows them
+		photoStore = dir
+		people = photos.Follow(log, people, dir)
+	default:
+		log.Error("unknown photo storage", slog.String("storage", cfg.Photos.Storage))
 		os.Exit(1)
 	}
+	photoOptions := photo.Options{
+		MaxSize: cfg.Photos.MaxSize,
+		Limits: photos.Limits{
+			MinWidth:      cfg.Photos.MinWidth,
+			MinHeight:     cfg.Photos.MinHeight,
+			MaxWidth:      cfg.Photos.MaxWidth,
+			MaxHeight:     cfg.Photos.MaxHeight,
+			ThumbnailSize: cfg.Photos.ThumbnailSize,
+		},
+	}
 
-	// 4. Router
+	// 9. Router
+	streamsDone := make(chan struct{})
 	router := chi.NewRouter()
 	router.Use(middleware.Logger)
 	router.Use(middleware.RequestID)
+	router.Use(requestid.New())
 	router.Use(middleware.URLFormat)
 	router.Use(middleware.Recoverer)
 	router.Use(mwLogger.New(log))
 
+	consentOptions := get.Options{RequirePurpose: cfg.Consent.RequirePurpose}
+
 	// Define the routes for the HTTP server.
 	router.Route("/", func(r chi.Router) {
-		r.Use(middleware.BasicAuth("citizen_website", map[string]string{
-			cfg.HTTPServer.User: cfg.HTTPServer.Password,
+		// Every client is scoped to its tenant, the operator of the service belonging to the default one
+		r.Use(auth.New(log, storage, auth.Options{
+			Realm:            "citizen_website",
+			OperatorUser:     cfg.HTTPServer.User,
+			OperatorPassword: cfg.HTTPServer.Password,
+			CacheTTL:         cfg.HTTPServer.CredentialCacheTTL,
+		}))
+
+		r.Get("/iin_check/{iin}", iin_validate.Execute(log, iinCheckCache))
+		r.Post("/people/info", save.Person(log, people, storage, save.Options{AdultAge: cfg.Guardians.AdultAge}))
+		r.Get("/people/info/iin/{iin}", get.ByIIN(log, people, storage, storage, consentOptions))
+		r.Put("/people/info/iin/{iin}", update.Person(log, people))
+		r.Get("/people/info/name/{name}", get.ByName(log, people, storage, storage, consentOptions))
+		r.Delete("/people/delete/{iin}", handlerDelete.ByIIN(log, people))
+		r.Post("/people/batch", batch.Execute(log, people, batch.Options{AdultAge: cfg.Guardians.AdultAge}))
+		r.Get("/people/info/{iin}/documents", documents.List(log, storage, storage))
+		r.Post("/people/info/{iin}/documents", documents.Create(log, storage))
+		r.Get("/people/info/{iin}/documents/{id}", documents.Get(log, storage, storage))
+		r.Put("/people/info/{iin}/documents/{id}", documents.Update(log, storage))
+		r.Delete("/people/info/{iin}/documents/{id}", documents.Delete(log, storage))
+		r.Get("/people/documents/expiring", documents.Expiring(log, storage, storage))
+		r.Get("/people/info/{iin}/photo", photo.Download(log, people, photoStore, storage))
+		r.Get("/people/info/{iin}/photo/thumbnail", photo.Thumbnail(log, people, photoStore, storage))
+		r.Put("/people/info/{iin}/photo", photo.Upload(log, people, photoStore, photoOptions))
+		r.Delete("/people/info/{iin}/photo", photo.Delete(log, photoStore))
+		r.Post("/people/relationships", relationships.Create(log, storage))
+		r.Delete("/people/relationships/{id}", relationships.Delete(log, storage))
+		r.Get("/people/info/{iin}/relationships", relationships.List(log, storage, storage))
+		r.Get("/people/info/{iin}/household", relationships.Household(log, storage, storage))
+		r.Get("/people/info/{iin}/addresses", addresses.List(log, storage, storage))
+		r.Put("/people/info/{iin}/addresses/{type}", addresses.Save(log, storage))
+		r.Delete("/people/info/{iin}/addresses/{type}", addresses.Delete(log, storage))
+		r.Get("/people/info/{iin}/employments", employments.List(log, storage, storage))
+		r.Post("/people/info/{iin}/employments", employments.Create(log, storage))
+		r.Put("/people/info/{iin}/employments/{id}", employments.Update(log, storage))
+		r.Delete("/people/info/{iin}/employments/{id}", employments.Delete(log, storage))
+		r.Post("/organizations", organizations.Create(log, storage))
+		r.Get("/organizations", organizations.List(log, storage))
+		r.Get("/organizations/{bin}", organizations.Get(log, storage))
+		r.Get("/organizations/{bin}/employees", organizations.Employees(log, storage, storage))
+		r.Get("/events", events.List(log, storage))
+		r.Get("/people/stream", stream.People(log, storage, stream.Options{
+			PollInterval: cfg.Stream.PollInterval,
+			Heartbeat:    min(cfg.Stream.Heartbeat, cfg.HTTPServer.IdleTimeout/2),
+			Retry:        cfg.Stream.Retry,
+			Done:         streamsDone,
 		}))
 
-		r.Get("/iin_check/{iin}", iin_validate.Execute(log))
-		r.Post("/people/info", save.Person(log, storage))
-		r.Get("/people/info/iin/{iin}", get.ByIIN(log, storage))
-		r.Get("/people/info/name/{name}", get.ByName(log, storage))
-		r.Delete("/people/delete/{iin}", handlerDelete.ByIIN(log, storage))
+		r.Get("/admin/people/duplicates", duplicates.Report(log, storage, storage, storage, consentOptions))
+		r.Post("/admin/people/merge", merge.Person(log, people, storage, storage, consentOptions))
+		r.Get("/admin/people/merges", merge.Log(log, storage, storage, storage, consentOptions))
+		r.Get("/admin/people/minors/without-guardian", guardians.Report(log, storage, cfg.Guardians.AdultAge))
+		r.Get("/admin/people/statistics/regions", addresses.Statistics(log, storage))
+		r.Get("/admin/people/{iin}/subject-report", subject_report.Execute(log, storage, photoStore, storage))
+		r.Get("/admin/people/{iin}/consents", consents.List(log, storage))
+		r.Post("/admin/people/{iin}/consents/grant", consents.Grant(log, storage))
+		r.Post("/admin/people/{iin}/consents/revoke", consents.Revoke(log, storage))
+		r.Get("/admin/people/{iin}/status", status.History(log, storage))
+		r.Post("/admin/people/{iin}/status", status.Change(log, people))
+
+		r.Get("/admin/attributes", attribute_schemas.List(log, storage))
+		r.Get("/admin/attributes/{namespace}", attribute_schemas.Get(log, storage))
+		r.Put("/admin/attributes/{namespace}", attribute_schemas.Register(log, storage))
+		r.Delete("/admin/attributes/{namespace}", attribute_schemas.Delete(log, storage))
+
+		r.Post("/admin/webhooks", handlerWebhooks.Create(log, storage, cfg.Webhooks.AllowPrivate))
+		r.Get("/admin/webhooks", handlerWebhooks.List(log, storage))
+		r.Get("/admin/webhooks/dead-letters", handlerWebhooks.DeadLetters(log, storage))
+		r.Get("/admin/webhooks/{id}", handlerWebhooks.Get(log, storage))
+		r.Put("/admin/webhooks/{id}", handlerWebhooks.Update(log, storage, cfg.Webhooks.AllowPrivate))
+		r.Delete("/admin/webhooks/{id}", handlerWebhooks.Delete(log, storage))
+		r.Get("/admin/webhooks/{id}/deliveries", handlerWebhooks.Deliveries(log, storage))
+		r.Post("/admin/webhooks/deliveries/{id}/retry", handlerWebhooks.Retry(log, storage))
+
+		// Routes spanning every tenant are reserved for the operator
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireOperator())
+
+			r.Post("/admin/tenants", tenants.Create(log, storage))
+			r.Get("/admin/tenants", tenants.List(log, storage))
+			r.Post("/admin/tenants/{id}/credentials", tenants.AssignCredential(log, storage, cfg.HTTPServer.User))
+
+			r.Get("/admin/retention/report", handlerRetention.Report(log, scheduler))
+
+			// Legal holds are placed and released by the operator alone, whatever the tenants are allowed to change,
+			// on the citizens of the tenant named in the path
+			holds := r.With(auth.ActAsTenant(log, storage, "id"))
+			holds.Get("/admin/tenants/{id}/people/{iin}/legal-hold", legal_holds.Get(log, storage))
+			holds.Post("/admin/tenants/{id}/people/{iin}/legal-hold", legal_holds.Place(log, storage))
+			holds.Post("/admin/tenants/{id}/people/{iin}/legal-hold/release", legal_holds.Release(log, storage))
+			holds.Get("/admin/tenants/{id}/people/{iin}/legal-hold/log", legal_holds.Log(log, storage))
+			holds.Get("/admin/tenants/{id}/legal-holds", legal_holds.List(log, storage))
+
+			if peopleCache != nil {
+				r.Get("/admin/cache/stats", cache_stats.Execute(log, peopleCache))
+			}
+		})
 	})
 
 	log.Info("starting server", slog.String("address", cfg.Address))
@@ -85,11 +394,12 @@ func main() {
 		WriteTimeout: cfg.HTTPServer.Timeout,
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
+	srv.RegisterOnShutdown(func() { close(streamsDone) })
 
 	// Start the HTTP server in a separate goroutine.
 	go func() {
 		if err := srv.ListenAndServe(); err != nil {
-			log.Error("failed to start server", err)
+			log.Error("failed to start server", slog.String("error", err.Error()))
 		}
 	}()
 
@@ -109,6 +419,23 @@ func main() {
 
 	log.Info("server stopped")
 
+	stopBackground()
+	<-webhooksDone
+	<-publisherDone
+	<-retentionDone
+	if natsConn != nil {
+		natsConn.Close()
+	}
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			log.Error("failed to close shared cache", slog.String("error", err.Error()))
+		}
+	}
+
+	if err := storage.Close(); err != nil {
+		log.Error("failed to close storage", slog.String("error", err.Error()))
+	}
+
 }
 
 // setupLogger initializes a logger based on the environment.
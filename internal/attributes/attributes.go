@@ -0,0 +1,135 @@
+// Package attributes provides the validation of the extension attributes of people against the JSON Schemas
+// registered for their namespaces, and of the paths the attributes are searched by.
+//
+// The attributes of a person are a JSON object keyed by namespace, each value of which must be valid
+// against the schema of its namespace. A path names a field of the attributes, the namespace first,
+// e.g. "benefits.category".
+package attributes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// MaxPathDepth is the number of fields a path may have below the namespace.
+const MaxPathDepth = 4
+
+var (
+	ErrorInvalidNamespace = errors.New("namespace must be 1 to 32 lowercase letters, digits or underscores, starting with a letter")
+	ErrorInvalidSchema    = errors.New("invalid JSON Schema")
+	ErrorInvalidValue     = errors.New("attribute does not match its schema")
+	ErrorInvalidPath      = errors.New("invalid attribute path")
+)
+
+var (
+	namespacePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+	fieldPattern     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+)
+
+// ValidateNamespace function checks the format of a namespace.
+func ValidateNamespace(namespace string) error {
+	if !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("%w: %q", ErrorInvalidNamespace, namespace)
+	}
+	return nil
+}
+
+// Compile function parses a JSON Schema, which must be a JSON object.
+// Only references within the schema itself are allowed, so that validation never loads anything from elsewhere.
+// It returns the compiled schema or an error, ErrorInvalidSchema.
+func Compile(schema []byte) (*gojsonschema.Schema, error) {
+	var document any
+	if err := json.Unmarshal(schema, &document); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidSchema, err.Error())
+	}
+	if _, ok := document.(map[string]any); !ok {
+		return nil, fmt.Errorf("%w: schema must be a JSON object", ErrorInvalidSchema)
+	}
+	if err := checkReferences(document); err != nil {
+		return nil, err
+	}
+
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(document))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidSchema, err.Error())
+	}
+	return compiled, nil
+}
+
+// Validate function checks a value against a JSON Schema.
+// It returns an error, ErrorInvalidSchema or ErrorInvalidValue listing every violation.
+func Validate(schema []byte, value json.RawMessage) error {
+	compiled, err := Compile(schema)
+	if err != nil {
+		return err
+	}
+
+	result, err := compiled.Validate(gojsonschema.NewBytesLoader(value))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrorInvalidValue, err.Error())
+	}
+	if !result.Valid() {
+		violations := make([]string, 0, len(result.Errors()))
+		for _, violation := range result.Errors() {
+			violations = append(violations, violation.String())
+		}
+		return fmt.Errorf("%w: %s", ErrorInvalidValue, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// ValidatePath function checks that a path is a namespace followed by at least one and at most MaxPathDepth fields.
+// It returns the namespace of the path or an error, ErrorInvalidPath.
+func ValidatePath(path string) (string, error) {
+	fields := strings.Split(path, ".")
+	if len(fields) < 2 || len(fields) > MaxPathDepth+1 || ValidateNamespace(fields[0]) != nil {
+		return "", fmt.Errorf("%w: %q", ErrorInvalidPath, path)
+	}
+	for _, field := range fields[1:] {
+		if !fieldPattern.MatchString(field) {
+			return "", fmt.Errorf("%w: %q", ErrorInvalidPath, path)
+		}
+	}
+	return fields[0], nil
+}
+
+// FilterValue function reads the value of an attribute filter given as text.
+// Numbers, true, false, null and quoted strings are read as JSON, anything else is taken as a string.
+func FilterValue(text string) json.RawMessage {
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err == nil {
+		switch value.(type) {
+		case float64, bool, string, nil:
+			return json.RawMessage(text)
+		}
+	}
+	quoted, _ := json.Marshal(text)
+	return quoted
+}
+
+// checkReferences is a helper function to reject the references of a schema to anything but the schema itself.
+func checkReferences(document any) error {
+	switch node := document.(type) {
+	case map[string]any:
+		for key, value := range node {
+			if ref, ok := value.(string); ok && key == "$ref" && !strings.HasPrefix(ref, "#") {
+				return fmt.Errorf("%w: only local references are allowed: %q", ErrorInvalidSchema, ref)
+			}
+			if err := checkReferences(value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, value := range node {
+			if err := checkReferences(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
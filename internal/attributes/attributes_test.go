@@ -0,0 +1,135 @@
+package attributes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const benefits = `{
+ "type": "object",
+ "properties": {
+  "category": {"enum": ["veteran", "disability", "large_family"]},
+  "since": {"type": "integer", "minimum": 1991}
+ },
+ "required": ["category"],
+ "additionalProperties": false
+}`
+
+func TestCompile(t *testing.T) {
+	testCases := []struct {
+		name   string
+		schema string
+		err    error
+	}{
+		{
+			name:   "Test Case 1: Valid schema",
+			schema: benefits,
+		},
+		{
+			name:   "Test Case 2: Local reference",
+			schema: `{"definitions": {"year": {"type": "integer"}}, "properties": {"since": {"$ref": "#/definitions/year"}}}`,
+		},
+		{
+			name:   "Test Case 3: Not JSON",
+			schema: `{"type": `,
+			err:    ErrorInvalidSchema,
+		},
+		{
+			name:   "Test Case 4: Not an object",
+			schema: `["type", "object"]`,
+			err:    ErrorInvalidSchema,
+		},
+		{
+			name:   "Test Case 5: Unknown type",
+			schema: `{"type": "date"}`,
+			err:    ErrorInvalidSchema,
+		},
+		{
+			name:   "Test Case 6: Remote reference",
+			schema: `{"properties": {"since": {"$ref": "http://example.com/year.json"}}}`,
+			err:    ErrorInvalidSchema,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Compile([]byte(tc.schema))
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value string
+		err   error
+	}{
+		{
+			name:  "Test Case 1: Valid value",
+			value: `{"category": "veteran", "since": 2001}`,
+		},
+		{
+			name:  "Test Case 2: Missing required field",
+			value: `{"since": 2001}`,
+			err:   ErrorInvalidValue,
+		},
+		{
+			name:  "Test Case 3: Value out of the enum",
+			value: `{"category": "student"}`,
+			err:   ErrorInvalidValue,
+		},
+		{
+			name:  "Test Case 4: Unknown field",
+			value: `{"category": "veteran", "note": "x"}`,
+			err:   ErrorInvalidValue,
+		},
+		{
+			name:  "Test Case 5: Not an object",
+			value: `"veteran"`,
+			err:   ErrorInvalidValue,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate([]byte(benefits), json.RawMessage(tc.value))
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	namespace, err := ValidatePath("benefits.category")
+	require.NoError(t, err)
+	assert.Equal(t, "benefits", namespace)
+
+	namespace, err = ValidatePath("benefits.address.city_code")
+	require.NoError(t, err)
+	assert.Equal(t, "benefits", namespace)
+
+	for _, path := range []string{"benefits", "Benefits.category", "benefits.", "benefits.a-b", "benefits.a') OR 1", "a.b.c.d.e.f"} {
+		_, err = ValidatePath(path)
+		assert.ErrorIs(t, err, ErrorInvalidPath, path)
+	}
+}
+
+func TestFilterValue(t *testing.T) {
+	assert.JSONEq(t, `2001`, string(FilterValue("2001")))
+	assert.JSONEq(t, `true`, string(FilterValue("true")))
+	assert.JSONEq(t, `null`, string(FilterValue("null")))
+	assert.JSONEq(t, `"2001"`, string(FilterValue(`"2001"`)))
+	assert.JSONEq(t, `"veteran"`, string(FilterValue("veteran")))
+	assert.JSONEq(t, `"{\"a\": 1}"`, string(FilterValue(`{"a": 1}`)))
+}
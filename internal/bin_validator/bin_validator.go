@@ -0,0 +1,59 @@
+// Package bin_validator provides functionality for validating Business Identification Numbers (BIN).
+//
+// A BIN has twelve digits: the year and month of registration (YYMM), the type of the entity,
+// its kind of registration, a serial number of five digits and a control digit computed like the one of an IIN.
+package bin_validator
+
+import (
+	"citizen_webservice/internal/iin_validator"
+	"fmt"
+	"unicode"
+)
+
+// Constants related to BIN validation.
+const (
+	BINLength = 12
+
+	FifthDigitResident     = 4 // Legal entity resident in Kazakhstan
+	FifthDigitNonResident  = 5 // Legal entity not resident in Kazakhstan
+	FifthDigitJointVenture = 6 // Individual entrepreneur in a joint venture
+
+	SixthDigitHeadOffice     = 0
+	SixthDigitBranch         = 1
+	SixthDigitRepresentative = 2
+	SixthDigitPeasantFarm    = 3
+)
+
+// ValidateBIN validates a BIN. It checks the length, digit-only content, month of registration,
+// type of the entity, kind of registration, and 12th digit.
+func ValidateBIN(bin string) error {
+	if len(bin) != BINLength {
+		return fmt.Errorf("BIN must be %d digits long", BINLength)
+	}
+	for _, r := range bin {
+		if !unicode.IsDigit(r) {
+			return fmt.Errorf("BIN must only contain digits")
+		}
+	}
+
+	month := int(bin[2]-'0')*10 + int(bin[3]-'0')
+	if month < 1 || month > 12 {
+		return fmt.Errorf("3rd and 4th digits of BIN must form a valid month of registration")
+	}
+	if fifth := int(bin[4] - '0'); fifth < FifthDigitResident || fifth > FifthDigitJointVenture {
+		return fmt.Errorf("invalid 5th digit, must be between %d and %d inclusive", FifthDigitResident, FifthDigitJointVenture)
+	}
+	if sixth := int(bin[5] - '0'); sixth > SixthDigitPeasantFarm {
+		return fmt.Errorf("invalid 6th digit, must be between %d and %d inclusive", SixthDigitHeadOffice, SixthDigitPeasantFarm)
+	}
+
+	twelfthDigit, err := iin_validator.CheckDigit(bin)
+	if err != nil {
+		return fmt.Errorf("error while validating 12th digit: %w", err)
+	}
+	if twelfthDigit != int(bin[11]-'0') {
+		return fmt.Errorf("invalid 12th digit")
+	}
+
+	return nil
+}
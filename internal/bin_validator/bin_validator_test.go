@@ -0,0 +1,70 @@
+package bin_validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBIN(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bin   string
+		valid bool
+	}{
+		{
+			name:  "Test Case 1: Resident head office",
+			bin:   "040840001231",
+			valid: true,
+		},
+		{
+			name:  "Test Case 2: Branch",
+			bin:   "150241003214",
+			valid: true,
+		},
+		{
+			name:  "Test Case 3: Joint venture",
+			bin:   "121060007895",
+			valid: true,
+		},
+		{
+			name: "Test Case 4: Too short",
+			bin:  "04084000123",
+		},
+		{
+			name: "Test Case 5: Not digits",
+			bin:  "04084000123A",
+		},
+		{
+			name: "Test Case 6: Invalid month",
+			bin:  "041340001231",
+		},
+		{
+			name: "Test Case 7: Invalid type",
+			bin:  "040830001231",
+		},
+		{
+			name: "Test Case 8: Invalid kind of registration",
+			bin:  "040845001231",
+		},
+		{
+			name: "Test Case 9: Invalid 12th digit",
+			bin:  "040840001232",
+		},
+		{
+			name: "Test Case 10: IIN",
+			bin:  "830218350074",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateBIN(tc.bin)
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+		})
+	}
+}
@@ -10,13 +10,148 @@ import (
 )
 
 // Config is the main configuration structure.
-// It includes the environment, storage path, and HTTP server configuration.
+// It includes the environment, storage path, SQLite, cache, webhooks, stream, NATS, retention, consent, photos, guardians,
+// and HTTP server configuration.
 type Config struct {
-	Env         string `yaml:"env" env-default:"local"`
-	StoragePath string `yaml:"storage_path" env-required:"true"`
+	Env         string    `yaml:"env" env-default:"local"`
+	StoragePath string    `yaml:"storage_path" env-required:"true"`
+	SQLite      SQLite    `yaml:"sqlite"`
+	Cache       Cache     `yaml:"cache"`
+	Webhooks    Webhooks  `yaml:"webhooks"`
+	Stream      Stream    `yaml:"stream"`
+	NATS        NATS      `yaml:"nats"`
+	Retention   Retention `yaml:"retention"`
+	Consent     Consent   `yaml:"consent"`
+	Photos      Photos    `yaml:"photos"`
+	Guardians   Guardians `yaml:"guardians"`
 	HTTPServer  `yaml:"http_server"`
 }
 
+// Cache is a structure for the read-through cache configuration.
+// It includes whether the cache is enabled, its size, how long found and missing IINs are cached,
+// and the optional Redis cache shared between replicas.
+type Cache struct {
+	Enabled     bool          `yaml:"enabled" env-default:"true"`
+	Size        int           `yaml:"size" env-default:"10000"`
+	TTL         time.Duration `yaml:"ttl" env-default:"5m"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" env-default:"30s"`
+	Redis       Redis         `yaml:"redis"`
+}
+
+// Redis is a structure for the shared cache configuration.
+// It includes whether it is enabled, the connection settings, the key prefix, the invalidation channel,
+// and how long /iin_check results are cached.
+type Redis struct {
+	Enabled      bool          `yaml:"enabled" env-default:"false"`
+	Address      string        `yaml:"address" env-default:"localhost:6379"`
+	Password     string        `yaml:"password" env:"REDIS_PASSWORD"`
+	DB           int           `yaml:"db" env-default:"0"`
+	KeyPrefix    string        `yaml:"key_prefix" env-default:"citizens:"`
+	Channel      string        `yaml:"channel" env-default:"invalidations"`
+	IINCheckTTL  time.Duration `yaml:"iin_check_ttl" env-default:"24h"`
+	DialTimeout  time.Duration `yaml:"dial_timeout" env-default:"2s"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env-default:"500ms"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env-default:"500ms"`
+}
+
+// Webhooks is a structure for the webhook delivery worker configuration.
+// It includes whether deliveries are sent, the poll interval, the request timeout, the retry policy,
+// how many deliveries are POSTed to a webhook at once, and whether webhooks may target private addresses.
+type Webhooks struct {
+	Enabled      bool          `yaml:"enabled" env-default:"true"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"5s"`
+	MaxAttempts  int           `yaml:"max_attempts" env-default:"8"`
+	BaseBackoff  time.Duration `yaml:"base_backoff" env-default:"1s"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" env-default:"1h"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	Concurrency  int           `yaml:"concurrency" env-default:"4"`
+	AllowPrivate bool          `yaml:"allow_private_hosts" env-default:"false"`
+}
+
+// Stream is a structure for the Server-Sent Events stream configuration.
+// It includes how often new events are looked up, the heartbeat interval, and the reconnection delay of clients.
+// The heartbeat is capped at half of the HTTP server idle timeout.
+type Stream struct {
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"500ms"`
+	Heartbeat    time.Duration `yaml:"heartbeat" env-default:"15s"`
+	Retry        time.Duration `yaml:"retry" env-default:"3s"`
+}
+
+// NATS is a structure for the change event publisher configuration.
+// It includes whether events are published, the server URL, whether publishing waits for JetStream acknowledgements,
+// the subject of every kind of change, left empty to not publish it, and the outbox polling settings.
+type NATS struct {
+	Enabled        bool          `yaml:"enabled" env-default:"false"`
+	URL            string        `yaml:"url" env-default:"nats://localhost:4222"`
+	JetStream      bool          `yaml:"jetstream" env-default:"false"`
+	CreatedSubject string        `yaml:"created_subject" env-default:"citizens.person.created"`
+	UpdatedSubject string        `yaml:"updated_subject"`
+	DeletedSubject string        `yaml:"deleted_subject" env-default:"citizens.person.deleted"`
+	PollInterval   time.Duration `yaml:"poll_interval" env-default:"1s"`
+	Timeout        time.Duration `yaml:"timeout" env-default:"5s"`
+	BatchSize      int           `yaml:"batch_size" env-default:"100"`
+}
+
+// Retention is a structure for the retention rules configuration.
+// It includes whether expired data is purged, how often, and how long the data of every target is kept.
+// Targets without a rule are kept forever. The report endpoint is available even if purging is disabled.
+type Retention struct {
+	Enabled  bool            `yaml:"enabled" env-default:"true"`
+	Interval time.Duration   `yaml:"interval" env-default:"24h"`
+	Rules    []RetentionRule `yaml:"rules"`
+}
+
+// RetentionRule is a structure for a retention rule.
+// It includes the target, one of deleted_people, deceased_people, events, merge_log, webhook_deliveries and access_log,
+// and its maximum age.
+type RetentionRule struct {
+	Target string        `yaml:"target"`
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// Consent is a structure for the consent enforcement configuration.
+// It includes whether clients declaring no purpose are refused the phones, as those declaring one without consent are.
+type Consent struct {
+	RequirePurpose bool `yaml:"require_purpose" env-default:"false"`
+}
+
+// Photos is a structure for the photo storage configuration.
+// It includes where the images are kept, sqlite for blobs in the database or directory for files in Directory,
+// the maximum size of an image in bytes, the bounds of its dimensions, and the bound of the longer side of thumbnails.
+type Photos struct {
+	Storage       string `yaml:"storage" env-default:"sqlite"`
+	Directory     string `yaml:"directory" env-default:"./photos"`
+	MaxSize       int64  `yaml:"max_size" env-default:"5242880"`
+	MinWidth      int    `yaml:"min_width" env-default:"64"`
+	MinHeight     int    `yaml:"min_height" env-default:"64"`
+	MaxWidth      int    `yaml:"max_width" env-default:"4096"`
+	MaxHeight     int    `yaml:"max_height" env-default:"4096"`
+	ThumbnailSize int    `yaml:"thumbnail_size" env-default:"160"`
+}
+
+// Guardians is a structure for the guardian requirement configuration.
+// It includes the age, in full years derived from the IIN, below which people are only saved with an adult guardian.
+type Guardians struct {
+	AdultAge int `yaml:"adult_age" env-default:"18"`
+}
+
+// SQLite is a structure for SQLite connection configuration.
+// It includes the journal mode, busy timeout, synchronous mode, connection pool limits, writer queue and access log buffer settings.
+type SQLite struct {
+	JournalMode         string        `yaml:"journal_mode" env-default:"WAL"`
+	BusyTimeout         time.Duration `yaml:"busy_timeout" env-default:"5s"`
+	Synchronous         string        `yaml:"synchronous" env-default:"NORMAL"`
+	MaxOpenConns        int           `yaml:"max_open_conns" env-default:"8"`
+	MaxIdleConns        int           `yaml:"max_idle_conns" env-default:"8"`
+	ConnMaxLifetime     time.Duration `yaml:"conn_max_lifetime" env-default:"0s"`
+	ConnMaxIdleTime     time.Duration `yaml:"conn_max_idle_time" env-default:"5m"`
+	WriteQueueDepth     int           `yaml:"write_queue_depth" env-default:"256"`
+	WriteBatchSize      int           `yaml:"write_batch_size" env-default:"64"`
+	AccessBufferSize    int           `yaml:"access_buffer_size" env-default:"4096"`
+	AccessFlushInterval time.Duration `yaml:"access_flush_interval" env-default:"1s"`
+}
+
 // HTTPServer is a structure for HTTP server configuration.
 // It includes the address, timeout, idle timeout, user, and password.
 type HTTPServer struct {
@@ -25,6 +160,8 @@ type HTTPServer struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
 	User        string        `yaml:"user" env-required:"true"`
 	Password    string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	// How long a verified tenant password is remembered, sparing a bcrypt comparison per request
+	CredentialCacheTTL time.Duration `yaml:"credential_cache_ttl" env-default:"1m"`
 }
 
 // Load reads the configuration file specified by the CONFIG_PATH environment variable.
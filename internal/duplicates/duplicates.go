@@ -0,0 +1,141 @@
+// Package duplicates provides functionality for finding records that likely describe the same citizen.
+package duplicates
+
+import (
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Weights of the individual signals in the total score of a candidate pair. They add up to 1.
+const (
+	WeightName        = 0.4
+	WeightPhone       = 0.2
+	WeightIIN         = 0.25
+	WeightDateOfBirth = 0.15
+)
+
+// DefaultMinScore is the score a pair must reach to be reported when no threshold is given.
+const DefaultMinScore = 0.75
+
+// Candidate is a pair of records that may describe the same citizen.
+// Every score is in the range [0, 1], where 1 means the values are identical.
+type Candidate struct {
+	First            storage.PersonInfo `json:"first"`
+	Second           storage.PersonInfo `json:"second"`
+	Score            float64            `json:"score"`
+	NameScore        float64            `json:"name_score"`
+	PhoneScore       float64            `json:"phone_score"`
+	IINScore         float64            `json:"iin_score"`
+	IINDistance      int                `json:"iin_distance"`
+	DateOfBirthMatch bool               `json:"date_of_birth_match"`
+}
+
+// Score compares two records and returns the candidate pair with all of its scores filled in.
+func Score(first, second storage.PersonInfo) Candidate {
+	candidate := Candidate{
+		First:            first,
+		Second:           second,
+		NameScore:        similarity(normalizeName(first.Name), normalizeName(second.Name)),
+		PhoneScore:       similarity(normalizePhone(first.Phone), normalizePhone(second.Phone)),
+		IINDistance:      distance(first.IIN, second.IIN),
+		DateOfBirthMatch: sameDateOfBirth(first.IIN, second.IIN),
+	}
+	candidate.IINScore = 1 - float64(candidate.IINDistance)/float64(iin_validator.IINLength)
+	if candidate.IINScore < 0 {
+		candidate.IINScore = 0
+	}
+
+	candidate.Score = WeightName*candidate.NameScore +
+		WeightPhone*candidate.PhoneScore +
+		WeightIIN*candidate.IINScore
+	if candidate.DateOfBirthMatch {
+		candidate.Score += WeightDateOfBirth
+	}
+
+	return candidate
+}
+
+// ScorePairs scores the pairs of records, e.g. those sharing a blocking key in the storage,
+// and returns the pairs scoring at least minScore, best matches first.
+func ScorePairs(pairs []storage.PersonPair, minScore float64) []Candidate {
+	candidates := []Candidate{}
+	for _, pair := range pairs {
+		candidate := Score(pair.First, pair.Second)
+		if candidate.Score >= minScore {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	return candidates
+}
+
+// sameDateOfBirth reports whether both IINs encode the same valid date of birth.
+func sameDateOfBirth(first, second string) bool {
+	firstDate, err := iin_validator.GetDateOfBirth(first)
+	if err != nil {
+		return false
+	}
+	secondDate, err := iin_validator.GetDateOfBirth(second)
+	if err != nil {
+		return false
+	}
+	return firstDate.Equal(secondDate)
+}
+
+// normalizeName lowercases the name and collapses all whitespace into single spaces.
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// normalizePhone keeps only the digits of the phone number.
+func normalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+// similarity returns 1 minus the edit distance of the strings relative to the length of the longer one.
+func similarity(first, second string) float64 {
+	longest := len([]rune(first))
+	if l := len([]rune(second)); l > longest {
+		longest = l
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(distance(first, second))/float64(longest)
+}
+
+// distance calculates the Levenshtein distance between two strings.
+func distance(first, second string) int {
+	a, b := []rune(first), []rune(second)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
@@ -0,0 +1,105 @@
+package duplicates
+
+import (
+	"citizen_webservice/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	testCases := []struct {
+		name     string
+		first    string
+		second   string
+		expected int
+	}{
+		{
+			name:     "Test Case 1: Identical strings",
+			first:    "830218350074",
+			second:   "830218350074",
+			expected: 0,
+		},
+		{
+			name:     "Test Case 2: One digit mistyped",
+			first:    "830218350074",
+			second:   "830218350075",
+			expected: 1,
+		},
+		{
+			name:     "Test Case 3: Empty string",
+			first:    "",
+			second:   "abc",
+			expected: 3,
+		},
+		{
+			name:     "Test Case 4: Non-ASCII names",
+			first:    "Әлия",
+			second:   "Алия",
+			expected: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, distance(tc.first, tc.second))
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	testCases := []struct {
+		name             string
+		first            storage.PersonInfo
+		second           storage.PersonInfo
+		expectedScore    float64
+		expectedDistance int
+		expectedDOBMatch bool
+	}{
+		{
+			name:             "Test Case 1: Same person, mistyped last digit",
+			first:            storage.PersonInfo{IIN: "830218350074", Name: "Test Name", Phone: "+7 701 123 45 67"},
+			second:           storage.PersonInfo{IIN: "830218350075", Name: "test  name", Phone: "87011234567"},
+			expectedScore:    0.4 + 0.2*10/11 + 0.25*11/12 + 0.15,
+			expectedDistance: 1,
+			expectedDOBMatch: true,
+		},
+		{
+			name:             "Test Case 2: Different people",
+			first:            storage.PersonInfo{IIN: "830218350074", Name: "ab", Phone: "12"},
+			second:           storage.PersonInfo{IIN: "600426400918", Name: "cd", Phone: "34"},
+			expectedScore:    0.25 * 2 / 12,
+			expectedDistance: 10,
+			expectedDOBMatch: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			candidate := Score(tc.first, tc.second)
+			assert.InDelta(t, tc.expectedScore, candidate.Score, 1e-9)
+			assert.Equal(t, tc.expectedDistance, candidate.IINDistance)
+			assert.Equal(t, tc.expectedDOBMatch, candidate.DateOfBirthMatch)
+		})
+	}
+}
+
+func TestScorePairs(t *testing.T) {
+	pairs := []storage.PersonPair{
+		{
+			First:  storage.PersonInfo{IIN: "600426400918", Name: "Test Name", Phone: "87770000000"},
+			Second: storage.PersonInfo{IIN: "830218350075", Name: "Test Name", Phone: "87011234568"},
+		},
+		{
+			First:  storage.PersonInfo{IIN: "830218350074", Name: "Test Name", Phone: "87011234567"},
+			Second: storage.PersonInfo{IIN: "830218350075", Name: "Test Name", Phone: "87011234568"},
+		},
+	}
+
+	candidates := ScorePairs(pairs, 0.4)
+	assert.Len(t, candidates, 2)
+	assert.Equal(t, "830218350074", candidates[0].First.IIN)
+	assert.Greater(t, candidates[0].Score, candidates[1].Score)
+	assert.Len(t, ScorePairs(pairs, DefaultMinScore), 1)
+	assert.Empty(t, ScorePairs(nil, 0))
+}
@@ -0,0 +1,323 @@
+// Package addresses provides HTTP handlers for managing the postal addresses of people
+// and for counting people by region.
+package addresses
+
+import (
+	"citizen_webservice/internal/http-server/handlers/get"
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/kato"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Types lists the address types.
+var Types = []string{storage.AddressRegistered, storage.AddressActual}
+
+var (
+	errorInvalidIIN    = errors.New("invalid IIN")
+	errorUnknownType   = errors.New("type must be registered or actual")
+	errorUnknownStatus = errors.New("unknown status")
+)
+
+// Request is the structure for the request body of the Save handler.
+type Request struct {
+	KATO       string `json:"kato" validate:"required"` // Validated against the KATO reference table
+	Locality   string `json:"locality" validate:"required,max=255"`
+	Street     string `json:"street" validate:"required,max=255"`
+	House      string `json:"house" validate:"required,max=32"`
+	Apartment  string `json:"apartment" validate:"max=32"`
+	PostalCode string `json:"postal_code" validate:"omitempty,len=6,numeric"`
+}
+
+// AddressSaver is an interface for saving addresses.
+type AddressSaver interface {
+	SaveAddress(ctx context.Context, address storage.Address) (storage.Address, error)
+}
+
+// AddressesGetter is an interface for listing the addresses of a person.
+type AddressesGetter interface {
+	GetAddresses(ctx context.Context, iin string) ([]storage.Address, error)
+}
+
+// AddressDeleter is an interface for deleting addresses.
+type AddressDeleter interface {
+	DeleteAddress(ctx context.Context, iin string, addressType string) error
+}
+
+// AccessRecorder is an interface for writing the access log.
+type AccessRecorder interface {
+	RecordAccess(ctx context.Context, entries []storage.AccessEntry) error
+}
+
+// StatisticsGetter is an interface for counting people by region.
+type StatisticsGetter interface {
+	GetRegionStatistics(ctx context.Context, addressType string, status string) (storage.RegionStatistics, error)
+}
+
+// AddressResponse is the response structure for the Save and Delete handlers.
+type AddressResponse struct {
+	Success bool             `json:"success"`
+	Errors  []string         `json:"errors"`
+	Address *storage.Address `json:"address,omitempty"`
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success   bool              `json:"success"`
+	Errors    []string          `json:"errors"`
+	Addresses []storage.Address `json:"addresses"`
+}
+
+// RegionCount is the number of people with an address in a region, with the name of the region.
+type RegionCount struct {
+	Region string `json:"region"` // KATO code of the region
+	Name   string `json:"name"`
+	People int64  `json:"people"`
+}
+
+// StatisticsResponse is the response structure for the Statistics handler.
+type StatisticsResponse struct {
+	Success bool          `json:"success"`
+	Errors  []string      `json:"errors"`
+	Type    string        `json:"type,omitempty"`
+	Regions []RegionCount `json:"regions"`
+	Unknown int64         `json:"unknown"` // People without an address of the type
+}
+
+// Save is a HTTP handler function for storing the address of a person of the type given by the type URL parameter,
+// replacing the previous one. It validates the IIN, the type and the request body, the KATO code against
+// the reference table, and returns a JSON response with the saved address.
+func Save(log *slog.Logger, addressSaver AddressSaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.addresses.Save"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, addressType, err := parseIINAndType(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		var req Request
+		if err = render.DecodeJSON(r.Body, &req); err == nil {
+			err = request_validator.GetValidator().Struct(req)
+		}
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+		region, err := kato.Validate(req.KATO)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		address, err := addressSaver.SaveAddress(r.Context(), storage.Address{
+			IIN:        iin,
+			Type:       addressType,
+			KATO:       req.KATO,
+			Region:     region.Code,
+			Locality:   req.Locality,
+			Street:     req.Street,
+			House:      req.House,
+			Apartment:  req.Apartment,
+			PostalCode: req.PostalCode,
+		})
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save address")
+			return
+		}
+
+		log.Info("address saved", slog.String("iin", iin), slog.String("type", addressType),
+			slog.String("region", region.Code))
+		render.JSON(w, r, AddressResponse{
+			Success: true,
+			Address: &address,
+		})
+	}
+}
+
+// List is a HTTP handler function for reading the addresses of a person.
+// The read is written to the access log.
+func List(log *slog.Logger, addressesGetter AddressesGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.addresses.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin := chi.URLParam(r, "iin")
+		if err := iin_validator.ValidateIIN(iin); err != nil {
+			handleError(w, r, log, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error()), "Invalid request")
+			return
+		}
+
+		addresses, err := addressesGetter.GetAddresses(r.Context(), iin)
+		if err != nil {
+			log.Error("failed to get addresses", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get addresses"},
+			})
+			return
+		}
+
+		get.RecordAccess(r, log, accessRecorder, storage.AccessAddresses, iin)
+
+		log.Info("addresses retrieved", slog.String("iin", iin), slog.Int("addresses", len(addresses)))
+		render.JSON(w, r, ListResponse{
+			Success:   true,
+			Addresses: addresses,
+		})
+	}
+}
+
+// Delete is a HTTP handler function for removing the address of a person of the type given by the type URL parameter.
+func Delete(log *slog.Logger, addressDeleter AddressDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.addresses.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, addressType, err := parseIINAndType(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		if err = addressDeleter.DeleteAddress(r.Context(), iin, addressType); err != nil {
+			handleError(w, r, log, err, "Failed to delete address")
+			return
+		}
+
+		log.Info("address deleted", slog.String("iin", iin), slog.String("type", addressType))
+		render.JSON(w, r, AddressResponse{
+			Success: true,
+		})
+	}
+}
+
+// Statistics is a HTTP handler function for counting the people by the region of their address of the type
+// given by the optional type query parameter, registered by default, only those with the status given
+// by the status query parameter if it is set. It returns every region of the reference table,
+// including those without anyone, and the number of people without such an address.
+func Statistics(log *slog.Logger, statisticsGetter StatisticsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.addresses.Statistics"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		addressType := r.URL.Query().Get("type")
+		if addressType == "" {
+			addressType = storage.AddressRegistered
+		}
+		if !slices.Contains(Types, addressType) {
+			handleError(w, r, log, errorUnknownType, "Invalid request")
+			return
+		}
+		status := r.URL.Query().Get("status")
+		if status != "" && !slices.Contains(storage.Statuses, status) {
+			handleError(w, r, log, fmt.Errorf("%w: %s", errorUnknownStatus, status), "Invalid request")
+			return
+		}
+
+		statistics, err := statisticsGetter.GetRegionStatistics(r.Context(), addressType, status)
+		if err != nil {
+			log.Error("failed to count people by region", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, StatisticsResponse{
+				Success: false,
+				Errors:  []string{"failed to count people by region"},
+			})
+			return
+		}
+
+		counts := make(map[string]int64, len(statistics.Regions))
+		for _, count := range statistics.Regions {
+			counts[count.Region] = count.People
+		}
+		regions := make([]RegionCount, 0, len(counts))
+		for _, region := range kato.Regions() {
+			regions = append(regions, RegionCount{Region: region.Code, Name: region.Name, People: counts[region.Code]})
+		}
+
+		log.Info("region statistics built", slog.String("type", addressType), slog.Int64("unknown", statistics.Unknown))
+		render.JSON(w, r, StatisticsResponse{
+			Success: true,
+			Type:    addressType,
+			Regions: regions,
+			Unknown: statistics.Unknown,
+		})
+	}
+}
+
+// parseIINAndType is a helper function to read and validate the iin and type URL parameters.
+func parseIINAndType(r *http.Request) (string, string, error) {
+	iin := chi.URLParam(r, "iin")
+	if err := iin_validator.ValidateIIN(iin); err != nil {
+		return iin, "", fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+
+	addressType := chi.URLParam(r, "type")
+	if !slices.Contains(Types, addressType) {
+		return iin, addressType, errorUnknownType
+	}
+	return iin, addressType, nil
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) ||
+		errors.Is(err, errorInvalidIIN) || errors.Is(err, errorUnknownType) || errors.Is(err, errorUnknownStatus) ||
+		errors.Is(err, kato.ErrorInvalidCode) || errors.Is(err, kato.ErrorUnknownRegion):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound) || errors.Is(err, storage.ErrorAddressNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, AddressResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
@@ -0,0 +1,224 @@
+// Package attribute_schemas provides HTTP handlers for registering the JSON Schemas
+// the extension attributes of people are validated against.
+package attribute_schemas
+
+import (
+	"citizen_webservice/internal/attributes"
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Request is the structure for the request body of the Register handler.
+type Request struct {
+	Schema json.RawMessage `json:"schema" validate:"required"` // JSON Schema the values of the namespace must be valid against
+}
+
+// SchemaSaver is an interface for registering schemas.
+type SchemaSaver interface {
+	SaveAttributeSchema(ctx context.Context, namespace string, schema []byte) (storage.AttributeSchema, error)
+}
+
+// SchemaGetter is an interface for reading the schema of a namespace.
+type SchemaGetter interface {
+	GetAttributeSchema(ctx context.Context, namespace string) (storage.AttributeSchema, error)
+}
+
+// SchemasGetter is an interface for listing the registered schemas.
+type SchemasGetter interface {
+	GetAttributeSchemas(ctx context.Context) ([]storage.AttributeSchema, error)
+}
+
+// SchemaDeleter is an interface for removing schemas.
+type SchemaDeleter interface {
+	DeleteAttributeSchema(ctx context.Context, namespace string) error
+}
+
+// SchemaResponse is the response structure for the Register, Get and Delete handlers.
+type SchemaResponse struct {
+	Success bool                     `json:"success"`
+	Errors  []string                 `json:"errors"`
+	Schema  *storage.AttributeSchema `json:"schema,omitempty"`
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success bool                      `json:"success"`
+	Errors  []string                  `json:"errors"`
+	Schemas []storage.AttributeSchema `json:"schemas"`
+}
+
+// Register is a HTTP handler function for registering the JSON Schema of the namespace given by the namespace
+// URL parameter, replacing its previous schema. It validates the namespace and compiles the schema before saving it,
+// and returns a JSON response with the saved schema. The values already stored in the namespace are not revalidated.
+func Register(log *slog.Logger, schemaSaver SchemaSaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attribute_schemas.Register"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		namespace := chi.URLParam(r, "namespace")
+		if err := attributes.ValidateNamespace(namespace); err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		var req Request
+		err := render.DecodeJSON(r.Body, &req)
+		if err == nil {
+			err = request_validator.GetValidator().Struct(req)
+		}
+		if err == nil {
+			_, err = attributes.Compile(req.Schema)
+		}
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		schema, err := schemaSaver.SaveAttributeSchema(r.Context(), namespace, req.Schema)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save schema")
+			return
+		}
+
+		log.Info("attribute schema registered", slog.String("namespace", namespace))
+		render.JSON(w, r, SchemaResponse{
+			Success: true,
+			Schema:  &schema,
+		})
+	}
+}
+
+// Get is a HTTP handler function for reading the JSON Schema of the namespace given by the namespace URL parameter.
+func Get(log *slog.Logger, schemaGetter SchemaGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attribute_schemas.Get"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		namespace := chi.URLParam(r, "namespace")
+		if err := attributes.ValidateNamespace(namespace); err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		schema, err := schemaGetter.GetAttributeSchema(r.Context(), namespace)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get schema")
+			return
+		}
+
+		log.Info("attribute schema retrieved", slog.String("namespace", namespace))
+		render.JSON(w, r, SchemaResponse{
+			Success: true,
+			Schema:  &schema,
+		})
+	}
+}
+
+// List is a HTTP handler function for reading every registered JSON Schema.
+func List(log *slog.Logger, schemasGetter SchemasGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attribute_schemas.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		schemas, err := schemasGetter.GetAttributeSchemas(r.Context())
+		if err != nil {
+			log.Error("failed to get schemas", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get schemas"},
+			})
+			return
+		}
+
+		log.Info("attribute schemas retrieved", slog.Int("schemas", len(schemas)))
+		render.JSON(w, r, ListResponse{
+			Success: true,
+			Schemas: schemas,
+		})
+	}
+}
+
+// Delete is a HTTP handler function for removing the JSON Schema of the namespace given by the namespace URL parameter.
+// A namespace someone still has a value in cannot be removed.
+func Delete(log *slog.Logger, schemaDeleter SchemaDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attribute_schemas.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		namespace := chi.URLParam(r, "namespace")
+		if err := attributes.ValidateNamespace(namespace); err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		if err := schemaDeleter.DeleteAttributeSchema(r.Context(), namespace); err != nil {
+			handleError(w, r, log, err, "Failed to delete schema")
+			return
+		}
+
+		log.Info("attribute schema deleted", slog.String("namespace", namespace))
+		render.JSON(w, r, SchemaResponse{
+			Success: true,
+		})
+	}
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) ||
+		errors.Is(err, attributes.ErrorInvalidNamespace) || errors.Is(err, attributes.ErrorInvalidSchema):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorSchemaNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorSchemaInUse):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, SchemaResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
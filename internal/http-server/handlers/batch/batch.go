@@ -0,0 +1,208 @@
+// Package batch provides HTTP handlers for executing several person writes atomically.
+package batch
+
+import (
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Statuses of a single operation in the response.
+const (
+	StatusOK         = "ok"          // The operation succeeded and was committed
+	StatusFailed     = "failed"      // The operation failed, which rolled back the whole batch
+	StatusRolledBack = "rolled_back" // The operation succeeded but was rolled back because a later one failed
+	StatusSkipped    = "skipped"     // The operation was not executed because an earlier one failed
+)
+
+// errorMinor is returned for a create of a minor, who is saved along with a guardian, which a batch cannot do.
+var errorMinor = errors.New("a minor must be saved with a guardian through POST /people/info")
+
+// Options struct holds the age from which people may be created in a batch.
+type Options struct {
+	AdultAge int
+}
+
+// Operation is a single write in the request body of the Execute handler.
+type Operation struct {
+	Op      string `json:"op" validate:"required,oneof=create update delete"` // Kind of the operation
+	IIN     string `json:"iin" validate:"required,len=12,iin"`                // Individual Identification Number
+	Name    string `json:"name" validate:"required_unless=Op delete"`         // Name of the person, for creates and updates
+	Phone   string `json:"phone" validate:"required_unless=Op delete"`        // Phone number of the person, for creates and updates
+	Version int64  `json:"version" validate:"min=0,excluded_if=Op create"`    // Expected version for updates and deletes, 0 matches any
+}
+
+// Request is the structure for the request body of the Execute handler.
+type Request struct {
+	Operations []Operation `json:"operations" validate:"required,min=1,max=100,dive"` // Operations, executed in order, at most 100
+}
+
+// OperationResult is the outcome of a single operation in the response.
+type OperationResult struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op"`
+	IIN     string `json:"iin"`
+	Status  string `json:"status"`
+	Version int64  `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Response is the response structure for the Execute handler.
+type Response struct {
+	Success bool              `json:"success"`
+	Errors  []string          `json:"errors"`
+	Results []OperationResult `json:"results,omitempty"`
+}
+
+// BatchExecutor is an interface for executing several writes in one transaction.
+type BatchExecutor interface {
+	ExecuteBatch(ctx context.Context, operations []storage.BatchOperation) ([]storage.BatchResult, error)
+}
+
+// Execute is a HTTP handler function for executing a batch of create, update and delete operations.
+// It decodes and validates the request body, executes all operations in one transaction,
+// and returns a JSON response with the result of every operation.
+// If any operation fails, nothing is written. People younger than the adult age, derived from their IIN,
+// are not created, as they must be saved along with their guardian.
+func Execute(log *slog.Logger, batchExecutor BatchExecutor, opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.batch.Execute"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to decode request body", nil)
+			return
+		}
+
+		log.Info("request body decoded", slog.Int("operations", len(req.Operations)))
+		if err := request_validator.GetValidator().Struct(req); err != nil {
+			handleError(w, r, log, err, "Validation failed", nil)
+			return
+		}
+		if err := checkMinors(req.Operations, opts.AdultAge, time.Now()); err != nil {
+			handleError(w, r, log, err, "Validation failed", nil)
+			return
+		}
+
+		operations := make([]storage.BatchOperation, 0, len(req.Operations))
+		for _, operation := range req.Operations {
+			operations = append(operations, storage.BatchOperation{
+				Op:              operation.Op,
+				IIN:             operation.IIN,
+				Name:            operation.Name,
+				Phone:           operation.Phone,
+				ExpectedVersion: operation.Version,
+			})
+		}
+
+		results, err := batchExecutor.ExecuteBatch(r.Context(), operations)
+		if err != nil {
+			handleError(w, r, log, err, "Batch rolled back", buildResults(req.Operations, results, false))
+			return
+		}
+
+		log.Info("batch committed", slog.Int("operations", len(results)))
+		render.JSON(w, r, Response{
+			Success: true,
+			Results: buildResults(req.Operations, results, true),
+		})
+	}
+}
+
+// checkMinors is a helper function to refuse the creates of people younger than the adult age.
+func checkMinors(operations []Operation, adultAge int, now time.Time) error {
+	for i, operation := range operations {
+		if operation.Op != storage.OperationCreate {
+			continue
+		}
+		age, err := iin_validator.GetAge(operation.IIN, now)
+		if err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+		if age < adultAge {
+			return fmt.Errorf("operation %d: %w: aged %d, adult at %d", i, errorMinor, age, adultAge)
+		}
+	}
+	return nil
+}
+
+// buildResults describes the outcome of every requested operation.
+// Operations without a storage result were skipped, successful ones were rolled back unless committed.
+func buildResults(operations []Operation, results []storage.BatchResult, committed bool) []OperationResult {
+	response := make([]OperationResult, 0, len(operations))
+	for i, operation := range operations {
+		result := OperationResult{
+			Index:  i,
+			Op:     operation.Op,
+			IIN:    operation.IIN,
+			Status: StatusSkipped,
+		}
+		if i < len(results) {
+			switch {
+			case results[i].Err != nil:
+				result.Status = StatusFailed
+				result.Error = results[i].Err.Error()
+			case committed:
+				result.Status = StatusOK
+				result.Version = results[i].Version
+			default:
+				result.Status = StatusRolledBack
+			}
+		}
+		response = append(response, result)
+	}
+	return response
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message and the per-operation results, if any.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string, results []OperationResult) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) || errors.Is(err, errorMinor):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, storage.ErrorIINExists) || errors.Is(err, storage.ErrorPhoneNumberExists):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorVersionMismatch):
+		status = http.StatusPreconditionFailed
+	}
+	render.Status(r, status)
+	render.JSON(w, r, Response{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+		Results: results,
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
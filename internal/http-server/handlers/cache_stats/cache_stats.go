@@ -0,0 +1,44 @@
+// Package cache_stats provides HTTP handlers for reading the cache metrics.
+package cache_stats
+
+import (
+	"citizen_webservice/internal/storage/cache"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Response is the response structure for the Execute handler.
+type Response struct {
+	Success bool        `json:"success"`
+	Errors  []string    `json:"errors"`
+	Stats   cache.Stats `json:"stats"`
+}
+
+// StatsGetter is an interface for getting the cache metrics.
+type StatsGetter interface {
+	Stats() cache.Stats
+}
+
+// Execute is a HTTP handler function for reading the cache metrics.
+// It returns the hit, miss, eviction and invalidation counters as a JSON response.
+func Execute(log *slog.Logger, statsGetter StatsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.cache_stats.Execute"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		stats := statsGetter.Stats()
+
+		log.Debug("cache stats retrieved", slog.Any("stats", stats))
+		render.JSON(w, r, Response{
+			Success: true,
+			Stats:   stats,
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Package consents provides HTTP handlers for granting, revoking and listing the consents of people.
+package consents
+
+import (
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var errorInvalidIIN = errors.New("invalid IIN")
+
+// Request is the structure for the request body of the Grant and Revoke handlers.
+type Request struct {
+	Purpose string `json:"purpose" validate:"required,max=64,printascii"` // Purpose the phone may be shared for
+	Source  string `json:"source" validate:"required,max=255"`            // Where the consent was given or withdrawn
+}
+
+// ConsentGranter is an interface for granting consents.
+type ConsentGranter interface {
+	GrantConsent(ctx context.Context, iin string, purpose string, source string) (storage.Consent, error)
+}
+
+// ConsentRevoker is an interface for revoking consents.
+type ConsentRevoker interface {
+	RevokeConsent(ctx context.Context, iin string, purpose string, source string) (storage.Consent, error)
+}
+
+// ConsentsGetter is an interface for reading the consents of a person.
+type ConsentsGetter interface {
+	GetConsents(ctx context.Context, iin string) ([]storage.Consent, error)
+}
+
+// ConsentResponse is the response structure for the Grant and Revoke handlers.
+type ConsentResponse struct {
+	Success bool             `json:"success"`
+	Errors  []string         `json:"errors"`
+	Consent *storage.Consent `json:"consent,omitempty"`
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success  bool              `json:"success"`
+	Errors   []string          `json:"errors"`
+	Consents []storage.Consent `json:"consents"`
+}
+
+// Grant is a HTTP handler function for granting the consent of a person to a purpose.
+// It validates the IIN and the request body, records the consent,
+// and returns a JSON response with the recorded consent.
+func Grant(log *slog.Logger, consentGranter ConsentGranter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.consents.Grant"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, req, err := decode(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		consent, err := consentGranter.GrantConsent(r.Context(), iin, req.Purpose, req.Source)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to grant consent")
+			return
+		}
+
+		log.Info("consent granted", slog.String("iin", iin), slog.String("purpose", req.Purpose))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, ConsentResponse{
+			Success: true,
+			Consent: &consent,
+		})
+	}
+}
+
+// Revoke is a HTTP handler function for revoking the consent of a person to a purpose.
+// It validates the IIN and the request body, records the revocation,
+// and returns a JSON response with the recorded revocation.
+func Revoke(log *slog.Logger, consentRevoker ConsentRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.consents.Revoke"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, req, err := decode(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		consent, err := consentRevoker.RevokeConsent(r.Context(), iin, req.Purpose, req.Source)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to revoke consent")
+			return
+		}
+
+		log.Info("consent revoked", slog.String("iin", iin), slog.String("purpose", req.Purpose))
+		render.JSON(w, r, ConsentResponse{
+			Success: true,
+			Consent: &consent,
+		})
+	}
+}
+
+// List is a HTTP handler function for reading the consents of a person.
+// It returns the current consent for every purpose, revoked ones included, as a JSON response.
+func List(log *slog.Logger, consentsGetter ConsentsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.consents.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin := chi.URLParam(r, "iin")
+		if err := iin_validator.ValidateIIN(iin); err != nil {
+			handleError(w, r, log, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error()), "Invalid request")
+			return
+		}
+
+		consents, err := consentsGetter.GetConsents(r.Context(), iin)
+		if err != nil {
+			log.Error("failed to get consents", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get consents"},
+			})
+			return
+		}
+
+		log.Info("consents retrieved", slog.String("iin", iin), slog.Int("consents", len(consents)))
+		render.JSON(w, r, ListResponse{
+			Success:  true,
+			Consents: consents,
+		})
+	}
+}
+
+// decode is a helper function to read the IIN from the URL and to decode and validate the request body.
+func decode(r *http.Request) (string, Request, error) {
+	var req Request
+
+	iin := chi.URLParam(r, "iin")
+	if err := iin_validator.ValidateIIN(iin); err != nil {
+		return iin, req, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		return iin, req, err
+	}
+
+	return iin, req, request_validator.GetValidator().Struct(req)
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) || errors.Is(err, errorInvalidIIN):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound) || errors.Is(err, storage.ErrorConsentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, ConsentResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
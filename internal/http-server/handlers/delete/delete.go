@@ -2,7 +2,9 @@
 package delete
 
 import (
+	"citizen_webservice/internal/http-server/handlers/etag"
 	resp "citizen_webservice/internal/http-server/handlers/response"
+	"context"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -15,12 +17,15 @@ import (
 
 // PersonDeleter is an interface for deleting person information.
 type PersonDeleter interface {
-	DeletePersonByIIN(iin string) error
+	DeletePersonByIIN(ctx context.Context, iin string, expectedVersions ...int64) error
 }
 
 // ByIIN is an HTTP handler function for deleting a person by their IIN.
 // It retrieves the IIN from the URL parameter, deletes the person from the storage,
 // and returns a JSON response.
+// If the If-Match header is set, the person is only deleted while its version matches one of its entity tags,
+// or while it exists if the header is *, otherwise it responds with 412 Precondition Failed. A person under legal hold is not deleted,
+// the response being 423 Locked.
 func ByIIN(log *slog.Logger, personDeleter PersonDeleter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.delete.ByIIN"
@@ -38,13 +43,39 @@ func ByIIN(log *slog.Logger, personDeleter PersonDeleter) http.HandlerFunc {
 			return
 		}
 
-		err := personDeleter.DeletePersonByIIN(iin)
+		expectedVersions, err := etag.ExpectedVersions(r)
+		if err != nil {
+			log.Info("invalid If-Match header", Err(err))
+			render.Status(r, http.StatusPreconditionFailed)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+
+		err = personDeleter.DeletePersonByIIN(r.Context(), iin, expectedVersions...)
 		if errors.Is(err, storage.ErrorIINNotFound) {
 			log.Info("iin not found", slog.String("iin", iin))
 			render.Status(r, http.StatusNotFound)
 			render.JSON(w, r, resp.Error("iin not found"))
 			return
 		}
+		if errors.Is(err, storage.ErrorVersionMismatch) {
+			log.Info("version mismatch", slog.String("iin", iin), slog.Any("expected_versions", expectedVersions))
+			render.Status(r, http.StatusPreconditionFailed)
+			render.JSON(w, r, resp.Error("person was modified or deleted, version mismatch"))
+			return
+		}
+		if errors.Is(err, storage.ErrorLegalHold) {
+			log.Info("person is under legal hold", slog.String("iin", iin))
+			render.Status(r, http.StatusLocked)
+			render.JSON(w, r, resp.Error("person is under legal hold"))
+			return
+		}
+		if errors.Is(err, storage.ErrorWriteQueueFull) {
+			log.Error("write queue is full", Err(err))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, resp.Error("too many concurrent writes, try again later"))
+			return
+		}
 		if err != nil {
 			log.Error("failed to delete person", Err(err))
 			render.Status(r, http.StatusInternalServerError)
@@ -0,0 +1,422 @@
+// Package documents provides HTTP handlers for managing the identity documents of people.
+package documents
+
+import (
+	"citizen_webservice/internal/http-server/handlers/get"
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// DateFormat is the format of the issue and expiry dates of a document.
+const DateFormat = "2006-01-02"
+
+// DefaultDays and MaxDays bound the period the Expiring handler looks ahead.
+const (
+	DefaultDays = 30
+	MaxDays     = 3650
+)
+
+var (
+	errorInvalidIIN       = errors.New("invalid IIN")
+	errorInvalidID        = errors.New("id must be a positive integer")
+	errorInvalidDays      = fmt.Errorf("days must be an integer between 0 and %d", MaxDays)
+	errorExpiryNotAfter   = errors.New("expiry date must follow the issue date")
+	errorIssueBeforeBirth = errors.New("issue date must follow the date of birth")
+)
+
+// Request is the structure for the request body of the Create and Update handlers.
+type Request struct {
+	Type             string `json:"type" validate:"required,oneof=id_card passport"`
+	Number           string `json:"number" validate:"required,max=32,alphanum"`
+	IssuingAuthority string `json:"issuing_authority" validate:"required,max=255"`
+	IssueDate        string `json:"issue_date" validate:"required,datetime=2006-01-02"`  // After the date of birth derived from the IIN
+	ExpiryDate       string `json:"expiry_date" validate:"required,datetime=2006-01-02"` // After the issue date
+}
+
+// DocumentSaver is an interface for saving documents.
+type DocumentSaver interface {
+	SaveDocument(ctx context.Context, document storage.Document) (storage.Document, error)
+}
+
+// DocumentGetter is an interface for reading a document.
+type DocumentGetter interface {
+	GetDocument(ctx context.Context, iin string, id int64) (storage.Document, error)
+}
+
+// DocumentsGetter is an interface for listing the documents of a person.
+type DocumentsGetter interface {
+	GetDocuments(ctx context.Context, iin string) ([]storage.Document, error)
+}
+
+// ExpiringDocumentsGetter is an interface for listing the documents expiring within a period.
+type ExpiringDocumentsGetter interface {
+	GetExpiringDocuments(ctx context.Context, from string, to string) ([]storage.Document, error)
+}
+
+// AccessRecorder is an interface for writing the access log.
+type AccessRecorder interface {
+	RecordAccess(ctx context.Context, entries []storage.AccessEntry) error
+}
+
+// DocumentUpdater is an interface for updating documents.
+type DocumentUpdater interface {
+	UpdateDocument(ctx context.Context, document storage.Document) (storage.Document, error)
+}
+
+// DocumentDeleter is an interface for deleting documents.
+type DocumentDeleter interface {
+	DeleteDocument(ctx context.Context, iin string, id int64) error
+}
+
+// DocumentResponse is the response structure for the Create, Get, Update and Delete handlers.
+type DocumentResponse struct {
+	Success  bool              `json:"success"`
+	Errors   []string          `json:"errors"`
+	Document *storage.Document `json:"document,omitempty"`
+}
+
+// ListResponse is the response structure for the List and Expiring handlers.
+type ListResponse struct {
+	Success   bool               `json:"success"`
+	Errors    []string           `json:"errors"`
+	Documents []storage.Document `json:"documents"`
+}
+
+// Create is a HTTP handler function for saving an identity document of a person.
+// It validates the IIN and the request body, saves the document,
+// and returns a JSON response with the saved document.
+func Create(log *slog.Logger, documentSaver DocumentSaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.documents.Create"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := parseIIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		req, err := decode(r, iin)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		document, err := documentSaver.SaveDocument(r.Context(), newDocument(iin, 0, req))
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save document")
+			return
+		}
+
+		log.Info("document saved", slog.String("iin", iin), slog.Int64("id", document.ID))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, DocumentResponse{
+			Success:  true,
+			Document: &document,
+		})
+	}
+}
+
+// List is a HTTP handler function for reading every document of a person.
+// The read is written to the access log.
+func List(log *slog.Logger, documentsGetter DocumentsGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.documents.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := parseIIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		documents, err := documentsGetter.GetDocuments(r.Context(), iin)
+		if err != nil {
+			log.Error("failed to get documents", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get documents"},
+			})
+			return
+		}
+
+		get.RecordAccess(r, log, accessRecorder, storage.AccessDocuments, iin)
+
+		log.Info("documents retrieved", slog.String("iin", iin), slog.Int("documents", len(documents)))
+		render.JSON(w, r, ListResponse{
+			Success:   true,
+			Documents: documents,
+		})
+	}
+}
+
+// Get is a HTTP handler function for reading the document of a person identified by the id URL parameter.
+// The read is written to the access log.
+func Get(log *slog.Logger, documentGetter DocumentGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.documents.Get"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, id, err := parseIINAndID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		document, err := documentGetter.GetDocument(r.Context(), iin, id)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get document")
+			return
+		}
+
+		get.RecordAccess(r, log, accessRecorder, storage.AccessDocuments, iin)
+
+		log.Info("document retrieved", slog.String("iin", iin), slog.Int64("id", id))
+		render.JSON(w, r, DocumentResponse{
+			Success:  true,
+			Document: &document,
+		})
+	}
+}
+
+// Update is a HTTP handler function for replacing the document of a person identified by the id URL parameter.
+// The request body is validated like the one of the Create handler.
+func Update(log *slog.Logger, documentUpdater DocumentUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.documents.Update"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, id, err := parseIINAndID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		req, err := decode(r, iin)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		document, err := documentUpdater.UpdateDocument(r.Context(), newDocument(iin, id, req))
+		if err != nil {
+			handleError(w, r, log, err, "Failed to update document")
+			return
+		}
+
+		log.Info("document updated", slog.String("iin", iin), slog.Int64("id", id))
+		render.JSON(w, r, DocumentResponse{
+			Success:  true,
+			Document: &document,
+		})
+	}
+}
+
+// Delete is a HTTP handler function for deleting the document of a person identified by the id URL parameter.
+func Delete(log *slog.Logger, documentDeleter DocumentDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.documents.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, id, err := parseIINAndID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		if err = documentDeleter.DeleteDocument(r.Context(), iin, id); err != nil {
+			handleError(w, r, log, err, "Failed to delete document")
+			return
+		}
+
+		log.Info("document deleted", slog.String("iin", iin), slog.Int64("id", id))
+		render.JSON(w, r, DocumentResponse{
+			Success: true,
+		})
+	}
+}
+
+// Expiring is a HTTP handler function for listing the documents expiring within the number of days
+// given by the optional days query parameter, counted from the current day, which is included.
+// Documents that have already expired are not listed. The read is written to the access log of every listed person.
+func Expiring(log *slog.Logger, expiringGetter ExpiringDocumentsGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.documents.Expiring"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		days := DefaultDays
+		if raw := r.URL.Query().Get("days"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 0 || parsed > MaxDays {
+				log.Info("invalid days", slog.String("days", raw))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, ListResponse{
+					Success: false,
+					Errors:  []string{errorInvalidDays.Error()},
+				})
+				return
+			}
+			days = parsed
+		}
+
+		today := time.Now()
+		documents, err := expiringGetter.GetExpiringDocuments(r.Context(),
+			today.Format(DateFormat), today.AddDate(0, 0, days).Format(DateFormat))
+		if err != nil {
+			log.Error("failed to get expiring documents", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get expiring documents"},
+			})
+			return
+		}
+
+		iins := make([]string, 0, len(documents))
+		for _, document := range documents {
+			iins = append(iins, document.IIN)
+		}
+		get.RecordAccess(r, log, accessRecorder, storage.AccessDocuments, iins...)
+
+		log.Info("expiring documents retrieved", slog.Int("days", days), slog.Int("documents", len(documents)))
+		render.JSON(w, r, ListResponse{
+			Success:   true,
+			Documents: documents,
+		})
+	}
+}
+
+// newDocument is a helper function to build the document described by a request.
+func newDocument(iin string, id int64, req Request) storage.Document {
+	return storage.Document{
+		ID:               id,
+		IIN:              iin,
+		Type:             req.Type,
+		Number:           req.Number,
+		IssuingAuthority: req.IssuingAuthority,
+		IssueDate:        req.IssueDate,
+		ExpiryDate:       req.ExpiryDate,
+	}
+}
+
+// parseIIN is a helper function to read and validate the iin URL parameter.
+func parseIIN(r *http.Request) (string, error) {
+	iin := chi.URLParam(r, "iin")
+	if err := iin_validator.ValidateIIN(iin); err != nil {
+		return iin, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+	return iin, nil
+}
+
+// parseIINAndID is a helper function to read the iin and id URL parameters.
+func parseIINAndID(r *http.Request) (string, int64, error) {
+	iin, err := parseIIN(r)
+	if err != nil {
+		return iin, 0, err
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		return iin, 0, errorInvalidID
+	}
+	return iin, id, nil
+}
+
+// decode is a helper function to decode and validate the request body.
+// The issue date must follow the date of birth derived from the IIN, and the expiry date the issue date.
+func decode(r *http.Request, iin string) (Request, error) {
+	var req Request
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		return req, err
+	}
+	if err := request_validator.GetValidator().Struct(req); err != nil {
+		return req, err
+	}
+
+	if req.ExpiryDate <= req.IssueDate {
+		return req, fmt.Errorf("%w: %s to %s", errorExpiryNotAfter, req.IssueDate, req.ExpiryDate)
+	}
+	dateOfBirth, err := iin_validator.GetDateOfBirth(iin)
+	if err != nil {
+		return req, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+	if req.IssueDate <= dateOfBirth.Format(DateFormat) {
+		return req, fmt.Errorf("%w: %s", errorIssueBeforeBirth, dateOfBirth.Format(DateFormat))
+	}
+
+	return req, nil
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) ||
+		errors.Is(err, errorInvalidIIN) || errors.Is(err, errorInvalidID) ||
+		errors.Is(err, errorExpiryNotAfter) || errors.Is(err, errorIssueBeforeBirth):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound) || errors.Is(err, storage.ErrorDocumentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorDocumentExists):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, DocumentResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
@@ -0,0 +1,155 @@
+// Package duplicates provides HTTP handlers for reporting possible duplicate person records.
+package duplicates
+
+import (
+	"citizen_webservice/internal/duplicates"
+	"citizen_webservice/internal/http-server/handlers/get"
+	"citizen_webservice/internal/storage"
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// DefaultLimit and MaxLimit bound the number of people whose candidate pairs are scored by a single request,
+// and MaxPairs the number of pairs.
+const (
+	DefaultLimit = 1000
+	MaxLimit     = 10000
+	MaxPairs     = 50000
+)
+
+// ReportResponse is the response structure for the Report handler.
+type ReportResponse struct {
+	Success    bool                   `json:"success"`
+	Errors     []string               `json:"errors"`
+	MinScore   float64                `json:"min_score"`
+	Candidates []duplicates.Candidate `json:"candidates"`
+	Next       string                 `json:"next,omitempty"` // Value of after for the next page, empty after the last one
+}
+
+// CandidatePairsGetter is an interface for listing the pairs of people who may describe the same citizen.
+type CandidatePairsGetter interface {
+	GetCandidatePairs(ctx context.Context, after string, limit int, maxPairs int) ([]storage.PersonPair, string, error)
+}
+
+// Report is a HTTP handler function for the duplicate candidate report.
+// It reads the optional min_score, after and limit query parameters, scores the candidate pairs of a page
+// of at most limit people with an IIN greater than after, and returns the pairs reaching the threshold,
+// best matches first, as a JSON response. Only the people born on the same day or whose names start
+// with the same letters are paired, the storage narrowing them down. A page scores at most MaxPairs pairs
+// and ends early once it reaches them. The phones are omitted unless they may be shared, as by get.ByIIN.
+// Every reported person is written to the access log.
+func Report(log *slog.Logger, pairsGetter CandidatePairsGetter, consentChecker get.ConsentChecker, accessRecorder get.AccessRecorder, opts get.Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.duplicates.Report"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		minScore := duplicates.DefaultMinScore
+		if raw := r.URL.Query().Get("min_score"); raw != "" {
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				log.Info("invalid min_score", slog.String("min_score", raw))
+				badRequest(w, r, "min_score must be a number between 0 and 1")
+				return
+			}
+			minScore = parsed
+		}
+
+		limit := DefaultLimit
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > MaxLimit {
+				log.Info("invalid limit", slog.String("limit", raw))
+				badRequest(w, r, "limit must be an integer between 1 and "+strconv.Itoa(MaxLimit))
+				return
+			}
+			limit = parsed
+		}
+
+		after := r.URL.Query().Get("after")
+		pairs, next, err := pairsGetter.GetCandidatePairs(r.Context(), after, limit, MaxPairs)
+		if err != nil {
+			log.Error("failed to get candidate pairs", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ReportResponse{
+				Success: false,
+				Errors:  []string{"failed to get candidate pairs"},
+			})
+			return
+		}
+
+		candidates := duplicates.ScorePairs(pairs, minScore)
+		if err = redactPhones(r, consentChecker, opts, candidates); err != nil {
+			log.Error("failed to check consent", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ReportResponse{
+				Success: false,
+				Errors:  []string{"failed to check consent"},
+			})
+			return
+		}
+
+		iins := make([]string, 0, 2*len(candidates))
+		for _, candidate := range candidates {
+			iins = append(iins, candidate.First.IIN, candidate.Second.IIN)
+		}
+		get.RecordAccess(r, log, accessRecorder, storage.AccessDuplicates, iins...)
+
+		log.Info("duplicate report built", slog.String("after", after), slog.Int("pairs", len(pairs)),
+			slog.Int("candidates", len(candidates)))
+		w.Header().Add("Vary", get.HeaderPurpose)
+		render.JSON(w, r, ReportResponse{
+			Success:    true,
+			MinScore:   minScore,
+			Candidates: candidates,
+			Next:       next,
+		})
+	}
+}
+
+// redactPhones is a helper function to omit the phones of the candidates that may not be shared with the client,
+// checking every person once.
+func redactPhones(r *http.Request, consentChecker get.ConsentChecker, opts get.Options, candidates []duplicates.Candidate) error {
+	shared := make(map[string]bool)
+	for i := range candidates {
+		for _, person := range []*storage.PersonInfo{&candidates[i].First, &candidates[i].Second} {
+			ok, checked := shared[person.IIN]
+			if !checked {
+				var err error
+				if ok, err = get.PhoneShared(r, consentChecker, opts, person.IIN); err != nil {
+					return err
+				}
+				shared[person.IIN] = ok
+			}
+			if !ok {
+				person.Phone = ""
+			}
+		}
+	}
+	return nil
+}
+
+// badRequest is a helper function to respond to an invalid query parameter.
+func badRequest(w http.ResponseWriter, r *http.Request, message string) {
+	render.Status(r, http.StatusBadRequest)
+	render.JSON(w, r, ReportResponse{
+		Success: false,
+		Errors:  []string{message},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
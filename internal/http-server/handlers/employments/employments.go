@@ -0,0 +1,323 @@
+// Package employments provides HTTP handlers for managing the periods people work at organizations.
+package employments
+
+import (
+	"citizen_webservice/internal/http-server/handlers/get"
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// DateFormat is the format of the start and end dates of an employment.
+const DateFormat = "2006-01-02"
+
+var (
+	errorInvalidIIN       = errors.New("invalid IIN")
+	errorInvalidID        = errors.New("id must be a positive integer")
+	errorEndBeforeStart   = errors.New("end date must not precede the start date")
+	errorStartBeforeBirth = errors.New("start date must follow the date of birth")
+)
+
+// Request is the structure for the request body of the Update handler.
+type Request struct {
+	Position  string `json:"position" validate:"max=255"`
+	StartDate string `json:"start_date" validate:"required,datetime=2006-01-02"` // After the date of birth derived from the IIN
+	EndDate   string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`  // Not before the start date, empty while still employed
+}
+
+// CreateRequest is the structure for the request body of the Create handler.
+// The organization of an employment cannot be changed afterwards.
+type CreateRequest struct {
+	BIN string `json:"bin" validate:"required,bin"`
+	Request
+}
+
+// EmploymentSaver is an interface for saving employments.
+type EmploymentSaver interface {
+	SaveEmployment(ctx context.Context, employment storage.Employment) (storage.Employment, error)
+}
+
+// EmploymentsGetter is an interface for listing the employments of a person.
+type EmploymentsGetter interface {
+	GetEmployments(ctx context.Context, iin string) ([]storage.Employment, error)
+}
+
+// EmploymentUpdater is an interface for updating employments.
+type EmploymentUpdater interface {
+	UpdateEmployment(ctx context.Context, employment storage.Employment) (storage.Employment, error)
+}
+
+// EmploymentDeleter is an interface for deleting employments.
+type EmploymentDeleter interface {
+	DeleteEmployment(ctx context.Context, iin string, id int64) error
+}
+
+// AccessRecorder is an interface for writing the access log.
+type AccessRecorder interface {
+	RecordAccess(ctx context.Context, entries []storage.AccessEntry) error
+}
+
+// EmploymentResponse is the response structure for the Create, Update and Delete handlers.
+type EmploymentResponse struct {
+	Success    bool                `json:"success"`
+	Errors     []string            `json:"errors"`
+	Employment *storage.Employment `json:"employment,omitempty"`
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success     bool                 `json:"success"`
+	Errors      []string             `json:"errors"`
+	Employments []storage.Employment `json:"employments"`
+}
+
+// Create is a HTTP handler function for saving a period a person works at an organization.
+// It validates the IIN and the request body, the BIN included, saves the employment,
+// and returns a JSON response with the saved employment.
+func Create(log *slog.Logger, employmentSaver EmploymentSaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.employments.Create"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := parseIIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		var req CreateRequest
+		if err = decode(r, iin, &req, &req.Request); err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		employment := newEmployment(iin, 0, req.Request)
+		employment.BIN = req.BIN
+		employment, err = employmentSaver.SaveEmployment(r.Context(), employment)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save employment")
+			return
+		}
+
+		log.Info("employment saved", slog.String("iin", iin), slog.String("bin", employment.BIN),
+			slog.Int64("id", employment.ID))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, EmploymentResponse{
+			Success:    true,
+			Employment: &employment,
+		})
+	}
+}
+
+// List is a HTTP handler function for reading every employment of a person, earliest first.
+// The read is written to the access log.
+func List(log *slog.Logger, employmentsGetter EmploymentsGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.employments.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := parseIIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		employments, err := employmentsGetter.GetEmployments(r.Context(), iin)
+		if err != nil {
+			log.Error("failed to get employments", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get employments"},
+			})
+			return
+		}
+
+		get.RecordAccess(r, log, accessRecorder, storage.AccessEmployments, iin)
+
+		log.Info("employments retrieved", slog.String("iin", iin), slog.Int("employments", len(employments)))
+		render.JSON(w, r, ListResponse{
+			Success:     true,
+			Employments: employments,
+		})
+	}
+}
+
+// Update is a HTTP handler function for replacing the position and dates of the employment of a person
+// identified by the id URL parameter, for instance to end it. The organization stays the same.
+func Update(log *slog.Logger, employmentUpdater EmploymentUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.employments.Update"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, id, err := parseIINAndID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		var req Request
+		if err = decode(r, iin, &req, &req); err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		employment, err := employmentUpdater.UpdateEmployment(r.Context(), newEmployment(iin, id, req))
+		if err != nil {
+			handleError(w, r, log, err, "Failed to update employment")
+			return
+		}
+
+		log.Info("employment updated", slog.String("iin", iin), slog.Int64("id", id))
+		render.JSON(w, r, EmploymentResponse{
+			Success:    true,
+			Employment: &employment,
+		})
+	}
+}
+
+// Delete is a HTTP handler function for deleting the employment of a person identified by the id URL parameter.
+func Delete(log *slog.Logger, employmentDeleter EmploymentDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.employments.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, id, err := parseIINAndID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		if err = employmentDeleter.DeleteEmployment(r.Context(), iin, id); err != nil {
+			handleError(w, r, log, err, "Failed to delete employment")
+			return
+		}
+
+		log.Info("employment deleted", slog.String("iin", iin), slog.Int64("id", id))
+		render.JSON(w, r, EmploymentResponse{
+			Success: true,
+		})
+	}
+}
+
+// newEmployment is a helper function to build the employment described by a request.
+func newEmployment(iin string, id int64, req Request) storage.Employment {
+	return storage.Employment{
+		ID:        id,
+		IIN:       iin,
+		Position:  req.Position,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+	}
+}
+
+// parseIIN is a helper function to read and validate the iin URL parameter.
+func parseIIN(r *http.Request) (string, error) {
+	iin := chi.URLParam(r, "iin")
+	if err := iin_validator.ValidateIIN(iin); err != nil {
+		return iin, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+	return iin, nil
+}
+
+// parseIINAndID is a helper function to read the iin and id URL parameters.
+func parseIINAndID(r *http.Request) (string, int64, error) {
+	iin, err := parseIIN(r)
+	if err != nil {
+		return iin, 0, err
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		return iin, 0, errorInvalidID
+	}
+	return iin, id, nil
+}
+
+// decode is a helper function to decode the request body into body and validate it.
+// The dates are read from req, which is body itself or embedded in it: the start date must follow
+// the date of birth derived from the IIN, and the end date, if any, must not precede the start date.
+func decode(r *http.Request, iin string, body any, req *Request) error {
+	if err := render.DecodeJSON(r.Body, body); err != nil {
+		return err
+	}
+	if err := request_validator.GetValidator().Struct(body); err != nil {
+		return err
+	}
+
+	if req.EndDate != "" && req.EndDate < req.StartDate {
+		return fmt.Errorf("%w: %s to %s", errorEndBeforeStart, req.StartDate, req.EndDate)
+	}
+	dateOfBirth, err := iin_validator.GetDateOfBirth(iin)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+	if req.StartDate <= dateOfBirth.Format(DateFormat) {
+		return fmt.Errorf("%w: %s", errorStartBeforeBirth, dateOfBirth.Format(DateFormat))
+	}
+
+	return nil
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) ||
+		errors.Is(err, errorInvalidIIN) || errors.Is(err, errorInvalidID) ||
+		errors.Is(err, errorEndBeforeStart) || errors.Is(err, errorStartBeforeBirth):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound) || errors.Is(err, storage.ErrorOrganizationNotFound) ||
+		errors.Is(err, storage.ErrorEmploymentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorEmploymentOverlaps):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, EmploymentResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
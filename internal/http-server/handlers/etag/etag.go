@@ -0,0 +1,93 @@
+// Package etag provides helpers for entity tags and conditional requests.
+package etag
+
+import (
+	"citizen_webservice/internal/storage"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrorInvalidIfMatch is returned when the If-Match header cannot be mapped to versions.
+var ErrorInvalidIfMatch = errors.New("invalid If-Match header, expected * or a list of entity tags")
+
+// redactedSuffix marks the entity tag of a representation the phone is omitted from.
+const redactedSuffix = "-redacted"
+
+// Format returns the entity tag of a record version, e.g. "3".
+func Format(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// FormatRepresentation returns the entity tag of the representation of a record version,
+// e.g. "3" if it is complete and "3-redacted" if the phone is omitted from it,
+// so that the two representations of the same version never share a strong entity tag.
+// Both hold the version and match it in If-Match.
+func FormatRepresentation(version int64, redacted bool) string {
+	if !redacted {
+		return Format(version)
+	}
+	return strconv.Quote(strconv.FormatInt(version, 10) + redactedSuffix)
+}
+
+// ExpectedVersions reads the If-Match header of the request.
+// It returns no versions if the header is absent, meaning that there is no precondition,
+// storage.AnyVersion if it is set to *, meaning that any current version matches but a missing record does not,
+// the versions of the listed strong entity tags, any of which matches, weak ones never matching,
+// and ErrorInvalidIfMatch if the header does not list any strong entity tag of a version.
+func ExpectedVersions(r *http.Request) ([]int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return nil, nil
+	}
+	if header == "*" {
+		return []int64{storage.AnyVersion}, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		// Weak entity tags never match in a strong comparison
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		unquoted, err := strconv.Unquote(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrorInvalidIfMatch, header)
+		}
+		version, err := strconv.ParseInt(strings.TrimSuffix(unquoted, redactedSuffix), 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrorInvalidIfMatch, header)
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidIfMatch, header)
+	}
+
+	return versions, nil
+}
+
+// NoneMatch reports whether the If-None-Match header of the request matches the given entity tag,
+// meaning that the client already holds the current representation.
+// Entity tags are compared weakly, as required for If-None-Match.
+func NoneMatch(r *http.Request, current string) bool {
+	header := strings.TrimSpace(r.Header.Get("If-None-Match"))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == current {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package etag
+
+import (
+	"citizen_webservice/internal/storage"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpectedVersions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   string
+		expected []int64
+		wantErr  bool
+	}{
+		{
+			name:     "Test Case 1: No header",
+			header:   "",
+			expected: nil,
+		},
+		{
+			name:     "Test Case 2: Any current version",
+			header:   "*",
+			expected: []int64{storage.AnyVersion},
+		},
+		{
+			name:     "Test Case 3: Single entity tag",
+			header:   `"7"`,
+			expected: []int64{7},
+		},
+		{
+			name:    "Test Case 4: Weak entity tag",
+			header:  `W/"7"`,
+			wantErr: true,
+		},
+		{
+			name:    "Test Case 5: Unquoted entity tag",
+			header:  "7",
+			wantErr: true,
+		},
+		{
+			name:     "Test Case 6: Several entity tags",
+			header:   `"7", "8"`,
+			expected: []int64{7, 8},
+		},
+		{
+			name:     "Test Case 7: Weak entity tag in a list",
+			header:   `W/"7", "8"`,
+			expected: []int64{8},
+		},
+		{
+			name:     "Test Case 8: Entity tag of a redacted representation",
+			header:   `"7-redacted"`,
+			expected: []int64{7},
+		},
+		{
+			name:    "Test Case 9: Invalid entity tag in a list",
+			header:  `"7", "eight"`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("DELETE", "/", nil)
+			if tc.header != "" {
+				r.Header.Set("If-Match", tc.header)
+			}
+			result, err := ExpectedVersions(r)
+			assert.Equal(t, tc.expected, result)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrorInvalidIfMatch)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNoneMatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   string
+		expected bool
+	}{
+		{
+			name:     "Test Case 1: No header",
+			header:   "",
+			expected: false,
+		},
+		{
+			name:     "Test Case 2: Current version",
+			header:   `"3"`,
+			expected: true,
+		},
+		{
+			name:     "Test Case 3: Weak current version in a list",
+			header:   `"1", W/"3"`,
+			expected: true,
+		},
+		{
+			name:     "Test Case 4: Stale version",
+			header:   `"2"`,
+			expected: false,
+		},
+		{
+			name:     "Test Case 5: Any version",
+			header:   "*",
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				r.Header.Set("If-None-Match", tc.header)
+			}
+			assert.Equal(t, tc.expected, NoneMatch(r, Format(3)))
+		})
+	}
+}
+
+func TestFormatRepresentation(t *testing.T) {
+	assert.Equal(t, `"3"`, FormatRepresentation(3, false))
+	assert.Equal(t, `"3-redacted"`, FormatRepresentation(3, true))
+
+	// A client holding the complete representation is not told that the redacted one is unchanged
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", FormatRepresentation(3, false))
+	assert.False(t, NoneMatch(r, FormatRepresentation(3, true)))
+}
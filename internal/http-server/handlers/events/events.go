@@ -0,0 +1,108 @@
+// Package events provides HTTP handlers for reading the change feed.
+package events
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// DefaultLimit and MaxLimit bound the number of events returned by a single request.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// EventsGetter is an interface for reading the change feed.
+type EventsGetter interface {
+	GetEvents(ctx context.Context, after int64, limit int) ([]storage.Event, error)
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success bool            `json:"success"`
+	Errors  []string        `json:"errors"`
+	Events  []storage.Event `json:"events"`
+	Next    int64           `json:"next"` // Value of after for the next request
+}
+
+// List is a HTTP handler function for polling the change feed.
+// It reads the optional after and limit query parameters and returns the events
+// with a greater sequence number, in sequence order, as a JSON response.
+func List(log *slog.Logger, eventsGetter EventsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.events.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var after int64
+		if raw := r.URL.Query().Get("after"); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed < 0 {
+				log.Info("invalid after", slog.String("after", raw))
+				badRequest(w, r, "after must be a non-negative integer")
+				return
+			}
+			after = parsed
+		}
+
+		limit := DefaultLimit
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > MaxLimit {
+				log.Info("invalid limit", slog.String("limit", raw))
+				badRequest(w, r, "limit must be an integer between 1 and "+strconv.Itoa(MaxLimit))
+				return
+			}
+			limit = parsed
+		}
+
+		events, err := eventsGetter.GetEvents(r.Context(), after, limit)
+		if err != nil {
+			log.Error("failed to get events", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get events"},
+			})
+			return
+		}
+
+		next := after
+		if len(events) > 0 {
+			next = events[len(events)-1].Seq
+		}
+
+		log.Info("events retrieved", slog.Int64("after", after), slog.Int("events", len(events)))
+		render.JSON(w, r, ListResponse{
+			Success: true,
+			Events:  events,
+			Next:    next,
+		})
+	}
+}
+
+// badRequest is a helper function to respond to an invalid query parameter.
+func badRequest(w http.ResponseWriter, r *http.Request, message string) {
+	render.Status(r, http.StatusBadRequest)
+	render.JSON(w, r, ListResponse{
+		Success: false,
+		Errors:  []string{message},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
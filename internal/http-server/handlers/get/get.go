@@ -2,19 +2,28 @@
 package get
 
 import (
+	"citizen_webservice/internal/http-server/handlers/etag"
 	resp "citizen_webservice/internal/http-server/handlers/response"
 	"citizen_webservice/internal/iin_validator"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 
+	"citizen_webservice/internal/attributes"
+	"citizen_webservice/internal/kato"
 	"citizen_webservice/internal/storage"
 	"github.com/go-chi/render"
 )
 
+// HeaderPurpose is the header a client declares the purpose of its request in.
+const HeaderPurpose = "X-Purpose"
+
 // ByIINResponse is the response structure for the ByIIN handler.
 type ByIINResponse struct {
 	Success bool     `json:"success"`
@@ -31,14 +40,32 @@ type ByNameResponse struct {
 
 // PersonGetter is an interface for getting person information.
 type PersonGetter interface {
-	GetPersonByIIN(iin string) (storage.PersonInfo, error)
-	GetPersonByName(name string) ([]storage.PersonInfo, error)
+	GetPersonByIIN(ctx context.Context, iin string) (storage.PersonInfo, error)
+	GetPersonByName(ctx context.Context, name string, filter storage.PersonFilter) ([]storage.PersonInfo, error)
+}
+
+// ConsentChecker is an interface for checking the consents of people.
+type ConsentChecker interface {
+	HasConsent(ctx context.Context, iin string, purpose string) (bool, error)
+}
+
+// AccessRecorder is an interface for writing the access log.
+type AccessRecorder interface {
+	RecordAccess(ctx context.Context, entries []storage.AccessEntry) error
+}
+
+// Options struct holds the consent enforcement settings.
+// The phone of a person is only returned to a client declaring a purpose if the person consents to it.
+type Options struct {
+	RequirePurpose bool // Whether the phone is also omitted for clients declaring no purpose
 }
 
 // ByIIN is a HTTP handler function for getting a person by their IIN.
 // It validates the IIN, retrieves the person information from the storage,
-// and returns a JSON response.
-func ByIIN(log *slog.Logger, personGetter PersonGetter) http.HandlerFunc {
+// and returns a JSON response without the phone unless it may be shared. The ETag holds the record version
+// and whether the phone was omitted. If the If-None-Match header matches it, it responds with 304 Not Modified.
+// Every returned record is written to the access log.
+func ByIIN(log *slog.Logger, personGetter PersonGetter, consentChecker ConsentChecker, accessRecorder AccessRecorder, opts Options) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.get.ByIIN"
 
@@ -68,7 +95,7 @@ func ByIIN(log *slog.Logger, personGetter PersonGetter) http.HandlerFunc {
 			return
 		}
 
-		personInfo, err := personGetter.GetPersonByIIN(iin)
+		personInfo, err := personGetter.GetPersonByIIN(r.Context(), iin)
 		if errors.Is(err, storage.ErrorIINNotFound) {
 			log.Info("iin not found", slog.String("iin", iin))
 			render.Status(r, http.StatusNotFound)
@@ -88,22 +115,58 @@ func ByIIN(log *slog.Logger, personGetter PersonGetter) http.HandlerFunc {
 			return
 		}
 
+		shared, err := PhoneShared(r, consentChecker, opts, iin)
+		if err != nil {
+			log.Error("failed to check consent", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ByIINResponse{
+				Success: false,
+				Errors:  []string{"failed to check consent"},
+			})
+			return
+		}
+		if !shared {
+			log.Info("phone omitted without consent", slog.String("iin", iin), slog.String("purpose", r.Header.Get(HeaderPurpose)))
+			personInfo.Phone = ""
+		}
+
+		// The body depends on the declared purpose, and so does the entity tag, which tells the representations
+		// with and without the phone apart
+		tag := etag.FormatRepresentation(personInfo.Version, !shared)
+		w.Header().Add("Vary", HeaderPurpose)
+		w.Header().Set("ETag", tag)
+		if etag.NoneMatch(r, tag) {
+			log.Info("person not modified", slog.String("iin", iin))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		RecordAccess(r, log, accessRecorder, storage.AccessRead, personInfo.IIN)
+
 		log.Info("person retrieved", slog.String("person", fmt.Sprintf("%+v", personInfo)))
 		render.JSON(w, r, ByIINResponse{
 			Success: true,
 			PersonInfo: storage.PersonInfo{
-				IIN:   personInfo.IIN,
-				Name:  personInfo.Name,
-				Phone: personInfo.Phone,
+				IIN:        personInfo.IIN,
+				Name:       personInfo.Name,
+				Phone:      personInfo.Phone,
+				Version:    personInfo.Version,
+				Status:     personInfo.Status,
+				Attributes: personInfo.Attributes,
 			},
 		})
 	}
 }
 
-// ByName is a HTTP handler function for getting persons by their name.
+// ByName is a HTTP handler function for getting persons by their name,
+// only those with the status given by the status query parameter if it is set,
+// only those with an address in the region whose KATO code is given by the region query parameter if it is set,
+// and only those whose extension attribute at the path following "attributes." in the name of any other
+// query parameter equals its value, e.g. attributes.benefits.category=veteran.
 // It retrieves the person information from the storage,
-// and returns a JSON response.
-func ByName(log *slog.Logger, personGetter PersonGetter) http.HandlerFunc {
+// and returns a JSON response without the phones that may not be shared.
+// Every returned record is written to the access log.
+func ByName(log *slog.Logger, personGetter PersonGetter, consentChecker ConsentChecker, accessRecorder AccessRecorder, opts Options) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.get.ByName"
 
@@ -119,7 +182,40 @@ func ByName(log *slog.Logger, personGetter PersonGetter) http.HandlerFunc {
 			render.JSON(w, r, resp.Error("invalid request"))
 			return
 		}
-		peopleInfo, err := personGetter.GetPersonByName(name)
+		status := r.URL.Query().Get("status")
+		if status != "" && !slices.Contains(storage.Statuses, status) {
+			log.Info("unknown status", slog.String("status", status))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request, unknown status"))
+			return
+		}
+		region := r.URL.Query().Get("region")
+		if _, known := kato.LookupRegion(region); region != "" && !known {
+			log.Info("unknown region", slog.String("region", region))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request, unknown region"))
+			return
+		}
+		filter := storage.PersonFilter{Status: status, Region: region}
+		for key, values := range r.URL.Query() {
+			path, found := strings.CutPrefix(key, "attributes.")
+			if !found {
+				continue
+			}
+			if _, err := attributes.ValidatePath(path); err != nil {
+				log.Info("invalid attribute path", slog.String("path", path))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid request, "+err.Error()))
+				return
+			}
+			for _, value := range values {
+				filter.Attributes = append(filter.Attributes, storage.AttributeFilter{
+					Path:  path,
+					Value: attributes.FilterValue(value),
+				})
+			}
+		}
+		peopleInfo, err := personGetter.GetPersonByName(r.Context(), name, filter)
 		if errors.Is(err, storage.ErrorNameNotFound) {
 			log.Info("name not found", slog.String("name", name))
 			render.Status(r, http.StatusNotFound)
@@ -134,6 +230,26 @@ func ByName(log *slog.Logger, personGetter PersonGetter) http.HandlerFunc {
 			return
 		}
 
+		for i, personInfo := range peopleInfo {
+			shared, err := PhoneShared(r, consentChecker, opts, personInfo.IIN)
+			if err != nil {
+				log.Error("failed to check consent", Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, resp.Error("failed to check consent"))
+				return
+			}
+			if !shared {
+				peopleInfo[i].Phone = ""
+			}
+		}
+
+		iins := make([]string, 0, len(peopleInfo))
+		for _, personInfo := range peopleInfo {
+			iins = append(iins, personInfo.IIN)
+		}
+		RecordAccess(r, log, accessRecorder, storage.AccessSearch, iins...)
+
+		w.Header().Add("Vary", HeaderPurpose)
 		log.Info("person match success", slog.String("matches", fmt.Sprintf("%+v", peopleInfo)))
 		render.JSON(w, r, ByNameResponse{
 			Success: true,
@@ -142,6 +258,42 @@ func ByName(log *slog.Logger, personGetter PersonGetter) http.HandlerFunc {
 	}
 }
 
+// PhoneShared is a helper function to report whether the phone of the person may be returned to the client.
+// Clients declaring a purpose need the consent of the person to it, the others are only refused if a purpose is required.
+// It is shared by every handler returning the phones of people.
+func PhoneShared(r *http.Request, consentChecker ConsentChecker, opts Options, iin string) (bool, error) {
+	purpose := r.Header.Get(HeaderPurpose)
+	if purpose == "" {
+		return !opts.RequirePurpose, nil
+	}
+	return consentChecker.HasConsent(r.Context(), iin, purpose)
+}
+
+// RecordAccess is a helper function to write the access of the client to the people to the access log,
+// once per person. It is shared by every handler returning the data of people.
+// A failure is logged but does not fail the request.
+func RecordAccess(r *http.Request, log *slog.Logger, accessRecorder AccessRecorder, action string, iins ...string) {
+	client, _, _ := r.BasicAuth()
+	entries := make([]storage.AccessEntry, 0, len(iins))
+	seen := make(map[string]bool, len(iins))
+	for _, iin := range iins {
+		if seen[iin] {
+			continue
+		}
+		seen[iin] = true
+		entries = append(entries, storage.AccessEntry{
+			IIN:     iin,
+			Action:  action,
+			Client:  client,
+			Purpose: r.Header.Get(HeaderPurpose),
+		})
+	}
+
+	if err := accessRecorder.RecordAccess(r.Context(), entries); err != nil {
+		log.Error("failed to record access", Err(err))
+	}
+}
+
 // Err is a helper function to create a structured log attribute for errors.
 func Err(err error) slog.Attr {
 	return slog.Attr{
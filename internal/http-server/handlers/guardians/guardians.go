@@ -0,0 +1,82 @@
+// Package guardians provides HTTP handlers for reporting minors left without a guardian.
+package guardians
+
+import (
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// ReportResponse is the response structure for the Report handler.
+type ReportResponse struct {
+	Success  bool           `json:"success"`
+	Errors   []string       `json:"errors"`
+	AdultAge int            `json:"adult_age"`
+	Minors   []storage.Ward `json:"minors"`
+}
+
+// MinorsLister is an interface for listing the people born after a day who have no active guardian.
+type MinorsLister interface {
+	GetMinorsWithoutGuardian(ctx context.Context, bornAfter time.Time) ([]storage.Ward, error)
+}
+
+// Report is a HTTP handler function for the report of minors without a guardian.
+// It lists the active people younger than the adult age, derived from their IIN, who have no active guardian:
+// those saved without one, whatever the path, and those whose guardians were all deleted or are deceased or emigrated.
+func Report(log *slog.Logger, minorsLister MinorsLister, adultAge int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.guardians.Report"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		// The storage narrows the people down by date of birth, a day early so that no leap day is missed,
+		// and their age is then checked exactly
+		now := time.Now()
+		wards, err := minorsLister.GetMinorsWithoutGuardian(r.Context(), now.AddDate(-adultAge, 0, -1))
+		if err != nil {
+			log.Error("failed to list wards", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ReportResponse{
+				Success: false,
+				Errors:  []string{"failed to list wards"},
+			})
+			return
+		}
+
+		minors := []storage.Ward{}
+		for _, ward := range wards {
+			age, err := iin_validator.GetAge(ward.IIN, now)
+			if err != nil {
+				log.Error("invalid stored IIN", slog.String("iin", ward.IIN), Err(err))
+				continue
+			}
+			if age < adultAge {
+				minors = append(minors, ward)
+			}
+		}
+
+		log.Info("minors without guardian report built", slog.Int("wards", len(wards)), slog.Int("minors", len(minors)))
+		render.JSON(w, r, ReportResponse{
+			Success:  true,
+			AdultAge: adultAge,
+			Minors:   minors,
+		})
+	}
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
@@ -3,6 +3,7 @@ package iin_validate
 
 import (
 	resp "citizen_webservice/internal/http-server/handlers/response"
+	"encoding/json"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"log/slog"
@@ -22,10 +23,18 @@ type ValidateByIINResponse struct {
 	DateOfBirth string `json:"date_of_birth"`
 }
 
+// ResultCache is an interface for caching validation responses.
+// A response only depends on the IIN, so cached responses never have to be invalidated.
+type ResultCache interface {
+	GetIINCheck(iin string) ([]byte, bool, error)
+	SetIINCheck(iin string, result []byte) error
+}
+
 // Execute is a HTTP handler function for validating an IIN.
 // It retrieves the IIN from the URL parameter, validates the IIN,
 // gets the gender and date of birth from the IIN, and returns a JSON response.
-func Execute(log *slog.Logger) http.HandlerFunc {
+// If results is not nil, responses are served from and stored in it.
+func Execute(log *slog.Logger, results ResultCache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.iin_validate"
 
@@ -40,10 +49,24 @@ func Execute(log *slog.Logger) http.HandlerFunc {
 			render.JSON(w, r, resp.Error("invalid request"))
 			return
 		}
+
+		if results != nil {
+			cached, found, err := results.GetIINCheck(iin)
+			if err != nil {
+				log.Error("failed to get cached result", Err(err))
+			}
+			if found {
+				log.Debug("IIN validation served from cache")
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write(cached)
+				return
+			}
+		}
+
 		err := iin_validator.ValidateIIN(iin)
 		if err != nil {
 			log.Error("failed to validate IIN", Err(err))
-			render.JSON(w, r, ValidateByIINResponse{
+			respond(w, r, log, results, iin, ValidateByIINResponse{
 				Correct:     false,
 				Sex:         "",
 				DateOfBirth: "",
@@ -65,7 +88,7 @@ func Execute(log *slog.Logger) http.HandlerFunc {
 
 		formattedDOB := dateOfBirth.Format(OutputDateFormat)
 		log.Info("IIN Validated", slog.String("gender", gender), slog.String("date of birth", formattedDOB))
-		render.JSON(w, r, ValidateByIINResponse{
+		respond(w, r, log, results, iin, ValidateByIINResponse{
 			Correct:     true,
 			Sex:         gender,
 			DateOfBirth: formattedDOB,
@@ -73,6 +96,19 @@ func Execute(log *slog.Logger) http.HandlerFunc {
 	}
 }
 
+// respond writes the validation response and stores it in the cache, if any.
+func respond(w http.ResponseWriter, r *http.Request, log *slog.Logger, results ResultCache, iin string, response ValidateByIINResponse) {
+	if results != nil {
+		if encoded, err := json.Marshal(response); err != nil {
+			log.Error("failed to encode result", Err(err))
+		} else if err = results.SetIINCheck(iin, encoded); err != nil {
+			log.Error("failed to cache result", Err(err))
+		}
+	}
+
+	render.JSON(w, r, response)
+}
+
 // Err is a helper function to create a structured log attribute for errors.
 func Err(err error) slog.Attr {
 	return slog.Attr{
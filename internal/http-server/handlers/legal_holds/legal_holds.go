@@ -0,0 +1,304 @@
+// Package legal_holds provides HTTP handlers for placing, releasing and listing the legal holds on people.
+package legal_holds
+
+import (
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var errorInvalidIIN = errors.New("invalid IIN")
+
+// PlaceRequest is the structure for the request body of the Place handler.
+type PlaceRequest struct {
+	Reason     string `json:"reason" validate:"required,max=255"`
+	CaseNumber string `json:"case_number" validate:"required,max=64"`
+}
+
+// ReleaseRequest is the structure for the request body of the Release handler.
+type ReleaseRequest struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+// HoldPlacer is an interface for placing legal holds.
+type HoldPlacer interface {
+	PlaceLegalHold(ctx context.Context, hold storage.LegalHold) (storage.LegalHold, error)
+}
+
+// HoldReleaser is an interface for releasing legal holds.
+type HoldReleaser interface {
+	ReleaseLegalHold(ctx context.Context, iin string, reason string, client string) (storage.HoldLogEntry, error)
+}
+
+// HoldGetter is an interface for reading the legal hold on a person.
+type HoldGetter interface {
+	GetLegalHold(ctx context.Context, iin string) (storage.LegalHold, error)
+}
+
+// HoldsGetter is an interface for listing legal holds.
+type HoldsGetter interface {
+	GetLegalHolds(ctx context.Context) ([]storage.LegalHold, error)
+}
+
+// HoldLogGetter is an interface for reading the legal hold log of a person.
+type HoldLogGetter interface {
+	GetLegalHoldLog(ctx context.Context, iin string) ([]storage.HoldLogEntry, error)
+}
+
+// HoldResponse is the response structure for the Place, Get and Release handlers.
+type HoldResponse struct {
+	Success bool                  `json:"success"`
+	Errors  []string              `json:"errors"`
+	Hold    *storage.LegalHold    `json:"hold,omitempty"`
+	Entry   *storage.HoldLogEntry `json:"entry,omitempty"` // Log entry of a release
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success bool                `json:"success"`
+	Errors  []string            `json:"errors"`
+	Holds   []storage.LegalHold `json:"holds"`
+}
+
+// LogResponse is the response structure for the Log handler.
+type LogResponse struct {
+	Success bool                   `json:"success"`
+	Errors  []string               `json:"errors"`
+	Entries []storage.HoldLogEntry `json:"entries"`
+}
+
+// Place is a HTTP handler function for placing a legal hold on a person, after which every write
+// to their data is answered with 423 Locked. The client placing the hold is taken from the credentials
+// of the request. It validates the IIN and the request body, places and logs the hold,
+// and returns a JSON response with the placed hold.
+func Place(log *slog.Logger, holdPlacer HoldPlacer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.legal_holds.Place"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req PlaceRequest
+		iin, err := decode(r, &req)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		client, _, _ := r.BasicAuth()
+		hold, err := holdPlacer.PlaceLegalHold(r.Context(), storage.LegalHold{
+			IIN:        iin,
+			Reason:     req.Reason,
+			CaseNumber: req.CaseNumber,
+			PlacedBy:   client,
+		})
+		if err != nil {
+			handleError(w, r, log, err, "Failed to place legal hold")
+			return
+		}
+
+		log.Info("legal hold placed", slog.String("iin", iin), slog.String("case_number", hold.CaseNumber),
+			slog.String("client", client))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, HoldResponse{
+			Success: true,
+			Hold:    &hold,
+		})
+	}
+}
+
+// Release is a HTTP handler function for releasing the legal hold on a person.
+// The client releasing the hold is taken from the credentials of the request, and the release is logged
+// with its reason. It returns a JSON response with the log entry of the release.
+func Release(log *slog.Logger, holdReleaser HoldReleaser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.legal_holds.Release"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req ReleaseRequest
+		iin, err := decode(r, &req)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		client, _, _ := r.BasicAuth()
+		entry, err := holdReleaser.ReleaseLegalHold(r.Context(), iin, req.Reason, client)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to release legal hold")
+			return
+		}
+
+		log.Info("legal hold released", slog.String("iin", iin), slog.String("case_number", entry.CaseNumber),
+			slog.String("client", client))
+		render.JSON(w, r, HoldResponse{
+			Success: true,
+			Entry:   &entry,
+		})
+	}
+}
+
+// Get is a HTTP handler function for reading the legal hold on a person.
+// It responds with 404 Not Found if the person is not under legal hold.
+func Get(log *slog.Logger, holdGetter HoldGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.legal_holds.Get"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := parseIIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		hold, err := holdGetter.GetLegalHold(r.Context(), iin)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get legal hold")
+			return
+		}
+
+		log.Info("legal hold retrieved", slog.String("iin", iin))
+		render.JSON(w, r, HoldResponse{
+			Success: true,
+			Hold:    &hold,
+		})
+	}
+}
+
+// List is a HTTP handler function for reading every legal hold in place, oldest first.
+func List(log *slog.Logger, holdsGetter HoldsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.legal_holds.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		holds, err := holdsGetter.GetLegalHolds(r.Context())
+		if err != nil {
+			log.Error("failed to get legal holds", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get legal holds"},
+			})
+			return
+		}
+
+		log.Info("legal holds retrieved", slog.Int("holds", len(holds)))
+		render.JSON(w, r, ListResponse{
+			Success: true,
+			Holds:   holds,
+		})
+	}
+}
+
+// Log is a HTTP handler function for reading every placement and release of a legal hold on a person, oldest first.
+func Log(log *slog.Logger, holdLogGetter HoldLogGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.legal_holds.Log"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := parseIIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		entries, err := holdLogGetter.GetLegalHoldLog(r.Context(), iin)
+		if err != nil {
+			log.Error("failed to get legal hold log", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, LogResponse{
+				Success: false,
+				Errors:  []string{"failed to get legal hold log"},
+			})
+			return
+		}
+
+		log.Info("legal hold log retrieved", slog.String("iin", iin), slog.Int("entries", len(entries)))
+		render.JSON(w, r, LogResponse{
+			Success: true,
+			Entries: entries,
+		})
+	}
+}
+
+// parseIIN is a helper function to read and validate the iin URL parameter.
+func parseIIN(r *http.Request) (string, error) {
+	iin := chi.URLParam(r, "iin")
+	if err := iin_validator.ValidateIIN(iin); err != nil {
+		return iin, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+	return iin, nil
+}
+
+// decode is a helper function to read the IIN from the URL and to decode and validate the request body into req.
+func decode(r *http.Request, req any) (string, error) {
+	iin, err := parseIIN(r)
+	if err != nil {
+		return iin, err
+	}
+
+	if err = render.DecodeJSON(r.Body, req); err != nil {
+		return iin, err
+	}
+
+	return iin, request_validator.GetValidator().Struct(req)
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) || errors.Is(err, errorInvalidIIN):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound) || errors.Is(err, storage.ErrorHoldNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorHoldExists):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, HoldResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
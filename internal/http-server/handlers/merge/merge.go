@@ -0,0 +1,220 @@
+// Package merge provides HTTP handlers for merging duplicate person records.
+package merge
+
+import (
+	"citizen_webservice/internal/attributes"
+	"citizen_webservice/internal/http-server/handlers/etag"
+	"citizen_webservice/internal/http-server/handlers/get"
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Request is the structure for the request body of the Person handler.
+type Request struct {
+	SourceIIN string `json:"source_iin" validate:"required,len=12,iin,nefield=TargetIIN"` // IIN of the record to merge and remove
+	TargetIIN string `json:"target_iin" validate:"required,len=12,iin"`                   // IIN of the record to keep
+}
+
+// PersonMerger is an interface for merging person records.
+type PersonMerger interface {
+	MergePeople(ctx context.Context, sourceIIN string, targetIIN string, expectedVersions ...int64) (storage.MergeRecord, error)
+}
+
+// MergeLogGetter is an interface for reading the merge log.
+type MergeLogGetter interface {
+	GetMergeLog(ctx context.Context) ([]storage.MergeRecord, error)
+}
+
+// PersonResponse is the response structure for the Person handler.
+type PersonResponse struct {
+	Success bool                 `json:"success"`         // Indicates if the operation was successful
+	Errors  []string             `json:"errors"`          // List of error messages, if any
+	Merge   *storage.MergeRecord `json:"merge,omitempty"` // The merge log entry of a successful merge
+}
+
+// LogResponse is the response structure for the Log handler.
+type LogResponse struct {
+	Success bool                  `json:"success"`
+	Errors  []string              `json:"errors"`
+	Merges  []storage.MergeRecord `json:"merges"`
+}
+
+// Person is a HTTP handler function for merging a source person record into a target one.
+// It decodes and validates the request body, merges the records atomically,
+// and returns a JSON response with the merge log entry. The merge bumps the version of the target,
+// whose new ETag is returned; an If-Match header makes it conditional on the current version of the target
+// and a mismatch is answered with 412 Precondition Failed. The phones are omitted unless they may be shared,
+// as by get.ByIIN. Both people are written to the access log.
+func Person(log *slog.Logger, personMerger PersonMerger, consentChecker get.ConsentChecker, accessRecorder get.AccessRecorder, opts get.Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.merge.Person"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		expectedVersions, err := etag.ExpectedVersions(r)
+		if err != nil {
+			handleError(w, r, log, err, "Precondition failed")
+			return
+		}
+
+		var req Request
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to decode request body")
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+		if err := request_validator.GetValidator().Struct(req); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+
+		record, err := personMerger.MergePeople(r.Context(), req.SourceIIN, req.TargetIIN, expectedVersions...)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to merge people")
+			return
+		}
+
+		log.Info("people merged", slog.String("source", req.SourceIIN), slog.String("target", req.TargetIIN),
+			slog.Int64("version", record.TargetVersion))
+		w.Header().Set("ETag", etag.Format(record.TargetVersion))
+		w.Header().Add("Vary", get.HeaderPurpose)
+		merges := []storage.MergeRecord{record}
+		if err = redactPhones(r, consentChecker, opts, merges); err != nil {
+			// The merge is committed, only its phones are left out
+			log.Error("failed to check consent", Err(err))
+			merges[0].SourcePhone, merges[0].TargetPhone = "", ""
+		}
+		record = merges[0]
+		get.RecordAccess(r, log, accessRecorder, storage.AccessMerges, record.SourceIIN, record.TargetIIN)
+		render.JSON(w, r, PersonResponse{
+			Success: true,
+			Merge:   &record,
+		})
+	}
+}
+
+// Log is a HTTP handler function for reading the merge log.
+// It returns every recorded merge, most recent first, as a JSON response.
+// The phones are omitted unless they may be shared, as by get.ByIIN. Every merged person is written to the access log.
+func Log(log *slog.Logger, mergeLogGetter MergeLogGetter, consentChecker get.ConsentChecker, accessRecorder get.AccessRecorder, opts get.Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.merge.Log"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		merges, err := mergeLogGetter.GetMergeLog(r.Context())
+		if err != nil {
+			log.Error("failed to get merge log", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, LogResponse{
+				Success: false,
+				Errors:  []string{"failed to get merge log"},
+			})
+			return
+		}
+		if err = redactPhones(r, consentChecker, opts, merges); err != nil {
+			log.Error("failed to check consent", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, LogResponse{
+				Success: false,
+				Errors:  []string{"failed to check consent"},
+			})
+			return
+		}
+
+		iins := make([]string, 0, 2*len(merges))
+		for _, merge := range merges {
+			iins = append(iins, merge.SourceIIN, merge.TargetIIN)
+		}
+		get.RecordAccess(r, log, accessRecorder, storage.AccessMerges, iins...)
+
+		log.Info("merge log retrieved", slog.Int("merges", len(merges)))
+		w.Header().Add("Vary", get.HeaderPurpose)
+		render.JSON(w, r, LogResponse{
+			Success: true,
+			Merges:  merges,
+		})
+	}
+}
+
+// redactPhones is a helper function to omit the phones of the merged people that may not be shared with the client,
+// checking every person once.
+func redactPhones(r *http.Request, consentChecker get.ConsentChecker, opts get.Options, merges []storage.MergeRecord) error {
+	shared := make(map[string]bool)
+	for i := range merges {
+		for _, person := range []struct {
+			iin   string
+			phone *string
+		}{
+			{merges[i].SourceIIN, &merges[i].SourcePhone},
+			{merges[i].TargetIIN, &merges[i].TargetPhone},
+		} {
+			ok, checked := shared[person.iin]
+			if !checked {
+				var err error
+				if ok, err = get.PhoneShared(r, consentChecker, opts, person.iin); err != nil {
+					return err
+				}
+				shared[person.iin] = ok
+			}
+			if !ok {
+				*person.phone = ""
+			}
+		}
+	}
+	return nil
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorEmploymentOverlaps) || errors.Is(err, attributes.ErrorInvalidValue) ||
+		errors.Is(err, storage.ErrorGuardianInactive):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorVersionMismatch) || errors.Is(err, etag.ErrorInvalidIfMatch):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, PersonResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
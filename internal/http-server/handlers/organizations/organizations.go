@@ -0,0 +1,269 @@
+// Package organizations provides HTTP handlers for managing organizations and listing the people working at them.
+package organizations
+
+import (
+	"citizen_webservice/internal/bin_validator"
+	"citizen_webservice/internal/http-server/handlers/get"
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// DateFormat is the format of the day the Employees handler lists the employees on.
+const DateFormat = "2006-01-02"
+
+var (
+	errorInvalidBIN  = errors.New("invalid BIN")
+	errorInvalidDate = errors.New("on must be a date formatted as YYYY-MM-DD")
+)
+
+// Request is the structure for the request body of the Create handler.
+type Request struct {
+	BIN  string `json:"bin" validate:"required,bin"`
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// OrganizationSaver is an interface for saving organizations.
+type OrganizationSaver interface {
+	SaveOrganization(ctx context.Context, bin string, name string) (storage.Organization, error)
+}
+
+// OrganizationGetter is an interface for reading an organization.
+type OrganizationGetter interface {
+	GetOrganization(ctx context.Context, bin string) (storage.Organization, error)
+}
+
+// OrganizationsGetter is an interface for listing organizations.
+type OrganizationsGetter interface {
+	GetOrganizations(ctx context.Context) ([]storage.Organization, error)
+}
+
+// EmployeesGetter is an interface for listing the people working at an organization.
+type EmployeesGetter interface {
+	GetEmployees(ctx context.Context, bin string, on string) ([]storage.Employee, error)
+}
+
+// AccessRecorder is an interface for writing the access log.
+type AccessRecorder interface {
+	RecordAccess(ctx context.Context, entries []storage.AccessEntry) error
+}
+
+// OrganizationResponse is the response structure for the Create and Get handlers.
+type OrganizationResponse struct {
+	Success      bool                  `json:"success"`
+	Errors       []string              `json:"errors"`
+	Organization *storage.Organization `json:"organization,omitempty"`
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success       bool                   `json:"success"`
+	Errors        []string               `json:"errors"`
+	Organizations []storage.Organization `json:"organizations"`
+}
+
+// EmployeesResponse is the response structure for the Employees handler.
+type EmployeesResponse struct {
+	Success   bool               `json:"success"`
+	Errors    []string           `json:"errors"`
+	On        string             `json:"on,omitempty"` // Empty when everyone who has ever worked there is listed
+	Employees []storage.Employee `json:"employees"`
+}
+
+// Create is a HTTP handler function for saving an organization.
+// It validates the request body, the BIN included, and returns a JSON response with the saved organization.
+func Create(log *slog.Logger, organizationSaver OrganizationSaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.organizations.Create"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+		err := render.DecodeJSON(r.Body, &req)
+		if err == nil {
+			err = request_validator.GetValidator().Struct(req)
+		}
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		organization, err := organizationSaver.SaveOrganization(r.Context(), req.BIN, req.Name)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save organization")
+			return
+		}
+
+		log.Info("organization saved", slog.String("bin", organization.BIN))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, OrganizationResponse{
+			Success:      true,
+			Organization: &organization,
+		})
+	}
+}
+
+// Get is a HTTP handler function for reading the organization identified by the bin URL parameter.
+func Get(log *slog.Logger, organizationGetter OrganizationGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.organizations.Get"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		bin, err := parseBIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		organization, err := organizationGetter.GetOrganization(r.Context(), bin)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get organization")
+			return
+		}
+
+		log.Info("organization retrieved", slog.String("bin", bin))
+		render.JSON(w, r, OrganizationResponse{
+			Success:      true,
+			Organization: &organization,
+		})
+	}
+}
+
+// List is a HTTP handler function for reading every organization.
+func List(log *slog.Logger, organizationsGetter OrganizationsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.organizations.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		organizations, err := organizationsGetter.GetOrganizations(r.Context())
+		if err != nil {
+			log.Error("failed to get organizations", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get organizations"},
+			})
+			return
+		}
+
+		log.Info("organizations retrieved", slog.Int("organizations", len(organizations)))
+		render.JSON(w, r, ListResponse{
+			Success:       true,
+			Organizations: organizations,
+		})
+	}
+}
+
+// Employees is a HTTP handler function for listing the people working at the organization identified
+// by the bin URL parameter on the day given by the optional on query parameter, the current day by default.
+// With the all query parameter set to true, everyone who has ever worked there is listed instead.
+// The read is written to the access log of every listed person.
+func Employees(log *slog.Logger, employeesGetter EmployeesGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.organizations.Employees"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		bin, err := parseBIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		on := ""
+		if r.URL.Query().Get("all") != "true" {
+			on = r.URL.Query().Get("on")
+			if on == "" {
+				on = time.Now().Format(DateFormat)
+			} else if _, err = time.Parse(DateFormat, on); err != nil {
+				handleError(w, r, log, fmt.Errorf("%w: %s", errorInvalidDate, on), "Invalid request")
+				return
+			}
+		}
+
+		employees, err := employeesGetter.GetEmployees(r.Context(), bin, on)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get employees")
+			return
+		}
+
+		iins := make([]string, 0, len(employees))
+		for _, employee := range employees {
+			iins = append(iins, employee.IIN)
+		}
+		get.RecordAccess(r, log, accessRecorder, storage.AccessEmployments, iins...)
+
+		log.Info("employees retrieved", slog.String("bin", bin), slog.String("on", on),
+			slog.Int("employees", len(employees)))
+		render.JSON(w, r, EmployeesResponse{
+			Success:   true,
+			On:        on,
+			Employees: employees,
+		})
+	}
+}
+
+// parseBIN is a helper function to read and validate the bin URL parameter.
+func parseBIN(r *http.Request) (string, error) {
+	bin := chi.URLParam(r, "bin")
+	if err := bin_validator.ValidateBIN(bin); err != nil {
+		return bin, fmt.Errorf("%w: %s", errorInvalidBIN, err.Error())
+	}
+	return bin, nil
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) ||
+		errors.Is(err, errorInvalidBIN) || errors.Is(err, errorInvalidDate):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorOrganizationNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorOrganizationExists):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, OrganizationResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
@@ -0,0 +1,245 @@
+// Package photo provides HTTP handlers for uploading, downloading and deleting the photos of people.
+package photo
+
+import (
+	"bytes"
+	"citizen_webservice/internal/http-server/handlers/get"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/photos"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var (
+	errorInvalidIIN = errors.New("invalid IIN")
+	errorEmptyBody  = errors.New("request body is empty")
+)
+
+// Options struct holds the limits of the uploaded photos.
+type Options struct {
+	MaxSize int64 // Bytes
+	Limits  photos.Limits
+}
+
+// PersonGetter is an interface for checking that a person is stored.
+type PersonGetter interface {
+	GetPersonByIIN(ctx context.Context, iin string) (storage.PersonInfo, error)
+}
+
+// PhotoSaver is an interface for storing photos.
+type PhotoSaver interface {
+	SavePhoto(ctx context.Context, photo storage.Photo, image []byte, thumbnail []byte) (storage.Photo, error)
+}
+
+// PhotoGetter is an interface for reading photos.
+type PhotoGetter interface {
+	GetPhoto(ctx context.Context, iin string) (storage.Photo, []byte, error)
+}
+
+// ThumbnailGetter is an interface for reading the thumbnails of photos.
+type ThumbnailGetter interface {
+	GetPhotoThumbnail(ctx context.Context, iin string) (storage.Photo, []byte, error)
+}
+
+// PhotoDeleter is an interface for removing photos.
+type PhotoDeleter interface {
+	DeletePhoto(ctx context.Context, iin string) error
+}
+
+// AccessRecorder is an interface for writing the access log.
+type AccessRecorder interface {
+	RecordAccess(ctx context.Context, entries []storage.AccessEntry) error
+}
+
+// Response is the response structure for the Upload and Delete handlers, and for failed downloads.
+type Response struct {
+	Success bool           `json:"success"`
+	Errors  []string       `json:"errors"`
+	Photo   *storage.Photo `json:"photo,omitempty"`
+}
+
+// Upload is a HTTP handler function for storing the photo of a person, replacing the previous one.
+// The request body is the image itself, a JPEG or PNG image whose type is sniffed from its content.
+// It validates the IIN, the size and the dimensions of the image, generates its thumbnail, stores both,
+// and returns a JSON response with the description of the photo. Images larger than the limit get a 413 response,
+// and people under legal hold, which the store checks as it saves the photo, a 423 response.
+func Upload(log *slog.Logger, personGetter PersonGetter, photoSaver PhotoSaver, opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.photo.Upload"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := personIIN(r, personGetter)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, opts.MaxSize))
+		if err == nil && len(data) == 0 {
+			err = errorEmptyBody
+		}
+		if err != nil {
+			handleError(w, r, log, err, "Failed to read photo")
+			return
+		}
+
+		photo, thumbnail, err := photos.Process(iin, data, opts.Limits)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid photo")
+			return
+		}
+
+		photo, err = photoSaver.SavePhoto(r.Context(), photo, data, thumbnail)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save photo")
+			return
+		}
+
+		log.Info("photo saved", slog.String("iin", iin), slog.String("content_type", photo.ContentType),
+			slog.Int64("size", photo.Size))
+		render.JSON(w, r, Response{
+			Success: true,
+			Photo:   &photo,
+		})
+	}
+}
+
+// Download is a HTTP handler function for reading the photo of a person.
+// It responds with the image itself, honouring If-Modified-Since and Range requests,
+// and writes the download to the access log.
+func Download(log *slog.Logger, personGetter PersonGetter, photoGetter PhotoGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return serve("handlers.photo.Download", log, personGetter, photoGetter.GetPhoto, false, accessRecorder)
+}
+
+// Thumbnail is a HTTP handler function for reading the thumbnail of the photo of a person, a JPEG image.
+// It responds like Download.
+func Thumbnail(log *slog.Logger, personGetter PersonGetter, thumbnailGetter ThumbnailGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return serve("handlers.photo.Thumbnail", log, personGetter, thumbnailGetter.GetPhotoThumbnail, true, accessRecorder)
+}
+
+// Delete is a HTTP handler function for removing the photo of a person, unless they are under legal hold,
+// which the store checks as it removes the photo.
+func Delete(log *slog.Logger, photoDeleter PhotoDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.photo.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin := chi.URLParam(r, "iin")
+		if err := iin_validator.ValidateIIN(iin); err != nil {
+			handleError(w, r, log, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error()), "Invalid request")
+			return
+		}
+
+		if err := photoDeleter.DeletePhoto(r.Context(), iin); err != nil {
+			handleError(w, r, log, err, "Failed to delete photo")
+			return
+		}
+
+		log.Info("photo deleted", slog.String("iin", iin))
+		render.JSON(w, r, Response{
+			Success: true,
+		})
+	}
+}
+
+// serve is a helper function building the handlers responding with the image read by read,
+// a thumbnail if thumbnail is set. Photos of people who are no longer stored are not served,
+// even if the store still holds them.
+func serve(op string, log *slog.Logger, personGetter PersonGetter,
+	read func(ctx context.Context, iin string) (storage.Photo, []byte, error), thumbnail bool, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := personIIN(r, personGetter)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		photo, data, err := read(r.Context(), iin)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get photo")
+			return
+		}
+
+		get.RecordAccess(r, log, accessRecorder, storage.AccessPhoto, iin)
+
+		log.Info("photo retrieved", slog.String("iin", iin), slog.Int("size", len(data)))
+		contentType := photo.ContentType
+		if thumbnail {
+			contentType = photos.ContentTypeJPEG
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		http.ServeContent(w, r, "", photo.UpdatedAt, bytes.NewReader(data))
+	}
+}
+
+// personIIN is a helper function to read and validate the IIN from the URL
+// and to check that the person is stored.
+func personIIN(r *http.Request, personGetter PersonGetter) (string, error) {
+	iin := chi.URLParam(r, "iin")
+	if err := iin_validator.ValidateIIN(iin); err != nil {
+		return iin, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+
+	_, err := personGetter.GetPersonByIIN(r.Context(), iin)
+	return iin, err
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, photos.ErrorUnsupportedType):
+		status = http.StatusUnsupportedMediaType
+	case errors.Is(err, errorInvalidIIN) || errors.Is(err, errorEmptyBody) ||
+		errors.Is(err, photos.ErrorInvalidImage) || errors.Is(err, photos.ErrorDimensions):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound) || errors.Is(err, storage.ErrorPhotoNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, Response{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
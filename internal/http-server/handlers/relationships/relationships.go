@@ -0,0 +1,328 @@
+// Package relationships provides HTTP handlers for managing the family relationships between people
+// and for reading their households.
+package relationships
+
+import (
+	"citizen_webservice/internal/http-server/handlers/get"
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// DefaultDepth and MaxDepth bound the number of relationships the Household handler follows.
+const (
+	DefaultDepth = 1
+	MaxDepth     = 5
+)
+
+var (
+	errorInvalidIIN       = errors.New("invalid IIN")
+	errorInvalidID        = errors.New("id must be a positive integer")
+	errorInvalidDepth     = fmt.Errorf("depth must be an integer between 1 and %d", MaxDepth)
+	errorSelfRelationship = errors.New("a person cannot be related to themselves")
+	errorParentNotOlder   = errors.New("a parent must be born before their child")
+)
+
+// Request is the structure for the request body of the Create handler.
+// It reads as "from_iin is the <type> of to_iin".
+type Request struct {
+	FromIIN string `json:"from_iin" validate:"required"`
+	Type    string `json:"type" validate:"required,oneof=parent spouse guardian"`
+	ToIIN   string `json:"to_iin" validate:"required"`
+}
+
+// RelationshipSaver is an interface for saving relationships.
+type RelationshipSaver interface {
+	SaveRelationship(ctx context.Context, fromIIN string, relType string, toIIN string) (storage.Relationship, error)
+}
+
+// RelationshipsGetter is an interface for listing the relationships of a person.
+type RelationshipsGetter interface {
+	GetRelationships(ctx context.Context, iin string) ([]storage.Relationship, error)
+}
+
+// RelationshipDeleter is an interface for deleting relationships.
+type RelationshipDeleter interface {
+	DeleteRelationship(ctx context.Context, id int64) error
+}
+
+// HouseholdGetter is an interface for reading the household of a person.
+type HouseholdGetter interface {
+	GetHousehold(ctx context.Context, iin string, hops int) (storage.Household, error)
+}
+
+// AccessRecorder is an interface for writing the access log.
+type AccessRecorder interface {
+	RecordAccess(ctx context.Context, entries []storage.AccessEntry) error
+}
+
+// RelationshipResponse is the response structure for the Create and Delete handlers.
+type RelationshipResponse struct {
+	Success      bool                  `json:"success"`
+	Errors       []string              `json:"errors"`
+	Relationship *storage.Relationship `json:"relationship,omitempty"`
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success       bool                   `json:"success"`
+	Errors        []string               `json:"errors"`
+	Relationships []storage.Relationship `json:"relationships"`
+}
+
+// HouseholdResponse is the response structure for the Household handler.
+type HouseholdResponse struct {
+	Success   bool               `json:"success"`
+	Errors    []string           `json:"errors"`
+	Household *storage.Household `json:"household,omitempty"`
+}
+
+// Create is a HTTP handler function for linking two stored people.
+// It validates the request body, checks that a parent is born before their child
+// using the dates of birth derived from the IINs, saves the relationship,
+// and returns a JSON response with the saved relationship.
+func Create(log *slog.Logger, relationshipSaver RelationshipSaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.relationships.Create"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		req, err := decode(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		relationship, err := relationshipSaver.SaveRelationship(r.Context(), req.FromIIN, req.Type, req.ToIIN)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save relationship")
+			return
+		}
+
+		log.Info("relationship saved", slog.Int64("id", relationship.ID), slog.String("type", relationship.Type))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, RelationshipResponse{
+			Success:      true,
+			Relationship: &relationship,
+		})
+	}
+}
+
+// List is a HTTP handler function for reading every relationship a person takes part in.
+// The read is written to the access log of the person and of everyone related to them.
+func List(log *slog.Logger, relationshipsGetter RelationshipsGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.relationships.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := parseIIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		relationships, err := relationshipsGetter.GetRelationships(r.Context(), iin)
+		if err != nil {
+			log.Error("failed to get relationships", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get relationships"},
+			})
+			return
+		}
+
+		iins := []string{iin}
+		for _, relationship := range relationships {
+			iins = append(iins, relationship.FromIIN, relationship.ToIIN)
+		}
+		get.RecordAccess(r, log, accessRecorder, storage.AccessRelationships, iins...)
+
+		log.Info("relationships retrieved", slog.String("iin", iin), slog.Int("relationships", len(relationships)))
+		render.JSON(w, r, ListResponse{
+			Success:       true,
+			Relationships: relationships,
+		})
+	}
+}
+
+// Delete is a HTTP handler function for removing the relationship identified by the id URL parameter.
+func Delete(log *slog.Logger, relationshipDeleter RelationshipDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.relationships.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id < 1 {
+			handleError(w, r, log, errorInvalidID, "Invalid request")
+			return
+		}
+
+		if err = relationshipDeleter.DeleteRelationship(r.Context(), id); err != nil {
+			handleError(w, r, log, err, "Failed to delete relationship")
+			return
+		}
+
+		log.Info("relationship deleted", slog.Int64("id", id))
+		render.JSON(w, r, RelationshipResponse{
+			Success: true,
+		})
+	}
+}
+
+// Household is a HTTP handler function for reading the household of a person: the people reachable
+// through at most the number of relationships given by the optional depth query parameter,
+// followed in either direction, and the relationships between them.
+// The read is written to the access log of every member.
+func Household(log *slog.Logger, householdGetter HouseholdGetter, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.relationships.Household"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin, err := parseIIN(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		depth := DefaultDepth
+		if raw := r.URL.Query().Get("depth"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > MaxDepth {
+				handleError(w, r, log, errorInvalidDepth, "Invalid request")
+				return
+			}
+			depth = parsed
+		}
+
+		household, err := householdGetter.GetHousehold(r.Context(), iin, depth)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get household")
+			return
+		}
+
+		iins := make([]string, 0, len(household.Members))
+		for _, member := range household.Members {
+			iins = append(iins, member.IIN)
+		}
+		get.RecordAccess(r, log, accessRecorder, storage.AccessHousehold, iins...)
+
+		log.Info("household retrieved", slog.String("iin", iin), slog.Int("depth", depth),
+			slog.Int("members", len(household.Members)))
+		render.JSON(w, r, HouseholdResponse{
+			Success:   true,
+			Household: &household,
+		})
+	}
+}
+
+// parseIIN is a helper function to read and validate the iin URL parameter.
+func parseIIN(r *http.Request) (string, error) {
+	iin := chi.URLParam(r, "iin")
+	if err := iin_validator.ValidateIIN(iin); err != nil {
+		return iin, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+	return iin, nil
+}
+
+// decode is a helper function to decode and validate the request body.
+// Both IINs must be valid and different, and a parent must be born before their child.
+// People born on the same day are not taken for parent and child either.
+func decode(r *http.Request) (Request, error) {
+	var req Request
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		return req, err
+	}
+	if err := request_validator.GetValidator().Struct(req); err != nil {
+		return req, err
+	}
+
+	for _, iin := range []string{req.FromIIN, req.ToIIN} {
+		if err := iin_validator.ValidateIIN(iin); err != nil {
+			return req, fmt.Errorf("%w: %s: %s", errorInvalidIIN, iin, err.Error())
+		}
+	}
+	if req.FromIIN == req.ToIIN {
+		return req, errorSelfRelationship
+	}
+
+	if req.Type == storage.RelationshipParent {
+		parentBirth, err := iin_validator.GetDateOfBirth(req.FromIIN)
+		if err != nil {
+			return req, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+		}
+		childBirth, err := iin_validator.GetDateOfBirth(req.ToIIN)
+		if err != nil {
+			return req, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+		}
+		if !parentBirth.Before(childBirth) {
+			return req, fmt.Errorf("%w: %s is not before %s", errorParentNotOlder,
+				parentBirth.Format("2006-01-02"), childBirth.Format("2006-01-02"))
+		}
+	}
+
+	return req, nil
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) ||
+		errors.Is(err, errorInvalidIIN) || errors.Is(err, errorInvalidID) || errors.Is(err, errorInvalidDepth) ||
+		errors.Is(err, errorSelfRelationship) || errors.Is(err, errorParentNotOlder):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound) || errors.Is(err, storage.ErrorRelationshipNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorRelationshipExists) || errors.Is(err, storage.ErrorTooManyParents) ||
+		errors.Is(err, storage.ErrorGuardianInactive):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, RelationshipResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
@@ -2,6 +2,7 @@
 package request_validator
 
 import (
+	"citizen_webservice/internal/bin_validator"
 	"citizen_webservice/internal/iin_validator"
 	"github.com/go-playground/validator/v10"
 )
@@ -18,8 +19,17 @@ func validateIIN(fl validator.FieldLevel) bool {
 	return err == nil
 }
 
+// validateBIN is a custom validation function for BIN (Business Identification Number).
+// It uses the bin_validator package to validate the BIN.
+// It returns true if the BIN is valid, and false otherwise.
+func validateBIN(fl validator.FieldLevel) bool {
+	bin := fl.Field().String()
+	err := bin_validator.ValidateBIN(bin)
+	return err == nil
+}
+
 // init is a special function that is called when the package is initialized.
-// It creates a new instance of the validator and registers the custom IIN validation function.
+// It creates a new instance of the validator and registers the custom IIN and BIN validation functions.
 // If the registration fails, it panics.
 func init() {
 	validate = validator.New()
@@ -27,6 +37,10 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	err = validate.RegisterValidation("bin", validateBIN)
+	if err != nil {
+		panic(err)
+	}
 }
 
 // GetValidator is a function that returns the global instance of the validator.
@@ -0,0 +1,63 @@
+// Package retention provides HTTP handlers for reviewing the retention rules.
+package retention
+
+import (
+	"citizen_webservice/internal/retention"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Response is the response structure for the Report handler.
+type Response struct {
+	Success bool               `json:"success"`
+	Errors  []string           `json:"errors"`
+	Rules   []retention.Result `json:"rules"`
+}
+
+// Reporter is an interface for counting the data the retention rules would purge.
+type Reporter interface {
+	Report(now time.Time) ([]retention.Result, error)
+}
+
+// Report is a HTTP handler function for a dry run of the retention rules.
+// It returns, for every rule, the cutoff and the count of entries a purge would remove now as a JSON response.
+// Nothing is removed.
+func Report(log *slog.Logger, reporter Reporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.retention.Report"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		results, err := reporter.Report(time.Now())
+		if err != nil {
+			log.Error("failed to report expired data", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, Response{
+				Success: false,
+				Errors:  []string{"failed to report expired data"},
+			})
+			return
+		}
+
+		log.Debug("retention report created", slog.Int("rules", len(results)))
+		render.JSON(w, r, Response{
+			Success: true,
+			Rules:   results,
+		})
+	}
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
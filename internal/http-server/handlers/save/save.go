@@ -2,27 +2,54 @@
 package save
 
 import (
+	"citizen_webservice/internal/attributes"
 	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5/middleware"
 	"io"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/render"
 )
 
+var (
+	errorGuardianRequired = errors.New("a guardian is required for a minor")
+	errorGuardianIsSelf   = errors.New("a person cannot be their own guardian")
+	errorGuardianMinor    = errors.New("guardian must be an adult")
+	errorUnknownNamespace = errors.New("unknown attribute namespace")
+)
+
 // Request is the structure for the request body of the Person handler.
 type Request struct {
-	IIN   string `json:"iin" validate:"required,len=12,iin"` // Individual Identification Number
-	Name  string `json:"name" validate:"required"`           // Name of the person
-	Phone string `json:"phone" validate:"required"`          // Phone number of the person
+	IIN         string `json:"iin" validate:"required,len=12,iin"`                     // Individual Identification Number
+	Name        string `json:"name" validate:"required"`                               // Name of the person
+	Phone       string `json:"phone" validate:"required"`                              // Phone number of the person
+	GuardianIIN string `json:"guardian_iin,omitempty" validate:"omitempty,len=12,iin"` // IIN of the guardian, required for minors
+	// Extension attributes by namespace, each validated against the schema registered for its namespace
+	Attributes map[string]json.RawMessage `json:"attributes,omitempty"`
+}
+
+// Options struct holds the age from which people are saved without a guardian.
+type Options struct {
+	AdultAge int
 }
 
 // PersonSaver is an interface for saving person information.
 type PersonSaver interface {
-	SavePerson(iin string, name string, phone string) error
+	SavePerson(ctx context.Context, iin string, name string, phone string) error
+	SavePersonWithOptions(ctx context.Context, iin string, name string, phone string, opts storage.SaveOptions) error
+}
+
+// SchemaGetter is an interface for reading the JSON Schemas of the attribute namespaces.
+type SchemaGetter interface {
+	GetAttributeSchema(ctx context.Context, namespace string) (storage.AttributeSchema, error)
 }
 
 // PersonResponse is the response structure for the Person handler.
@@ -33,8 +60,10 @@ type PersonResponse struct {
 
 // Person is a HTTP handler function for saving a person's information.
 // It decodes the request body, validates the request, saves the person information,
-// and returns a JSON response.
-func Person(log *slog.Logger, personSaver PersonSaver) http.HandlerFunc {
+// and returns a JSON response. People younger than the adult age, derived from their IIN,
+// must be saved with a stored adult guardian, who is neither deceased nor emigrated.
+// Every extension attribute must belong to a registered namespace and be valid against its schema.
+func Person(log *slog.Logger, personSaver PersonSaver, schemaGetter SchemaGetter, opts Options) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.save.Person"
 
@@ -58,7 +87,24 @@ func Person(log *slog.Logger, personSaver PersonSaver) http.HandlerFunc {
 			return
 		}
 
-		err = personSaver.SavePerson(req.IIN, req.Name, req.Phone)
+		if err := checkGuardian(req, opts.AdultAge, time.Now()); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+
+		if err := checkAttributes(r.Context(), schemaGetter, req.Attributes); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+
+		if req.GuardianIIN != "" || len(req.Attributes) > 0 {
+			err = personSaver.SavePersonWithOptions(r.Context(), req.IIN, req.Name, req.Phone, storage.SaveOptions{
+				GuardianIIN: req.GuardianIIN,
+				Attributes:  req.Attributes,
+			})
+		} else {
+			err = personSaver.SavePerson(r.Context(), req.IIN, req.Name, req.Phone)
+		}
 		if err != nil {
 			handleError(w, r, log, err, "Failed to save person")
 			return
@@ -71,14 +117,73 @@ func Person(log *slog.Logger, personSaver PersonSaver) http.HandlerFunc {
 	}
 }
 
+// checkGuardian is a helper function to check the guardian of the person of a validated request.
+// A minor must have a guardian, and a guardian, whoever they are the guardian of, must be an adult.
+func checkGuardian(req Request, adultAge int, now time.Time) error {
+	age, err := iin_validator.GetAge(req.IIN, now)
+	if err != nil {
+		return err
+	}
+	if req.GuardianIIN == "" {
+		if age < adultAge {
+			return fmt.Errorf("%w: aged %d, adult at %d", errorGuardianRequired, age, adultAge)
+		}
+		return nil
+	}
+
+	if req.GuardianIIN == req.IIN {
+		return errorGuardianIsSelf
+	}
+	guardianAge, err := iin_validator.GetAge(req.GuardianIIN, now)
+	if err != nil {
+		return err
+	}
+	if guardianAge < adultAge {
+		return fmt.Errorf("%w: aged %d, adult at %d", errorGuardianMinor, guardianAge, adultAge)
+	}
+	return nil
+}
+
+// checkAttributes is a helper function to validate every extension attribute against the schema of its namespace.
+func checkAttributes(ctx context.Context, schemaGetter SchemaGetter, attrs map[string]json.RawMessage) error {
+	for namespace, value := range attrs {
+		if err := attributes.ValidateNamespace(namespace); err != nil {
+			return err
+		}
+		schema, err := schemaGetter.GetAttributeSchema(ctx, namespace)
+		if errors.Is(err, storage.ErrorSchemaNotFound) {
+			return fmt.Errorf("%w: %s", errorUnknownNamespace, namespace)
+		}
+		if err != nil {
+			return err
+		}
+		if err = attributes.Validate(schema.Schema, value); err != nil {
+			return fmt.Errorf("%s: %w", namespace, err)
+		}
+	}
+	return nil
+}
+
 // handleError is a helper function to handle errors.
 // It logs the error, determines the appropriate HTTP status code,
 // and sends a JSON response with the error message.
 func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
 	log.Error(message, Err(err))
 	status := http.StatusInternalServerError
-	if errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) {
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) ||
+		errors.Is(err, errorGuardianRequired) || errors.Is(err, errorGuardianIsSelf) || errors.Is(err, errorGuardianMinor) ||
+		errors.Is(err, errorUnknownNamespace) || errors.Is(err, attributes.ErrorInvalidNamespace) ||
+		errors.Is(err, attributes.ErrorInvalidValue):
 		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorGuardianNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorGuardianInactive):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
 	}
 	render.Status(r, status)
 	render.JSON(w, r, PersonResponse{
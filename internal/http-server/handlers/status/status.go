@@ -0,0 +1,197 @@
+// Package status provides HTTP handlers for recording and listing the lifecycle status changes of people.
+package status
+
+import (
+	"citizen_webservice/internal/http-server/handlers/etag"
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// DateFormat is the format of the effective date of a status change.
+const DateFormat = "2006-01-02"
+
+var (
+	errorInvalidIIN = errors.New("invalid IIN")
+	errorFutureDate = errors.New("effective date is in the future")
+)
+
+// Request is the structure for the request body of the Change handler.
+type Request struct {
+	Status        string `json:"status" validate:"required,oneof=active deceased emigrated"`
+	EffectiveDate string `json:"effective_date" validate:"required,datetime=2006-01-02"` // Day the change took effect
+	Reason        string `json:"reason" validate:"required,max=255"`                     // e.g. the certificate the change is based on
+}
+
+// StatusChanger is an interface for changing the status of people.
+type StatusChanger interface {
+	ChangeStatus(ctx context.Context, iin string, status string, effectiveDate string, reason string, expectedVersions ...int64) (storage.StatusChange, error)
+}
+
+// StatusHistoryGetter is an interface for reading the status history of a person.
+type StatusHistoryGetter interface {
+	GetStatusHistory(ctx context.Context, iin string) ([]storage.StatusChange, error)
+}
+
+// ChangeResponse is the response structure for the Change handler.
+type ChangeResponse struct {
+	Success bool                  `json:"success"`
+	Errors  []string              `json:"errors"`
+	Change  *storage.StatusChange `json:"change,omitempty"`
+}
+
+// HistoryResponse is the response structure for the History handler.
+type HistoryResponse struct {
+	Success bool                   `json:"success"`
+	Errors  []string               `json:"errors"`
+	Changes []storage.StatusChange `json:"changes"`
+}
+
+// Change is a HTTP handler function for recording a change of the lifecycle status of a person.
+// It validates the IIN and the request body, changes the status if the transition is allowed,
+// and returns a JSON response with the recorded change and the new ETag of the person. A disallowed transition
+// gets a 409 response. An If-Match header makes the change conditional on the current version of the person,
+// like an update, and a mismatch is answered with 412 Precondition Failed.
+func Change(log *slog.Logger, statusChanger StatusChanger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.status.Change"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		expectedVersions, err := etag.ExpectedVersions(r)
+		if err != nil {
+			handleError(w, r, log, err, "Precondition failed")
+			return
+		}
+
+		iin, req, err := decode(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		change, err := statusChanger.ChangeStatus(r.Context(), iin, req.Status, req.EffectiveDate, req.Reason, expectedVersions...)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to change status")
+			return
+		}
+
+		log.Info("status changed", slog.String("iin", iin), slog.String("from", change.From), slog.String("to", change.To))
+		w.Header().Set("ETag", etag.Format(change.Version))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, ChangeResponse{
+			Success: true,
+			Change:  &change,
+		})
+	}
+}
+
+// History is a HTTP handler function for reading the status history of a person.
+// It returns every status change of the person, oldest first, as a JSON response.
+func History(log *slog.Logger, historyGetter StatusHistoryGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.status.History"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin := chi.URLParam(r, "iin")
+		if err := iin_validator.ValidateIIN(iin); err != nil {
+			handleError(w, r, log, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error()), "Invalid request")
+			return
+		}
+
+		changes, err := historyGetter.GetStatusHistory(r.Context(), iin)
+		if err != nil {
+			log.Error("failed to get status history", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, HistoryResponse{
+				Success: false,
+				Errors:  []string{"failed to get status history"},
+			})
+			return
+		}
+
+		log.Info("status history retrieved", slog.String("iin", iin), slog.Int("changes", len(changes)))
+		render.JSON(w, r, HistoryResponse{
+			Success: true,
+			Changes: changes,
+		})
+	}
+}
+
+// decode is a helper function to read the IIN from the URL and to decode and validate the request body.
+// Effective dates after the current day are rejected.
+func decode(r *http.Request) (string, Request, error) {
+	var req Request
+
+	iin := chi.URLParam(r, "iin")
+	if err := iin_validator.ValidateIIN(iin); err != nil {
+		return iin, req, fmt.Errorf("%w: %s", errorInvalidIIN, err.Error())
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		return iin, req, err
+	}
+	if err := request_validator.GetValidator().Struct(req); err != nil {
+		return iin, req, err
+	}
+
+	if req.EffectiveDate > time.Now().Format(DateFormat) {
+		return iin, req, fmt.Errorf("%w: %s", errorFutureDate, req.EffectiveDate)
+	}
+
+	return iin, req, nil
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) ||
+		errors.Is(err, errorInvalidIIN) || errors.Is(err, errorFutureDate):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorStatusTransition):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorVersionMismatch) || errors.Is(err, etag.ErrorInvalidIfMatch):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, ChangeResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
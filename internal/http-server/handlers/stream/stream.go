@@ -0,0 +1,197 @@
+// Package stream provides HTTP handlers streaming person changes as Server-Sent Events.
+package stream
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// batchSize is the maximum number of events read from the storage at once.
+const batchSize = 100
+
+// Defaults used when the options leave them unset.
+const (
+	DefaultPollInterval = 500 * time.Millisecond
+	DefaultHeartbeat    = 15 * time.Second
+	DefaultRetry        = 3 * time.Second
+)
+
+// EventsGetter is an interface for reading the change feed.
+type EventsGetter interface {
+	GetEvents(ctx context.Context, after int64, limit int) ([]storage.Event, error)
+	GetLastEventSeq(ctx context.Context) (int64, error)
+}
+
+// Options struct holds the stream settings.
+type Options struct {
+	PollInterval time.Duration   // How often new events are looked up
+	Heartbeat    time.Duration   // How often a comment is sent while there are no events, to keep proxies from closing the connection
+	Retry        time.Duration   // How long clients wait before reconnecting
+	Done         <-chan struct{} // Closes the open streams when closed, so that they do not hold up the shutdown
+}
+
+// ErrorResponse is the response structure sent instead of the stream if it cannot be started.
+type ErrorResponse struct {
+	Success bool     `json:"success"`
+	Errors  []string `json:"errors"`
+}
+
+// People is a HTTP handler function streaming change events as Server-Sent Events.
+// Every event carries its sequence number as the event ID and its type as the event name.
+// If the Last-Event-ID header is set, the stream resumes after that event,
+// otherwise it starts with the next change.
+func People(log *slog.Logger, eventsGetter EventsGetter, opts Options) http.HandlerFunc {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = DefaultHeartbeat
+	}
+	if opts.Retry <= 0 {
+		opts.Retry = DefaultRetry
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.stream.People"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var after int64
+		if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed < 0 {
+				log.Info("invalid Last-Event-ID", slog.String("last_event_id", raw))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, ErrorResponse{
+					Success: false,
+					Errors:  []string{"Last-Event-ID must be a non-negative integer"},
+				})
+				return
+			}
+			after = parsed
+		} else {
+			last, err := eventsGetter.GetLastEventSeq(r.Context())
+			if err != nil {
+				log.Error("failed to get last event", Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, ErrorResponse{
+					Success: false,
+					Errors:  []string{"failed to get last event"},
+				})
+				return
+			}
+			after = last
+		}
+
+		s := &sender{w: w, rc: http.NewResponseController(w), timeout: 2 * opts.Heartbeat}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if err := s.send(fmt.Sprintf("retry: %d\n\n", opts.Retry.Milliseconds())); err != nil {
+			log.Info("stream closed", Err(err))
+			return
+		}
+
+		log.Info("stream started", slog.Int64("after", after))
+
+		poll := time.NewTicker(opts.PollInterval)
+		defer poll.Stop()
+		heartbeat := time.NewTicker(opts.Heartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			var err error
+			select {
+			case <-r.Context().Done():
+				log.Info("stream closed by client", slog.Int64("after", after))
+				return
+			case <-opts.Done:
+				log.Info("stream closed on shutdown", slog.Int64("after", after))
+				return
+			case <-heartbeat.C:
+				err = s.send(": heartbeat\n\n")
+			case <-poll.C:
+				var sent bool
+				after, sent, err = sendEvents(r.Context(), s, eventsGetter, after)
+				if sent {
+					heartbeat.Reset(opts.Heartbeat)
+				}
+			}
+
+			if err != nil {
+				log.Info("stream closed", slog.Int64("after", after), Err(err))
+				return
+			}
+		}
+	}
+}
+
+// sendEvents is a helper function to send every event after the given sequence number.
+// It returns the sequence number of the last sent event and whether any was sent.
+func sendEvents(ctx context.Context, s *sender, eventsGetter EventsGetter, after int64) (int64, bool, error) {
+	sent := false
+	for {
+		events, err := eventsGetter.GetEvents(ctx, after, batchSize)
+		if err != nil {
+			return after, sent, err
+		}
+
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return after, sent, err
+			}
+			if err = s.send(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)); err != nil {
+				return after, sent, err
+			}
+			after, sent = event.Seq, true
+		}
+
+		if len(events) < batchSize {
+			return after, sent, nil
+		}
+	}
+}
+
+// sender struct writes and flushes the stream, extending the write deadline of the server before every write
+// so that the stream outlives the write timeout while the client keeps reading.
+type sender struct {
+	w       io.Writer
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+// send method writes and flushes a message of the stream.
+func (s *sender) send(message string) error {
+	if err := s.rc.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if _, err := io.WriteString(s.w, message); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
@@ -0,0 +1,206 @@
+// Package subject_report provides HTTP handlers for data subject access reports.
+package subject_report
+
+import (
+	"citizen_webservice/internal/iin_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// OutputDateFormat is the format for the date of birth in the report.
+const OutputDateFormat = "02.01.2006"
+
+// SubjectDataGetter is an interface for reading everything stored about a person.
+type SubjectDataGetter interface {
+	GetPersonByIIN(ctx context.Context, iin string) (storage.PersonInfo, error)
+	GetPersonEvents(ctx context.Context, iin string) ([]storage.Event, error)
+	GetPersonMerges(ctx context.Context, iin string) ([]storage.MergeRecord, error)
+	GetConsentHistory(ctx context.Context, iin string) ([]storage.Consent, error)
+	GetAccessLog(ctx context.Context, iin string) ([]storage.AccessEntry, error)
+	GetStatusHistory(ctx context.Context, iin string) ([]storage.StatusChange, error)
+	GetDocuments(ctx context.Context, iin string) ([]storage.Document, error)
+	GetAddresses(ctx context.Context, iin string) ([]storage.Address, error)
+	GetEmployments(ctx context.Context, iin string) ([]storage.Employment, error)
+	GetRelationships(ctx context.Context, iin string) ([]storage.Relationship, error)
+	GetGuardianIIN(ctx context.Context, iin string) (string, error)
+}
+
+// PhotoDescriber is an interface for reading the description of the photo of a person, wherever it is kept.
+type PhotoDescriber interface {
+	GetPhotoDescription(ctx context.Context, iin string) (storage.Photo, error)
+}
+
+// AccessRecorder is an interface for writing the access log.
+type AccessRecorder interface {
+	RecordAccess(ctx context.Context, entries []storage.AccessEntry) error
+}
+
+// Report is the structure of a data subject access report.
+type Report struct {
+	IIN           string                 `json:"iin"`
+	GeneratedAt   time.Time              `json:"generated_at"`
+	Person        *storage.PersonInfo    `json:"person"`         // Current record, null if none is stored
+	GuardianIIN   string                 `json:"guardian_iin"`   // Guardian the person was saved with, empty if none
+	Attributes    Attributes             `json:"attributes"`     // Derived from the IIN itself
+	Events        []storage.Event        `json:"events"`         // Change history
+	Merges        []storage.MergeRecord  `json:"merges"`         // Merges the IIN took part in
+	Consents      []storage.Consent      `json:"consents"`       // Every grant and revocation
+	StatusChanges []storage.StatusChange `json:"status_changes"` // Every change of the lifecycle status
+	Documents     []storage.Document     `json:"documents"`      // Identity documents
+	Addresses     []storage.Address      `json:"addresses"`      // Registered and actual addresses
+	Employments   []storage.Employment   `json:"employments"`    // Periods of work at organizations
+	Relationships []storage.Relationship `json:"relationships"`  // Links to relatives, guardians and wards
+	Photo         *storage.Photo         `json:"photo"`          // Description of the photo without the image, null if none is stored
+	AccessLog     []storage.AccessEntry  `json:"access_log"`     // Every read of the record, this report excluded
+}
+
+// Attributes is the structure of the attributes derived from an IIN.
+type Attributes struct {
+	Sex         string `json:"sex"`
+	DateOfBirth string `json:"date_of_birth"`
+}
+
+// Response is the response structure for the Execute handler.
+type Response struct {
+	Success bool     `json:"success"`
+	Errors  []string `json:"errors"`
+	Report  *Report  `json:"report,omitempty"`
+}
+
+// Execute is a HTTP handler function for assembling everything stored about a person.
+// It validates the IIN, reads the current record with its guardian, change history, merges, consents, status changes,
+// documents, addresses, employments, relationships, the description of the photo and the access log,
+// and returns them as a JSON document to be downloaded. The report itself is written to the access log.
+func Execute(log *slog.Logger, dataGetter SubjectDataGetter, photoDescriber PhotoDescriber, accessRecorder AccessRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.subject_report.Execute"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin := chi.URLParam(r, "iin")
+		if err := iin_validator.ValidateIIN(iin); err != nil {
+			log.Info("invalid IIN", Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, Response{
+				Success: false,
+				Errors:  []string{"failed to validate IIN"},
+			})
+			return
+		}
+
+		report, err := assemble(r.Context(), dataGetter, photoDescriber, iin)
+		if err != nil {
+			log.Error("failed to assemble report", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, Response{
+				Success: false,
+				Errors:  []string{"failed to assemble report"},
+			})
+			return
+		}
+
+		client, _, _ := r.BasicAuth()
+		err = accessRecorder.RecordAccess(r.Context(), []storage.AccessEntry{{
+			IIN:    iin,
+			Action: storage.AccessSubjectReport,
+			Client: client,
+		}})
+		if err != nil {
+			log.Error("failed to record access", Err(err))
+		}
+
+		log.Info("subject report assembled", slog.String("iin", iin),
+			slog.Int("events", len(report.Events)), slog.Int("access_log", len(report.AccessLog)))
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="subject-report-%s.json"`, iin))
+		render.JSON(w, r, Response{
+			Success: true,
+			Report:  &report,
+		})
+	}
+}
+
+// assemble is a helper function to read everything stored about the IIN into a report.
+func assemble(ctx context.Context, dataGetter SubjectDataGetter, photoDescriber PhotoDescriber, iin string) (Report, error) {
+	report := Report{
+		IIN:         iin,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	person, err := dataGetter.GetPersonByIIN(ctx, iin)
+	switch {
+	case err == nil:
+		report.Person = &person
+		if report.GuardianIIN, err = dataGetter.GetGuardianIIN(ctx, iin); err != nil && !errors.Is(err, storage.ErrorIINNotFound) {
+			return report, err
+		}
+	case !errors.Is(err, storage.ErrorIINNotFound):
+		return report, err
+	}
+
+	sex, err := iin_validator.GetGender(int(iin[6] - '0'))
+	if err != nil {
+		return report, err
+	}
+	dateOfBirth, err := iin_validator.GetDateOfBirth(iin)
+	if err != nil {
+		return report, err
+	}
+	report.Attributes = Attributes{Sex: sex, DateOfBirth: dateOfBirth.Format(OutputDateFormat)}
+
+	if report.Events, err = dataGetter.GetPersonEvents(ctx, iin); err != nil {
+		return report, err
+	}
+	if report.Merges, err = dataGetter.GetPersonMerges(ctx, iin); err != nil {
+		return report, err
+	}
+	if report.Consents, err = dataGetter.GetConsentHistory(ctx, iin); err != nil {
+		return report, err
+	}
+	if report.StatusChanges, err = dataGetter.GetStatusHistory(ctx, iin); err != nil {
+		return report, err
+	}
+	if report.Documents, err = dataGetter.GetDocuments(ctx, iin); err != nil {
+		return report, err
+	}
+	if report.Addresses, err = dataGetter.GetAddresses(ctx, iin); err != nil {
+		return report, err
+	}
+	if report.Employments, err = dataGetter.GetEmployments(ctx, iin); err != nil {
+		return report, err
+	}
+	if report.Relationships, err = dataGetter.GetRelationships(ctx, iin); err != nil {
+		return report, err
+	}
+	photo, err := photoDescriber.GetPhotoDescription(ctx, iin)
+	switch {
+	case err == nil:
+		report.Photo = &photo
+	case !errors.Is(err, storage.ErrorPhotoNotFound):
+		return report, err
+	}
+	if report.AccessLog, err = dataGetter.GetAccessLog(ctx, iin); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
@@ -0,0 +1,212 @@
+// Package tenants provides HTTP handlers for managing the tenants and the credentials of their clients.
+package tenants
+
+import (
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/http-server/middleware/auth"
+	"citizen_webservice/internal/storage"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var errorReservedUser = errors.New("user is reserved for the operator")
+
+// CreateRequest is the structure for the request body of the Create handler.
+type CreateRequest struct {
+	ID   string `json:"id" validate:"required,max=64,lowercase,alphanum"` // Short name of the tenant, e.g. "health"
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// CredentialRequest is the structure for the request body of the AssignCredential handler.
+type CredentialRequest struct {
+	Username string `json:"username" validate:"required,max=255,excludes=:"`
+	Password string `json:"password" validate:"required,min=12,max=72"` // bcrypt ignores anything past 72 bytes
+}
+
+// TenantSaver is an interface for registering tenants.
+type TenantSaver interface {
+	SaveTenant(id string, name string) (storage.Tenant, error)
+}
+
+// TenantsGetter is an interface for listing the tenants.
+type TenantsGetter interface {
+	GetTenants() ([]storage.Tenant, error)
+}
+
+// CredentialSaver is an interface for assigning credentials to tenants.
+type CredentialSaver interface {
+	SaveCredential(username string, tenant string, passwordHash string) (storage.Credential, error)
+}
+
+// TenantResponse is the response structure for the Create handler.
+type TenantResponse struct {
+	Success bool            `json:"success"`
+	Errors  []string        `json:"errors"`
+	Tenant  *storage.Tenant `json:"tenant,omitempty"`
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success bool             `json:"success"`
+	Errors  []string         `json:"errors"`
+	Tenants []storage.Tenant `json:"tenants"`
+}
+
+// CredentialResponse is the response structure for the AssignCredential handler.
+type CredentialResponse struct {
+	Success    bool                `json:"success"`
+	Errors     []string            `json:"errors"`
+	Credential *storage.Credential `json:"credential,omitempty"`
+}
+
+// Create is a HTTP handler function for registering a tenant.
+// It decodes and validates the request body, saves the tenant,
+// and returns a JSON response with the created tenant.
+func Create(log *slog.Logger, tenantSaver TenantSaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tenants.Create"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req CreateRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			handleError(w, r, log, err, "Failed to decode request body")
+			return
+		}
+		if err := request_validator.GetValidator().Struct(req); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+
+		tenant, err := tenantSaver.SaveTenant(req.ID, req.Name)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save tenant")
+			return
+		}
+
+		log.Info("tenant saved", slog.String("tenant", tenant.ID))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, TenantResponse{
+			Success: true,
+			Tenant:  &tenant,
+		})
+	}
+}
+
+// List is a HTTP handler function for listing the tenants.
+func List(log *slog.Logger, tenantsGetter TenantsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tenants.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		tenants, err := tenantsGetter.GetTenants()
+		if err != nil {
+			log.Error("failed to get tenants", Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, ListResponse{
+				Success: false,
+				Errors:  []string{"failed to get tenants"},
+			})
+			return
+		}
+
+		log.Info("tenants retrieved", slog.Int("tenants", len(tenants)))
+		render.JSON(w, r, ListResponse{
+			Success: true,
+			Tenants: tenants,
+		})
+	}
+}
+
+// AssignCredential is a HTTP handler function for assigning a user to the tenant identified by the id URL parameter.
+// It decodes and validates the request body, hashes the password and saves the credential,
+// replacing the tenant and password of an existing user. The user of the operator cannot be assigned.
+// It returns a JSON response with the saved credential.
+func AssignCredential(log *slog.Logger, credentialSaver CredentialSaver, operatorUser string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tenants.AssignCredential"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req CredentialRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			handleError(w, r, log, err, "Failed to decode request body")
+			return
+		}
+		if err := request_validator.GetValidator().Struct(req); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+		if req.Username == operatorUser {
+			handleError(w, r, log, errorReservedUser, "Validation failed")
+			return
+		}
+
+		hash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to hash password")
+			return
+		}
+
+		credential, err := credentialSaver.SaveCredential(req.Username, chi.URLParam(r, "id"), hash)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save credential")
+			return
+		}
+
+		log.Info("credential saved", slog.String("user", credential.Username), slog.String("tenant", credential.Tenant))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, CredentialResponse{
+			Success:    true,
+			Credential: &credential,
+		})
+	}
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorTenantExists) || errors.Is(err, errorReservedUser):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorTenantNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, TenantResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
@@ -0,0 +1,126 @@
+// Package update provides HTTP handlers for updating person information.
+package update
+
+import (
+	"citizen_webservice/internal/http-server/handlers/etag"
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Request is the structure for the request body of the Person handler.
+type Request struct {
+	Name  string `json:"name" validate:"required"`  // Name of the person
+	Phone string `json:"phone" validate:"required"` // Phone number of the person
+}
+
+// PersonUpdater is an interface for updating person information.
+type PersonUpdater interface {
+	UpdatePerson(ctx context.Context, iin string, name string, phone string, expectedVersions ...int64) (int64, error)
+}
+
+// PersonResponse is the response structure for the Person handler.
+type PersonResponse struct {
+	Success bool     `json:"success"`           // Indicates if the operation was successful
+	Errors  []string `json:"errors"`            // List of error messages, if any
+	Version int64    `json:"version,omitempty"` // New version of the record
+}
+
+// Person is a HTTP handler function for updating a person's information.
+// It decodes and validates the request body, updates the person identified by the IIN URL parameter,
+// and returns a JSON response with the new record version as the ETag.
+// If the If-Match header is set, the person is only updated while its version matches one of its entity tags,
+// or while it exists if the header is *, otherwise it responds with 412 Precondition Failed.
+func Person(log *slog.Logger, personUpdater PersonUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.update.Person"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		iin := chi.URLParam(r, "iin")
+		if iin == "" {
+			handleError(w, r, log, errors.New("iin is empty"), "Invalid request")
+			return
+		}
+
+		expectedVersions, err := etag.ExpectedVersions(r)
+		if err != nil {
+			handleError(w, r, log, err, "Precondition failed")
+			return
+		}
+
+		var req Request
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to decode request body")
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+		if err := request_validator.GetValidator().Struct(req); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+
+		version, err := personUpdater.UpdatePerson(r.Context(), iin, req.Name, req.Phone, expectedVersions...)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to update person")
+			return
+		}
+
+		log.Info("person updated", slog.String("id", iin), slog.Int64("version", version))
+		w.Header().Set("ETag", etag.Format(version))
+		render.JSON(w, r, PersonResponse{
+			Success: true,
+			Version: version,
+		})
+	}
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorIINNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorLegalHold):
+		status = http.StatusLocked
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, storage.ErrorPhoneNumberExists):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorVersionMismatch) || errors.Is(err, etag.ErrorInvalidIfMatch):
+		status = http.StatusPreconditionFailed
+	}
+	render.Status(r, status)
+	render.JSON(w, r, PersonResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
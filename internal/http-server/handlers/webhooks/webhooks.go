@@ -0,0 +1,442 @@
+// Package webhooks provides HTTP handlers for managing webhook subscriptions and their deliveries.
+package webhooks
+
+import (
+	"citizen_webservice/internal/http-server/handlers/request_validator"
+	"citizen_webservice/internal/storage"
+	"citizen_webservice/internal/webhooks"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// DefaultLimit and MaxLimit bound the number of deliveries returned by a single request.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+var (
+	errorInvalidID    = errors.New("id must be a positive integer")
+	errorInvalidLimit = fmt.Errorf("limit must be an integer between 1 and %d", MaxLimit)
+)
+
+// CreateRequest is the structure for the request body of the Create handler.
+type CreateRequest struct {
+	URL    string   `json:"url" validate:"required,http_url"`                                    // URL the events are POSTed to
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=created updated deleted"` // Kinds of changes to deliver
+	Secret string   `json:"secret" validate:"omitempty,min=16"`                                  // Signing secret, generated if empty
+}
+
+// UpdateRequest is the structure for the request body of the Update handler.
+type UpdateRequest struct {
+	URL    string   `json:"url" validate:"required,http_url"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=created updated deleted"`
+	Active *bool    `json:"active" validate:"required"` // Paused webhooks receive the changes made meanwhile once active again
+}
+
+// WebhookSaver is an interface for registering webhooks.
+type WebhookSaver interface {
+	SaveWebhook(ctx context.Context, url string, secret string, events []string) (storage.Webhook, error)
+}
+
+// WebhookGetter is an interface for reading webhooks.
+type WebhookGetter interface {
+	GetWebhook(ctx context.Context, id int64) (storage.Webhook, error)
+}
+
+// WebhooksGetter is an interface for listing webhooks.
+type WebhooksGetter interface {
+	GetWebhooks(ctx context.Context) ([]storage.Webhook, error)
+}
+
+// WebhookUpdater is an interface for updating webhooks.
+type WebhookUpdater interface {
+	UpdateWebhook(ctx context.Context, id int64, url string, events []string, active bool) (storage.Webhook, error)
+}
+
+// WebhookDeleter is an interface for deleting webhooks.
+type WebhookDeleter interface {
+	DeleteWebhook(ctx context.Context, id int64) error
+}
+
+// DeliveriesGetter is an interface for reading the delivery history of a webhook.
+type DeliveriesGetter interface {
+	GetWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]storage.WebhookDelivery, error)
+}
+
+// DeadLettersGetter is an interface for reading the dead-letter list.
+type DeadLettersGetter interface {
+	GetDeadWebhookDeliveries(ctx context.Context, limit int) ([]storage.WebhookDelivery, error)
+}
+
+// DeliveryRetrier is an interface for moving dead deliveries back to the queue.
+type DeliveryRetrier interface {
+	RetryWebhookDelivery(ctx context.Context, id int64) error
+}
+
+// WebhookResponse is the response structure for the handlers of a single webhook.
+type WebhookResponse struct {
+	Success bool             `json:"success"`
+	Errors  []string         `json:"errors"`
+	Webhook *storage.Webhook `json:"webhook,omitempty"`
+	Secret  string           `json:"secret,omitempty"` // Only returned by Create
+}
+
+// ListResponse is the response structure for the List handler.
+type ListResponse struct {
+	Success  bool              `json:"success"`
+	Errors   []string          `json:"errors"`
+	Webhooks []storage.Webhook `json:"webhooks"`
+}
+
+// DeliveriesResponse is the response structure for the Deliveries and DeadLetters handlers.
+type DeliveriesResponse struct {
+	Success    bool                      `json:"success"`
+	Errors     []string                  `json:"errors"`
+	Deliveries []storage.WebhookDelivery `json:"deliveries"`
+}
+
+// Create is a HTTP handler function for registering a webhook.
+// It decodes and validates the request body, rejecting URLs of loopback and private addresses
+// unless they are allowed, see webhooks.CheckURL, generates a signing secret unless one is given,
+// and returns a JSON response with the webhook and its secret.
+func Create(log *slog.Logger, webhookSaver WebhookSaver, allowPrivate bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.Create"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req CreateRequest
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to decode request body")
+			return
+		}
+
+		log.Info("request body decoded", slog.String("url", req.URL), slog.Any("events", req.Events))
+		if err := request_validator.GetValidator().Struct(req); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+		if err := webhooks.CheckURL(req.URL, allowPrivate); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+
+		if req.Secret == "" {
+			req.Secret, err = webhooks.NewSecret()
+			if err != nil {
+				handleError(w, r, log, err, "Failed to generate secret")
+				return
+			}
+		}
+
+		webhook, err := webhookSaver.SaveWebhook(r.Context(), req.URL, req.Secret, req.Events)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to save webhook")
+			return
+		}
+
+		log.Info("webhook saved", slog.Int64("id", webhook.ID))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, WebhookResponse{
+			Success: true,
+			Webhook: &webhook,
+			Secret:  webhook.Secret,
+		})
+	}
+}
+
+// List is a HTTP handler function for listing the registered webhooks.
+func List(log *slog.Logger, webhooksGetter WebhooksGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		list, err := webhooksGetter.GetWebhooks(r.Context())
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get webhooks")
+			return
+		}
+
+		log.Info("webhooks retrieved", slog.Int("webhooks", len(list)))
+		render.JSON(w, r, ListResponse{
+			Success:  true,
+			Webhooks: list,
+		})
+	}
+}
+
+// Get is a HTTP handler function for reading the webhook identified by the id URL parameter.
+func Get(log *slog.Logger, webhookGetter WebhookGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.Get"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := parseID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		webhook, err := webhookGetter.GetWebhook(r.Context(), id)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get webhook")
+			return
+		}
+
+		log.Info("webhook retrieved", slog.Int64("id", id))
+		render.JSON(w, r, WebhookResponse{
+			Success: true,
+			Webhook: &webhook,
+		})
+	}
+}
+
+// Update is a HTTP handler function for replacing the URL, the kinds of changes and the state
+// of the webhook identified by the id URL parameter. The URL is checked as by Create.
+func Update(log *slog.Logger, webhookUpdater WebhookUpdater, allowPrivate bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.Update"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := parseID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		var req UpdateRequest
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to decode request body")
+			return
+		}
+
+		if err := request_validator.GetValidator().Struct(req); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+		if err := webhooks.CheckURL(req.URL, allowPrivate); err != nil {
+			handleError(w, r, log, err, "Validation failed")
+			return
+		}
+
+		webhook, err := webhookUpdater.UpdateWebhook(r.Context(), id, req.URL, req.Events, *req.Active)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to update webhook")
+			return
+		}
+
+		log.Info("webhook updated", slog.Int64("id", id), slog.Bool("active", webhook.Active))
+		render.JSON(w, r, WebhookResponse{
+			Success: true,
+			Webhook: &webhook,
+		})
+	}
+}
+
+// Delete is a HTTP handler function for deleting the webhook identified by the id URL parameter
+// along with its delivery history.
+func Delete(log *slog.Logger, webhookDeleter WebhookDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := parseID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		if err = webhookDeleter.DeleteWebhook(r.Context(), id); err != nil {
+			handleError(w, r, log, err, "Failed to delete webhook")
+			return
+		}
+
+		log.Info("webhook deleted", slog.Int64("id", id))
+		render.JSON(w, r, WebhookResponse{
+			Success: true,
+		})
+	}
+}
+
+// Deliveries is a HTTP handler function for the delivery history of the webhook identified
+// by the id URL parameter, most recent first, limited by the optional limit query parameter.
+func Deliveries(log *slog.Logger, deliveriesGetter DeliveriesGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.Deliveries"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := parseID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+		limit, err := parseLimit(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		deliveries, err := deliveriesGetter.GetWebhookDeliveries(r.Context(), id, limit)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get deliveries")
+			return
+		}
+
+		log.Info("deliveries retrieved", slog.Int64("id", id), slog.Int("deliveries", len(deliveries)))
+		render.JSON(w, r, DeliveriesResponse{
+			Success:    true,
+			Deliveries: deliveries,
+		})
+	}
+}
+
+// DeadLetters is a HTTP handler function for the dead-letter list, the deliveries every attempt of which failed,
+// most recent first, limited by the optional limit query parameter.
+func DeadLetters(log *slog.Logger, deadLettersGetter DeadLettersGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.DeadLetters"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		limit, err := parseLimit(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		deliveries, err := deadLettersGetter.GetDeadWebhookDeliveries(r.Context(), limit)
+		if err != nil {
+			handleError(w, r, log, err, "Failed to get dead letters")
+			return
+		}
+
+		log.Info("dead letters retrieved", slog.Int("deliveries", len(deliveries)))
+		render.JSON(w, r, DeliveriesResponse{
+			Success:    true,
+			Deliveries: deliveries,
+		})
+	}
+}
+
+// Retry is a HTTP handler function for moving the dead delivery identified by the id URL parameter
+// back to the queue with a fresh set of attempts.
+func Retry(log *slog.Logger, deliveryRetrier DeliveryRetrier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhooks.Retry"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := parseID(r)
+		if err != nil {
+			handleError(w, r, log, err, "Invalid request")
+			return
+		}
+
+		if err = deliveryRetrier.RetryWebhookDelivery(r.Context(), id); err != nil {
+			handleError(w, r, log, err, "Failed to retry delivery")
+			return
+		}
+
+		log.Info("delivery queued for retry", slog.Int64("id", id))
+		render.JSON(w, r, WebhookResponse{
+			Success: true,
+		})
+	}
+}
+
+// parseID is a helper function to read the id URL parameter.
+func parseID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errorInvalidID
+	}
+	return id, nil
+}
+
+// parseLimit is a helper function to read the optional limit query parameter.
+func parseLimit(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return DefaultLimit, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > MaxLimit {
+		return 0, errorInvalidLimit
+	}
+	return limit, nil
+}
+
+// handleError is a helper function to handle errors.
+// It logs the error, determines the appropriate HTTP status code,
+// and sends a JSON response with the error message.
+func handleError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, message string) {
+	log.Error(message, Err(err))
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, io.EOF) || request_validator.CheckErrorIsValidation(err) ||
+		errors.Is(err, errorInvalidID) || errors.Is(err, errorInvalidLimit) || errors.Is(err, webhooks.ErrorURLNotAllowed):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrorWebhookNotFound) || errors.Is(err, storage.ErrorDeliveryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrorDeliveryNotDead):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrorWriteQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	render.Status(r, status)
+	render.JSON(w, r, WebhookResponse{
+		Success: false,
+		Errors:  []string{fmt.Sprintf("%s: %s", message, err.Error())},
+	})
+}
+
+// Err is a helper function to create a structured log attribute for errors.
+func Err(err error) slog.Attr {
+	return slog.Attr{
+		Key:   "error",
+		Value: slog.StringValue(err.Error()),
+	}
+}
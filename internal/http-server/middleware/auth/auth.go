@@ -0,0 +1,212 @@
+// Package auth provides a middleware authenticating the clients and scoping their requests to their tenant.
+package auth
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultCacheTTL is how long a verified password is remembered when the options leave it unset.
+const DefaultCacheTTL = time.Minute
+
+// CredentialGetter is an interface for reading the credentials of the tenants.
+type CredentialGetter interface {
+	GetCredential(username string) (storage.Credential, error)
+}
+
+// TenantsGetter is an interface for listing the tenants.
+type TenantsGetter interface {
+	GetTenants() ([]storage.Tenant, error)
+}
+
+// Options struct holds the authentication settings.
+type Options struct {
+	Realm            string        // Realm of the basic authentication challenge
+	OperatorUser     string        // User of the operator, who belongs to the default tenant and manages the tenants
+	OperatorPassword string        // Password of the operator
+	CacheTTL         time.Duration // How long a verified password is remembered, sparing a bcrypt comparison per request
+}
+
+// operatorKey is the context key marking the requests of the operator.
+type operatorKey struct{}
+
+// verified struct is a password remembered after a successful bcrypt comparison.
+type verified struct {
+	tenant    string
+	sum       [sha256.Size]byte
+	expiresAt time.Time
+}
+
+// authenticator struct checks the basic authentication credentials of the requests.
+type authenticator struct {
+	credentialGetter CredentialGetter
+	opts             Options
+
+	mu       sync.Mutex
+	verified map[string]verified
+}
+
+// New is a function that creates a new authentication middleware.
+// The middleware function checks the basic authentication credentials of every request against the operator
+// configured in the options and the credentials stored for the tenants, and adds the tenant of the client
+// to the request context with storage.WithTenant. Requests without valid credentials get a 401 response.
+// A changed password may keep working for the cache TTL on the replicas that have verified the previous one.
+func New(log *slog.Logger, credentialGetter CredentialGetter, opts Options) func(next http.Handler) http.Handler {
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = DefaultCacheTTL
+	}
+	a := &authenticator{
+		credentialGetter: credentialGetter,
+		opts:             opts,
+		verified:         map[string]verified{},
+	}
+
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok {
+				a.unauthorized(w)
+				return
+			}
+
+			if a.isOperator(username, password) {
+				ctx := context.WithValue(r.Context(), operatorKey{}, true)
+				next.ServeHTTP(w, r.WithContext(storage.WithTenant(ctx, storage.DefaultTenant)))
+				return
+			}
+
+			tenant, err := a.authenticate(username, password, time.Now())
+			if err != nil {
+				if errors.Is(err, storage.ErrorCredentialNotFound) || errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+					a.unauthorized(w)
+					return
+				}
+				log.Error("failed to authenticate", slog.String("user", username), slog.String("error", err.Error()))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(storage.WithTenant(r.Context(), tenant)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireOperator is a function that creates a middleware letting only the requests of the operator through.
+// The other clients get a 403 response. It must be used after the middleware created by New.
+func RequireOperator() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !IsOperator(r.Context()) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ActAsTenant is a function that creates a middleware scoping the requests of the operator to the tenant
+// named by the URL parameter instead of the default one, so that they read and write its data.
+// Unregistered tenants get a 404 response. It must be used after the middleware created by RequireOperator.
+func ActAsTenant(log *slog.Logger, tenantsGetter TenantsGetter, param string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			tenant := chi.URLParam(r, param)
+			tenants, err := tenantsGetter.GetTenants()
+			if err != nil {
+				log.Error("failed to get tenants", slog.String("error", err.Error()))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			for _, registered := range tenants {
+				if registered.ID == tenant {
+					next.ServeHTTP(w, r.WithContext(storage.WithTenant(r.Context(), tenant)))
+					return
+				}
+			}
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// IsOperator reports whether the context is the one of a request authenticated as the operator.
+func IsOperator(ctx context.Context) bool {
+	operator, _ := ctx.Value(operatorKey{}).(bool)
+	return operator
+}
+
+// HashPassword is a function that hashes a password to be stored with a credential.
+// It returns the bcrypt hash or an error.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("auth.HashPassword: %w", err)
+	}
+	return string(hash), nil
+}
+
+// isOperator method reports whether the credentials are the ones of the operator, in constant time.
+func (a *authenticator) isOperator(username string, password string) bool {
+	userMatch := subtle.ConstantTimeCompare([]byte(username), []byte(a.opts.OperatorUser))
+	passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(a.opts.OperatorPassword))
+	return userMatch&passwordMatch == 1
+}
+
+// authenticate method checks the password of the user against the stored credential,
+// or against the remembered password if it was verified within the cache TTL.
+// It returns the tenant of the user or an error.
+func (a *authenticator) authenticate(username string, password string, now time.Time) (string, error) {
+	sum := sha256.Sum256([]byte(password))
+
+	a.mu.Lock()
+	v, ok := a.verified[username]
+	a.mu.Unlock()
+	if ok && now.Before(v.expiresAt) && subtle.ConstantTimeCompare(sum[:], v.sum[:]) == 1 {
+		return v.tenant, nil
+	}
+
+	credential, err := a.credentialGetter.GetCredential(username)
+	if err != nil {
+		return "", err
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(credential.PasswordHash), []byte(password)); err != nil {
+		return "", err
+	}
+
+	a.mu.Lock()
+	a.verified[username] = verified{tenant: credential.Tenant, sum: sum, expiresAt: now.Add(a.opts.CacheTTL)}
+	a.mu.Unlock()
+
+	return credential.Tenant, nil
+}
+
+// unauthorized method asks the client for credentials.
+func (a *authenticator) unauthorized(w http.ResponseWriter) {
+	w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, a.opts.Realm))
+	w.WriteHeader(http.StatusUnauthorized)
+}
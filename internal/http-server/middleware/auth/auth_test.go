@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"citizen_webservice/internal/storage"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCredentials is an in-memory CredentialGetter counting the lookups that reach it.
+type fakeCredentials struct {
+	credentials map[string]storage.Credential
+	lookups     atomic.Int64
+}
+
+func (f *fakeCredentials) GetCredential(username string) (storage.Credential, error) {
+	f.lookups.Add(1)
+	credential, ok := f.credentials[username]
+	if !ok {
+		return storage.Credential{}, storage.ErrorCredentialNotFound
+	}
+	return credential, nil
+}
+
+// fakeTenants is an in-memory TenantsGetter.
+type fakeTenants struct {
+	tenants []storage.Tenant
+	err     error
+}
+
+func (f *fakeTenants) GetTenants() ([]storage.Tenant, error) {
+	return f.tenants, f.err
+}
+
+func TestAuthentication(t *testing.T) {
+	hash, err := HashPassword("clinic-password")
+	require.NoError(t, err)
+	credentials := &fakeCredentials{credentials: map[string]storage.Credential{
+		"clinic": {Username: "clinic", Tenant: "health", PasswordHash: hash},
+	}}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := New(log, credentials, Options{Realm: "test", OperatorUser: "user", OperatorPassword: "password"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, storage.TenantID(r.Context()))
+		}))
+	operatorOnly := New(log, credentials, Options{OperatorUser: "user", OperatorPassword: "password"})(
+		RequireOperator()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	tests := []struct {
+		name         string
+		user         string
+		password     string
+		wantStatus   int
+		wantTenant   string
+		wantOperator int
+	}{
+		{name: "operator", user: "user", password: "password", wantStatus: http.StatusOK, wantTenant: storage.DefaultTenant, wantOperator: http.StatusOK},
+		{name: "tenant user", user: "clinic", password: "clinic-password", wantStatus: http.StatusOK, wantTenant: "health", wantOperator: http.StatusForbidden},
+		{name: "wrong password", user: "clinic", password: "password", wantStatus: http.StatusUnauthorized, wantOperator: http.StatusUnauthorized},
+		{name: "unknown user", user: "unknown", password: "password", wantStatus: http.StatusUnauthorized, wantOperator: http.StatusUnauthorized},
+		{name: "no credentials", wantStatus: http.StatusUnauthorized, wantOperator: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantTenant, w.Body.String())
+			} else {
+				assert.Equal(t, `Basic realm="test"`, w.Header().Get("WWW-Authenticate"))
+			}
+
+			w = httptest.NewRecorder()
+			operatorOnly.ServeHTTP(w, r)
+			assert.Equal(t, tt.wantOperator, w.Code)
+		})
+	}
+}
+
+func TestVerifiedPasswordsAreRemembered(t *testing.T) {
+	hash, err := HashPassword("clinic-password")
+	require.NoError(t, err)
+	credentials := &fakeCredentials{credentials: map[string]storage.Credential{
+		"clinic": {Username: "clinic", Tenant: "health", PasswordHash: hash},
+	}}
+	a := &authenticator{credentialGetter: credentials, opts: Options{CacheTTL: time.Minute}, verified: map[string]verified{}}
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		tenant, err := a.authenticate("clinic", "clinic-password", now)
+		require.NoError(t, err)
+		assert.Equal(t, "health", tenant)
+	}
+	assert.Equal(t, int64(1), credentials.lookups.Load())
+
+	// Another password is checked against the storage
+	_, err = a.authenticate("clinic", "other-password", now)
+	assert.Error(t, err)
+	assert.Equal(t, int64(2), credentials.lookups.Load())
+
+	_, err = a.authenticate("clinic", "clinic-password", now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), credentials.lookups.Load())
+}
+
+func TestActAsTenant(t *testing.T) {
+	tenants := &fakeTenants{tenants: []storage.Tenant{{ID: storage.DefaultTenant}, {ID: "health"}}}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	router := chi.NewRouter()
+	router.Use(New(log, &fakeCredentials{}, Options{OperatorUser: "user", OperatorPassword: "password"}))
+	router.With(RequireOperator(), ActAsTenant(log, tenants, "tenant")).
+		Get("/tenants/{tenant}", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, storage.TenantID(r.Context()))
+		})
+
+	tests := []struct {
+		name       string
+		path       string
+		err        error
+		wantStatus int
+		wantTenant string
+	}{
+		{name: "other tenant", path: "/tenants/health", wantStatus: http.StatusOK, wantTenant: "health"},
+		{name: "default tenant", path: "/tenants/default", wantStatus: http.StatusOK, wantTenant: storage.DefaultTenant},
+		{name: "unregistered tenant", path: "/tenants/police", wantStatus: http.StatusNotFound},
+		{name: "storage failure", path: "/tenants/health", err: errors.New("disk I/O error"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenants.err = tt.err
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.SetBasicAuth("user", "password")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantTenant, w.Body.String())
+			}
+		})
+	}
+}
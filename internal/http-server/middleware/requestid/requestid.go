@@ -0,0 +1,26 @@
+// Package requestid provides a middleware passing the request ID on to the storage.
+package requestid
+
+import (
+	"citizen_webservice/internal/storage"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// New is a function that creates a new request ID middleware.
+// The middleware function adds the request ID set by middleware.RequestID to the request context
+// with storage.WithRequestID, so that it is recorded with the change events of the request.
+// It must be used after middleware.RequestID.
+func New() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if requestID := middleware.GetReqID(r.Context()); requestID != "" {
+				r = r.WithContext(storage.WithRequestID(r.Context(), requestID))
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
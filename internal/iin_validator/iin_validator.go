@@ -47,16 +47,10 @@ func ValidateIIN(iin string) error {
 		return fmt.Errorf("invalid 7th digit: %w", err)
 	}
 
-	twelfthDigit, err := calculate12thDigit(iin, Algorithm1)
+	twelfthDigit, err := CheckDigit(iin)
 	if err != nil {
 		return fmt.Errorf("error while validating 12th digit: %w", err)
 	}
-	if twelfthDigit == TwelfthDigitForSecondAlgorithm {
-		twelfthDigit, err = calculate12thDigit(iin, Algorithm2)
-		if err != nil {
-			return fmt.Errorf("error while validating 12th digit: %w", err)
-		}
-	}
 	if twelfthDigit != int(iin[11]-'0') {
 		return fmt.Errorf("invalid 12th digit")
 	}
@@ -97,6 +91,21 @@ func GetDateOfBirth(iin string) (time.Time, error) {
 	return date, nil
 }
 
+// GetAge calculates the age in full years, on the given day, of the person the IIN belongs to.
+// People born on the 29th of February come of age on the 1st of March in common years.
+func GetAge(iin string, on time.Time) (int, error) {
+	dateOfBirth, err := GetDateOfBirth(iin)
+	if err != nil {
+		return 0, err
+	}
+
+	age := on.Year() - dateOfBirth.Year()
+	if on.Month() < dateOfBirth.Month() || (on.Month() == dateOfBirth.Month() && on.Day() < dateOfBirth.Day()) {
+		age--
+	}
+	return age, nil
+}
+
 // getCenturyOfBirth determines the century of birth from the 7th digit of the IIN.
 func getCenturyOfBirth(digit int) (int, error) {
 	if err := validateSeventhDigit(digit); err != nil {
@@ -121,6 +130,17 @@ func validateSeventhDigit(digit int) error {
 	return nil
 }
 
+// CheckDigit calculates the 12th digit of a 12-digit number from its first 11 digits, using the second algorithm
+// if the first one yields 10. The same scheme protects IINs and BINs. A result of 10 means that no number
+// starting with these digits is valid.
+func CheckDigit(number string) (int, error) {
+	digit, err := calculate12thDigit(number, Algorithm1)
+	if err != nil || digit != TwelfthDigitForSecondAlgorithm {
+		return digit, err
+	}
+	return calculate12thDigit(number, Algorithm2)
+}
+
 // calculate12thDigit calculates the 12th digit of the IIN using the specified algorithm.
 func calculate12thDigit(iin string, algorithm int) (int, error) {
 	if len(iin) < 11 {
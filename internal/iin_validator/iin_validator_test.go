@@ -80,6 +80,51 @@ func TestGetDateOfBirth(t *testing.T) {
 	}
 }
 
+func TestGetAge(t *testing.T) {
+	testCases := []struct {
+		name     string
+		iin      string
+		on       time.Time
+		expected int
+	}{
+		{
+			name:     "Test Case 1: Day before the birthday",
+			iin:      "830218350074",
+			on:       time.Date(2001, 2, 17, 0, 0, 0, 0, time.UTC),
+			expected: 17,
+		},
+		{
+			name:     "Test Case 2: Birthday",
+			iin:      "830218350074",
+			on:       time.Date(2001, 2, 18, 0, 0, 0, 0, time.UTC),
+			expected: 18,
+		},
+		{
+			name:     "Test Case 3: Later in the month of the birthday",
+			iin:      "830218350074",
+			on:       time.Date(2001, 2, 28, 0, 0, 0, 0, time.UTC),
+			expected: 18,
+		},
+		{
+			name:     "Test Case 4: Earlier month",
+			iin:      "830218350074",
+			on:       time.Date(2001, 1, 30, 0, 0, 0, 0, time.UTC),
+			expected: 17,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := GetAge(tc.iin, tc.on)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+
+	_, err := GetAge("12345", time.Now())
+	assert.Error(t, err)
+}
+
 func TestGetCenturyOfBirth(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -202,3 +247,41 @@ func TestCalculate12thDigit(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckDigit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		number   string
+		expected int
+		err      error
+	}{
+		{
+			name:     "Test Case 1: Algorithm 1",
+			number:   "830218350074",
+			expected: 4,
+		},
+		{
+			name:     "Test Case 2: Falls back to Algorithm 2",
+			number:   "600426400918",
+			expected: 8,
+		},
+		{
+			name:     "Test Case 3: BIN",
+			number:   "040840001231",
+			expected: 1,
+		},
+		{
+			name:   "Test Case 4: Too short",
+			number: "12345",
+			err:    fmt.Errorf("iin string too short"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := CheckDigit(tc.number)
+			assert.Equal(t, tc.expected, result)
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Package kato provides the validation of the codes of the Kazakhstan classifier of administrative-territorial
+// objects (KATO) against an embedded reference table of its regions.
+//
+// A KATO code has nine digits, the first two of which identify the region, an oblast or a city
+// of republican significance. The code of a region itself is those two digits followed by zeros.
+package kato
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CodeLength is the number of digits of a KATO code.
+const CodeLength = 9
+
+var (
+	ErrorInvalidCode   = errors.New("KATO code must be 9 digits")
+	ErrorUnknownRegion = errors.New("unknown KATO region")
+)
+
+// Region is a top level unit of the classifier.
+type Region struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+//go:embed regions.csv
+var regionsCSV []byte
+
+// regions holds the reference table by code, loaded once at startup.
+var regions = mustLoad(regionsCSV)
+
+// Validate function checks that the code has the KATO format and lies in a known region.
+// It returns the region of the code or an error, ErrorInvalidCode or ErrorUnknownRegion.
+func Validate(code string) (Region, error) {
+	if len(code) != CodeLength || strings.Trim(code, "0123456789") != "" {
+		return Region{}, fmt.Errorf("%w: %q", ErrorInvalidCode, code)
+	}
+
+	region, ok := regions[RegionCode(code)]
+	if !ok {
+		return Region{}, fmt.Errorf("%w: %s", ErrorUnknownRegion, code[:2])
+	}
+	return region, nil
+}
+
+// LookupRegion function returns the region with the given code, which must be the code of the region itself.
+func LookupRegion(code string) (Region, bool) {
+	region, ok := regions[code]
+	return region, ok
+}
+
+// RegionCode function returns the code of the region of a code of the KATO format.
+func RegionCode(code string) string {
+	return code[:2] + strings.Repeat("0", CodeLength-2)
+}
+
+// Regions function returns every region of the reference table ordered by code.
+func Regions() []Region {
+	list := make([]Region, 0, len(regions))
+	for _, region := range regions {
+		list = append(list, region)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// mustLoad function parses the reference table, a CSV file with a header and code and name columns.
+// It panics on a malformed table, as the table is embedded in the binary.
+func mustLoad(data []byte) map[string]Region {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("kato: reading the reference table: %v", err))
+	}
+
+	table := make(map[string]Region, len(records))
+	for _, record := range records[1:] {
+		code := record[0]
+		if len(code) != CodeLength || code != RegionCode(code) {
+			panic(fmt.Sprintf("kato: %q is not the code of a region", code))
+		}
+		table[code] = Region{Code: code, Name: record[1]}
+	}
+	return table
+}
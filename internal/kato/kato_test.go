@@ -0,0 +1,74 @@
+package kato
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		code   string
+		region string
+		err    error
+	}{
+		{
+			name:   "Test Case 1: City of republican significance",
+			code:   "751110000",
+			region: "Almaty",
+		},
+		{
+			name:   "Test Case 2: Region itself",
+			code:   "350000000",
+			region: "Karaganda Region",
+		},
+		{
+			name: "Test Case 3: Too short",
+			code: "75111000",
+			err:  ErrorInvalidCode,
+		},
+		{
+			name: "Test Case 4: Not digits",
+			code: "75111000A",
+			err:  ErrorInvalidCode,
+		},
+		{
+			name: "Test Case 5: Unknown region",
+			code: "990000000",
+			err:  ErrorUnknownRegion,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			region, err := Validate(tc.code)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.region, region.Name)
+			assert.Equal(t, RegionCode(tc.code), region.Code)
+		})
+	}
+}
+
+func TestLookupRegion(t *testing.T) {
+	region, ok := LookupRegion("710000000")
+	assert.True(t, ok)
+	assert.Equal(t, "Astana", region.Name)
+
+	_, ok = LookupRegion("711110000")
+	assert.False(t, ok)
+	_, ok = LookupRegion("990000000")
+	assert.False(t, ok)
+}
+
+func TestRegions(t *testing.T) {
+	regions := Regions()
+	require.Len(t, regions, 20)
+	assert.Equal(t, "100000000", regions[0].Code)
+	assert.Equal(t, "790000000", regions[len(regions)-1].Code)
+}
@@ -0,0 +1,227 @@
+// Package natspub publishes change events from the outbox to NATS subjects.
+package natspub
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Defaults of the publisher used when the options leave them unset.
+const (
+	DefaultConsumer     = "nats"
+	DefaultPollInterval = time.Second
+	DefaultTimeout      = 5 * time.Second
+	DefaultBatchSize    = 100
+)
+
+// Store is the outbox of change events of every tenant and the cursor of the publisher.
+type Store interface {
+	GetOutboxEvents(after int64, limit int) ([]storage.Event, error)
+	GetOutboxCursor(consumer string) (int64, error)
+	SaveOutboxCursor(consumer string, seq int64) error
+}
+
+// Subjects struct holds the subject every kind of change is published to.
+// Changes with an empty subject are not published.
+type Subjects struct {
+	Created string
+	Updated string
+	Deleted string
+}
+
+// Options struct holds the publisher settings.
+type Options struct {
+	Consumer     string        // Name of the outbox cursor of the publisher
+	Subjects     Subjects      // Subjects the events are published to
+	JetStream    bool          // Whether every message waits for the acknowledgement of a JetStream stream
+	PollInterval time.Duration // How often new events are looked up
+	Timeout      time.Duration // How long a batch of messages waits for the server
+	BatchSize    int           // Maximum number of events published per confirmation
+}
+
+// Message is the body of a published change event.
+type Message struct {
+	Seq       int64     `json:"seq"`
+	Tenant    string    `json:"tenant"`
+	Type      string    `json:"type"`
+	IIN       string    `json:"iin"`
+	RequestID string    `json:"request_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Publisher struct publishes the change events of the outbox in sequence order.
+// The cursor is only moved after the server confirms the messages, so every event is published at least once;
+// a message may be published again after a failure and carries the sequence number in the Nats-Msg-Id header
+// for JetStream and consumers to drop duplicates.
+type Publisher struct {
+	log   *slog.Logger
+	conn  *nats.Conn
+	js    jetstream.JetStream
+	store Store
+	opts  Options
+}
+
+// New function creates a publisher using the connection.
+// It returns a pointer to a Publisher struct or an error.
+func New(log *slog.Logger, conn *nats.Conn, store Store, opts Options) (*Publisher, error) {
+	const op = "natspub.New"
+
+	if opts.Consumer == "" {
+		opts.Consumer = DefaultConsumer
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	p := &Publisher{
+		log:   log.With(slog.String("op", "natspub.Publisher")),
+		conn:  conn,
+		store: store,
+		opts:  opts,
+	}
+
+	if opts.JetStream {
+		js, err := jetstream.New(conn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		p.js = js
+	}
+
+	return p, nil
+}
+
+// Run method publishes new events until the context is cancelled.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.RunOnce(ctx); err != nil {
+			p.log.Error("failed to publish events", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce method publishes every event after the cursor, one batch at a time,
+// and moves the cursor after every confirmed batch.
+// It returns an error if the storage or the server fails, the unconfirmed events are published again on the next run.
+func (p *Publisher) RunOnce(ctx context.Context) error {
+	const op = "natspub.Publisher.RunOnce"
+
+	after, err := p.store.GetOutboxCursor(p.opts.Consumer)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for ctx.Err() == nil {
+		events, err := p.store.GetOutboxEvents(after, p.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		published, err := p.publish(ctx, events)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		after = events[len(events)-1].OutboxSeq
+		if err = p.store.SaveOutboxCursor(p.opts.Consumer, after); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		p.log.Debug("events published", slog.Int("published", published), slog.Int64("seq", after))
+
+		if len(events) < p.opts.BatchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// publish method publishes the events with a subject and waits until the server confirms them.
+// It returns the number of published events or an error.
+func (p *Publisher) publish(ctx context.Context, events []storage.Event) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	published := 0
+	for _, event := range events {
+		subject := p.subject(event.Type)
+		if subject == "" {
+			continue
+		}
+
+		data, err := json.Marshal(Message{
+			Seq:       event.Seq,
+			Tenant:    event.Tenant,
+			Type:      event.Type,
+			IIN:       event.IIN,
+			RequestID: event.RequestID,
+			Timestamp: event.CreatedAt,
+		})
+		if err != nil {
+			return published, err
+		}
+
+		msg := nats.NewMsg(subject)
+		msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(event.OutboxSeq, 10))
+		msg.Data = data
+
+		if p.js != nil {
+			_, err = p.js.PublishMsg(ctx, msg)
+		} else {
+			err = p.conn.PublishMsg(msg)
+		}
+		if err != nil {
+			return published, fmt.Errorf("event %d: %w", event.OutboxSeq, err)
+		}
+		published++
+	}
+
+	if p.js == nil && published > 0 {
+		// Core NATS publishes are buffered, the flush returns once the server has processed them
+		if err := p.conn.FlushWithContext(ctx); err != nil {
+			return published, err
+		}
+	}
+
+	return published, nil
+}
+
+// subject method returns the subject of the kind of change, empty if it is not published.
+func (p *Publisher) subject(eventType string) string {
+	switch eventType {
+	case storage.EventPersonCreated:
+		return p.opts.Subjects.Created
+	case storage.EventPersonUpdated:
+		return p.opts.Subjects.Updated
+	case storage.EventPersonDeleted:
+		return p.opts.Subjects.Deleted
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,169 @@
+package natspub
+
+import (
+	"citizen_webservice/internal/storage"
+	"citizen_webservice/internal/storage/sqlite"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+var subjects = Subjects{Created: "people.created", Deleted: "people.deleted"}
+
+// newTestServer starts an embedded NATS server with JetStream enabled and connects to it.
+func newTestServer(t *testing.T) *nats.Conn {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		NoLog:     true,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	require.NoError(t, err)
+	go ns.Start()
+	t.Cleanup(ns.Shutdown)
+	require.True(t, ns.ReadyForConnections(5*time.Second), "NATS server is not ready")
+
+	conn, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func newTestStorage(t *testing.T) *sqlite.Storage {
+	t.Helper()
+
+	s, err := sqlite.New(filepath.Join(t.TempDir(), "test.db"), sqlite.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// writePeople creates, updates and deletes a person, then creates another one.
+func writePeople(t *testing.T, s *sqlite.Storage) {
+	t.Helper()
+
+	operator := storage.WithTenant(context.Background(), storage.DefaultTenant)
+	ctx := storage.WithRequestID(operator, "host/abc-000001")
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+	_, err := s.UpdatePerson(ctx, "830218350074", "New Name", "+77010000001", 0)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePersonByIIN(ctx, "830218350074", 0))
+	require.NoError(t, s.SavePerson(operator, "980301450725", "Test Name", "+77010000002"))
+}
+
+func TestPublishesConfiguredEvents(t *testing.T) {
+	conn := newTestServer(t)
+	s := newTestStorage(t)
+
+	messages := make(chan *nats.Msg, 10)
+	sub, err := conn.ChanSubscribe("people.>", messages)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	require.NoError(t, conn.Flush())
+
+	writePeople(t, s)
+
+	publisher, err := New(discard, conn, s, Options{Subjects: subjects, BatchSize: 2})
+	require.NoError(t, err)
+	require.NoError(t, publisher.RunOnce(context.Background()))
+
+	// Updates have no subject and are skipped
+	for _, want := range []struct {
+		subject   string
+		seq       int64
+		eventType string
+		iin       string
+		requestID string
+	}{
+		{"people.created", 1, storage.EventPersonCreated, "830218350074", "host/abc-000001"},
+		{"people.deleted", 3, storage.EventPersonDeleted, "830218350074", "host/abc-000001"},
+		{"people.created", 4, storage.EventPersonCreated, "980301450725", ""},
+	} {
+		select {
+		case msg := <-messages:
+			assert.Equal(t, want.subject, msg.Subject)
+
+			var message Message
+			require.NoError(t, json.Unmarshal(msg.Data, &message))
+			assert.Equal(t, want.seq, message.Seq)
+			assert.Equal(t, want.eventType, message.Type)
+			assert.Equal(t, want.iin, message.IIN)
+			assert.Equal(t, storage.DefaultTenant, message.Tenant)
+			assert.Equal(t, want.requestID, message.RequestID)
+			assert.False(t, message.Timestamp.IsZero())
+			assert.Equal(t, strconv.FormatInt(want.seq, 10), msg.Header.Get(nats.MsgIdHdr))
+		case <-time.After(time.Second):
+			t.Fatalf("message %d was not published", want.seq)
+		}
+	}
+
+	seq, err := s.GetOutboxCursor(DefaultConsumer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), seq)
+
+	// Nothing is published twice
+	require.NoError(t, publisher.RunOnce(context.Background()))
+	select {
+	case msg := <-messages:
+		t.Fatalf("unexpected message on %s", msg.Subject)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestJetStreamCursorWaitsForAcknowledgements(t *testing.T) {
+	conn := newTestServer(t)
+	s := newTestStorage(t)
+	writePeople(t, s)
+
+	publisher, err := New(discard, conn, s, Options{Subjects: subjects, JetStream: true, Timeout: time.Second})
+	require.NoError(t, err)
+
+	// No stream stores the subjects yet, so nothing is acknowledged and the cursor stays
+	require.Error(t, publisher.RunOnce(context.Background()))
+	seq, err := s.GetOutboxCursor(DefaultConsumer)
+	require.NoError(t, err)
+	assert.Zero(t, seq)
+
+	js, err := jetstream.New(conn)
+	require.NoError(t, err)
+	stream, err := js.CreateStream(context.Background(), jetstream.StreamConfig{
+		Name:     "PEOPLE",
+		Subjects: []string{"people.>"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, publisher.RunOnce(context.Background()))
+	seq, err = s.GetOutboxCursor(DefaultConsumer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), seq)
+
+	info, err := stream.Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), info.State.Msgs)
+
+	// Messages published again after a failure are dropped as duplicates
+	again, err := New(discard, conn, s, Options{Consumer: "again", Subjects: subjects, JetStream: true})
+	require.NoError(t, err)
+	require.NoError(t, again.RunOnce(context.Background()))
+
+	info, err = stream.Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), info.State.Msgs)
+}
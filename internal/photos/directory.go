@@ -0,0 +1,268 @@
+package photos
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Names of the files of a photo, each prefixed with the IIN of the person.
+// The description is written last and removed first, so a photo only exists while it is complete.
+const (
+	imageSuffix       = ".image"
+	thumbnailSuffix   = ".thumbnail.jpg"
+	descriptionSuffix = ".json"
+)
+
+// Holds is the storage of the legal holds the photos of a Directory are only changed outside of.
+type Holds interface {
+	// WithoutHold runs the change unless the person stored under the IIN in the tenant of the context
+	// is under legal hold, reporting storage.ErrorLegalHold if they are, and places no hold on them until it returns.
+	WithoutHold(ctx context.Context, iin string, change func() error) error
+}
+
+// Directory struct stores photos as files in a local directory, in a subdirectory per tenant.
+type Directory struct {
+	root  string
+	holds Holds
+}
+
+// NewDirectory function creates a store of photos in the directory, creating it if it does not exist,
+// whose photos are only saved and deleted for people who are not under legal hold in the holds.
+// It returns a pointer to a Directory struct or an error.
+func NewDirectory(root string, holds Holds) (*Directory, error) {
+	const op = "photos.NewDirectory"
+
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Directory{root: root, holds: holds}, nil
+}
+
+// SavePhoto method stores the photo of a person in the tenant of the context with its image and thumbnail,
+// replacing the previous one.
+// It returns the stored Photo struct or an error, storage.ErrorLegalHold if the person is under legal hold.
+func (d *Directory) SavePhoto(ctx context.Context, photo storage.Photo, image []byte, thumbnail []byte) (storage.Photo, error) {
+	const op = "photos.Directory.SavePhoto"
+
+	photo.UpdatedAt = time.Now().UTC()
+	description, err := json.Marshal(photo)
+	if err != nil {
+		return storage.Photo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	dir := d.dir(ctx)
+	err = d.holds.WithoutHold(ctx, photo.IIN, func() error {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+		for _, file := range []struct {
+			suffix string
+			data   []byte
+		}{
+			{imageSuffix, image},
+			{thumbnailSuffix, thumbnail},
+			{descriptionSuffix, description},
+		} {
+			if err := writeFile(filepath.Join(dir, photo.IIN+file.suffix), file.data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return storage.Photo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return photo, nil
+}
+
+// GetPhoto method reads the photo of the person stored under the IIN in the tenant of the context.
+// It returns the Photo struct and the image, or an error, storage.ErrorPhotoNotFound if there is no photo.
+func (d *Directory) GetPhoto(ctx context.Context, iin string) (storage.Photo, []byte, error) {
+	const op = "photos.Directory.GetPhoto"
+
+	photo, image, err := d.read(ctx, iin, imageSuffix)
+	if err != nil {
+		return storage.Photo{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return photo, image, nil
+}
+
+// GetPhotoThumbnail method reads the thumbnail of the photo of the person stored under the IIN.
+// It returns the Photo struct and the thumbnail, or an error, storage.ErrorPhotoNotFound if there is no photo.
+func (d *Directory) GetPhotoThumbnail(ctx context.Context, iin string) (storage.Photo, []byte, error) {
+	const op = "photos.Directory.GetPhotoThumbnail"
+
+	photo, thumbnail, err := d.read(ctx, iin, thumbnailSuffix)
+	if err != nil {
+		return storage.Photo{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return photo, thumbnail, nil
+}
+
+// GetPhotoDescription method reads the description of the photo of the person stored under the IIN,
+// without the image and the thumbnail.
+// It returns the Photo struct or an error, storage.ErrorPhotoNotFound if there is no photo.
+func (d *Directory) GetPhotoDescription(ctx context.Context, iin string) (storage.Photo, error) {
+	const op = "photos.Directory.GetPhotoDescription"
+
+	photo, err := d.describe(ctx, iin)
+	if err != nil {
+		return storage.Photo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return photo, nil
+}
+
+// DeletePhoto method removes the photo of the person stored under the IIN in the tenant of the context.
+// It returns an error, storage.ErrorPhotoNotFound if there is no photo
+// and storage.ErrorLegalHold if the person is under legal hold.
+func (d *Directory) DeletePhoto(ctx context.Context, iin string) error {
+	const op = "photos.Directory.DeletePhoto"
+
+	err := d.holds.WithoutHold(ctx, iin, func() error {
+		return d.remove(ctx, iin)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MovePhoto method moves the photo of the person stored under sourceIIN in the tenant of the context
+// to the person stored under targetIIN, unless the target has a photo, in which case the photo of the source
+// is removed, as a merge does.
+// It returns an error, storage.ErrorPhotoNotFound if the source has no photo.
+func (d *Directory) MovePhoto(ctx context.Context, sourceIIN string, targetIIN string) error {
+	const op = "photos.Directory.MovePhoto"
+
+	dir := d.dir(ctx)
+	_, err := os.Stat(filepath.Join(dir, targetIIN+descriptionSuffix))
+	if err == nil {
+		if err = d.remove(ctx, sourceIIN); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	description, err := os.ReadFile(filepath.Join(dir, sourceIIN+descriptionSuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", op, storage.ErrorPhotoNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	var photo storage.Photo
+	if err = json.Unmarshal(description, &photo); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	photo.IIN = targetIIN
+	if description, err = json.Marshal(photo); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The source loses its photo before the target gets it, whose description is written last
+	if err = os.Remove(filepath.Join(dir, sourceIIN+descriptionSuffix)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, suffix := range []string{imageSuffix, thumbnailSuffix} {
+		if err = os.Rename(filepath.Join(dir, sourceIIN+suffix), filepath.Join(dir, targetIIN+suffix)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err = writeFile(filepath.Join(dir, targetIIN+descriptionSuffix), description); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// dir method returns the directory of the photos of the tenant of the context.
+// Tenant IDs only consist of lowercase letters and digits, so they are safe to use as names.
+func (d *Directory) dir(ctx context.Context) string {
+	return filepath.Join(d.root, storage.TenantID(ctx))
+}
+
+// remove method removes the files of the photo of the person stored under the IIN in the tenant of the context.
+// Unlike DeletePhoto, it does not check legal holds, which the deletions and merges of people it follows already have.
+// It returns an error, storage.ErrorPhotoNotFound if there is no photo.
+func (d *Directory) remove(ctx context.Context, iin string) error {
+	dir := d.dir(ctx)
+	err := os.Remove(filepath.Join(dir, iin+descriptionSuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return storage.ErrorPhotoNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, suffix := range []string{imageSuffix, thumbnailSuffix} {
+		if err = os.Remove(filepath.Join(dir, iin+suffix)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// read method reads the description of a photo and the file with the given suffix.
+func (d *Directory) read(ctx context.Context, iin string, suffix string) (storage.Photo, []byte, error) {
+	photo, err := d.describe(ctx, iin)
+	if err != nil {
+		return storage.Photo{}, nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(d.dir(ctx), iin+suffix))
+	return photo, data, err
+}
+
+// describe method reads the description of a photo.
+// It returns storage.ErrorPhotoNotFound if there is no photo.
+func (d *Directory) describe(ctx context.Context, iin string) (storage.Photo, error) {
+	description, err := os.ReadFile(filepath.Join(d.dir(ctx), iin+descriptionSuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return storage.Photo{}, storage.ErrorPhotoNotFound
+	}
+	if err != nil {
+		return storage.Photo{}, err
+	}
+
+	var photo storage.Photo
+	if err = json.Unmarshal(description, &photo); err != nil {
+		return storage.Photo{}, err
+	}
+	return photo, nil
+}
+
+// writeFile is a helper function to replace the content of a file atomically,
+// by writing a temporary file next to it and renaming it.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
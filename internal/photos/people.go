@@ -0,0 +1,81 @@
+package photos
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// People is the storage of people whose photos a Directory keeps, as the handlers use it.
+type People interface {
+	GetPersonByIIN(ctx context.Context, iin string) (storage.PersonInfo, error)
+	GetPersonByName(ctx context.Context, name string, filter storage.PersonFilter) ([]storage.PersonInfo, error)
+	SavePerson(ctx context.Context, iin string, name string, phone string) error
+	SavePersonWithOptions(ctx context.Context, iin string, name string, phone string, opts storage.SaveOptions) error
+	UpdatePerson(ctx context.Context, iin string, name string, phone string, expectedVersions ...int64) (int64, error)
+	DeletePersonByIIN(ctx context.Context, iin string, expectedVersions ...int64) error
+	ExecuteBatch(ctx context.Context, operations []storage.BatchOperation) ([]storage.BatchResult, error)
+	MergePeople(ctx context.Context, sourceIIN string, targetIIN string, expectedVersions ...int64) (storage.MergeRecord, error)
+	ChangeStatus(ctx context.Context, iin string, status string, effectiveDate string, reason string, expectedVersions ...int64) (storage.StatusChange, error)
+}
+
+// Following struct is a decorator of People keeping the photos of a Directory in step with the people,
+// as the database does for the photos it keeps itself: the photo of a person deleted, by itself or in a batch,
+// is removed, and the photo of the source of a merge is moved to the target unless the target has one.
+// The photos are changed once the people are, a failure being logged, as the change of the people
+// cannot be undone by then.
+type Following struct {
+	People
+	dir *Directory
+	log *slog.Logger
+}
+
+// Follow function creates a decorator of people keeping the photos of the directory in step with them.
+// It returns a pointer to a Following struct.
+func Follow(log *slog.Logger, people People, dir *Directory) *Following {
+	return &Following{People: people, dir: dir, log: log}
+}
+
+// DeletePersonByIIN method deletes the person and then their photo.
+func (f *Following) DeletePersonByIIN(ctx context.Context, iin string, expectedVersions ...int64) error {
+	if err := f.People.DeletePersonByIIN(ctx, iin, expectedVersions...); err != nil {
+		return err
+	}
+	f.deletePhoto(ctx, iin)
+	return nil
+}
+
+// ExecuteBatch method executes the batch and then deletes the photos of the people it deleted.
+func (f *Following) ExecuteBatch(ctx context.Context, operations []storage.BatchOperation) ([]storage.BatchResult, error) {
+	results, err := f.People.ExecuteBatch(ctx, operations)
+	if err != nil {
+		return results, err
+	}
+	for _, result := range results {
+		if result.Op == storage.OperationDelete && result.Err == nil {
+			f.deletePhoto(ctx, result.IIN)
+		}
+	}
+	return results, nil
+}
+
+// MergePeople method merges the people and then moves the photo of the source to the target.
+func (f *Following) MergePeople(ctx context.Context, sourceIIN string, targetIIN string, expectedVersions ...int64) (storage.MergeRecord, error) {
+	record, err := f.People.MergePeople(ctx, sourceIIN, targetIIN, expectedVersions...)
+	if err != nil {
+		return record, err
+	}
+	if err = f.dir.MovePhoto(ctx, sourceIIN, targetIIN); err != nil && !errors.Is(err, storage.ErrorPhotoNotFound) {
+		f.log.Error("failed to move photo of merged person", slog.String("source", sourceIIN),
+			slog.String("target", targetIIN), slog.String("error", err.Error()))
+	}
+	return record, nil
+}
+
+// deletePhoto method removes the photo of a deleted person, if any.
+func (f *Following) deletePhoto(ctx context.Context, iin string) {
+	if err := f.dir.remove(ctx, iin); err != nil && !errors.Is(err, storage.ErrorPhotoNotFound) {
+		f.log.Error("failed to delete photo of deleted person", slog.String("iin", iin), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,134 @@
+// Package photos provides the validation of the photos of people, the generation of their thumbnails,
+// and a store keeping them in a local directory.
+package photos
+
+import (
+	"bytes"
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"slices"
+)
+
+// Content types of the accepted photos. Thumbnails are always JPEG images.
+const (
+	ContentTypeJPEG = "image/jpeg"
+	ContentTypePNG  = "image/png"
+)
+
+// ThumbnailQuality is the JPEG quality thumbnails are encoded with.
+const ThumbnailQuality = 85
+
+var (
+	ErrorUnsupportedType = errors.New("unsupported image type")
+	ErrorInvalidImage    = errors.New("invalid image")
+	ErrorDimensions      = errors.New("image dimensions out of bounds")
+)
+
+// Store is a place the photos are kept in, either the database or a Directory.
+type Store interface {
+	SavePhoto(ctx context.Context, photo storage.Photo, image []byte, thumbnail []byte) (storage.Photo, error)
+	GetPhoto(ctx context.Context, iin string) (storage.Photo, []byte, error)
+	GetPhotoThumbnail(ctx context.Context, iin string) (storage.Photo, []byte, error)
+	GetPhotoDescription(ctx context.Context, iin string) (storage.Photo, error)
+	DeletePhoto(ctx context.Context, iin string) error
+}
+
+// Limits struct holds the bounds of the accepted photos and the size of their thumbnails.
+type Limits struct {
+	MinWidth      int
+	MinHeight     int
+	MaxWidth      int
+	MaxHeight     int
+	ThumbnailSize int // Bound of the longer side of thumbnails, in pixels
+}
+
+// Process function validates a photo of the person with the given IIN and generates its thumbnail.
+// The content type is sniffed from the data itself, whatever the client declared, and only JPEG and PNG
+// images within the dimension limits are accepted. The dimensions are checked before the image is decoded.
+// It returns the description of the photo and the thumbnail, or an error.
+func Process(iin string, data []byte, limits Limits) (storage.Photo, []byte, error) {
+	contentType := http.DetectContentType(data)
+	if !slices.Contains([]string{ContentTypeJPEG, ContentTypePNG}, contentType) {
+		return storage.Photo{}, nil, fmt.Errorf("%w: %s", ErrorUnsupportedType, contentType)
+	}
+
+	decode := jpeg.Decode
+	decodeConfig := jpeg.DecodeConfig
+	if contentType == ContentTypePNG {
+		decode, decodeConfig = png.Decode, png.DecodeConfig
+	}
+
+	config, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return storage.Photo{}, nil, fmt.Errorf("%w: %s", ErrorInvalidImage, err.Error())
+	}
+	if config.Width < limits.MinWidth || config.Height < limits.MinHeight ||
+		config.Width > limits.MaxWidth || config.Height > limits.MaxHeight {
+		return storage.Photo{}, nil, fmt.Errorf("%w: %dx%d, allowed %dx%d to %dx%d", ErrorDimensions,
+			config.Width, config.Height, limits.MinWidth, limits.MinHeight, limits.MaxWidth, limits.MaxHeight)
+	}
+
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return storage.Photo{}, nil, fmt.Errorf("%w: %s", ErrorInvalidImage, err.Error())
+	}
+
+	var thumbnail bytes.Buffer
+	err = jpeg.Encode(&thumbnail, Thumbnail(img, limits.ThumbnailSize), &jpeg.Options{Quality: ThumbnailQuality})
+	if err != nil {
+		return storage.Photo{}, nil, err
+	}
+
+	return storage.Photo{
+		IIN:         iin,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       config.Width,
+		Height:      config.Height,
+	}, thumbnail.Bytes(), nil
+}
+
+// Thumbnail function scales the image down to fit a square of the given size, keeping its aspect ratio.
+// Every pixel of the thumbnail is the average of the pixels of the image it covers.
+// Images already fitting the square are copied as they are.
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			thumbnail.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n),
+			})
+		}
+	}
+
+	return thumbnail
+}
@@ -0,0 +1,232 @@
+package photos
+
+import (
+	"bytes"
+	"citizen_webservice/internal/storage"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const iin = "830218350074"
+
+var limits = Limits{MinWidth: 16, MinHeight: 16, MaxWidth: 400, MaxHeight: 300, ThumbnailSize: 40}
+
+// encode is a helper function to encode a plain image of the given size.
+func encode(t *testing.T, width int, height int, format string) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	testCases := []struct {
+		name        string
+		data        []byte
+		contentType string
+		thumbnail   image.Point
+		err         error
+	}{
+		{
+			name:        "Test Case 1: Landscape PNG",
+			data:        encode(t, 200, 100, "png"),
+			contentType: ContentTypePNG,
+			thumbnail:   image.Pt(40, 20),
+		},
+		{
+			name:        "Test Case 2: Portrait JPEG",
+			data:        encode(t, 150, 300, "jpeg"),
+			contentType: ContentTypeJPEG,
+			thumbnail:   image.Pt(20, 40),
+		},
+		{
+			name:        "Test Case 3: Smaller than a thumbnail",
+			data:        encode(t, 30, 20, "png"),
+			contentType: ContentTypePNG,
+			thumbnail:   image.Pt(30, 20),
+		},
+		{
+			name: "Test Case 4: GIF",
+			data: encode(t, 100, 100, "gif"),
+			err:  ErrorUnsupportedType,
+		},
+		{
+			name: "Test Case 5: Not an image",
+			data: []byte("%PDF-1.4"),
+			err:  ErrorUnsupportedType,
+		},
+		{
+			name: "Test Case 6: Too large",
+			data: encode(t, 401, 100, "png"),
+			err:  ErrorDimensions,
+		},
+		{
+			name: "Test Case 7: Too small",
+			data: encode(t, 100, 15, "jpeg"),
+			err:  ErrorDimensions,
+		},
+		{
+			name: "Test Case 8: Truncated",
+			data: encode(t, 100, 100, "png")[:100],
+			err:  ErrorInvalidImage,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			photo, thumbnail, err := Process(iin, tc.data, limits)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, iin, photo.IIN)
+			assert.Equal(t, tc.contentType, photo.ContentType)
+			assert.Equal(t, int64(len(tc.data)), photo.Size)
+
+			config, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail))
+			require.NoError(t, err)
+			assert.Equal(t, tc.thumbnail, image.Pt(config.Width, config.Height))
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	// Black and white columns average to grey
+	img := image.NewGray(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x += 2 {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+
+	thumbnail := Thumbnail(img, 2)
+	assert.Equal(t, image.Rect(0, 0, 2, 1), thumbnail.Bounds())
+	for x := 0; x < 2; x++ {
+		r, g, b, a := thumbnail.At(x, 0).RGBA()
+		assert.InDelta(t, 0x7fff, r, 0x100) // Thumbnails keep 8 bits per channel
+		assert.Equal(t, r, g)
+		assert.Equal(t, r, b)
+		assert.Equal(t, uint32(0xffff), a)
+	}
+}
+
+// holds is a set of the IINs under legal hold.
+type holds map[string]bool
+
+func (h holds) WithoutHold(_ context.Context, iin string, change func() error) error {
+	if h[iin] {
+		return storage.ErrorLegalHold
+	}
+	return change()
+}
+
+func TestDirectory(t *testing.T) {
+	const held = "600426400918"
+	dir, err := NewDirectory(t.TempDir(), holds{held: true})
+	require.NoError(t, err)
+	ctx := storage.WithTenant(context.Background(), storage.DefaultTenant)
+	other := storage.WithTenant(ctx, "other")
+
+	_, _, err = dir.GetPhoto(ctx, iin)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+	assert.ErrorIs(t, dir.DeletePhoto(ctx, iin), storage.ErrorPhotoNotFound)
+
+	photo := storage.Photo{IIN: iin, ContentType: ContentTypePNG, Size: 5, Width: 100, Height: 80}
+	saved, err := dir.SavePhoto(ctx, photo, []byte("image"), []byte("thumb"))
+	require.NoError(t, err)
+	assert.False(t, saved.UpdatedAt.IsZero())
+
+	got, data, err := dir.GetPhoto(ctx, iin)
+	require.NoError(t, err)
+	assert.Equal(t, saved.ContentType, got.ContentType)
+	assert.Equal(t, saved.Width, got.Width)
+	assert.True(t, saved.UpdatedAt.Equal(got.UpdatedAt))
+	assert.Equal(t, []byte("image"), data)
+
+	_, thumbnail, err := dir.GetPhotoThumbnail(ctx, iin)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("thumb"), thumbnail)
+
+	described, err := dir.GetPhotoDescription(ctx, iin)
+	require.NoError(t, err)
+	assert.Equal(t, got, described)
+	_, err = dir.GetPhotoDescription(other, iin)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+
+	// The photos of people under legal hold are neither saved nor deleted
+	_, err = dir.SavePhoto(ctx, storage.Photo{IIN: held, ContentType: ContentTypePNG}, []byte("image"), []byte("thumb"))
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	_, _, err = dir.GetPhoto(ctx, held)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+	assert.ErrorIs(t, dir.DeletePhoto(ctx, held), storage.ErrorLegalHold)
+
+	// Tenants have their own photos
+	_, _, err = dir.GetPhoto(other, iin)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+
+	// A new photo replaces the previous one
+	_, err = dir.SavePhoto(ctx, photo, []byte("new image"), []byte("new thumb"))
+	require.NoError(t, err)
+	_, data, err = dir.GetPhoto(ctx, iin)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new image"), data)
+
+	// A move gives the photo to a target without one and drops it otherwise
+	const target, third = "980301450725", "790708301327"
+	assert.ErrorIs(t, dir.MovePhoto(ctx, target, iin), storage.ErrorPhotoNotFound)
+	require.NoError(t, dir.MovePhoto(ctx, iin, target))
+	_, _, err = dir.GetPhoto(ctx, iin)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+	got, data, err = dir.GetPhoto(ctx, target)
+	require.NoError(t, err)
+	assert.Equal(t, target, got.IIN)
+	assert.Equal(t, []byte("new image"), data)
+	_, thumbnail, err = dir.GetPhotoThumbnail(ctx, target)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new thumb"), thumbnail)
+
+	photo.IIN = third
+	_, err = dir.SavePhoto(ctx, photo, []byte("third image"), []byte("third thumb"))
+	require.NoError(t, err)
+	require.NoError(t, dir.MovePhoto(ctx, target, third))
+	_, _, err = dir.GetPhoto(ctx, target)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+	_, data, err = dir.GetPhoto(ctx, third)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("third image"), data)
+
+	require.NoError(t, dir.DeletePhoto(ctx, third))
+	_, _, err = dir.GetPhotoThumbnail(ctx, third)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+	entries, err := os.ReadDir(dir.dir(ctx))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
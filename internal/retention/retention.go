@@ -0,0 +1,149 @@
+// Package retention removes the data kept longer than the retention rules allow.
+package retention
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DefaultInterval is how often the rules are applied when the options leave it unset.
+const DefaultInterval = 24 * time.Hour
+
+// Store is the storage the expired data is counted in and removed from.
+type Store interface {
+	CountExpired(target string, cutoff time.Time) (int64, error)
+	PurgeExpired(target string, cutoff time.Time) (int64, error)
+}
+
+// Rule struct holds how long the data of a target is kept, see the storage.Retention constants.
+type Rule struct {
+	Target string
+	MaxAge time.Duration
+}
+
+// Result struct holds the outcome of a rule: the entries recorded before the cutoff,
+// counted by a report or removed by a purge.
+type Result struct {
+	Target string    `json:"target"`
+	MaxAge string    `json:"max_age"`
+	Cutoff time.Time `json:"cutoff"`
+	Count  int64     `json:"count"`
+}
+
+// Scheduler struct applies the retention rules periodically.
+type Scheduler struct {
+	log      *slog.Logger
+	store    Store
+	rules    []Rule
+	interval time.Duration
+}
+
+// NewScheduler function creates a scheduler applying the rules every interval.
+// It returns a pointer to a Scheduler struct or an error if a rule is not valid.
+func NewScheduler(log *slog.Logger, store Store, rules []Rule, interval time.Duration) (*Scheduler, error) {
+	const op = "retention.NewScheduler"
+
+	for _, rule := range rules {
+		switch rule.Target {
+		case storage.RetentionDeletedPeople, storage.RetentionDeceasedPeople, storage.RetentionEvents,
+			storage.RetentionMergeLog, storage.RetentionWebhookDeliveries, storage.RetentionAccessLog:
+		default:
+			return nil, fmt.Errorf("%s: %q: %w", op, rule.Target, storage.ErrorUnknownTarget)
+		}
+		if rule.MaxAge <= 0 {
+			return nil, fmt.Errorf("%s: %s: max age must be positive", op, rule.Target)
+		}
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &Scheduler{
+		log:      log.With(slog.String("op", "retention.Scheduler")),
+		store:    store,
+		rules:    rules,
+		interval: interval,
+	}, nil
+}
+
+// Run method purges the expired data at start and then every interval until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Purge(time.Now()); err != nil {
+			s.log.Error("failed to purge expired data", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report method counts, without removing anything, the data every rule would purge at the given time.
+// It returns the result of every rule or an error.
+func (s *Scheduler) Report(now time.Time) ([]Result, error) {
+	const op = "retention.Scheduler.Report"
+
+	results := make([]Result, 0, len(s.rules))
+	for _, rule := range s.rules {
+		result := newResult(rule, now)
+		count, err := s.store.CountExpired(rule.Target, result.Cutoff)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", op, err)
+		}
+		result.Count = count
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Purge method removes the data expired at the given time and logs the count removed by every rule.
+// A failing rule does not stop the others.
+// It returns the result of every applied rule and the errors of the failed ones.
+func (s *Scheduler) Purge(now time.Time) ([]Result, error) {
+	const op = "retention.Scheduler.Purge"
+
+	results := make([]Result, 0, len(s.rules))
+	var errs []error
+	for _, rule := range s.rules {
+		result := newResult(rule, now)
+		count, err := s.store.PurgeExpired(rule.Target, result.Cutoff)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.Count = count
+		results = append(results, result)
+
+		s.log.Info("expired data purged",
+			slog.String("target", rule.Target),
+			slog.String("max_age", result.MaxAge),
+			slog.Time("cutoff", result.Cutoff),
+			slog.Int64("count", count),
+		)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return results, fmt.Errorf("%s: %w", op, err)
+	}
+	return results, nil
+}
+
+// newResult is a helper function to create the result of the rule applied at the given time.
+func newResult(rule Rule, now time.Time) Result {
+	return Result{
+		Target: rule.Target,
+		MaxAge: rule.MaxAge.String(),
+		Cutoff: now.Add(-rule.MaxAge).UTC(),
+	}
+}
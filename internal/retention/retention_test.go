@@ -0,0 +1,95 @@
+package retention
+
+import (
+	"citizen_webservice/internal/storage"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeStore records the calls and counts one expired entry per target, or fails for failing targets.
+type fakeStore struct {
+	failing string
+	counted []string
+	purged  []string
+	cutoffs []time.Time
+}
+
+func (f *fakeStore) CountExpired(target string, cutoff time.Time) (int64, error) {
+	f.counted = append(f.counted, target)
+	f.cutoffs = append(f.cutoffs, cutoff)
+	return 1, nil
+}
+
+func (f *fakeStore) PurgeExpired(target string, cutoff time.Time) (int64, error) {
+	if target == f.failing {
+		return 0, errors.New("purge failed")
+	}
+	f.purged = append(f.purged, target)
+	return 1, nil
+}
+
+func TestNewSchedulerValidatesRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		wantErr bool
+	}{
+		{"no rules", nil, false},
+		{"valid", []Rule{{storage.RetentionDeletedPeople, time.Hour}, {storage.RetentionEvents, time.Hour}}, false},
+		{"unknown target", []Rule{{"users", time.Hour}}, true},
+		{"zero max age", []Rule{{storage.RetentionMergeLog, 0}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewScheduler(discard, &fakeStore{}, tt.rules, 0)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReportOnlyCounts(t *testing.T) {
+	store := &fakeStore{}
+	scheduler, err := NewScheduler(discard, store, []Rule{
+		{storage.RetentionDeletedPeople, 24 * time.Hour},
+		{storage.RetentionWebhookDeliveries, time.Hour},
+	}, 0)
+	require.NoError(t, err)
+
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	results, err := scheduler.Report(now)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Result{
+		{Target: storage.RetentionDeletedPeople, MaxAge: "24h0m0s", Cutoff: now.Add(-24 * time.Hour), Count: 1},
+		{Target: storage.RetentionWebhookDeliveries, MaxAge: "1h0m0s", Cutoff: now.Add(-time.Hour), Count: 1},
+	}, results)
+	assert.Empty(t, store.purged)
+}
+
+func TestPurgeContinuesAfterFailure(t *testing.T) {
+	store := &fakeStore{failing: storage.RetentionEvents}
+	scheduler, err := NewScheduler(discard, store, []Rule{
+		{storage.RetentionEvents, time.Hour},
+		{storage.RetentionMergeLog, time.Hour},
+	}, 0)
+	require.NoError(t, err)
+
+	results, err := scheduler.Purge(time.Now())
+	assert.Error(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, storage.RetentionMergeLog, results[0].Target)
+	assert.Equal(t, []string{storage.RetentionMergeLog}, store.purged)
+}
@@ -0,0 +1,300 @@
+// Package cache provides a read-through cache in front of the person storage.
+package cache
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Backend is the storage wrapped by the cache.
+type Backend interface {
+	GetPersonByIIN(ctx context.Context, iin string) (storage.PersonInfo, error)
+	GetPersonByName(ctx context.Context, name string, filter storage.PersonFilter) ([]storage.PersonInfo, error)
+	SavePerson(ctx context.Context, iin string, name string, phone string) error
+	SavePersonWithOptions(ctx context.Context, iin string, name string, phone string, opts storage.SaveOptions) error
+	UpdatePerson(ctx context.Context, iin string, name string, phone string, expectedVersions ...int64) (int64, error)
+	DeletePersonByIIN(ctx context.Context, iin string, expectedVersions ...int64) error
+	ExecuteBatch(ctx context.Context, operations []storage.BatchOperation) ([]storage.BatchResult, error)
+	MergePeople(ctx context.Context, sourceIIN string, targetIIN string, expectedVersions ...int64) (storage.MergeRecord, error)
+	ChangeStatus(ctx context.Context, iin string, status string, effectiveDate string, reason string, expectedVersions ...int64) (storage.StatusChange, error)
+}
+
+// Entry struct is a cached lookup result, either a person or a "not found".
+type Entry struct {
+	Person   storage.PersonInfo `json:"person"`
+	NotFound bool               `json:"not_found"`
+}
+
+// Shared is a cache shared by every replica of the service, consulted when the local cache misses.
+// Entries are stored under the key of the IIN in its tenant, see key.
+// Its generation of a key changes on every invalidation, which lets a lookup store its result
+// only if no write happened since the lookup started, even on another replica.
+type Shared interface {
+	// Get returns the cached entry, if any, and the current generation of the key.
+	Get(key string) (e Entry, found bool, generation string, err error)
+	// Set stores the entry unless the generation of the key has changed.
+	Set(key string, e Entry, generation string, ttl time.Duration) error
+	// Invalidate drops the entries and tells the other replicas to drop their local copies.
+	Invalidate(keys ...string) error
+}
+
+// Options struct holds the cache settings.
+type Options struct {
+	Size        int           // Maximum number of cached IINs
+	TTL         time.Duration // How long a found person is cached
+	NegativeTTL time.Duration // How long a "not found" result is cached
+	Shared      Shared        // Optional cache shared between replicas
+}
+
+// Stats struct holds the cache metrics.
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	NegativeHits  uint64 `json:"negative_hits"` // Hits of a cached "not found", included in Hits
+	Misses        uint64 `json:"misses"`
+	Loads         uint64 `json:"loads"` // Storage lookups, lower than Misses when concurrent misses are collapsed
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	SharedHits    uint64 `json:"shared_hits"`   // Local misses served by the shared cache
+	SharedErrors  uint64 `json:"shared_errors"` // Failed shared cache calls, which fall back to the backend
+	Entries       int    `json:"entries"`
+}
+
+// Storage struct is a caching decorator of a Backend.
+// It caches GetPersonByIIN results per tenant, including "not found", and drops them on every write of the IIN.
+// With a shared cache, local misses are looked up there before reaching the backend.
+type Storage struct {
+	next  Backend
+	opts  Options
+	cache *lru
+	group singleflight.Group
+
+	hits          atomic.Uint64
+	negativeHits  atomic.Uint64
+	misses        atomic.Uint64
+	loads         atomic.Uint64
+	evictions     atomic.Uint64
+	invalidations atomic.Uint64
+	sharedHits    atomic.Uint64
+	sharedErrors  atomic.Uint64
+}
+
+// New function creates a cache in front of the backend.
+// It returns a pointer to a Storage struct.
+func New(next Backend, opts Options) *Storage {
+	return &Storage{
+		next:  next,
+		opts:  opts,
+		cache: newLRU(opts.Size),
+	}
+}
+
+// GetPersonByIIN method returns the cached person or loads it from the backend.
+// Concurrent misses of the same IIN are collapsed into a single backend lookup, which is not cancelled
+// along with the request that started it, while each request stops waiting for it once its own context is done.
+// It returns a PersonInfo struct or an error.
+func (s *Storage) GetPersonByIIN(ctx context.Context, iin string) (storage.PersonInfo, error) {
+	const fn = "storage.cache.GetPersonByIIN"
+
+	k := key(ctx, iin)
+	if e, ok := s.cache.get(k, time.Now()); ok {
+		s.hits.Add(1)
+		if e.notFound {
+			s.negativeHits.Add(1)
+			return storage.PersonInfo{}, fmt.Errorf("%s: %w", fn, storage.ErrorIINNotFound)
+		}
+		return e.person, nil
+	}
+	s.misses.Add(1)
+
+	loaded := s.group.DoChan(k, func() (interface{}, error) {
+		return s.load(context.WithoutCancel(ctx), k, iin)
+	})
+	var result singleflight.Result
+	select {
+	case result = <-loaded:
+	case <-ctx.Done():
+		return storage.PersonInfo{}, fmt.Errorf("%s: %w", fn, ctx.Err())
+	}
+	if result.Err != nil {
+		return storage.PersonInfo{}, result.Err
+	}
+
+	e := result.Val.(Entry)
+	if e.NotFound {
+		return storage.PersonInfo{}, fmt.Errorf("%s: %w", fn, storage.ErrorIINNotFound)
+	}
+	return e.Person, nil
+}
+
+// load method reads the IIN from the shared cache, if any, or from the backend, and caches the result under the key.
+func (s *Storage) load(ctx context.Context, k string, iin string) (Entry, error) {
+	epoch := s.cache.currentEpoch()
+
+	var generation string
+	sharedOK := false
+	if s.opts.Shared != nil {
+		e, found, gen, err := s.opts.Shared.Get(k)
+		switch {
+		case err != nil:
+			s.sharedErrors.Add(1)
+		case found:
+			s.sharedHits.Add(1)
+			s.store(k, e, epoch)
+			return e, nil
+		default:
+			generation, sharedOK = gen, true
+		}
+	}
+
+	s.loads.Add(1)
+	person, err := s.next.GetPersonByIIN(ctx, iin)
+	notFound := errors.Is(err, storage.ErrorIINNotFound)
+	if err != nil && !notFound {
+		return Entry{}, err
+	}
+
+	e := Entry{Person: person, NotFound: notFound}
+	if sharedOK {
+		if err := s.opts.Shared.Set(k, e, generation, s.ttl(e)); err != nil {
+			s.sharedErrors.Add(1)
+		}
+	}
+	s.store(k, e, epoch)
+	return e, nil
+}
+
+// ttl method returns how long the entry may be cached.
+func (s *Storage) ttl(e Entry) time.Duration {
+	if e.NotFound {
+		return s.opts.NegativeTTL
+	}
+	return s.opts.TTL
+}
+
+// store method caches a lookup result locally with the TTL matching its kind,
+// unless the cache was invalidated since the lookup started.
+func (s *Storage) store(k string, e Entry, epoch uint64) {
+	ttl := s.ttl(e)
+	if ttl <= 0 {
+		return
+	}
+
+	evicted := s.cache.add(entry{
+		key:       k,
+		person:    e.Person,
+		notFound:  e.NotFound,
+		expiresAt: time.Now().Add(ttl),
+	}, epoch)
+	if evicted {
+		s.evictions.Add(1)
+	}
+}
+
+// Invalidate method drops the cached results of the IINs in the tenant of the context,
+// locally and in the shared cache, so that the next lookup on any replica reads the backend.
+func (s *Storage) Invalidate(ctx context.Context, iins ...string) {
+	keys := make([]string, 0, len(iins))
+	for _, iin := range iins {
+		keys = append(keys, key(ctx, iin))
+	}
+
+	s.InvalidateLocal(keys...)
+	if s.opts.Shared != nil {
+		if err := s.opts.Shared.Invalidate(keys...); err != nil {
+			s.sharedErrors.Add(1)
+		}
+	}
+}
+
+// InvalidateLocal method drops the locally cached results under the keys.
+// It is called for invalidations received from other replicas.
+func (s *Storage) InvalidateLocal(keys ...string) {
+	s.cache.remove(keys...)
+	for _, k := range keys {
+		s.group.Forget(k)
+	}
+	s.invalidations.Add(uint64(len(keys)))
+}
+
+// key returns the cache key of the IIN in the tenant of the context.
+// The IINs of the default tenant are keyed by the IIN alone, like before tenants were introduced,
+// so that replicas of both versions share their entries during a rolling upgrade.
+func key(ctx context.Context, iin string) string {
+	tenant := storage.TenantID(ctx)
+	if tenant == storage.DefaultTenant {
+		return iin
+	}
+	return tenant + "/" + iin
+}
+
+// Stats method returns the current cache metrics.
+func (s *Storage) Stats() Stats {
+	return Stats{
+		Hits:          s.hits.Load(),
+		NegativeHits:  s.negativeHits.Load(),
+		Misses:        s.misses.Load(),
+		Loads:         s.loads.Load(),
+		Evictions:     s.evictions.Load(),
+		Invalidations: s.invalidations.Load(),
+		SharedHits:    s.sharedHits.Load(),
+		SharedErrors:  s.sharedErrors.Load(),
+		Entries:       s.cache.len(),
+	}
+}
+
+// GetPersonByName method passes the search through to the backend, search results are not cached.
+func (s *Storage) GetPersonByName(ctx context.Context, name string, filter storage.PersonFilter) ([]storage.PersonInfo, error) {
+	return s.next.GetPersonByName(ctx, name, filter)
+}
+
+// SavePerson method saves the person and drops the cached "not found" of the IIN.
+func (s *Storage) SavePerson(ctx context.Context, iin string, name string, phone string) error {
+	defer s.Invalidate(ctx, iin)
+	return s.next.SavePerson(ctx, iin, name, phone)
+}
+
+// SavePersonWithOptions method saves the person with the options and drops the cached "not found" of the IIN.
+func (s *Storage) SavePersonWithOptions(ctx context.Context, iin string, name string, phone string, opts storage.SaveOptions) error {
+	defer s.Invalidate(ctx, iin)
+	return s.next.SavePersonWithOptions(ctx, iin, name, phone, opts)
+}
+
+// UpdatePerson method updates the person and drops the cached record.
+func (s *Storage) UpdatePerson(ctx context.Context, iin string, name string, phone string, expectedVersions ...int64) (int64, error) {
+	defer s.Invalidate(ctx, iin)
+	return s.next.UpdatePerson(ctx, iin, name, phone, expectedVersions...)
+}
+
+// DeletePersonByIIN method deletes the person and drops the cached record.
+func (s *Storage) DeletePersonByIIN(ctx context.Context, iin string, expectedVersions ...int64) error {
+	defer s.Invalidate(ctx, iin)
+	return s.next.DeletePersonByIIN(ctx, iin, expectedVersions...)
+}
+
+// ExecuteBatch method executes the batch and drops the cached records of every IIN in it.
+func (s *Storage) ExecuteBatch(ctx context.Context, operations []storage.BatchOperation) ([]storage.BatchResult, error) {
+	iins := make([]string, 0, len(operations))
+	for _, operation := range operations {
+		iins = append(iins, operation.IIN)
+	}
+	defer s.Invalidate(ctx, iins...)
+	return s.next.ExecuteBatch(ctx, operations)
+}
+
+// MergePeople method merges the people and drops the cached records of both IINs.
+func (s *Storage) MergePeople(ctx context.Context, sourceIIN string, targetIIN string, expectedVersions ...int64) (storage.MergeRecord, error) {
+	defer s.Invalidate(ctx, sourceIIN, targetIIN)
+	return s.next.MergePeople(ctx, sourceIIN, targetIIN, expectedVersions...)
+}
+
+// ChangeStatus method changes the status of the person and drops the cached record.
+func (s *Storage) ChangeStatus(ctx context.Context, iin string, status string, effectiveDate string, reason string, expectedVersions ...int64) (storage.StatusChange, error) {
+	defer s.Invalidate(ctx, iin)
+	return s.next.ChangeStatus(ctx, iin, status, effectiveDate, reason, expectedVersions...)
+}
@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend is an in-memory Backend counting the lookups that reach it.
+type fakeBackend struct {
+	mu      sync.Mutex
+	people  map[string]storage.PersonInfo
+	lookups atomic.Int64
+	delay   time.Duration
+}
+
+// operator returns a context scoped to the default tenant, like the requests of the operator.
+func operator() context.Context {
+	return storage.WithTenant(context.Background(), storage.DefaultTenant)
+}
+
+func newFakeBackend(people ...storage.PersonInfo) *fakeBackend {
+	b := &fakeBackend{people: map[string]storage.PersonInfo{}}
+	for _, person := range people {
+		b.people[person.IIN] = person
+	}
+	return b
+}
+
+func (b *fakeBackend) GetPersonByIIN(ctx context.Context, iin string) (storage.PersonInfo, error) {
+	b.lookups.Add(1)
+	select {
+	case <-time.After(b.delay):
+	case <-ctx.Done():
+		return storage.PersonInfo{}, ctx.Err()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	person, ok := b.people[iin]
+	if !ok {
+		return storage.PersonInfo{}, storage.ErrorIINNotFound
+	}
+	return person, nil
+}
+
+func (b *fakeBackend) GetPersonByName(context.Context, string, storage.PersonFilter) ([]storage.PersonInfo, error) {
+	return nil, nil
+}
+
+func (b *fakeBackend) SavePerson(_ context.Context, iin string, name string, phone string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.people[iin] = storage.PersonInfo{IIN: iin, Name: name, Phone: phone, Version: 1}
+	return nil
+}
+
+func (b *fakeBackend) SavePersonWithOptions(ctx context.Context, iin string, name string, phone string, _ storage.SaveOptions) error {
+	return b.SavePerson(ctx, iin, name, phone)
+}
+
+func (b *fakeBackend) UpdatePerson(_ context.Context, iin string, name string, phone string, _ ...int64) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	person := b.people[iin]
+	person.Name, person.Phone = name, phone
+	person.Version++
+	b.people[iin] = person
+	return person.Version, nil
+}
+
+func (b *fakeBackend) DeletePersonByIIN(_ context.Context, iin string, _ ...int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.people, iin)
+	return nil
+}
+
+func (b *fakeBackend) ExecuteBatch(context.Context, []storage.BatchOperation) ([]storage.BatchResult, error) {
+	return nil, nil
+}
+
+func (b *fakeBackend) MergePeople(context.Context, string, string, ...int64) (storage.MergeRecord, error) {
+	return storage.MergeRecord{}, nil
+}
+
+func (b *fakeBackend) ChangeStatus(_ context.Context, iin string, status string, _ string, _ string, _ ...int64) (storage.StatusChange, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	person := b.people[iin]
+	change := storage.StatusChange{IIN: iin, From: person.Status, To: status}
+	person.Status = status
+	person.Version++
+	b.people[iin] = person
+	return change, nil
+}
+
+var testOptions = Options{Size: 2, TTL: time.Minute, NegativeTTL: time.Minute}
+
+func TestGetPersonByIINCachesHitsAndMisses(t *testing.T) {
+	backend := newFakeBackend(storage.PersonInfo{IIN: "830218350074", Name: "Test Name", Version: 1})
+	s := New(backend, testOptions)
+
+	for i := 0; i < 3; i++ {
+		person, err := s.GetPersonByIIN(operator(), "830218350074")
+		require.NoError(t, err)
+		assert.Equal(t, "Test Name", person.Name)
+
+		_, err = s.GetPersonByIIN(operator(), "600426400918")
+		assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	}
+
+	assert.Equal(t, int64(2), backend.lookups.Load())
+	stats := s.Stats()
+	assert.Equal(t, uint64(4), stats.Hits)
+	assert.Equal(t, uint64(2), stats.NegativeHits)
+	assert.Equal(t, uint64(2), stats.Misses)
+}
+
+func TestWritesInvalidate(t *testing.T) {
+	backend := newFakeBackend()
+	s := New(backend, testOptions)
+
+	_, err := s.GetPersonByIIN(operator(), "830218350074")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	require.NoError(t, s.SavePerson(operator(), "830218350074", "Test Name", "1"))
+	person, err := s.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Name", person.Name)
+
+	_, err = s.UpdatePerson(operator(), "830218350074", "New Name", "1", 0)
+	require.NoError(t, err)
+	person, err = s.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, "New Name", person.Name)
+
+	_, err = s.ChangeStatus(operator(), "830218350074", storage.StatusDeceased, "2024-01-31", "certificate", 0)
+	require.NoError(t, err)
+	person, err = s.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusDeceased, person.Status)
+
+	require.NoError(t, s.DeletePersonByIIN(operator(), "830218350074", 0))
+	_, err = s.GetPersonByIIN(operator(), "830218350074")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	assert.Equal(t, uint64(4), s.Stats().Invalidations)
+}
+
+func TestTenantsAreCachedSeparately(t *testing.T) {
+	backend := newFakeBackend(storage.PersonInfo{IIN: "830218350074", Name: "Test Name"})
+	s := New(backend, testOptions)
+	defaultTenant := operator()
+	otherTenant := storage.WithTenant(context.Background(), "health")
+
+	for i := 0; i < 2; i++ {
+		_, err := s.GetPersonByIIN(defaultTenant, "830218350074")
+		require.NoError(t, err)
+		_, err = s.GetPersonByIIN(otherTenant, "830218350074")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(2), backend.lookups.Load())
+
+	// A write in one tenant leaves the entries of the others cached
+	s.Invalidate(otherTenant, "830218350074")
+	_, err := s.GetPersonByIIN(defaultTenant, "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), backend.lookups.Load())
+	_, err = s.GetPersonByIIN(otherTenant, "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), backend.lookups.Load())
+}
+
+func TestConcurrentMissesAreCollapsed(t *testing.T) {
+	backend := newFakeBackend(storage.PersonInfo{IIN: "830218350074", Name: "Test Name"})
+	backend.delay = 50 * time.Millisecond
+	s := New(backend, testOptions)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.GetPersonByIIN(operator(), "830218350074")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), backend.lookups.Load())
+}
+
+func TestCancelledMissDoesNotFailCollapsedMisses(t *testing.T) {
+	backend := newFakeBackend(storage.PersonInfo{IIN: "830218350074", Name: "Test Name"})
+	backend.delay = 50 * time.Millisecond
+	s := New(backend, testOptions)
+
+	ctx, cancel := context.WithCancel(operator())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := s.GetPersonByIIN(ctx, "830218350074")
+		cancelled <- err
+	}()
+	require.Eventually(t, func() bool { return backend.lookups.Load() == 1 }, time.Second, time.Millisecond)
+
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := s.GetPersonByIIN(operator(), "830218350074")
+		waiting <- err
+	}()
+	cancel()
+
+	assert.ErrorIs(t, <-cancelled, context.Canceled)
+	assert.NoError(t, <-waiting)
+	assert.Equal(t, int64(1), backend.lookups.Load())
+}
+
+func TestLeastRecentlyUsedIsEvicted(t *testing.T) {
+	backend := newFakeBackend(
+		storage.PersonInfo{IIN: "1"},
+		storage.PersonInfo{IIN: "2"},
+		storage.PersonInfo{IIN: "3"},
+	)
+	s := New(backend, testOptions)
+
+	for _, iin := range []string{"1", "2", "1", "3", "1"} {
+		_, err := s.GetPersonByIIN(operator(), iin)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(3), backend.lookups.Load())
+
+	// "2" was the least recently used entry when "3" was added
+	_, err := s.GetPersonByIIN(operator(), "2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), backend.lookups.Load())
+	assert.Equal(t, 2, s.Stats().Entries)
+	assert.Equal(t, uint64(2), s.Stats().Evictions)
+}
+
+func TestEntriesExpire(t *testing.T) {
+	backend := newFakeBackend(storage.PersonInfo{IIN: "830218350074"})
+	s := New(backend, Options{Size: 2, TTL: 10 * time.Millisecond})
+
+	_, err := s.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = s.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), backend.lookups.Load())
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"citizen_webservice/internal/storage"
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry struct is a cached lookup result, either a person or a cached "not found".
+type entry struct {
+	key       string
+	person    storage.PersonInfo
+	notFound  bool
+	expiresAt time.Time
+}
+
+// lru struct is a fixed-size cache that evicts the least recently used entry when full.
+// It is safe for concurrent use.
+type lru struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // Front is the most recently used entry
+	entries map[string]*list.Element
+
+	// epoch is incremented by every removal, so that a value read from the backend before
+	// a write is not added after the write has removed the entry.
+	epoch uint64
+}
+
+// newLRU function creates an empty cache holding at most size entries.
+func newLRU(size int) *lru {
+	return &lru{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// get method returns the entry stored under the key unless it is missing or expired.
+func (c *lru) get(key string, now time.Time) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return entry{}, false
+	}
+	e := element.Value.(*entry)
+	if now.After(e.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return entry{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return *e, true
+}
+
+// currentEpoch method returns the epoch to pass to add for a value about to be read from the backend.
+func (c *lru) currentEpoch() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.epoch
+}
+
+// add method stores the entry unless anything was removed since the given epoch.
+// It reports whether another entry had to be evicted to make room for it.
+func (c *lru) add(e entry, epoch uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if epoch != c.epoch {
+		return false
+	}
+
+	if element, ok := c.entries[e.key]; ok {
+		element.Value = &e
+		c.order.MoveToFront(element)
+		return false
+	}
+
+	c.entries[e.key] = c.order.PushFront(&e)
+	if c.order.Len() <= c.size {
+		return false
+	}
+
+	oldest := c.order.Back()
+	c.order.Remove(oldest)
+	delete(c.entries, oldest.Value.(*entry).key)
+	return true
+}
+
+// remove method drops the entries stored under the keys.
+func (c *lru) remove(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	for _, key := range keys {
+		if element, ok := c.entries[key]; ok {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// len method returns the number of stored entries, including expired ones not yet evicted.
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
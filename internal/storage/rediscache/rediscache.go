@@ -0,0 +1,229 @@
+// Package rediscache provides a cache shared between replicas over the Redis protocol.
+package rediscache
+
+import (
+	"citizen_webservice/internal/storage/cache"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// generationTTL is how long the generation of an IIN is kept after its last invalidation.
+// It only has to outlive the lookups in flight at the time of the invalidation.
+const generationTTL = time.Hour
+
+// setIfGeneration stores the value only if the generation of the key has not changed since it was read.
+var setIfGeneration = redis.NewScript(`
+local generation = redis.call('GET', KEYS[2]) or ''
+if generation ~= ARGV[1] then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// Options struct holds the shared cache settings.
+type Options struct {
+	KeyPrefix   string        // Prefix of every key, so that several services can share a Redis
+	Channel     string        // Pub/sub channel of the invalidations, prefixed with KeyPrefix
+	IINCheckTTL time.Duration // How long /iin_check results are cached
+}
+
+// Cache struct is a shared cache of IIN lookups and /iin_check results.
+// It implements cache.Shared.
+type Cache struct {
+	client     *redis.Client
+	opts       Options
+	instanceID string
+}
+
+// invalidation struct is the message published on every write.
+type invalidation struct {
+	Origin string   `json:"origin"`
+	IINs   []string `json:"iins"`
+}
+
+// New function creates a shared cache on top of the Redis client.
+// It returns a pointer to a Cache struct or an error.
+func New(client *redis.Client, opts Options) (*Cache, error) {
+	const op = "storage.rediscache.New"
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Cache{
+		client:     client,
+		opts:       opts,
+		instanceID: hex.EncodeToString(id),
+	}, nil
+}
+
+// Ping method checks the connection to Redis.
+func (c *Cache) Ping(ctx context.Context) error {
+	const op = "storage.rediscache.Ping"
+
+	if err := c.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (c *Cache) personKey(iin string) string {
+	return c.opts.KeyPrefix + "person:" + iin
+}
+
+func (c *Cache) generationKey(iin string) string {
+	return c.opts.KeyPrefix + "person-generation:" + iin
+}
+
+func (c *Cache) iinCheckKey(iin string) string {
+	return c.opts.KeyPrefix + "iin-check:" + iin
+}
+
+func (c *Cache) channel() string {
+	return c.opts.KeyPrefix + c.opts.Channel
+}
+
+// Get method returns the cached entry of the IIN, if any, and its current generation.
+func (c *Cache) Get(iin string) (cache.Entry, bool, string, error) {
+	const op = "storage.rediscache.Get"
+
+	values, err := c.client.MGet(context.Background(), c.personKey(iin), c.generationKey(iin)).Result()
+	if err != nil {
+		return cache.Entry{}, false, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	generation, _ := values[1].(string)
+	raw, ok := values[0].(string)
+	if !ok {
+		return cache.Entry{}, false, generation, nil
+	}
+
+	var e cache.Entry
+	if err = json.Unmarshal([]byte(raw), &e); err != nil {
+		return cache.Entry{}, false, generation, fmt.Errorf("%s: %w", op, err)
+	}
+	return e, true, generation, nil
+}
+
+// Set method stores the entry of the IIN unless it was invalidated since its generation was read.
+func (c *Cache) Set(iin string, e cache.Entry, generation string, ttl time.Duration) error {
+	const op = "storage.rediscache.Set"
+
+	if ttl <= 0 {
+		return nil
+	}
+
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = setIfGeneration.Run(context.Background(), c.client,
+		[]string{c.personKey(iin), c.generationKey(iin)},
+		generation, raw, ttl.Milliseconds(),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Invalidate method drops the entries of the IINs, bumps their generations
+// and publishes the invalidation to the other replicas.
+func (c *Cache) Invalidate(iins ...string) error {
+	const op = "storage.rediscache.Invalidate"
+
+	if len(iins) == 0 {
+		return nil
+	}
+
+	message, err := json.Marshal(invalidation{Origin: c.instanceID, IINs: iins})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx := context.Background()
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, iin := range iins {
+			pipe.Del(ctx, c.personKey(iin))
+			pipe.Incr(ctx, c.generationKey(iin))
+			pipe.Expire(ctx, c.generationKey(iin), generationTTL)
+		}
+		pipe.Publish(ctx, c.channel(), message)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Subscribe method listens for invalidations published by the other replicas
+// and passes their IINs to onInvalidate until the context is cancelled.
+func (c *Cache) Subscribe(ctx context.Context, onInvalidate func(iins ...string)) error {
+	const op = "storage.rediscache.Subscribe"
+
+	pubsub := c.client.Subscribe(ctx, c.channel())
+	defer pubsub.Close()
+
+	// Wait for the subscription to be confirmed, so that no invalidation is missed after Subscribe returns
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var inv invalidation
+			if err := json.Unmarshal([]byte(message.Payload), &inv); err != nil {
+				continue
+			}
+			if inv.Origin != c.instanceID {
+				onInvalidate(inv.IINs...)
+			}
+		}
+	}
+}
+
+// GetIINCheck method returns the cached /iin_check response of the IIN, if any.
+func (c *Cache) GetIINCheck(iin string) ([]byte, bool, error) {
+	const op = "storage.rediscache.GetIINCheck"
+
+	result, err := c.client.Get(context.Background(), c.iinCheckKey(iin)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return result, true, nil
+}
+
+// SetIINCheck method caches the /iin_check response of the IIN.
+// The response only depends on the IIN, so it is never invalidated.
+func (c *Cache) SetIINCheck(iin string, result []byte) error {
+	const op = "storage.rediscache.SetIINCheck"
+
+	if err := c.client.Set(context.Background(), c.iinCheckKey(iin), result, c.opts.IINCheckTTL).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
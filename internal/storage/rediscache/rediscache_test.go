@@ -0,0 +1,237 @@
+package rediscache
+
+import (
+	"citizen_webservice/internal/storage"
+	"citizen_webservice/internal/storage/cache"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backend is an in-memory cache.Backend counting the lookups that reach it.
+type backend struct {
+	mu      sync.Mutex
+	people  map[string]storage.PersonInfo
+	lookups int
+}
+
+func (b *backend) GetPersonByIIN(_ context.Context, iin string) (storage.PersonInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lookups++
+	person, ok := b.people[iin]
+	if !ok {
+		return storage.PersonInfo{}, storage.ErrorIINNotFound
+	}
+	return person, nil
+}
+
+func (b *backend) GetPersonByName(context.Context, string, storage.PersonFilter) ([]storage.PersonInfo, error) {
+	return nil, nil
+}
+
+func (b *backend) SavePerson(_ context.Context, iin string, name string, phone string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.people[iin] = storage.PersonInfo{IIN: iin, Name: name, Phone: phone, Version: 1}
+	return nil
+}
+
+func (b *backend) SavePersonWithOptions(ctx context.Context, iin string, name string, phone string, _ storage.SaveOptions) error {
+	return b.SavePerson(ctx, iin, name, phone)
+}
+
+func (b *backend) UpdatePerson(context.Context, string, string, string, ...int64) (int64, error) {
+	return 0, nil
+}
+
+func (b *backend) DeletePersonByIIN(_ context.Context, iin string, _ ...int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.people, iin)
+	return nil
+}
+
+func (b *backend) ExecuteBatch(context.Context, []storage.BatchOperation) ([]storage.BatchResult, error) {
+	return nil, nil
+}
+
+func (b *backend) MergePeople(context.Context, string, string, ...int64) (storage.MergeRecord, error) {
+	return storage.MergeRecord{}, nil
+}
+
+func (b *backend) ChangeStatus(context.Context, string, string, string, string, ...int64) (storage.StatusChange, error) {
+	return storage.StatusChange{}, nil
+}
+
+func (b *backend) lookupCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lookups
+}
+
+var testOptions = Options{KeyPrefix: "test:", Channel: "invalidations", IINCheckTTL: time.Hour}
+
+// operator returns a context scoped to the default tenant, like the requests of the operator.
+func operator() context.Context {
+	return storage.WithTenant(context.Background(), storage.DefaultTenant)
+}
+
+func newTestCache(t *testing.T, server *miniredis.Miniredis) *Cache {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	c, err := New(client, testOptions)
+	require.NoError(t, err)
+	return c
+}
+
+// newReplica creates a service instance: a local cache in front of the backend, subscribed to invalidations.
+func newReplica(t *testing.T, server *miniredis.Miniredis, b *backend) (*cache.Storage, *Cache) {
+	t.Helper()
+
+	shared := newTestCache(t, server)
+	people := cache.New(b, cache.Options{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute, Shared: shared})
+
+	channel := testOptions.KeyPrefix + testOptions.Channel
+	subscribers := server.PubSubNumSub(channel)[channel]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, shared.Subscribe(ctx, people.InvalidateLocal))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	require.Eventually(t, func() bool {
+		return server.PubSubNumSub(channel)[channel] > subscribers
+	}, time.Second, 5*time.Millisecond)
+
+	return people, shared
+}
+
+func TestSharedHitsSkipTheBackend(t *testing.T) {
+	server := miniredis.RunT(t)
+	b := &backend{people: map[string]storage.PersonInfo{
+		"830218350074": {IIN: "830218350074", Name: "Test Name", Version: 1},
+	}}
+	first := cache.New(b, cache.Options{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute, Shared: newTestCache(t, server)})
+	second := cache.New(b, cache.Options{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute, Shared: newTestCache(t, server)})
+
+	person, err := first.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Name", person.Name)
+	_, err = first.GetPersonByIIN(operator(), "600426400918")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	person, err = second.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Name", person.Name)
+	_, err = second.GetPersonByIIN(operator(), "600426400918")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	assert.Equal(t, 2, b.lookupCount())
+	assert.Equal(t, uint64(2), second.Stats().SharedHits)
+}
+
+func TestWritesInvalidateOtherReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	b := &backend{people: map[string]storage.PersonInfo{}}
+	first, _ := newReplica(t, server, b)
+	second, _ := newReplica(t, server, b)
+
+	// Both replicas cache "not found"
+	_, err := first.GetPersonByIIN(operator(), "830218350074")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = second.GetPersonByIIN(operator(), "830218350074")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	require.NoError(t, first.SavePerson(operator(), "830218350074", "Test Name", "1"))
+	require.Eventually(t, func() bool {
+		return second.Stats().Invalidations == 1
+	}, time.Second, 5*time.Millisecond)
+
+	person, err := second.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Name", person.Name)
+
+	require.NoError(t, second.DeletePersonByIIN(operator(), "830218350074", 0))
+	require.Eventually(t, func() bool {
+		return first.Stats().Invalidations == 2
+	}, time.Second, 5*time.Millisecond)
+
+	_, err = first.GetPersonByIIN(operator(), "830218350074")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	// Replicas do not act on their own invalidations twice
+	assert.Equal(t, uint64(2), second.Stats().Invalidations)
+}
+
+func TestSetIsSkippedAfterInvalidation(t *testing.T) {
+	server := miniredis.RunT(t)
+	c := newTestCache(t, server)
+
+	_, found, generation, err := c.Get("830218350074")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// A write between the lookup and the store makes the looked up value stale
+	require.NoError(t, c.Invalidate("830218350074"))
+	require.NoError(t, c.Set("830218350074", cache.Entry{NotFound: true}, generation, time.Minute))
+
+	_, found, generation, err = c.Get("830218350074")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, c.Set("830218350074", cache.Entry{NotFound: true}, generation, time.Minute))
+	e, found, _, err := c.Get("830218350074")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.True(t, e.NotFound)
+}
+
+func TestIINCheckResults(t *testing.T) {
+	server := miniredis.RunT(t)
+	c := newTestCache(t, server)
+
+	_, found, err := c.GetIINCheck("830218350074")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, c.SetIINCheck("830218350074", []byte(`{"correct":true}`)))
+	result, found, err := c.GetIINCheck("830218350074")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.JSONEq(t, `{"correct":true}`, string(result))
+
+	server.FastForward(testOptions.IINCheckTTL + time.Second)
+	_, found, err = c.GetIINCheck("830218350074")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestUnavailableRedisFallsBackToTheBackend(t *testing.T) {
+	server := miniredis.RunT(t)
+	b := &backend{people: map[string]storage.PersonInfo{
+		"830218350074": {IIN: "830218350074", Name: "Test Name", Version: 1},
+	}}
+	people := cache.New(b, cache.Options{Size: 10, TTL: time.Minute, Shared: newTestCache(t, server)})
+	server.Close()
+
+	person, err := people.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Name", person.Name)
+	assert.Equal(t, uint64(1), people.Stats().SharedErrors)
+}
@@ -0,0 +1,155 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// accessBuffer struct holds the access log entries waiting for the writer goroutine,
+// which writes them along with the next writes, so that recording an access never waits for a commit
+// nor takes a place in the write queue.
+type accessBuffer struct {
+	mu      sync.Mutex
+	entries []storage.AccessEntry
+	size    int           // Maximum number of waiting entries
+	flush   chan struct{} // Signalled once half of the buffer is taken
+}
+
+// add method appends the entries unless the buffer would overflow.
+// It returns storage.ErrorAccessLogFull, dropping the entries, if it would.
+func (b *accessBuffer) add(entries []storage.AccessEntry) error {
+	b.mu.Lock()
+	if len(b.entries)+len(entries) > b.size {
+		b.mu.Unlock()
+		return storage.ErrorAccessLogFull
+	}
+	b.entries = append(b.entries, entries...)
+	full := len(b.entries) >= b.size/2
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// take method removes and returns every waiting entry.
+func (b *accessBuffer) take() []storage.AccessEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := b.entries
+	b.entries = nil
+	return entries
+}
+
+// putBack method returns entries that could not be written to the front of the buffer,
+// dropping the newest entries if they no longer fit.
+func (b *accessBuffer) putBack(entries []storage.AccessEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries = append(entries, b.entries...)
+	if len(b.entries) > b.size {
+		b.entries = b.entries[:b.size]
+	}
+}
+
+// RecordAccess method queues the entries for the access log of the tenant of the context and returns
+// without waiting for them to be written. The writer goroutine writes them along with the next writes,
+// or on its own within Options.AccessFlushInterval, outside the write queue.
+// Entries without a request ID or time get the request ID carried by the context and the current time.
+// It returns storage.ErrorAccessLogFull, dropping the entries, if Options.AccessBufferSize entries are already waiting.
+func (s *Storage) RecordAccess(ctx context.Context, entries []storage.AccessEntry) error {
+	const fn = "storage.sqlite.RecordAccess"
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	s.writeMu.RLock()
+	defer s.writeMu.RUnlock()
+	if s.closed {
+		return fmt.Errorf("%s: %w", fn, storage.ErrorStorageClosed)
+	}
+
+	tenant := storage.TenantID(ctx)
+	now := time.Now().UTC()
+	queued := make([]storage.AccessEntry, 0, len(entries))
+	for _, entry := range entries {
+		entry.Tenant = tenant
+		if entry.RequestID == "" {
+			entry.RequestID = storage.RequestID(ctx)
+		}
+		if entry.AccessedAt.IsZero() {
+			entry.AccessedAt = now
+		}
+		queued = append(queued, entry)
+	}
+
+	if err := s.access.add(queued); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// saveAccess method writes the access log entries within the transaction.
+func (s *Storage) saveAccess(tx *sql.Tx, entries []storage.AccessEntry) error {
+	saveAccess := tx.Stmt(s.stmts.saveAccess)
+	for _, entry := range entries {
+		_, err := saveAccess.Exec(entry.Tenant, entry.IIN, entry.Action, entry.Client, entry.Purpose, entry.RequestID, entry.AccessedAt.UTC())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushAccess method waits until the access log entries queued so far are written.
+func (s *Storage) flushAccess() error {
+	return s.write(context.Background(), func(*sql.Tx) error { return nil })
+}
+
+// GetAccessLog method retrieves every access log entry of the IIN within the tenant of the context, oldest first,
+// once the entries queued so far are written.
+// It returns a slice of AccessEntry structs or an error.
+func (s *Storage) GetAccessLog(ctx context.Context, iin string) ([]storage.AccessEntry, error) {
+	const fn = "storage.sqlite.GetAccessLog"
+
+	entries := []storage.AccessEntry{}
+	if err := s.flushAccess(); err != nil {
+		return entries, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	rows, err := s.stmts.getAccessLog.Query(storage.TenantID(ctx), iin)
+	if err != nil {
+		return entries, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := storage.AccessEntry{}
+		err = rows.Scan(&entry.ID, &entry.IIN, &entry.Action, &entry.Client, &entry.Purpose, &entry.RequestID, &entry.AccessedAt)
+		if err != nil {
+			return entries, fmt.Errorf("%s: %w", fn, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return entries, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	s := newTestStorage(t, wal)
+
+	ctx := storage.WithRequestID(operator(), "host/abc-000001")
+	require.NoError(t, s.RecordAccess(ctx, []storage.AccessEntry{
+		{IIN: "830218350074", Action: storage.AccessSearch, Client: "user"},
+		{IIN: "980301450725", Action: storage.AccessSearch, Client: "user"},
+	}))
+	require.NoError(t, s.RecordAccess(operator(), []storage.AccessEntry{
+		{IIN: "830218350074", Action: storage.AccessRead, Client: "partner", Purpose: "marketing", RequestID: "own"},
+	}))
+	require.NoError(t, s.RecordAccess(operator(), nil))
+
+	entries, err := s.GetAccessLog(ctx, "830218350074")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, storage.AccessSearch, entries[0].Action)
+	assert.Equal(t, "host/abc-000001", entries[0].RequestID)
+	assert.False(t, entries[0].AccessedAt.IsZero())
+	assert.Equal(t, storage.AccessRead, entries[1].Action)
+	assert.Equal(t, "partner", entries[1].Client)
+	assert.Equal(t, "marketing", entries[1].Purpose)
+	assert.Equal(t, "own", entries[1].RequestID)
+
+	entries, err = s.GetAccessLog(ctx, "600426400918")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPersonHistory(t *testing.T) {
+	s := newTestStorage(t, wal)
+	ctx := operator()
+
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, "980301450725", "Test Name", "+77010000002"))
+	require.NoError(t, s.SavePerson(ctx, "790708301327", "Other Name", "+77010000003"))
+	_, err := s.GrantConsent(ctx, "830218350074", "marketing", "form")
+	require.NoError(t, err)
+	_, err = s.RevokeConsent(ctx, "830218350074", "marketing", "portal")
+	require.NoError(t, err)
+	_, err = s.MergePeople(ctx, "830218350074", "980301450725", 0)
+	require.NoError(t, err)
+
+	events, err := s.GetPersonEvents(ctx, "830218350074")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, storage.EventPersonCreated, events[0].Type)
+	assert.Equal(t, storage.EventPersonDeleted, events[1].Type)
+
+	for _, iin := range []string{"830218350074", "980301450725"} {
+		merges, err := s.GetPersonMerges(ctx, iin)
+		require.NoError(t, err)
+		assert.Len(t, merges, 1, iin)
+	}
+	merges, err := s.GetPersonMerges(ctx, "790708301327")
+	require.NoError(t, err)
+	assert.Empty(t, merges)
+
+	consents, err := s.GetConsentHistory(ctx, "830218350074")
+	require.NoError(t, err)
+	require.Len(t, consents, 2)
+	assert.Equal(t, storage.ConsentGranted, consents[0].Status)
+	assert.Equal(t, storage.ConsentRevoked, consents[1].Status)
+}
@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// addressColumns are the columns read by GetAddresses.
+const addressColumns = "iin, type, kato, region, locality, street, house, apartment, postal_code, updated_at"
+
+// SaveAddress method stores an address of a person of the tenant of the context,
+// replacing their previous address of the same type.
+// It returns the saved Address struct or an error, storage.ErrorIINNotFound if the person is not stored.
+func (s *Storage) SaveAddress(ctx context.Context, address storage.Address) (storage.Address, error) {
+	const fn = "storage.sqlite.SaveAddress"
+
+	address.UpdatedAt = time.Now().UTC()
+	tenant := storage.TenantID(ctx)
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, address.IIN); err != nil {
+			return err
+		}
+
+		var exists bool
+		if err := tx.Stmt(s.stmts.personExists).QueryRow(tenant, address.IIN).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrorIINNotFound
+		}
+
+		_, err := tx.Stmt(s.stmts.saveAddress).Exec(tenant, address.IIN, address.Type, address.KATO, address.Region,
+			address.Locality, address.Street, address.House, address.Apartment, address.PostalCode, address.UpdatedAt)
+		return err
+	})
+	if err != nil {
+		return storage.Address{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return address, nil
+}
+
+// GetAddresses method retrieves the addresses of the person stored under the IIN, the registered one first.
+// It returns a slice of Address structs or an error.
+func (s *Storage) GetAddresses(ctx context.Context, iin string) ([]storage.Address, error) {
+	const fn = "storage.sqlite.GetAddresses"
+
+	addresses := []storage.Address{}
+	rows, err := s.stmts.getAddresses.Query(storage.TenantID(ctx), iin)
+	if err != nil {
+		return addresses, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var address storage.Address
+		err = rows.Scan(&address.IIN, &address.Type, &address.KATO, &address.Region, &address.Locality,
+			&address.Street, &address.House, &address.Apartment, &address.PostalCode, &address.UpdatedAt)
+		if err != nil {
+			return addresses, fmt.Errorf("%s: %w", fn, err)
+		}
+		addresses = append(addresses, address)
+	}
+	if err = rows.Err(); err != nil {
+		return addresses, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return addresses, nil
+}
+
+// DeleteAddress method removes the address of the given type of the person stored under the IIN.
+// It returns an error, storage.ErrorAddressNotFound if the person has no such address.
+func (s *Storage) DeleteAddress(ctx context.Context, iin string, addressType string) error {
+	const fn = "storage.sqlite.DeleteAddress"
+
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, iin); err != nil {
+			return err
+		}
+
+		result, err := tx.Stmt(s.stmts.deleteAddress).Exec(storage.TenantID(ctx), iin, addressType)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return storage.ErrorAddressNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// GetRegionStatistics method counts the people of the tenant of the context by the region of their address
+// of the given type, only those with the given status, or any status if it is empty.
+// Regions without anyone are not listed, and people without such an address are counted apart.
+// It returns the RegionStatistics struct or an error.
+func (s *Storage) GetRegionStatistics(ctx context.Context, addressType string, status string) (storage.RegionStatistics, error) {
+	const fn = "storage.sqlite.GetRegionStatistics"
+
+	tenant := storage.TenantID(ctx)
+	statistics := storage.RegionStatistics{Regions: []storage.RegionCount{}}
+	rows, err := s.stmts.countByRegion.Query(tenant, addressType, status, status)
+	if err != nil {
+		return statistics, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var count storage.RegionCount
+		if err = rows.Scan(&count.Region, &count.People); err != nil {
+			return statistics, fmt.Errorf("%s: %w", fn, err)
+		}
+		statistics.Regions = append(statistics.Regions, count)
+	}
+	if err = rows.Err(); err != nil {
+		return statistics, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	err = s.stmts.countWithoutAddress.QueryRow(tenant, status, status, addressType).Scan(&statistics.Unknown)
+	if err != nil {
+		return statistics, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return statistics, nil
+}
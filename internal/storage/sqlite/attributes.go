@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/attributes"
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// attributeSchemaColumns are the columns read by scanAttributeSchema.
+const attributeSchemaColumns = "namespace, schema, created_at, updated_at"
+
+// SaveAttributeSchema method registers the JSON Schema of an attribute namespace of the tenant of the context,
+// replacing the previous schema of the namespace. The schema is stored as it is, its validation is up to the caller.
+// It returns the saved AttributeSchema struct or an error.
+func (s *Storage) SaveAttributeSchema(ctx context.Context, namespace string, schema []byte) (storage.AttributeSchema, error) {
+	const fn = "storage.sqlite.SaveAttributeSchema"
+
+	saved := storage.AttributeSchema{Namespace: namespace, Schema: schema, UpdatedAt: time.Now().UTC()}
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		return tx.Stmt(s.stmts.saveAttributeSchema).
+			QueryRow(storage.TenantID(ctx), namespace, string(schema), saved.UpdatedAt, saved.UpdatedAt).
+			Scan(&saved.CreatedAt)
+	})
+	if err != nil {
+		return storage.AttributeSchema{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return saved, nil
+}
+
+// GetAttributeSchema method retrieves the JSON Schema of an attribute namespace of the tenant of the context.
+// It returns the AttributeSchema struct or an error, storage.ErrorSchemaNotFound if the namespace is not registered.
+func (s *Storage) GetAttributeSchema(ctx context.Context, namespace string) (storage.AttributeSchema, error) {
+	const fn = "storage.sqlite.GetAttributeSchema"
+
+	schema, err := scanAttributeSchema(s.stmts.getAttributeSchema.QueryRow(storage.TenantID(ctx), namespace))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.AttributeSchema{}, fmt.Errorf("%s: %s: %w", fn, namespace, storage.ErrorSchemaNotFound)
+	}
+	if err != nil {
+		return storage.AttributeSchema{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return schema, nil
+}
+
+// GetAttributeSchemas method retrieves every JSON Schema registered in the tenant of the context, ordered by namespace.
+// It returns a slice of AttributeSchema structs or an error.
+func (s *Storage) GetAttributeSchemas(ctx context.Context) ([]storage.AttributeSchema, error) {
+	const fn = "storage.sqlite.GetAttributeSchemas"
+
+	schemas := []storage.AttributeSchema{}
+	rows, err := s.stmts.getAttributeSchemas.Query(storage.TenantID(ctx))
+	if err != nil {
+		return schemas, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		schema, err := scanAttributeSchema(rows)
+		if err != nil {
+			return schemas, fmt.Errorf("%s: %w", fn, err)
+		}
+		schemas = append(schemas, schema)
+	}
+	if err = rows.Err(); err != nil {
+		return schemas, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return schemas, nil
+}
+
+// DeleteAttributeSchema method removes the JSON Schema of an attribute namespace of the tenant of the context.
+// A namespace cannot be removed while anyone still has a value in it.
+// It returns an error, storage.ErrorSchemaNotFound if the namespace is not registered
+// or storage.ErrorSchemaInUse if it is still in use.
+func (s *Storage) DeleteAttributeSchema(ctx context.Context, namespace string) error {
+	const fn = "storage.sqlite.DeleteAttributeSchema"
+
+	tenant := storage.TenantID(ctx)
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		var inUse bool
+		if err := tx.Stmt(s.stmts.attributeInUse).QueryRow(tenant, namespace).Scan(&inUse); err != nil {
+			return err
+		}
+		if inUse {
+			return fmt.Errorf("%s: %w", namespace, storage.ErrorSchemaInUse)
+		}
+
+		result, err := tx.Stmt(s.stmts.deleteAttributeSchema).Exec(tenant, namespace)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("%s: %w", namespace, storage.ErrorSchemaNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// checkAttributes method validates every extension attribute of the person stored under the IIN
+// against the schema of its namespace within the transaction, for the attributes written by the storage itself
+// rather than by a caller, such as those a merge combines.
+// It returns an error, attributes.ErrorInvalidValue if an attribute does not match its schema.
+func (s *Storage) checkAttributes(ctx context.Context, tx *sql.Tx, iin string) error {
+	tenant := storage.TenantID(ctx)
+	person, err := scanPerson(tx.Stmt(s.stmts.getPersonByIIN).QueryRow(tenant, iin))
+	if err != nil {
+		return err
+	}
+
+	for namespace, value := range person.Attributes {
+		schema, err := scanAttributeSchema(tx.Stmt(s.stmts.getAttributeSchema).QueryRow(tenant, namespace))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", namespace, storage.ErrorSchemaNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if err = attributes.Validate(schema.Schema, value); err != nil {
+			return fmt.Errorf("%s: %w", namespace, err)
+		}
+	}
+	return nil
+}
+
+// scanAttributeSchema scans a row of attributeSchemaColumns into an AttributeSchema struct.
+func scanAttributeSchema(row rowScanner) (storage.AttributeSchema, error) {
+	var schema storage.AttributeSchema
+	var raw string
+	if err := row.Scan(&schema.Namespace, &raw, &schema.CreatedAt, &schema.UpdatedAt); err != nil {
+		return schema, err
+	}
+	schema.Schema = []byte(raw)
+	return schema, nil
+}
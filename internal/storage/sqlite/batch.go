@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ExecuteBatch method executes the operations in order as a single atomic write.
+// If any operation fails, all of them are rolled back and the returned results
+// end with the failed operation.
+// It returns the result of every executed operation or an error.
+func (s *Storage) ExecuteBatch(ctx context.Context, operations []storage.BatchOperation) ([]storage.BatchResult, error) {
+	const fn = "storage.sqlite.ExecuteBatch"
+
+	var results []storage.BatchResult
+	err := s.write(ctx, func(tx *sql.Tx) (err error) {
+		results, err = s.executeBatch(ctx, tx, operations)
+		return err
+	})
+	if err != nil {
+		return results, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return results, nil
+}
+
+// executeBatch method executes the operations in order within the transaction and stops at the first failure.
+func (s *Storage) executeBatch(ctx context.Context, tx *sql.Tx, operations []storage.BatchOperation) ([]storage.BatchResult, error) {
+	results := make([]storage.BatchResult, 0, len(operations))
+
+	for i, operation := range operations {
+		result := storage.BatchResult{
+			Index: i,
+			Op:    operation.Op,
+			IIN:   operation.IIN,
+		}
+
+		switch operation.Op {
+		case storage.OperationCreate:
+			result.Version, result.Err = s.savePerson(ctx, tx, operation.IIN, operation.Name, operation.Phone)
+		case storage.OperationUpdate:
+			result.Version, result.Err = s.updatePerson(ctx, tx, operation.IIN, operation.Name, operation.Phone, []int64{operation.ExpectedVersion})
+		case storage.OperationDelete:
+			result.Err = s.deletePerson(ctx, tx, operation.IIN, []int64{operation.ExpectedVersion})
+		default:
+			result.Err = storage.ErrorUnknownOperation
+		}
+
+		results = append(results, result)
+		if result.Err != nil {
+			return results, fmt.Errorf("operation %d: %w", i, result.Err)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/photos"
+	"citizen_webservice/internal/storage"
+	"citizen_webservice/internal/storage/storagetest"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "wal", opts: wal},
+		{name: "rollback journal", opts: Options{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+				return newTestStorage(t, tt.opts)
+			})
+		})
+	}
+
+	t.Run("directory photos", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+			s := newTestStorage(t, wal)
+			dir, err := photos.NewDirectory(t.TempDir(), s)
+			require.NoError(t, err)
+			return &directoryStorage{
+				Storage:   s,
+				dir:       dir,
+				following: photos.Follow(slog.New(slog.NewTextHandler(io.Discard, nil)), s, dir),
+			}
+		})
+	})
+}
+
+// directoryStorage is the storage as the service puts it together with photos.storage set to directory:
+// the photos are kept in a photos.Directory, which follows the deletions and merges of people
+// and checks legal holds with the storage, and are only saved for stored people, as the upload handler checks.
+type directoryStorage struct {
+	*Storage
+	dir       *photos.Directory
+	following *photos.Following
+}
+
+func (s *directoryStorage) SavePhoto(ctx context.Context, photo storage.Photo, image []byte, thumbnail []byte) (storage.Photo, error) {
+	if _, err := s.Storage.GetPersonByIIN(ctx, photo.IIN); err != nil {
+		return storage.Photo{}, err
+	}
+	return s.dir.SavePhoto(ctx, photo, image, thumbnail)
+}
+
+func (s *directoryStorage) GetPhoto(ctx context.Context, iin string) (storage.Photo, []byte, error) {
+	return s.dir.GetPhoto(ctx, iin)
+}
+
+func (s *directoryStorage) GetPhotoThumbnail(ctx context.Context, iin string) (storage.Photo, []byte, error) {
+	return s.dir.GetPhotoThumbnail(ctx, iin)
+}
+
+func (s *directoryStorage) GetPhotoDescription(ctx context.Context, iin string) (storage.Photo, error) {
+	return s.dir.GetPhotoDescription(ctx, iin)
+}
+
+func (s *directoryStorage) DeletePhoto(ctx context.Context, iin string) error {
+	return s.dir.DeletePhoto(ctx, iin)
+}
+
+func (s *directoryStorage) DeletePersonByIIN(ctx context.Context, iin string, expectedVersions ...int64) error {
+	return s.following.DeletePersonByIIN(ctx, iin, expectedVersions...)
+}
+
+func (s *directoryStorage) ExecuteBatch(ctx context.Context, operations []storage.BatchOperation) ([]storage.BatchResult, error) {
+	return s.following.ExecuteBatch(ctx, operations)
+}
+
+func (s *directoryStorage) MergePeople(ctx context.Context, sourceIIN string, targetIIN string, expectedVersions ...int64) (storage.MergeRecord, error) {
+	return s.following.MergePeople(ctx, sourceIIN, targetIIN, expectedVersions...)
+}
@@ -0,0 +1,158 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GrantConsent method records that the person stored under the IIN in the tenant of the context consents to sharing their phone for the purpose.
+// Granting a consent again renews it.
+// It returns the recorded Consent struct or an error, storage.ErrorIINNotFound if there is no such person.
+func (s *Storage) GrantConsent(ctx context.Context, iin string, purpose string, source string) (storage.Consent, error) {
+	const fn = "storage.sqlite.GrantConsent"
+
+	var consent storage.Consent
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, iin); err != nil {
+			return err
+		}
+
+		var exists bool
+		if err := tx.Stmt(s.stmts.personExists).QueryRow(storage.TenantID(ctx), iin).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrorIINNotFound
+		}
+
+		var err error
+		consent, err = s.saveConsent(ctx, tx, iin, purpose, storage.ConsentGranted, source)
+		return err
+	})
+	if err != nil {
+		return storage.Consent{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return consent, nil
+}
+
+// RevokeConsent method records that the person withdraws the consent granted for the purpose.
+// It returns the recorded Consent struct or an error, storage.ErrorConsentNotFound if no consent is granted.
+func (s *Storage) RevokeConsent(ctx context.Context, iin string, purpose string, source string) (storage.Consent, error) {
+	const fn = "storage.sqlite.RevokeConsent"
+
+	var consent storage.Consent
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, iin); err != nil {
+			return err
+		}
+
+		granted, err := s.hasConsent(ctx, tx, iin, purpose)
+		if err != nil {
+			return err
+		}
+		if !granted {
+			return storage.ErrorConsentNotFound
+		}
+
+		consent, err = s.saveConsent(ctx, tx, iin, purpose, storage.ConsentRevoked, source)
+		return err
+	})
+	if err != nil {
+		return storage.Consent{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return consent, nil
+}
+
+// HasConsent method reports whether the person stored under the IIN currently consents to the purpose.
+// It returns true if the latest consent entry for the purpose is a grant, or an error.
+func (s *Storage) HasConsent(ctx context.Context, iin string, purpose string) (bool, error) {
+	const fn = "storage.sqlite.HasConsent"
+
+	granted, err := s.hasConsent(ctx, nil, iin, purpose)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return granted, nil
+}
+
+// GetConsents method retrieves the current consent of the person for every purpose it was ever given for,
+// in purpose order. Revoked consents are included with their revocation.
+// It returns a slice of Consent structs or an error.
+func (s *Storage) GetConsents(ctx context.Context, iin string) ([]storage.Consent, error) {
+	const fn = "storage.sqlite.GetConsents"
+
+	consents, err := scanConsents(s.stmts.getConsents.Query(storage.TenantID(ctx), iin))
+	if err != nil {
+		return consents, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return consents, nil
+}
+
+// GetConsentHistory method retrieves every grant and revocation of the person, oldest first.
+// It returns a slice of Consent structs or an error.
+func (s *Storage) GetConsentHistory(ctx context.Context, iin string) ([]storage.Consent, error) {
+	const fn = "storage.sqlite.GetConsentHistory"
+
+	consents, err := scanConsents(s.stmts.getConsentHistory.Query(storage.TenantID(ctx), iin))
+	if err != nil {
+		return consents, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return consents, nil
+}
+
+// hasConsent method reports whether the latest consent entry of the IIN for the purpose is a grant.
+func (s *Storage) hasConsent(ctx context.Context, tx *sql.Tx, iin string, purpose string) (bool, error) {
+	var status string
+	err := stmt(tx, s.stmts.getConsentStatus).QueryRow(storage.TenantID(ctx), iin, purpose).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return status == storage.ConsentGranted, nil
+}
+
+// saveConsent method appends an entry to the consent history within the transaction.
+func (s *Storage) saveConsent(ctx context.Context, tx *sql.Tx, iin string, purpose string, status string, source string) (storage.Consent, error) {
+	consent := storage.Consent{
+		IIN:       iin,
+		Purpose:   purpose,
+		Status:    status,
+		Source:    source,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err := tx.Stmt(s.stmts.saveConsent).QueryRow(storage.TenantID(ctx), iin, purpose, status, source, consent.CreatedAt).Scan(&consent.ID)
+	return consent, err
+}
+
+// scanConsents scans the result rows into Consent structs and closes the rows.
+func scanConsents(rows *sql.Rows, err error) ([]storage.Consent, error) {
+	consents := []storage.Consent{}
+	if err != nil {
+		return consents, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		consent := storage.Consent{}
+		err = rows.Scan(&consent.ID, &consent.IIN, &consent.Purpose, &consent.Status, &consent.Source, &consent.CreatedAt)
+		if err != nil {
+			return consents, err
+		}
+		consents = append(consents, consent)
+	}
+
+	return consents, rows.Err()
+}
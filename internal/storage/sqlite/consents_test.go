@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsentHistory(t *testing.T) {
+	s := newTestStorage(t, wal)
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+
+	_, err := s.GrantConsent(ctx, "980301450725", "marketing", "form")
+	require.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.RevokeConsent(ctx, "830218350074", "marketing", "form")
+	require.ErrorIs(t, err, storage.ErrorConsentNotFound)
+
+	granted, err := s.GrantConsent(ctx, "830218350074", "marketing", "form")
+	require.NoError(t, err)
+	assert.Equal(t, storage.ConsentGranted, granted.Status)
+	_, err = s.GrantConsent(ctx, "830218350074", "delivery", "portal")
+	require.NoError(t, err)
+
+	ok, err := s.HasConsent(ctx, "830218350074", "marketing")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	revoked, err := s.RevokeConsent(ctx, "830218350074", "marketing", "call center")
+	require.NoError(t, err)
+	assert.Equal(t, storage.ConsentRevoked, revoked.Status)
+	assert.Greater(t, revoked.ID, granted.ID)
+
+	ok, err = s.HasConsent(ctx, "830218350074", "marketing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = s.HasConsent(ctx, "830218350074", "unknown")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	consents, err := s.GetConsents(ctx, "830218350074")
+	require.NoError(t, err)
+	require.Len(t, consents, 2)
+	assert.Equal(t, "delivery", consents[0].Purpose)
+	assert.Equal(t, storage.ConsentGranted, consents[0].Status)
+	assert.Equal(t, "marketing", consents[1].Purpose)
+	assert.Equal(t, storage.ConsentRevoked, consents[1].Status)
+	assert.Equal(t, "call center", consents[1].Source)
+
+	consents, err = s.GetConsents(ctx, "980301450725")
+	require.NoError(t, err)
+	assert.Empty(t, consents)
+}
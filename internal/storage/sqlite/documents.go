@@ -0,0 +1,196 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// documentColumns are the columns read by scanDocument.
+const documentColumns = "id, iin, type, number, issuing_authority, issue_date, expiry_date, created_at, updated_at"
+
+// SaveDocument method saves an identity document of the person stored under its IIN in the tenant of the context.
+// It returns the saved Document struct or an error, storage.ErrorIINNotFound if there is no such person
+// and storage.ErrorDocumentExists if the tenant already holds a document of the same type and number.
+func (s *Storage) SaveDocument(ctx context.Context, document storage.Document) (storage.Document, error) {
+	const fn = "storage.sqlite.SaveDocument"
+
+	tenant := storage.TenantID(ctx)
+	document.CreatedAt = time.Now().UTC()
+	document.UpdatedAt = document.CreatedAt
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, document.IIN); err != nil {
+			return err
+		}
+
+		var exists bool
+		if err := tx.Stmt(s.stmts.personExists).QueryRow(tenant, document.IIN).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrorIINNotFound
+		}
+
+		err := tx.Stmt(s.stmts.saveDocument).QueryRow(tenant, document.IIN, document.Type, document.Number,
+			document.IssuingAuthority, document.IssueDate, document.ExpiryDate, document.CreatedAt, document.UpdatedAt,
+		).Scan(&document.ID)
+		return documentError(err)
+	})
+	if err != nil {
+		return storage.Document{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return document, nil
+}
+
+// GetDocument method retrieves a document of the person stored under the IIN in the tenant of the context.
+// It returns a Document struct or an error, storage.ErrorDocumentNotFound if the person holds no such document.
+func (s *Storage) GetDocument(ctx context.Context, iin string, id int64) (storage.Document, error) {
+	const fn = "storage.sqlite.GetDocument"
+
+	document, err := scanDocument(s.stmts.getDocument.QueryRow(storage.TenantID(ctx), iin, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Document{}, fmt.Errorf("%s: %w", fn, storage.ErrorDocumentNotFound)
+	}
+	if err != nil {
+		return storage.Document{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return document, nil
+}
+
+// GetDocuments method retrieves every document of the person stored under the IIN, oldest first.
+// It returns a slice of Document structs or an error.
+func (s *Storage) GetDocuments(ctx context.Context, iin string) ([]storage.Document, error) {
+	const fn = "storage.sqlite.GetDocuments"
+
+	documents, err := scanDocuments(s.stmts.getDocuments.Query(storage.TenantID(ctx), iin))
+	if err != nil {
+		return documents, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return documents, nil
+}
+
+// GetExpiringDocuments method retrieves the documents of the tenant of the context expiring between
+// the from and to days, both given as YYYY-MM-DD and included, in expiry order.
+// It returns a slice of Document structs or an error.
+func (s *Storage) GetExpiringDocuments(ctx context.Context, from string, to string) ([]storage.Document, error) {
+	const fn = "storage.sqlite.GetExpiringDocuments"
+
+	documents, err := scanDocuments(s.stmts.getExpiringDocuments.Query(storage.TenantID(ctx), from, to))
+	if err != nil {
+		return documents, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return documents, nil
+}
+
+// UpdateDocument method replaces the type, number, issuing authority and dates of the document
+// identified by the ID and IIN of the given one.
+// It returns the updated Document struct or an error, storage.ErrorDocumentNotFound if the person holds
+// no such document and storage.ErrorDocumentExists if another document has the same type and number.
+func (s *Storage) UpdateDocument(ctx context.Context, document storage.Document) (storage.Document, error) {
+	const fn = "storage.sqlite.UpdateDocument"
+
+	tenant := storage.TenantID(ctx)
+	var updated storage.Document
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, document.IIN); err != nil {
+			return err
+		}
+
+		result, err := tx.Stmt(s.stmts.updateDocument).Exec(document.Type, document.Number, document.IssuingAuthority,
+			document.IssueDate, document.ExpiryDate, time.Now().UTC(), tenant, document.IIN, document.ID)
+		if err != nil {
+			return documentError(err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return storage.ErrorDocumentNotFound
+		}
+
+		updated, err = scanDocument(tx.Stmt(s.stmts.getDocument).QueryRow(tenant, document.IIN, document.ID))
+		return err
+	})
+	if err != nil {
+		return storage.Document{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return updated, nil
+}
+
+// DeleteDocument method deletes a document of the person stored under the IIN in the tenant of the context.
+// It returns an error, storage.ErrorDocumentNotFound if the person holds no such document.
+func (s *Storage) DeleteDocument(ctx context.Context, iin string, id int64) error {
+	const fn = "storage.sqlite.DeleteDocument"
+
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, iin); err != nil {
+			return err
+		}
+
+		result, err := tx.Stmt(s.stmts.deleteDocument).Exec(storage.TenantID(ctx), iin, id)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return storage.ErrorDocumentNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// documentError is a helper function to report a write violating the uniqueness of document numbers
+// as storage.ErrorDocumentExists.
+func documentError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+		return storage.ErrorDocumentExists
+	}
+	return err
+}
+
+// scanDocument scans a row of documentColumns into a Document struct.
+func scanDocument(row rowScanner) (storage.Document, error) {
+	var document storage.Document
+	err := row.Scan(&document.ID, &document.IIN, &document.Type, &document.Number, &document.IssuingAuthority,
+		&document.IssueDate, &document.ExpiryDate, &document.CreatedAt, &document.UpdatedAt)
+	return document, err
+}
+
+// scanDocuments scans the result rows into Document structs and closes the rows.
+func scanDocuments(rows *sql.Rows, err error) ([]storage.Document, error) {
+	documents := []storage.Document{}
+	if err != nil {
+		return documents, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		document, err := scanDocument(rows)
+		if err != nil {
+			return documents, err
+		}
+		documents = append(documents, document)
+	}
+
+	return documents, rows.Err()
+}
@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// nameBlock is the expression of the first letters of the name of a person,
+// which the users_name_block index is built on.
+const nameBlock = "substr(lower(trim(name)), 1, 3)"
+
+// candidatePairsQuery pairs every person of a page with the people of a higher IIN who were born on the same day,
+// as the first six digits of their IINs tell, or whose names start with the same letters.
+// Both joins are served by an index, so that only the people sharing a block are compared,
+// the planner otherwise preferring the primary key for the names.
+// The pairs of the first person of the page only follow the given second IIN, if any, when a page is resumed,
+// and each join as well as the result is cut at the given number of pairs.
+const candidatePairsQuery = `
+ SELECT * FROM (
+  SELECT a.iin, a.name, a.phone, a.version, a.status, a.attributes, b.iin, b.name, b.phone, b.version, b.status, b.attributes
+  FROM users a JOIN users b ON b.tenant = a.tenant AND b.iin > a.iin AND b.iin <= substr(a.iin, 1, 6) || '999999'
+  WHERE a.tenant = ?1 AND (a.iin > ?2 OR (a.iin = ?2 AND b.iin > ?3)) AND a.iin <= ?4
+  ORDER BY 1, 7 LIMIT ?5)
+ UNION
+ SELECT * FROM (
+  SELECT a.iin, a.name, a.phone, a.version, a.status, a.attributes, b.iin, b.name, b.phone, b.version, b.status, b.attributes
+  FROM users a JOIN users b INDEXED BY users_name_block
+   ON b.tenant = a.tenant AND substr(lower(trim(b.name)), 1, 3) = substr(lower(trim(a.name)), 1, 3) AND b.iin > a.iin
+  WHERE a.tenant = ?1 AND (a.iin > ?2 OR (a.iin = ?2 AND b.iin > ?3)) AND a.iin <= ?4
+  ORDER BY 1, 7 LIMIT ?5)
+ ORDER BY 1, 7 LIMIT ?5;`
+
+// cursorSeparator separates the IINs of the last pair returned in the cursor of a page cut at the number of pairs.
+const cursorSeparator = ":"
+
+// GetCandidatePairs method retrieves the pairs of people of the tenant of the context who may describe the same citizen,
+// for a page of at most limit people with an IIN greater than after, each paired with the people of a higher IIN
+// born on the same day or whose names start with the same three letters.
+// Every pair is therefore returned once, on the page of its first person.
+// A page holds at most maxPairs pairs, which must be positive, so that people sharing a block with many others cannot blow it up:
+// once they are reached, the page ends and its cursor holds the IINs of the last pair, which the next page resumes after.
+// It returns the pairs ordered by IIN, the cursor to pass as after for the next page, empty after the last one, or an error.
+func (s *Storage) GetCandidatePairs(ctx context.Context, after string, limit int, maxPairs int) ([]storage.PersonPair, string, error) {
+	const fn = "storage.sqlite.GetCandidatePairs"
+
+	tenant := storage.TenantID(ctx)
+	pairs := []storage.PersonPair{}
+
+	// A resumed page starts with the person of the last pair returned, whose pairs follow its second IIN
+	first, second, resumed := strings.Cut(after, cursorSeparator)
+	var resume any
+	if resumed {
+		resume = first
+	}
+
+	var people int
+	var last string
+	if err := s.stmts.getCandidatePage.QueryRow(tenant, first, resume, limit).Scan(&people, &last); err != nil {
+		return pairs, "", fmt.Errorf("%s: %w", fn, err)
+	}
+	if people == 0 {
+		return pairs, "", nil
+	}
+
+	var from any
+	if resumed {
+		from = second
+	}
+	rows, err := s.stmts.getCandidatePairs.Query(tenant, first, from, last, maxPairs+1)
+	if err != nil {
+		return pairs, "", fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		pair, err := scanPersonPair(rows)
+		if err != nil {
+			return pairs, "", fmt.Errorf("%s: %w", fn, err)
+		}
+		pairs = append(pairs, pair)
+	}
+	if err = rows.Err(); err != nil {
+		return pairs, "", fmt.Errorf("%s: %w", fn, err)
+	}
+
+	// A page holding more pairs than allowed is cut after the last one it returns
+	if len(pairs) > maxPairs {
+		pairs = pairs[:maxPairs]
+		cut := pairs[maxPairs-1]
+		return pairs, cut.First.IIN + cursorSeparator + cut.Second.IIN, nil
+	}
+
+	// A short page is the last one
+	if people < limit {
+		last = ""
+	}
+	return pairs, last, nil
+}
+
+// scanPersonPair scans a row of the personColumns of both people into a PersonPair struct.
+func scanPersonPair(row rowScanner) (storage.PersonPair, error) {
+	var pair storage.PersonPair
+	var first, second sql.NullString
+	err := row.Scan(&pair.First.IIN, &pair.First.Name, &pair.First.Phone, &pair.First.Version, &pair.First.Status, &first,
+		&pair.Second.IIN, &pair.Second.Name, &pair.Second.Phone, &pair.Second.Version, &pair.Second.Status, &second)
+	if err != nil {
+		return pair, err
+	}
+
+	if first.Valid {
+		if err = json.Unmarshal([]byte(first.String), &pair.First.Attributes); err != nil {
+			return pair, err
+		}
+	}
+	if second.Valid {
+		err = json.Unmarshal([]byte(second.String), &pair.Second.Attributes)
+	}
+	return pair, err
+}
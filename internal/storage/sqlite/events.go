@@ -0,0 +1,142 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// eventColumns are the columns read by scanEvents.
+const eventColumns = "tenant_seq, seq, tenant, type, iin, payload, request_id, created_at"
+
+// saveEvent method writes a change event to the outbox within the transaction of the change,
+// numbered after the last event of the tenant of the context.
+func (s *Storage) saveEvent(ctx context.Context, tx *sql.Tx, eventType string, payload storage.EventPayload) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	tenant := storage.TenantID(ctx)
+	if _, err = stmt(tx, s.stmts.nextEventSeq).Exec(tenant); err != nil {
+		return err
+	}
+	_, err = stmt(tx, s.stmts.saveEvent).Exec(tenant, eventType, payload.IIN, string(encoded), storage.RequestID(ctx), time.Now().UTC())
+	return err
+}
+
+// GetEvents method retrieves at most limit change events of the tenant of the context
+// with a sequence number greater than after, in sequence order.
+// It returns a slice of Event structs or an error.
+func (s *Storage) GetEvents(ctx context.Context, after int64, limit int) ([]storage.Event, error) {
+	const fn = "storage.sqlite.GetEvents"
+
+	events, err := scanEvents(s.stmts.getEvents.Query(storage.TenantID(ctx), after, limit))
+	if err != nil {
+		return events, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return events, nil
+}
+
+// GetOutboxEvents method retrieves at most limit change events of every tenant
+// with an outbox sequence number greater than after, in outbox order.
+// It returns a slice of Event structs or an error.
+func (s *Storage) GetOutboxEvents(after int64, limit int) ([]storage.Event, error) {
+	const fn = "storage.sqlite.GetOutboxEvents"
+
+	events, err := scanEvents(s.stmts.getOutboxEvents.Query(after, limit))
+	if err != nil {
+		return events, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return events, nil
+}
+
+// GetPersonEvents method retrieves every change event of the IIN within the tenant of the context in sequence order.
+// It returns a slice of Event structs or an error.
+func (s *Storage) GetPersonEvents(ctx context.Context, iin string) ([]storage.Event, error) {
+	const fn = "storage.sqlite.GetPersonEvents"
+
+	events, err := scanEvents(s.stmts.getPersonEvents.Query(storage.TenantID(ctx), iin))
+	if err != nil {
+		return events, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return events, nil
+}
+
+// GetLastEventSeq method retrieves the sequence number of the most recent change event of the tenant of the context,
+// zero if there is none. Purged events keep their numbers from being reused.
+// It returns the sequence number or an error.
+func (s *Storage) GetLastEventSeq(ctx context.Context) (int64, error) {
+	const fn = "storage.sqlite.GetLastEventSeq"
+
+	var seq int64
+	if err := s.stmts.getLastEventSeq.QueryRow(storage.TenantID(ctx)).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return seq, nil
+}
+
+// GetOutboxCursor method retrieves the outbox sequence number of the last event published by the consumer,
+// zero if it has not published any.
+// It returns the sequence number or an error.
+func (s *Storage) GetOutboxCursor(consumer string) (int64, error) {
+	const fn = "storage.sqlite.GetOutboxCursor"
+
+	var seq int64
+	err := s.stmts.getOutboxCursor.QueryRow(consumer).Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return seq, nil
+}
+
+// SaveOutboxCursor method records that the consumer has published every event up to seq.
+// The cursor never moves back.
+// It returns an error if the write fails.
+func (s *Storage) SaveOutboxCursor(consumer string, seq int64) error {
+	const fn = "storage.sqlite.SaveOutboxCursor"
+
+	err := s.write(context.Background(), func(tx *sql.Tx) error {
+		_, err := tx.Stmt(s.stmts.saveOutboxCursor).Exec(consumer, seq)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// scanEvents scans the result rows of eventColumns into Event structs and closes the rows.
+func scanEvents(rows *sql.Rows, err error) ([]storage.Event, error) {
+	events := []storage.Event{}
+	if err != nil {
+		return events, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event := storage.Event{}
+		var payload string
+		err = rows.Scan(&event.Seq, &event.OutboxSeq, &event.Tenant, &event.Type, &event.IIN, &payload, &event.RequestID, &event.CreatedAt)
+		if err != nil {
+			return events, err
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,181 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritesRecordEvents(t *testing.T) {
+	s := newTestStorage(t, wal)
+	ctx := operator()
+
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+	_, err := s.UpdatePerson(ctx, "830218350074", "New Name", "+77010000001", 1)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePersonByIIN(ctx, "830218350074", 0))
+
+	// Failed writes record nothing
+	require.ErrorIs(t, s.DeletePersonByIIN(ctx, "830218350074", 0), storage.ErrorIINNotFound)
+	_, err = s.ExecuteBatch(ctx, []storage.BatchOperation{
+		{Op: storage.OperationCreate, IIN: "980301450725", Name: "Test Name", Phone: "+77010000002"},
+		{Op: storage.OperationDelete, IIN: "600426400918"},
+	})
+	require.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	events, err := s.GetEvents(ctx, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	for i, want := range []struct {
+		eventType string
+		payload   storage.EventPayload
+	}{
+		{storage.EventPersonCreated, storage.EventPayload{IIN: "830218350074", Name: "Test Name", Version: 1, Status: storage.StatusActive}},
+		{storage.EventPersonUpdated, storage.EventPayload{IIN: "830218350074", Name: "New Name", Version: 2, Status: storage.StatusActive}},
+		{storage.EventPersonDeleted, storage.EventPayload{IIN: "830218350074"}},
+	} {
+		assert.Equal(t, int64(i+1), events[i].Seq)
+		assert.Equal(t, want.eventType, events[i].Type)
+		assert.Equal(t, "830218350074", events[i].IIN)
+
+		var payload storage.EventPayload
+		require.NoError(t, json.Unmarshal(events[i].Payload, &payload))
+		assert.Equal(t, want.payload, payload)
+	}
+
+	events, err = s.GetEvents(ctx, 2, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, int64(3), events[0].Seq)
+}
+
+func TestEventSequenceHasNoGaps(t *testing.T) {
+	s := newTestStorage(t, wal)
+	ctx := operator()
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every pair of writers competes for the same phone number, so half of the writes are rolled back
+			_ = s.SavePerson(ctx, fmt.Sprintf("%012d", i), "Test Name", fmt.Sprintf("+7%010d", i/2))
+		}(i)
+	}
+	wg.Wait()
+
+	var all []storage.Event
+	for after := int64(0); ; {
+		events, err := s.GetEvents(ctx, after, 10)
+		require.NoError(t, err)
+		if len(events) == 0 {
+			break
+		}
+		all = append(all, events...)
+		after = events[len(events)-1].Seq
+	}
+
+	require.Len(t, all, writers/2)
+	for i, event := range all {
+		assert.Equal(t, int64(i+1), event.Seq)
+	}
+}
+
+func TestEventsRecordRequestID(t *testing.T) {
+	s := newTestStorage(t, wal)
+
+	ctx := storage.WithRequestID(operator(), "host/abc-000001")
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(operator(), "980301450725", "Test Name", "+77010000002"))
+
+	events, err := s.GetEvents(ctx, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "host/abc-000001", events[0].RequestID)
+	assert.Empty(t, events[1].RequestID)
+}
+
+func TestOutboxCursor(t *testing.T) {
+	s := newTestStorage(t, wal)
+
+	seq, err := s.GetOutboxCursor("nats")
+	require.NoError(t, err)
+	assert.Zero(t, seq)
+
+	require.NoError(t, s.SaveOutboxCursor("nats", 5))
+	// The cursor never moves back
+	require.NoError(t, s.SaveOutboxCursor("nats", 3))
+
+	seq, err = s.GetOutboxCursor("nats")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), seq)
+
+	seq, err = s.GetOutboxCursor("other")
+	require.NoError(t, err)
+	assert.Zero(t, seq)
+}
+
+func TestMigrateRemovesPhonesFromEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, wal)
+	require.NoError(t, err)
+
+	// An event stored while the phone was part of the payloads
+	_, err = s.db.Exec(`INSERT INTO events(tenant, tenant_seq, type, iin, payload, request_id, created_at) VALUES(?, 1, ?, ?, ?, '', ?);`,
+		storage.DefaultTenant, storage.EventPersonCreated, "830218350074",
+		`{"iin":"830218350074","name":"Test Name","phone":"+77010000001","version":1}`, time.Now().UTC())
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s, err = New(path, wal)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	events, err := s.GetEvents(operator(), 0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.JSONEq(t, `{"iin":"830218350074","name":"Test Name","version":1}`, string(events[0].Payload))
+}
+
+func TestMigrateNumbersTenantEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, wal)
+	require.NoError(t, err)
+
+	// Events stored while the tenants shared the sequence numbers of the outbox
+	_, err = s.db.Exec(`
+ DROP INDEX events_tenant_seq;
+ ALTER TABLE events DROP COLUMN tenant_seq;
+ DROP TABLE event_sequences;`)
+	require.NoError(t, err)
+	for _, tenant := range []string{storage.DefaultTenant, "health", storage.DefaultTenant} {
+		_, err = s.db.Exec(`INSERT INTO events(tenant, type, iin, payload, request_id, created_at) VALUES(?, ?, ?, '{}', '', ?);`,
+			tenant, storage.EventPersonCreated, "830218350074", time.Now().UTC())
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Close())
+
+	s, err = New(path, wal)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	ctx := operator()
+
+	// The stored events keep their numbers and the next ones follow the last number of the outbox
+	require.NoError(t, s.SavePerson(ctx, "980301450725", "Test Name", "+77010000002"))
+	events, err := s.GetEvents(ctx, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, []int64{1, 3, 4}, []int64{events[0].Seq, events[1].Seq, events[2].Seq})
+	last, err := s.GetLastEventSeq(storage.WithTenant(ctx, "health"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), last)
+}
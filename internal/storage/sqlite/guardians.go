@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetMinorsWithoutGuardian method retrieves the active people of the tenant of the context born after the given day,
+// according to their IIN, whose guardians are all inactive or missing: they have no guardian relationship,
+// or everyone linked to them by one is deceased or emigrated. The relationships of deleted people are deleted with them.
+// The guardian they were saved with is reported, if any. They are ordered by IIN.
+// It returns a slice of Ward structs or an error.
+func (s *Storage) GetMinorsWithoutGuardian(ctx context.Context, bornAfter time.Time) ([]storage.Ward, error) {
+	const fn = "storage.sqlite.GetMinorsWithoutGuardian"
+
+	wards := []storage.Ward{}
+	rows, err := s.stmts.getMinorsWithoutGuardian.Query(storage.TenantID(ctx), bornAfter.Format("2006-01-02"))
+	if err != nil {
+		return wards, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ward storage.Ward
+		if err = rows.Scan(&ward.IIN, &ward.Name, &ward.GuardianIIN); err != nil {
+			return wards, fmt.Errorf("%s: %w", fn, err)
+		}
+		wards = append(wards, ward)
+	}
+	if err = rows.Err(); err != nil {
+		return wards, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return wards, nil
+}
+
+// GetGuardianIIN method retrieves the IIN of the guardian the person stored under the IIN in the tenant of the context
+// was saved with, empty if none.
+// It returns the IIN or an error, storage.ErrorIINNotFound if there is no such person.
+func (s *Storage) GetGuardianIIN(ctx context.Context, iin string) (string, error) {
+	const fn = "storage.sqlite.GetGuardianIIN"
+
+	var guardianIIN string
+	err := s.stmts.getGuardianIIN.QueryRow(storage.TenantID(ctx), iin).Scan(&guardianIIN)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%s: %w", fn, storage.ErrorIINNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return guardianIIN, nil
+}
+
+// checkGuardian method reports storage.ErrorGuardianNotFound if no person is stored under the IIN
+// in the tenant of the context, and storage.ErrorGuardianInactive if they are deceased or emigrated.
+func (s *Storage) checkGuardian(ctx context.Context, tx *sql.Tx, iin string) error {
+	var status string
+	var version int64
+	err := tx.Stmt(s.stmts.getPersonStatus).QueryRow(storage.TenantID(ctx), iin).Scan(&status, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", iin, storage.ErrorGuardianNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if status != storage.StatusActive {
+		return fmt.Errorf("%s is %s: %w", iin, status, storage.ErrorGuardianInactive)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// legalHoldColumns are the columns read by scanLegalHold.
+const legalHoldColumns = "iin, reason, case_number, placed_by, placed_at"
+
+// holdLockStripes is the number of locks the people are spread over by holdLock.
+const holdLockStripes = 64
+
+// PlaceLegalHold method places a legal hold on the person stored under the IIN of the hold in the tenant of the context
+// and records it in the legal hold log. Until the hold is released, every write to the data of the person
+// fails with storage.ErrorLegalHold.
+// It returns the placed LegalHold struct or an error, storage.ErrorIINNotFound if there is no such person
+// and storage.ErrorHoldExists if the person is already under legal hold.
+func (s *Storage) PlaceLegalHold(ctx context.Context, hold storage.LegalHold) (storage.LegalHold, error) {
+	const fn = "storage.sqlite.PlaceLegalHold"
+
+	tenant := storage.TenantID(ctx)
+	lock := s.holdLock(tenant, hold.IIN)
+	lock.Lock()
+	defer lock.Unlock()
+
+	hold.PlacedAt = time.Now().UTC()
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Stmt(s.stmts.personExists).QueryRow(tenant, hold.IIN).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrorIINNotFound
+		}
+
+		_, err := tx.Stmt(s.stmts.placeLegalHold).
+			Exec(tenant, hold.IIN, hold.Reason, hold.CaseNumber, hold.PlacedBy, hold.PlacedAt)
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintPrimaryKey) {
+			return storage.ErrorHoldExists
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = s.saveHoldLog(ctx, tx, storage.HoldLogEntry{
+			IIN:        hold.IIN,
+			Action:     storage.HoldPlaced,
+			Reason:     hold.Reason,
+			CaseNumber: hold.CaseNumber,
+			Client:     hold.PlacedBy,
+			CreatedAt:  hold.PlacedAt,
+		})
+		return err
+	})
+	if err != nil {
+		return storage.LegalHold{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return hold, nil
+}
+
+// ReleaseLegalHold method releases the legal hold on the person stored under the IIN in the tenant of the context
+// and records the release, with its reason and the client releasing it, in the legal hold log.
+// It returns the recorded HoldLogEntry struct or an error, storage.ErrorHoldNotFound if the person is not under legal hold.
+func (s *Storage) ReleaseLegalHold(ctx context.Context, iin string, reason string, client string) (storage.HoldLogEntry, error) {
+	const fn = "storage.sqlite.ReleaseLegalHold"
+
+	tenant := storage.TenantID(ctx)
+	var entry storage.HoldLogEntry
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		hold, err := scanLegalHold(tx.Stmt(s.stmts.getLegalHold).QueryRow(tenant, iin))
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrorHoldNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Stmt(s.stmts.releaseLegalHold).Exec(tenant, iin); err != nil {
+			return err
+		}
+
+		entry, err = s.saveHoldLog(ctx, tx, storage.HoldLogEntry{
+			IIN:        iin,
+			Action:     storage.HoldReleased,
+			Reason:     reason,
+			CaseNumber: hold.CaseNumber,
+			Client:     client,
+			CreatedAt:  time.Now().UTC(),
+		})
+		return err
+	})
+	if err != nil {
+		return storage.HoldLogEntry{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return entry, nil
+}
+
+// GetLegalHold method retrieves the legal hold on the person stored under the IIN in the tenant of the context.
+// It returns a LegalHold struct or an error, storage.ErrorHoldNotFound if the person is not under legal hold.
+func (s *Storage) GetLegalHold(ctx context.Context, iin string) (storage.LegalHold, error) {
+	const fn = "storage.sqlite.GetLegalHold"
+
+	hold, err := scanLegalHold(s.stmts.getLegalHold.QueryRow(storage.TenantID(ctx), iin))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.LegalHold{}, fmt.Errorf("%s: %w", fn, storage.ErrorHoldNotFound)
+	}
+	if err != nil {
+		return storage.LegalHold{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return hold, nil
+}
+
+// GetLegalHolds method retrieves every legal hold of the tenant of the context, oldest first.
+// It returns a slice of LegalHold structs or an error.
+func (s *Storage) GetLegalHolds(ctx context.Context) ([]storage.LegalHold, error) {
+	const fn = "storage.sqlite.GetLegalHolds"
+
+	holds := []storage.LegalHold{}
+	rows, err := s.stmts.getLegalHolds.Query(storage.TenantID(ctx))
+	if err != nil {
+		return holds, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		hold, err := scanLegalHold(rows)
+		if err != nil {
+			return holds, fmt.Errorf("%s: %w", fn, err)
+		}
+		holds = append(holds, hold)
+	}
+	if err = rows.Err(); err != nil {
+		return holds, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return holds, nil
+}
+
+// GetLegalHoldLog method retrieves every placement and release of a legal hold on the person stored under the IIN,
+// oldest first.
+// It returns a slice of HoldLogEntry structs or an error.
+func (s *Storage) GetLegalHoldLog(ctx context.Context, iin string) ([]storage.HoldLogEntry, error) {
+	const fn = "storage.sqlite.GetLegalHoldLog"
+
+	entries := []storage.HoldLogEntry{}
+	rows, err := s.stmts.getHoldLog.Query(storage.TenantID(ctx), iin)
+	if err != nil {
+		return entries, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry storage.HoldLogEntry
+		err = rows.Scan(&entry.ID, &entry.IIN, &entry.Action, &entry.Reason, &entry.CaseNumber, &entry.Client,
+			&entry.CreatedAt)
+		if err != nil {
+			return entries, fmt.Errorf("%s: %w", fn, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return entries, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return entries, nil
+}
+
+// saveHoldLog method appends an entry to the legal hold log within the transaction.
+func (s *Storage) saveHoldLog(ctx context.Context, tx *sql.Tx, entry storage.HoldLogEntry) (storage.HoldLogEntry, error) {
+	err := tx.Stmt(s.stmts.saveHoldLog).QueryRow(storage.TenantID(ctx), entry.IIN, entry.Action, entry.Reason,
+		entry.CaseNumber, entry.Client, entry.CreatedAt).Scan(&entry.ID)
+	return entry, err
+}
+
+// WithoutHold method runs the function once it has checked that the person stored under the IIN
+// in the tenant of the context is not under legal hold, so that no hold is placed on them until it returns.
+// The function runs outside of the writer, so that the stores keeping data outside the database,
+// such as a photos.Directory, respect legal holds without holding up the writes of the database.
+// It returns an error, storage.ErrorLegalHold if the person is under legal hold, or the error of the function.
+func (s *Storage) WithoutHold(ctx context.Context, iin string, change func() error) error {
+	const fn = "storage.sqlite.WithoutHold"
+
+	lock := s.holdLock(storage.TenantID(ctx), iin)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if err := s.checkHold(ctx, nil, iin); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	if err := change(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// holdLock method returns the lock of the person stored under the IIN in the tenant,
+// held exclusively while a legal hold is placed on them and shared while WithoutHold runs a change.
+func (s *Storage) holdLock(tenant string, iin string) *sync.RWMutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(tenant))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(iin))
+	return &s.holdLocks[h.Sum32()%holdLockStripes]
+}
+
+// checkHold method reports storage.ErrorLegalHold if any of the people stored under the IINs
+// in the tenant of the context is under legal hold.
+func (s *Storage) checkHold(ctx context.Context, tx *sql.Tx, iins ...string) error {
+	for _, iin := range iins {
+		var held bool
+		if err := stmt(tx, s.stmts.holdExists).QueryRow(storage.TenantID(ctx), iin).Scan(&held); err != nil {
+			return err
+		}
+		if held {
+			return fmt.Errorf("%s: %w", iin, storage.ErrorLegalHold)
+		}
+	}
+	return nil
+}
+
+// scanLegalHold scans a row of legalHoldColumns into a LegalHold struct.
+func scanLegalHold(row rowScanner) (storage.LegalHold, error) {
+	var hold storage.LegalHold
+	err := row.Scan(&hold.IIN, &hold.Reason, &hold.CaseNumber, &hold.PlacedBy, &hold.PlacedAt)
+	return hold, err
+}
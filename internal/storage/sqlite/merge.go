@@ -0,0 +1,176 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MergePeople method merges the person stored under sourceIIN into the person stored under targetIIN.
+// The name and phone of the target are kept, the documents and employments of the source are moved to the target,
+// as are its photo and addresses unless the target has its own, the extension attributes of the source are added
+// to those of the target, which wins for the fields both have, the source record is removed and the merge is written
+// to the merge log, all as a single atomic write. The relationships of the source are removed with it, as the date
+// of birth of the target may not agree with them, except for its guardian relationships, which are moved
+// to the target so that neither the wards of the source nor the source as a ward lose their guardian. The version of the target is bumped and an update event
+// is recorded for it. If expected versions are given, the merge only happens while the version of the target
+// is still one of them, or, if it is storage.AnyVersion, while the target exists. Zero matches any version.
+// It returns the merge log entry or an error, storage.ErrorGuardianInactive if the source is the guardian of anyone
+// and the target is deceased or emigrated, storage.ErrorEmploymentOverlaps if an employment of the source
+// shares a day with one of the target at the same organization, and attributes.ErrorInvalidValue if the combined
+// extension attributes of the target no longer match the schemas of their namespaces.
+func (s *Storage) MergePeople(ctx context.Context, sourceIIN string, targetIIN string, expectedVersions ...int64) (storage.MergeRecord, error) {
+	const fn = "storage.sqlite.MergePeople"
+
+	var record storage.MergeRecord
+	err := s.write(ctx, func(tx *sql.Tx) (err error) {
+		record, err = s.mergePeople(ctx, tx, sourceIIN, targetIIN, expectedVersions)
+		return err
+	})
+	if err != nil {
+		return storage.MergeRecord{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return record, nil
+}
+
+// mergePeople method moves the documents, photo, addresses, employments, attributes and guardian relationships
+// of the source person to the target, bumps the version of the target, removes the source person and writes the merge log entry
+// along with the change events within the transaction.
+func (s *Storage) mergePeople(ctx context.Context, tx *sql.Tx, sourceIIN string, targetIIN string, expectedVersions []int64) (storage.MergeRecord, error) {
+	record := storage.MergeRecord{
+		SourceIIN: sourceIIN,
+		TargetIIN: targetIIN,
+		MergedAt:  time.Now().UTC(),
+	}
+
+	if err := s.checkHold(ctx, tx, sourceIIN, targetIIN); err != nil {
+		return record, err
+	}
+
+	tenant := storage.TenantID(ctx)
+	err := tx.Stmt(s.stmts.getNameAndPhone).QueryRow(tenant, sourceIIN).Scan(&record.SourceName, &record.SourcePhone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return record, fmt.Errorf("source %s: %w", sourceIIN, storage.ErrorIINNotFound)
+	}
+	if err != nil {
+		return record, err
+	}
+
+	target := storage.EventPayload{IIN: targetIIN}
+	err = tx.Stmt(s.stmts.touchPerson).QueryRow(tenant, targetIIN, versionsJSON(expectedVersions)).
+		Scan(&record.TargetName, &record.TargetPhone, &target.Version, &target.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return record, fmt.Errorf("target %s: %w", targetIIN, s.missingOrStale(ctx, tx, targetIIN, expectedVersions))
+	}
+	if err != nil {
+		return record, err
+	}
+	target.Name, record.TargetVersion = record.TargetName, target.Version
+
+	if _, err = tx.Stmt(s.stmts.moveDocuments).Exec(targetIIN, record.MergedAt, tenant, sourceIIN); err != nil {
+		return record, err
+	}
+	if _, err = tx.Stmt(s.stmts.movePhoto).Exec(targetIIN, tenant, sourceIIN); err != nil {
+		return record, err
+	}
+	if _, err = tx.Stmt(s.stmts.moveAddresses).Exec(targetIIN, record.MergedAt, tenant, sourceIIN); err != nil {
+		return record, err
+	}
+	if err = s.checkMovedOverlaps(tx, tenant, sourceIIN, targetIIN); err != nil {
+		return record, err
+	}
+	if _, err = tx.Stmt(s.stmts.moveEmployments).Exec(targetIIN, record.MergedAt, tenant, sourceIIN); err != nil {
+		return record, err
+	}
+	result, err := tx.Stmt(s.stmts.moveAttributes).Exec(sourceIIN, tenant, targetIIN, sourceIIN)
+	if err != nil {
+		return record, err
+	}
+	if moved, err := result.RowsAffected(); err != nil {
+		return record, err
+	} else if moved > 0 {
+		if err = s.checkAttributes(ctx, tx, targetIIN); err != nil {
+			return record, fmt.Errorf("merged attributes of %s: %w", targetIIN, err)
+		}
+	}
+	if result, err = tx.Stmt(s.stmts.moveWards).Exec(targetIIN, tenant, sourceIIN); err != nil {
+		return record, err
+	}
+	if moved, err := result.RowsAffected(); err != nil {
+		return record, err
+	} else if moved > 0 {
+		if err = s.checkGuardian(ctx, tx, targetIIN); err != nil {
+			return record, fmt.Errorf("target %s: %w", targetIIN, err)
+		}
+	}
+	if _, err = tx.Stmt(s.stmts.moveGuardians).Exec(targetIIN, tenant, sourceIIN); err != nil {
+		return record, err
+	}
+	if err = s.deletePerson(ctx, tx, sourceIIN, nil); err != nil {
+		return record, err
+	}
+	if err = s.saveEvent(ctx, tx, storage.EventPersonUpdated, target); err != nil {
+		return record, err
+	}
+
+	result, err = tx.Stmt(s.stmts.saveMergeRecord).Exec(tenant,
+		record.SourceIIN, record.SourceName, record.SourcePhone,
+		record.TargetIIN, record.TargetName, record.TargetPhone, record.TargetVersion, record.MergedAt)
+	if err != nil {
+		return record, err
+	}
+
+	record.ID, err = result.LastInsertId()
+	return record, err
+}
+
+// GetMergeLog method retrieves the merge log of the tenant of the context, most recent merges first.
+// It returns a slice of MergeRecord structs or an error.
+func (s *Storage) GetMergeLog(ctx context.Context) ([]storage.MergeRecord, error) {
+	const fn = "storage.sqlite.GetMergeLog"
+
+	records, err := scanMerges(s.stmts.getMergeLog.Query(storage.TenantID(ctx)))
+	if err != nil {
+		return records, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return records, nil
+}
+
+// GetPersonMerges method retrieves the merges the IIN took part in, as source or target, oldest first.
+// It returns a slice of MergeRecord structs or an error.
+func (s *Storage) GetPersonMerges(ctx context.Context, iin string) ([]storage.MergeRecord, error) {
+	const fn = "storage.sqlite.GetPersonMerges"
+
+	records, err := scanMerges(s.stmts.getPersonMerges.Query(storage.TenantID(ctx), iin, iin))
+	if err != nil {
+		return records, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return records, nil
+}
+
+// scanMerges scans the result rows into MergeRecord structs and closes the rows.
+func scanMerges(rows *sql.Rows, err error) ([]storage.MergeRecord, error) {
+	records := []storage.MergeRecord{}
+	if err != nil {
+		return records, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record := storage.MergeRecord{}
+		err = rows.Scan(&record.ID, &record.SourceIIN, &record.SourceName, &record.SourcePhone,
+			&record.TargetIIN, &record.TargetName, &record.TargetPhone, &record.TargetVersion, &record.MergedAt)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
@@ -0,0 +1,318 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// employmentColumns are the columns read by scanEmployment.
+const employmentColumns = "id, iin, bin, position, start_date, end_date, created_at, updated_at"
+
+// SaveOrganization method saves an organization in the tenant of the context.
+// It returns the saved Organization struct or an error, storage.ErrorOrganizationExists if the tenant
+// already holds an organization with the same BIN.
+func (s *Storage) SaveOrganization(ctx context.Context, bin string, name string) (storage.Organization, error) {
+	const fn = "storage.sqlite.SaveOrganization"
+
+	organization := storage.Organization{BIN: bin, Name: name, CreatedAt: time.Now().UTC()}
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		_, err := tx.Stmt(s.stmts.saveOrganization).Exec(storage.TenantID(ctx), bin, name, organization.CreatedAt)
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintPrimaryKey) {
+			return storage.ErrorOrganizationExists
+		}
+		return err
+	})
+	if err != nil {
+		return storage.Organization{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return organization, nil
+}
+
+// GetOrganization method retrieves the organization with the BIN in the tenant of the context.
+// It returns an Organization struct or an error, storage.ErrorOrganizationNotFound if there is no such organization.
+func (s *Storage) GetOrganization(ctx context.Context, bin string) (storage.Organization, error) {
+	const fn = "storage.sqlite.GetOrganization"
+
+	var organization storage.Organization
+	err := s.stmts.getOrganization.QueryRow(storage.TenantID(ctx), bin).
+		Scan(&organization.BIN, &organization.Name, &organization.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Organization{}, fmt.Errorf("%s: %w", fn, storage.ErrorOrganizationNotFound)
+	}
+	if err != nil {
+		return storage.Organization{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return organization, nil
+}
+
+// GetOrganizations method retrieves every organization of the tenant of the context, ordered by BIN.
+// It returns a slice of Organization structs or an error.
+func (s *Storage) GetOrganizations(ctx context.Context) ([]storage.Organization, error) {
+	const fn = "storage.sqlite.GetOrganizations"
+
+	organizations := []storage.Organization{}
+	rows, err := s.stmts.getOrganizations.Query(storage.TenantID(ctx))
+	if err != nil {
+		return organizations, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var organization storage.Organization
+		if err = rows.Scan(&organization.BIN, &organization.Name, &organization.CreatedAt); err != nil {
+			return organizations, fmt.Errorf("%s: %w", fn, err)
+		}
+		organizations = append(organizations, organization)
+	}
+	if err = rows.Err(); err != nil {
+		return organizations, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return organizations, nil
+}
+
+// SaveEmployment method saves a period the person stored under its IIN works at the organization stored under its BIN,
+// both in the tenant of the context.
+// It returns the saved Employment struct or an error, storage.ErrorIINNotFound or storage.ErrorOrganizationNotFound
+// if there is no such person or organization, and storage.ErrorEmploymentOverlaps if the person already works
+// at the organization during the period.
+func (s *Storage) SaveEmployment(ctx context.Context, employment storage.Employment) (storage.Employment, error) {
+	const fn = "storage.sqlite.SaveEmployment"
+
+	tenant := storage.TenantID(ctx)
+	employment.CreatedAt = time.Now().UTC()
+	employment.UpdatedAt = employment.CreatedAt
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, employment.IIN); err != nil {
+			return err
+		}
+
+		var exists bool
+		if err := tx.Stmt(s.stmts.personExists).QueryRow(tenant, employment.IIN).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrorIINNotFound
+		}
+		if err := tx.Stmt(s.stmts.organizationExists).QueryRow(tenant, employment.BIN).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrorOrganizationNotFound
+		}
+		if err := s.checkOverlap(tx, tenant, employment); err != nil {
+			return err
+		}
+
+		return tx.Stmt(s.stmts.saveEmployment).QueryRow(tenant, employment.IIN, employment.BIN, employment.Position,
+			employment.StartDate, employment.EndDate, employment.CreatedAt, employment.UpdatedAt,
+		).Scan(&employment.ID)
+	})
+	if err != nil {
+		return storage.Employment{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return employment, nil
+}
+
+// GetEmployments method retrieves every employment of the person stored under the IIN, earliest first.
+// It returns a slice of Employment structs or an error.
+func (s *Storage) GetEmployments(ctx context.Context, iin string) ([]storage.Employment, error) {
+	const fn = "storage.sqlite.GetEmployments"
+
+	employments := []storage.Employment{}
+	rows, err := s.stmts.getEmployments.Query(storage.TenantID(ctx), iin)
+	if err != nil {
+		return employments, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		employment, err := scanEmployment(rows)
+		if err != nil {
+			return employments, fmt.Errorf("%s: %w", fn, err)
+		}
+		employments = append(employments, employment)
+	}
+	if err = rows.Err(); err != nil {
+		return employments, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return employments, nil
+}
+
+// UpdateEmployment method replaces the position and dates of the employment identified by the ID and IIN
+// of the given one. The organization of an employment does not change.
+// It returns the updated Employment struct or an error, storage.ErrorEmploymentNotFound if the person has
+// no such employment and storage.ErrorEmploymentOverlaps if the new period overlaps another one at the organization.
+func (s *Storage) UpdateEmployment(ctx context.Context, employment storage.Employment) (storage.Employment, error) {
+	const fn = "storage.sqlite.UpdateEmployment"
+
+	tenant := storage.TenantID(ctx)
+	var updated storage.Employment
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, employment.IIN); err != nil {
+			return err
+		}
+
+		current, err := scanEmployment(tx.Stmt(s.stmts.getEmployment).QueryRow(tenant, employment.IIN, employment.ID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrorEmploymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		employment.BIN = current.BIN
+		if err = s.checkOverlap(tx, tenant, employment); err != nil {
+			return err
+		}
+
+		_, err = tx.Stmt(s.stmts.updateEmployment).Exec(employment.Position, employment.StartDate, employment.EndDate,
+			time.Now().UTC(), tenant, employment.IIN, employment.ID)
+		if err != nil {
+			return err
+		}
+
+		updated, err = scanEmployment(tx.Stmt(s.stmts.getEmployment).QueryRow(tenant, employment.IIN, employment.ID))
+		return err
+	})
+	if err != nil {
+		return storage.Employment{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return updated, nil
+}
+
+// DeleteEmployment method deletes an employment of the person stored under the IIN in the tenant of the context.
+// It returns an error, storage.ErrorEmploymentNotFound if the person has no such employment.
+func (s *Storage) DeleteEmployment(ctx context.Context, iin string, id int64) error {
+	const fn = "storage.sqlite.DeleteEmployment"
+
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, iin); err != nil {
+			return err
+		}
+
+		result, err := tx.Stmt(s.stmts.deleteEmployment).Exec(storage.TenantID(ctx), iin, id)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return storage.ErrorEmploymentNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// GetEmployees method retrieves the people working at the organization stored under the BIN on the given day,
+// given as YYYY-MM-DD, or who have ever worked there if it is empty, ordered by name.
+// A person who worked there several times is listed once for every period.
+// It returns a slice of Employee structs or an error, storage.ErrorOrganizationNotFound if there is no such organization.
+func (s *Storage) GetEmployees(ctx context.Context, bin string, on string) ([]storage.Employee, error) {
+	const fn = "storage.sqlite.GetEmployees"
+
+	tenant := storage.TenantID(ctx)
+	employees := []storage.Employee{}
+	var exists bool
+	if err := s.stmts.organizationExists.QueryRow(tenant, bin).Scan(&exists); err != nil {
+		return employees, fmt.Errorf("%s: %w", fn, err)
+	}
+	if !exists {
+		return employees, fmt.Errorf("%s: %w", fn, storage.ErrorOrganizationNotFound)
+	}
+
+	rows, err := s.stmts.getEmployees.Query(tenant, bin, on, on, on)
+	if err != nil {
+		return employees, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var employee storage.Employee
+		err = rows.Scan(&employee.Name, &employee.ID, &employee.IIN, &employee.BIN, &employee.Position,
+			&employee.StartDate, &employee.EndDate, &employee.CreatedAt, &employee.UpdatedAt)
+		if err != nil {
+			return employees, fmt.Errorf("%s: %w", fn, err)
+		}
+		employees = append(employees, employee)
+	}
+	if err = rows.Err(); err != nil {
+		return employees, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return employees, nil
+}
+
+// checkOverlap method reports storage.ErrorEmploymentOverlaps if another employment of the person
+// at the same organization shares a day with the given one.
+func (s *Storage) checkOverlap(tx *sql.Tx, tenant string, employment storage.Employment) error {
+	var overlaps bool
+	err := tx.Stmt(s.stmts.employmentOverlaps).QueryRow(tenant, employment.IIN, employment.BIN, employment.ID,
+		employment.EndDate, employment.EndDate, employment.StartDate).Scan(&overlaps)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return storage.ErrorEmploymentOverlaps
+	}
+	return nil
+}
+
+// checkMovedOverlaps method reports storage.ErrorEmploymentOverlaps if an employment of the person stored
+// under sourceIIN shares a day with an employment of the person stored under targetIIN at the same organization,
+// as moving it to the target would then break the rule SaveEmployment and UpdateEmployment enforce.
+func (s *Storage) checkMovedOverlaps(tx *sql.Tx, tenant string, sourceIIN string, targetIIN string) error {
+	rows, err := tx.Stmt(s.stmts.getEmployments).Query(tenant, sourceIIN)
+	if err != nil {
+		return err
+	}
+	var moved []storage.Employment
+	for rows.Next() {
+		employment, err := scanEmployment(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		moved = append(moved, employment)
+	}
+	if err = rows.Close(); err != nil {
+		return err
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, employment := range moved {
+		employment.IIN = targetIIN
+		if err = s.checkOverlap(tx, tenant, employment); err != nil {
+			return fmt.Errorf("employment %d at %s: %w", employment.ID, employment.BIN, err)
+		}
+	}
+	return nil
+}
+
+// scanEmployment scans a row of employmentColumns into an Employment struct.
+func scanEmployment(row rowScanner) (storage.Employment, error) {
+	var employment storage.Employment
+	err := row.Scan(&employment.ID, &employment.IIN, &employment.BIN, &employment.Position,
+		&employment.StartDate, &employment.EndDate, &employment.CreatedAt, &employment.UpdatedAt)
+	return employment, err
+}
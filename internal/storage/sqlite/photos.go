@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// photoColumns are the columns read by getPhoto, followed by the image, the thumbnail or NULL.
+const photoColumns = "iin, content_type, size, width, height, updated_at"
+
+// SavePhoto method stores the photo of the person stored under its IIN in the tenant of the context,
+// along with its image and thumbnail, replacing the previous one.
+// It returns the stored Photo struct or an error, storage.ErrorIINNotFound if there is no such person
+// and storage.ErrorLegalHold if they are under legal hold.
+func (s *Storage) SavePhoto(ctx context.Context, photo storage.Photo, image []byte, thumbnail []byte) (storage.Photo, error) {
+	const fn = "storage.sqlite.SavePhoto"
+
+	tenant := storage.TenantID(ctx)
+	photo.UpdatedAt = time.Now().UTC()
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Stmt(s.stmts.personExists).QueryRow(tenant, photo.IIN).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrorIINNotFound
+		}
+		if err := s.checkHold(ctx, tx, photo.IIN); err != nil {
+			return err
+		}
+
+		_, err := tx.Stmt(s.stmts.savePhoto).Exec(tenant, photo.IIN, photo.ContentType, photo.Size,
+			photo.Width, photo.Height, image, thumbnail, photo.UpdatedAt)
+		return err
+	})
+	if err != nil {
+		return storage.Photo{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return photo, nil
+}
+
+// GetPhoto method reads the photo of the person stored under the IIN in the tenant of the context.
+// It returns the Photo struct and the image, or an error, storage.ErrorPhotoNotFound if there is no photo.
+func (s *Storage) GetPhoto(ctx context.Context, iin string) (storage.Photo, []byte, error) {
+	const fn = "storage.sqlite.GetPhoto"
+
+	photo, image, err := s.getPhoto(ctx, s.stmts.getPhoto, iin)
+	if err != nil {
+		return storage.Photo{}, nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return photo, image, nil
+}
+
+// GetPhotoThumbnail method reads the thumbnail of the photo of the person stored under the IIN.
+// It returns the Photo struct and the thumbnail, or an error, storage.ErrorPhotoNotFound if there is no photo.
+func (s *Storage) GetPhotoThumbnail(ctx context.Context, iin string) (storage.Photo, []byte, error) {
+	const fn = "storage.sqlite.GetPhotoThumbnail"
+
+	photo, thumbnail, err := s.getPhoto(ctx, s.stmts.getPhotoThumbnail, iin)
+	if err != nil {
+		return storage.Photo{}, nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return photo, thumbnail, nil
+}
+
+// GetPhotoDescription method reads the description of the photo of the person stored under the IIN,
+// without the image and the thumbnail.
+// It returns the Photo struct or an error, storage.ErrorPhotoNotFound if there is no photo.
+func (s *Storage) GetPhotoDescription(ctx context.Context, iin string) (storage.Photo, error) {
+	const fn = "storage.sqlite.GetPhotoDescription"
+
+	photo, _, err := s.getPhoto(ctx, s.stmts.getPhotoDescription, iin)
+	if err != nil {
+		return storage.Photo{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return photo, nil
+}
+
+// DeletePhoto method removes the photo of the person stored under the IIN in the tenant of the context.
+// It returns an error, storage.ErrorPhotoNotFound if there is no photo
+// and storage.ErrorLegalHold if the person is under legal hold.
+func (s *Storage) DeletePhoto(ctx context.Context, iin string) error {
+	const fn = "storage.sqlite.DeletePhoto"
+
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, iin); err != nil {
+			return err
+		}
+		result, err := tx.Stmt(s.stmts.deletePhoto).Exec(storage.TenantID(ctx), iin)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return storage.ErrorPhotoNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// getPhoto method reads the description of a photo and the image or thumbnail selected by the statement, if any.
+func (s *Storage) getPhoto(ctx context.Context, st *sql.Stmt, iin string) (storage.Photo, []byte, error) {
+	var photo storage.Photo
+	var data []byte
+	err := st.QueryRow(storage.TenantID(ctx), iin).Scan(&photo.IIN, &photo.ContentType, &photo.Size,
+		&photo.Width, &photo.Height, &photo.UpdatedAt, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Photo{}, nil, storage.ErrorPhotoNotFound
+	}
+	return photo, data, err
+}
@@ -0,0 +1,200 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// relationshipColumns are the columns read by scanRelationships.
+const relationshipColumns = "id, from_iin, type, to_iin, created_at"
+
+// householdQuery walks the relationships of a tenant in both directions, starting with a person,
+// and collects every IIN it reaches within a number of hops along with the hops it took.
+// Its parameters are the IIN, the tenant and the number of hops.
+const householdQuery = `
+ WITH RECURSIVE household(iin, hops) AS (
+  SELECT ?, 0
+  UNION
+  SELECT CASE WHEN r.from_iin = h.iin THEN r.to_iin ELSE r.from_iin END, h.hops + 1
+  FROM household h JOIN relationships r ON r.tenant = ? AND (r.from_iin = h.iin OR r.to_iin = h.iin)
+  WHERE h.hops < ?
+ )`
+
+// SaveRelationship method links two people of the tenant of the context, fromIIN being the <relType> of toIIN.
+// A spouse relationship is stored with the lower IIN first, whichever order it is given in.
+// It returns the saved Relationship struct or an error, storage.ErrorIINNotFound if either person is not stored,
+// storage.ErrorRelationshipExists if they are already linked so, storage.ErrorTooManyParents
+// if the child already has two parents, and storage.ErrorGuardianInactive if a guardian is deceased or emigrated.
+func (s *Storage) SaveRelationship(ctx context.Context, fromIIN string, relType string, toIIN string) (storage.Relationship, error) {
+	const fn = "storage.sqlite.SaveRelationship"
+
+	if relType == storage.RelationshipSpouse && toIIN < fromIIN {
+		fromIIN, toIIN = toIIN, fromIIN
+	}
+	relationship := storage.Relationship{
+		FromIIN:   fromIIN,
+		Type:      relType,
+		ToIIN:     toIIN,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	tenant := storage.TenantID(ctx)
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, fromIIN, toIIN); err != nil {
+			return err
+		}
+
+		for _, iin := range []string{fromIIN, toIIN} {
+			var exists bool
+			if err := tx.Stmt(s.stmts.personExists).QueryRow(tenant, iin).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("%s: %w", iin, storage.ErrorIINNotFound)
+			}
+		}
+
+		if relType == storage.RelationshipGuardian {
+			if err := s.checkGuardian(ctx, tx, fromIIN); err != nil {
+				return err
+			}
+		}
+
+		if relType == storage.RelationshipParent {
+			var parents int
+			err := tx.Stmt(s.stmts.countParents).QueryRow(tenant, toIIN, storage.RelationshipParent).Scan(&parents)
+			if err != nil {
+				return err
+			}
+			if parents >= 2 {
+				return fmt.Errorf("%s: %w", toIIN, storage.ErrorTooManyParents)
+			}
+		}
+
+		err := tx.Stmt(s.stmts.saveRelationship).QueryRow(tenant, fromIIN, relType, toIIN, relationship.CreatedAt).
+			Scan(&relationship.ID)
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+			return storage.ErrorRelationshipExists
+		}
+		return err
+	})
+	if err != nil {
+		return storage.Relationship{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return relationship, nil
+}
+
+// GetRelationships method retrieves every relationship the person stored under the IIN takes part in, oldest first.
+// It returns a slice of Relationship structs or an error.
+func (s *Storage) GetRelationships(ctx context.Context, iin string) ([]storage.Relationship, error) {
+	const fn = "storage.sqlite.GetRelationships"
+
+	relationships, err := scanRelationships(s.stmts.getRelationships.Query(storage.TenantID(ctx), iin, iin))
+	if err != nil {
+		return relationships, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return relationships, nil
+}
+
+// DeleteRelationship method removes a relationship of the tenant of the context.
+// It returns an error, storage.ErrorRelationshipNotFound if there is no such relationship
+// and storage.ErrorLegalHold if either person is under legal hold.
+func (s *Storage) DeleteRelationship(ctx context.Context, id int64) error {
+	const fn = "storage.sqlite.DeleteRelationship"
+
+	tenant := storage.TenantID(ctx)
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		var held bool
+		if err := tx.Stmt(s.stmts.relationshipHeld).QueryRow(tenant, id).Scan(&held); err != nil {
+			return err
+		}
+		if held {
+			return storage.ErrorLegalHold
+		}
+
+		result, err := tx.Stmt(s.stmts.deleteRelationship).Exec(tenant, id)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return storage.ErrorRelationshipNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// GetHousehold method retrieves the people reachable from the person stored under the IIN through at most
+// hops relationships, followed in either direction, the person included, nearest first.
+// It returns the Household struct, with the relationships between its members, or an error,
+// storage.ErrorIINNotFound if the person is not stored.
+func (s *Storage) GetHousehold(ctx context.Context, iin string, hops int) (storage.Household, error) {
+	const fn = "storage.sqlite.GetHousehold"
+
+	tenant := storage.TenantID(ctx)
+	household := storage.Household{Members: []storage.HouseholdMember{}}
+	rows, err := s.stmts.getHouseholdMembers.Query(iin, tenant, hops, tenant)
+	if err != nil {
+		return household, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member storage.HouseholdMember
+		if err = rows.Scan(&member.IIN, &member.Name, &member.Hops); err != nil {
+			return household, fmt.Errorf("%s: %w", fn, err)
+		}
+		household.Members = append(household.Members, member)
+	}
+	if err = rows.Err(); err != nil {
+		return household, fmt.Errorf("%s: %w", fn, err)
+	}
+	// The person is the first member, if stored
+	if len(household.Members) == 0 || household.Members[0].IIN != iin {
+		return storage.Household{}, fmt.Errorf("%s: %w", fn, storage.ErrorIINNotFound)
+	}
+
+	household.Relationships, err = scanRelationships(s.stmts.getHouseholdRelationships.Query(iin, tenant, hops, tenant))
+	if err != nil {
+		return household, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return household, nil
+}
+
+// scanRelationships scans the result rows of relationshipColumns into Relationship structs and closes the rows.
+func scanRelationships(rows *sql.Rows, err error) ([]storage.Relationship, error) {
+	relationships := []storage.Relationship{}
+	if err != nil {
+		return relationships, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relationship storage.Relationship
+		err = rows.Scan(&relationship.ID, &relationship.FromIIN, &relationship.Type, &relationship.ToIIN, &relationship.CreatedAt)
+		if err != nil {
+			return relationships, err
+		}
+		relationships = append(relationships, relationship)
+	}
+
+	return relationships, rows.Err()
+}
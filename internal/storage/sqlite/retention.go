@@ -0,0 +1,180 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CountExpired method counts the data of the retention target recorded before the cutoff,
+// which PurgeExpired would remove, across every tenant. Deleted people are counted by person,
+// access log entries once the entries queued so far are written.
+// It returns the count or an error, storage.ErrorUnknownTarget if the target is not known.
+func (s *Storage) CountExpired(target string, cutoff time.Time) (int64, error) {
+	const fn = "storage.sqlite.CountExpired"
+
+	if target == storage.RetentionAccessLog {
+		if err := s.flushAccess(); err != nil {
+			return 0, fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+
+	count, err := s.expired(nil, target, cutoff.UTC(), false)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return count, nil
+}
+
+// PurgeExpired method removes the data of the retention target recorded before the cutoff as a single atomic write.
+// Deleted people are removed once their deletion is older than the cutoff, along with every change event,
+// merge log entry, webhook delivery, consent, access log entry and status change about them.
+// Deceased people who died before the cutoff are deleted, unless they are under legal hold.
+// Change events are only removed once the NATS publisher and the webhooks of their tenant have read them.
+// It returns the number of removed entries, or people, or an error.
+func (s *Storage) PurgeExpired(target string, cutoff time.Time) (int64, error) {
+	const fn = "storage.sqlite.PurgeExpired"
+
+	var count int64
+	err := s.write(context.Background(), func(tx *sql.Tx) (err error) {
+		count, err = s.expired(tx, target, cutoff.UTC(), true)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return count, nil
+}
+
+// expired method counts, and removes if purge is set, the data of the target recorded before the cutoff.
+func (s *Storage) expired(tx *sql.Tx, target string, cutoff time.Time, purge bool) (int64, error) {
+	switch target {
+	case storage.RetentionDeletedPeople:
+		return s.expiredPeople(tx, cutoff, purge)
+	case storage.RetentionDeceasedPeople:
+		return s.expiredDeceased(tx, cutoff, purge)
+	case storage.RetentionEvents:
+		return countOrPurge(tx, s.stmts.countExpiredEvents, s.stmts.purgeExpiredEvents, purge, cutoff)
+	case storage.RetentionMergeLog:
+		return countOrPurge(tx, s.stmts.countExpiredMerges, s.stmts.purgeExpiredMerges, purge, cutoff)
+	case storage.RetentionWebhookDeliveries:
+		return countOrPurge(tx, s.stmts.countExpiredDeliveries, s.stmts.purgeExpiredDeliveries, purge,
+			storage.DeliveryDelivered, storage.DeliveryDead, cutoff)
+	case storage.RetentionAccessLog:
+		return countOrPurge(tx, s.stmts.countExpiredAccess, s.stmts.purgeExpiredAccess, purge, cutoff)
+	default:
+		return 0, fmt.Errorf("%q: %w", target, storage.ErrorUnknownTarget)
+	}
+}
+
+// expiredPeople method counts, and removes the history of if purge is set, the people deleted before the cutoff.
+// People are told apart by tenant, and a person recreated since the deletion is not expired.
+func (s *Storage) expiredPeople(tx *sql.Tx, cutoff time.Time, purge bool) (int64, error) {
+	rows, err := stmt(tx, s.stmts.getExpiredPeople).Query(storage.EventPersonDeleted, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	type person struct{ tenant, iin string }
+	var people []person
+	for rows.Next() {
+		var p person
+		if err = rows.Scan(&p.tenant, &p.iin); err != nil {
+			return 0, err
+		}
+		people = append(people, p)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	if !purge {
+		return int64(len(people)), nil
+	}
+
+	for _, p := range people {
+		// Deliveries are found through the events, so they go first
+		if _, err = stmt(tx, s.stmts.purgePersonDeliveries).Exec(p.tenant, p.iin); err != nil {
+			return 0, err
+		}
+		if _, err = stmt(tx, s.stmts.purgePersonMerges).Exec(p.tenant, p.iin, p.iin); err != nil {
+			return 0, err
+		}
+		if _, err = stmt(tx, s.stmts.purgePersonEvents).Exec(p.tenant, p.iin); err != nil {
+			return 0, err
+		}
+		if _, err = stmt(tx, s.stmts.purgePersonConsents).Exec(p.tenant, p.iin); err != nil {
+			return 0, err
+		}
+		if _, err = stmt(tx, s.stmts.purgePersonAccess).Exec(p.tenant, p.iin); err != nil {
+			return 0, err
+		}
+		if _, err = stmt(tx, s.stmts.purgePersonStatus).Exec(p.tenant, p.iin); err != nil {
+			return 0, err
+		}
+		if _, err = stmt(tx, s.stmts.purgeRetiredVersion).Exec(p.tenant, p.iin); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(people)), nil
+}
+
+// expiredDeceased method counts, and deletes if purge is set, the people who died before the cutoff,
+// as recorded by the effective date of their change to the deceased status. People under legal hold are kept.
+func (s *Storage) expiredDeceased(tx *sql.Tx, cutoff time.Time, purge bool) (int64, error) {
+	rows, err := stmt(tx, s.stmts.getExpiredDeceased).Query(storage.StatusDeceased, cutoff.Format(time.DateOnly))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	type person struct{ tenant, iin string }
+	var people []person
+	for rows.Next() {
+		var p person
+		if err = rows.Scan(&p.tenant, &p.iin); err != nil {
+			return 0, err
+		}
+		people = append(people, p)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	if !purge {
+		return int64(len(people)), nil
+	}
+
+	for _, p := range people {
+		// Deleted as by a client of the tenant, so the deletion reaches the change feed
+		if err = s.deletePerson(storage.WithTenant(context.Background(), p.tenant), tx, p.iin, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(people)), nil
+}
+
+// countOrPurge is a helper function to either count the rows matched by the arguments or delete them.
+// It returns the number of matched rows or an error.
+func countOrPurge(tx *sql.Tx, count *sql.Stmt, purge *sql.Stmt, doPurge bool, args ...any) (int64, error) {
+	if !doPurge {
+		var n int64
+		err := stmt(tx, count).QueryRow(args...).Scan(&n)
+		return n, err
+	}
+
+	result, err := stmt(tx, purge).Exec(args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,218 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeExpiredDeletedPeople(t *testing.T) {
+	s := newTestStorage(t, wal)
+	ctx := operator()
+
+	webhook, err := s.SaveWebhook(ctx, "http://localhost/hook", "secret", []string{"created", "updated", "deleted"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Deleted Person", "+77010000001"))
+	_, err = s.GrantConsent(ctx, "830218350074", "marketing", "form")
+	require.NoError(t, err)
+	require.NoError(t, s.RecordAccess(ctx, []storage.AccessEntry{{IIN: "830218350074", Action: storage.AccessRead}}))
+	require.NoError(t, s.DeletePersonByIIN(ctx, "830218350074", 0))
+	require.NoError(t, s.SavePerson(ctx, "980301450725", "Merged Person", "+77010000002"))
+	require.NoError(t, s.SavePerson(ctx, "790708301327", "Kept Person", "+77010000003"))
+	_, err = s.MergePeople(ctx, "980301450725", "790708301327", 0)
+	require.NoError(t, err)
+	// Recreated after the deletion
+	require.NoError(t, s.SavePerson(ctx, "600426400918", "Recreated Person", "+77010000004"))
+	require.NoError(t, s.DeletePersonByIIN(ctx, "600426400918", 0))
+	require.NoError(t, s.SavePerson(ctx, "600426400918", "Recreated Person", "+77010000004"))
+
+	_, err = s.EnqueueWebhookDeliveries(100)
+	require.NoError(t, err)
+
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	count, err := s.CountExpired(storage.RetentionDeletedPeople, past)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	count, err = s.CountExpired(storage.RetentionDeletedPeople, future)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = s.PurgeExpired(storage.RetentionDeletedPeople, future)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	events, err := s.GetEvents(ctx, 0, 100)
+	require.NoError(t, err)
+	var iins []string
+	for _, event := range events {
+		iins = append(iins, event.IIN)
+	}
+	assert.Equal(t, []string{"790708301327", "790708301327", "600426400918", "600426400918", "600426400918"}, iins)
+
+	deliveries, err := s.GetWebhookDeliveries(ctx, webhook.ID, 100)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 5)
+
+	merges, err := s.GetMergeLog(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, merges)
+
+	consents, err := s.GetConsents(ctx, "830218350074")
+	require.NoError(t, err)
+	assert.Empty(t, consents)
+
+	entries, err := s.GetAccessLog(ctx, "830218350074")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	count, err = s.CountExpired(storage.RetentionDeletedPeople, future)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	// The last version of a purged person is forgotten, while that of a recreated one is kept
+	var retired int
+	require.NoError(t, s.db.QueryRow("SELECT COUNT(*) FROM retired_versions;").Scan(&retired))
+	assert.Equal(t, 1, retired)
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Deleted Person", "+77010000001"))
+	person, err := s.GetPersonByIIN(ctx, "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), person.Version)
+}
+
+func TestPurgeExpiredByAge(t *testing.T) {
+	s := newTestStorage(t, wal)
+	ctx := operator()
+
+	webhook, err := s.SaveWebhook(ctx, "http://localhost/hook", "secret", []string{"created"})
+	require.NoError(t, err)
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, "980301450725", "Test Name", "+77010000002"))
+	_, err = s.MergePeople(ctx, "830218350074", "980301450725", 0)
+	require.NoError(t, err)
+	_, err = s.EnqueueWebhookDeliveries(100)
+	require.NoError(t, err)
+	require.NoError(t, s.RecordAccess(ctx, []storage.AccessEntry{{IIN: "980301450725", Action: storage.AccessRead}}))
+
+	deliveries, err := s.GetWebhookDeliveries(ctx, webhook.ID, 100)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	// Pending deliveries are kept
+	deliveries[0].Status = storage.DeliveryDelivered
+	require.NoError(t, s.UpdateWebhookDelivery(deliveries[0]))
+
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		target string
+		want   int64
+	}{
+		{storage.RetentionWebhookDeliveries, 1},
+		{storage.RetentionMergeLog, 1},
+		{storage.RetentionEvents, 4},
+		{storage.RetentionAccessLog, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			count, err := s.CountExpired(tt.target, time.Now().Add(-time.Hour))
+			require.NoError(t, err)
+			assert.Zero(t, count)
+
+			count, err = s.CountExpired(tt.target, future)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, count)
+
+			count, err = s.PurgeExpired(tt.target, future)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, count)
+
+			count, err = s.CountExpired(tt.target, future)
+			require.NoError(t, err)
+			assert.Zero(t, count)
+		})
+	}
+
+	_, err = s.CountExpired("users", future)
+	assert.ErrorIs(t, err, storage.ErrorUnknownTarget)
+	_, err = s.PurgeExpired("users", future)
+	assert.ErrorIs(t, err, storage.ErrorUnknownTarget)
+}
+
+func TestPurgeExpiredEventsKeepsUnreadEvents(t *testing.T) {
+	s := newTestStorage(t, wal)
+	ctx := operator()
+	health := storage.WithTenant(ctx, "health")
+
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(health, "830218350074", "Test Name", "+77010000001"))
+	_, err := s.SaveWebhook(health, "http://localhost/hook", "secret", []string{"created"})
+	require.NoError(t, err)
+	require.NoError(t, s.SavePerson(ctx, "980301450725", "Test Name", "+77010000002"))
+	require.NoError(t, s.SavePerson(health, "980301450725", "Test Name", "+77010000002"))
+	require.NoError(t, s.SaveOutboxCursor("nats", 3))
+
+	// The publisher has read the first three events, and the webhook none of the events of its tenant since it was saved
+	future := time.Now().Add(time.Hour)
+	count, err := s.PurgeExpired(storage.RetentionEvents, future)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	events, err := s.GetOutboxEvents(0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "health", events[0].Tenant)
+
+	_, err = s.EnqueueWebhookDeliveries(100)
+	require.NoError(t, err)
+	require.NoError(t, s.SaveOutboxCursor("nats", 4))
+	count, err = s.PurgeExpired(storage.RetentionEvents, future)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestPurgeExpiredDeceasedPeople(t *testing.T) {
+	s := newTestStorage(t, wal)
+	ctx := operator()
+
+	for i, iin := range []string{"830218350074", "980301450725", "790708301327"} {
+		require.NoError(t, s.SavePerson(ctx, iin, "Test Name", fmt.Sprintf("+7701000000%d", i)))
+	}
+	_, err := s.ChangeStatus(ctx, "830218350074", storage.StatusDeceased, "2020-01-01", "certificate", 0)
+	require.NoError(t, err)
+	_, err = s.ChangeStatus(ctx, "980301450725", storage.StatusDeceased, "2020-01-01", "certificate", 0)
+	require.NoError(t, err)
+	_, err = s.PlaceLegalHold(ctx, storage.LegalHold{IIN: "980301450725", Reason: "court order", PlacedBy: "user"})
+	require.NoError(t, err)
+	_, err = s.ChangeStatus(ctx, "790708301327", storage.StatusEmigrated, "2020-01-01", "certificate", 0)
+	require.NoError(t, err)
+
+	count, err := s.CountExpired(storage.RetentionDeceasedPeople, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	cutoff := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	count, err = s.PurgeExpired(storage.RetentionDeceasedPeople, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Deleted as by a client, the deceased person leaves a deletion event
+	_, err = s.GetPersonByIIN(ctx, "830218350074")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	events, err := s.GetPersonEvents(ctx, "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, storage.EventPersonDeleted, events[len(events)-1].Type)
+	for _, iin := range []string{"980301450725", "790708301327"} {
+		_, err = s.GetPersonByIIN(ctx, iin)
+		assert.NoError(t, err)
+	}
+
+	count, err = s.CountExpired(storage.RetentionDeceasedPeople, cutoff)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
@@ -3,99 +3,1256 @@ package sqlite
 
 import (
 	"citizen_webservice/internal/storage"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/mattn/go-sqlite3"
 	_ "github.com/mattn/go-sqlite3" // Importing the SQLite driver
 )
 
+// Options struct holds the connection settings of the SQLite database.
+// Zero values keep the defaults of the driver and of database/sql.
+type Options struct {
+	JournalMode         string        // Journal mode, e.g. WAL or DELETE
+	BusyTimeout         time.Duration // How long a connection waits for a lock before failing with "database is locked"
+	Synchronous         string        // Synchronous mode, e.g. NORMAL or FULL
+	MaxOpenConns        int           // Maximum number of open connections
+	MaxIdleConns        int           // Maximum number of idle connections
+	ConnMaxLifetime     time.Duration // Maximum time a connection may be reused
+	ConnMaxIdleTime     time.Duration // Maximum time a connection may stay idle
+	WriteQueueDepth     int           // Number of writes that may wait for the writer before new ones are rejected
+	WriteBatchSize      int           // Maximum number of writes committed in a single transaction
+	AccessBufferSize    int           // Number of access log entries that may wait for the writer before new ones are dropped
+	AccessFlushInterval time.Duration // How often the waiting access log entries are written when no other write comes along
+}
+
 // Storage struct represents a SQLite database.
+// All writes are funnelled through a single writer goroutine, which commits them in groups.
 type Storage struct {
-	db *sql.DB
+	db    *sql.DB
+	stmts statements
+
+	writes     chan writeJob
+	stopWriter chan struct{}
+	writerDone chan struct{}
+	writeMu    sync.RWMutex
+	closed     bool
+
+	access accessBuffer
+
+	holdLocks [holdLockStripes]sync.RWMutex
+}
+
+// statements struct holds the SQL statements prepared once in New and reused by every call.
+type statements struct {
+	savePerson      *sql.Stmt
+	retireVersion   *sql.Stmt
+	getPersonByIIN  *sql.Stmt
+	getPersonByName *sql.Stmt
+	getAllPeople    *sql.Stmt
+	updatePerson    *sql.Stmt
+	deletePerson    *sql.Stmt
+	personExists    *sql.Stmt
+	touchPerson     *sql.Stmt
+
+	getNameAndPhone   *sql.Stmt
+	saveMergeRecord   *sql.Stmt
+	getMergeLog       *sql.Stmt
+	getCandidatePage  *sql.Stmt
+	getCandidatePairs *sql.Stmt
+
+	nextEventSeq    *sql.Stmt
+	saveEvent       *sql.Stmt
+	getEvents       *sql.Stmt
+	getOutboxEvents *sql.Stmt
+	getLastEventSeq *sql.Stmt
+
+	getOutboxCursor  *sql.Stmt
+	saveOutboxCursor *sql.Stmt
+
+	saveConsent      *sql.Stmt
+	getConsentStatus *sql.Stmt
+	getConsents      *sql.Stmt
+
+	getConsentHistory *sql.Stmt
+	getPersonEvents   *sql.Stmt
+	getPersonMerges   *sql.Stmt
+	saveAccess        *sql.Stmt
+	getAccessLog      *sql.Stmt
+
+	getExpiredPeople       *sql.Stmt
+	getExpiredDeceased     *sql.Stmt
+	purgePersonDeliveries  *sql.Stmt
+	purgePersonMerges      *sql.Stmt
+	purgePersonEvents      *sql.Stmt
+	purgePersonConsents    *sql.Stmt
+	purgePersonAccess      *sql.Stmt
+	purgeRetiredVersion    *sql.Stmt
+	countExpiredEvents     *sql.Stmt
+	purgeExpiredEvents     *sql.Stmt
+	countExpiredMerges     *sql.Stmt
+	purgeExpiredMerges     *sql.Stmt
+	countExpiredDeliveries *sql.Stmt
+	purgeExpiredDeliveries *sql.Stmt
+	countExpiredAccess     *sql.Stmt
+	purgeExpiredAccess     *sql.Stmt
+
+	saveWebhook              *sql.Stmt
+	getWebhook               *sql.Stmt
+	getWebhooks              *sql.Stmt
+	getActiveWebhooks        *sql.Stmt
+	updateWebhook            *sql.Stmt
+	advanceWebhook           *sql.Stmt
+	deleteWebhook            *sql.Stmt
+	deleteWebhookDeliveries  *sql.Stmt
+	saveWebhookDelivery      *sql.Stmt
+	getDueWebhookDeliveries  *sql.Stmt
+	getWebhookDeliveries     *sql.Stmt
+	getDeadWebhookDeliveries *sql.Stmt
+	getWebhookDeliveryStatus *sql.Stmt
+	updateWebhookDelivery    *sql.Stmt
+
+	saveTenant     *sql.Stmt
+	getTenants     *sql.Stmt
+	tenantExists   *sql.Stmt
+	saveCredential *sql.Stmt
+	getCredential  *sql.Stmt
+
+	getPersonStatus   *sql.Stmt
+	setPersonStatus   *sql.Stmt
+	saveStatusChange  *sql.Stmt
+	getStatusHistory  *sql.Stmt
+	purgePersonStatus *sql.Stmt
+
+	saveDocument          *sql.Stmt
+	getDocument           *sql.Stmt
+	getDocuments          *sql.Stmt
+	getExpiringDocuments  *sql.Stmt
+	updateDocument        *sql.Stmt
+	deleteDocument        *sql.Stmt
+	deletePersonDocuments *sql.Stmt
+	moveDocuments         *sql.Stmt
+
+	savePhoto           *sql.Stmt
+	getPhoto            *sql.Stmt
+	getPhotoThumbnail   *sql.Stmt
+	getPhotoDescription *sql.Stmt
+	deletePhoto         *sql.Stmt
+	movePhoto           *sql.Stmt
+
+	saveRelationship          *sql.Stmt
+	countParents              *sql.Stmt
+	getRelationships          *sql.Stmt
+	deleteRelationship        *sql.Stmt
+	deletePersonRelationships *sql.Stmt
+	getHouseholdMembers       *sql.Stmt
+	getHouseholdRelationships *sql.Stmt
+	moveWards                 *sql.Stmt
+	moveGuardians             *sql.Stmt
+
+	setGuardian              *sql.Stmt
+	getGuardianIIN           *sql.Stmt
+	getMinorsWithoutGuardian *sql.Stmt
+
+	saveAddress           *sql.Stmt
+	getAddresses          *sql.Stmt
+	deleteAddress         *sql.Stmt
+	deletePersonAddresses *sql.Stmt
+	moveAddresses         *sql.Stmt
+	countByRegion         *sql.Stmt
+	countWithoutAddress   *sql.Stmt
+
+	setAttributes         *sql.Stmt
+	moveAttributes        *sql.Stmt
+	saveAttributeSchema   *sql.Stmt
+	getAttributeSchema    *sql.Stmt
+	getAttributeSchemas   *sql.Stmt
+	deleteAttributeSchema *sql.Stmt
+	attributeInUse        *sql.Stmt
+
+	saveOrganization        *sql.Stmt
+	getOrganization         *sql.Stmt
+	getOrganizations        *sql.Stmt
+	organizationExists      *sql.Stmt
+	saveEmployment          *sql.Stmt
+	getEmployment           *sql.Stmt
+	getEmployments          *sql.Stmt
+	employmentOverlaps      *sql.Stmt
+	updateEmployment        *sql.Stmt
+	deleteEmployment        *sql.Stmt
+	deletePersonEmployments *sql.Stmt
+	moveEmployments         *sql.Stmt
+	getEmployees            *sql.Stmt
+
+	placeLegalHold   *sql.Stmt
+	getLegalHold     *sql.Stmt
+	getLegalHolds    *sql.Stmt
+	releaseLegalHold *sql.Stmt
+	holdExists       *sql.Stmt
+	relationshipHeld *sql.Stmt
+	saveHoldLog      *sql.Stmt
+	getHoldLog       *sql.Stmt
 }
 
 // New function initializes a new SQLite database at the provided storage path.
 // It returns a pointer to a Storage struct or an error.
-func New(storagePath string) (*Storage, error) {
+func New(storagePath string, opts Options) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
 	// Open a new database connection
-	db, err := sql.Open("sqlite3", storagePath)
+	db, err := sql.Open("sqlite3", dataSourceName(storagePath, opts))
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	// Apply the connection pool limits
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
 	// Check the database connection
 	err = db.Ping()
 	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = migrate(db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if opts.WriteQueueDepth <= 0 {
+		opts.WriteQueueDepth = DefaultWriteQueueDepth
+	}
+	if opts.WriteBatchSize <= 0 {
+		opts.WriteBatchSize = DefaultWriteBatchSize
+	}
+	if opts.AccessBufferSize <= 0 {
+		opts.AccessBufferSize = DefaultAccessBufferSize
+	}
+	if opts.AccessFlushInterval <= 0 {
+		opts.AccessFlushInterval = DefaultAccessFlushInterval
+	}
+
+	// Return a new Storage struct
+	s := &Storage{
+		db:         db,
+		writes:     make(chan writeJob, opts.WriteQueueDepth),
+		stopWriter: make(chan struct{}),
+		writerDone: make(chan struct{}),
+		access:     accessBuffer{size: opts.AccessBufferSize, flush: make(chan struct{}, 1)},
+	}
+	if err = s.prepare(); err != nil {
+		close(s.writerDone)
+		_ = s.Close()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	// Prepare a SQL statement to create the users table if it doesn't exist
+	go s.runWriter(opts.WriteBatchSize, opts.AccessFlushInterval)
+
+	return s, nil
+}
+
+// Close method stops the writer once the queued writes and access log entries are committed,
+// then closes the prepared statements and the database.
+// It returns an error if any of them fails to close.
+func (s *Storage) Close() error {
+	const op = "storage.sqlite.Close"
+
+	s.writeMu.Lock()
+	if s.closed {
+		s.writeMu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.writeMu.Unlock()
+
+	close(s.stopWriter)
+	<-s.writerDone
+
+	var errs []error
+	for _, stmt := range s.stmts.all() {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
+	}
+	errs = append(errs, s.db.Close())
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// dataSourceName builds the driver connection string, which applies the pragmas on every new connection.
+// Immediate transactions take the write lock up front, so the busy timeout also covers them.
+func dataSourceName(storagePath string, opts Options) string {
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	if opts.JournalMode != "" {
+		params.Set("_journal_mode", opts.JournalMode)
+	}
+	if opts.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	}
+	if opts.Synchronous != "" {
+		params.Set("_synchronous", opts.Synchronous)
+	}
+
+	separator := "?"
+	if strings.Contains(storagePath, "?") {
+		separator = "&"
+	}
+	return storagePath + separator + params.Encode()
+}
+
+// migrate creates the tables if they don't exist and brings existing ones up to date.
+func migrate(db *sql.DB) error {
+	// Prepare a SQL statement to create the users table if it doesn't exist.
+	// The IIN and the phone number of a person are unique within their tenant.
 	stmt, err := db.Prepare(`
  CREATE TABLE IF NOT EXISTS users (
-  iin VARCHAR(14) PRIMARY KEY,
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
   name VARCHAR(255) NOT NULL,
-  phone VARCHAR(30) NOT NULL UNIQUE
+  phone VARCHAR(30) NOT NULL,
+  version INTEGER NOT NULL DEFAULT 1,
+  status VARCHAR(16) NOT NULL DEFAULT 'active',
+  PRIMARY KEY (tenant, iin),
+  UNIQUE (tenant, phone)
  );`)
 
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return err
 	}
+	defer stmt.Close()
 
 	// Execute the SQL statement
 	_, err = stmt.Exec()
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
-	// Return a new Storage struct
-	return &Storage{db: db}, nil
+	// Create the merge log table, which records every merge of a duplicate record
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS merge_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  source_iin VARCHAR(14) NOT NULL,
+  source_name VARCHAR(255) NOT NULL,
+  source_phone VARCHAR(30) NOT NULL,
+  target_iin VARCHAR(14) NOT NULL,
+  target_name VARCHAR(255) NOT NULL,
+  target_phone VARCHAR(30) NOT NULL,
+  merged_at TIMESTAMP NOT NULL
+ );`)
+	if err != nil {
+		return err
+	}
+
+	// Create the outbox of change events. AUTOINCREMENT never reuses a sequence number,
+	// and since every write goes through the writer, sequence numbers are committed in order.
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS events (
+  seq INTEGER PRIMARY KEY AUTOINCREMENT,
+  type VARCHAR(32) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  payload TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+ );
+ CREATE INDEX IF NOT EXISTS events_iin ON events(iin, seq);`)
+	if err != nil {
+		return err
+	}
+
+	// Create the webhook subscriptions and their deliveries.
+	// last_seq is the last event fanned out to the deliveries of the webhook.
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS webhooks (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  events TEXT NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT 1,
+  last_seq INTEGER NOT NULL,
+  created_at TIMESTAMP NOT NULL
+ );
+ CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  webhook_id INTEGER NOT NULL,
+  event_seq INTEGER NOT NULL,
+  event_type VARCHAR(32) NOT NULL,
+  payload TEXT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  response_status INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+ );
+ CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+ CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);`)
+	if err != nil {
+		return err
+	}
+
+	// Create the consent history, the latest entry of an IIN and purpose being the current state
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS consents (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iin VARCHAR(14) NOT NULL,
+  purpose VARCHAR(64) NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  source VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL
+ );
+ CREATE INDEX IF NOT EXISTS consents_iin ON consents(iin, purpose, id);`)
+	if err != nil {
+		return err
+	}
+
+	// Create the access log, which records every read of the data of a person
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS access_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  iin VARCHAR(14) NOT NULL,
+  action VARCHAR(32) NOT NULL,
+  client VARCHAR(255) NOT NULL,
+  purpose VARCHAR(64) NOT NULL,
+  request_id VARCHAR(64) NOT NULL,
+  accessed_at TIMESTAMP NOT NULL
+ );
+ CREATE INDEX IF NOT EXISTS access_log_iin ON access_log(iin, id);`)
+	if err != nil {
+		return err
+	}
+
+	// Create the cursors of the consumers publishing the outbox, the last event each of them published
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS outbox_cursors (
+  consumer VARCHAR(64) PRIMARY KEY,
+  seq INTEGER NOT NULL
+ );`)
+	if err != nil {
+		return err
+	}
+
+	// Create the tenants and the credentials their clients authenticate with.
+	// The default tenant owns the data stored before tenants were introduced.
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS tenants (
+  id VARCHAR(64) PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL
+ );
+ CREATE TABLE IF NOT EXISTS credentials (
+  username VARCHAR(255) PRIMARY KEY,
+  tenant VARCHAR(64) NOT NULL,
+  password_hash TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+ );
+ INSERT OR IGNORE INTO tenants(id, name, created_at) VALUES(?, 'Default', ?);`, storage.DefaultTenant, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	// Add the row version used for optimistic concurrency control
+	if err = addColumn(db, "users", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+
+	// Record the version the target of a merge was left at
+	if err = addColumn(db, "merge_log", "target_version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Add the ID of the request that made the change to the change events
+	if err = addColumn(db, "events", "request_id", "VARCHAR(64) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Partition every row by tenant, existing rows belonging to the default one
+	if err = partitionUsers(db); err != nil {
+		return err
+	}
+	for _, table := range []string{"merge_log", "events", "webhooks", "webhook_deliveries", "consents", "access_log"} {
+		err = addColumn(db, table, "tenant", fmt.Sprintf("VARCHAR(64) NOT NULL DEFAULT '%s'", storage.DefaultTenant))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = db.Exec(`
+ CREATE INDEX IF NOT EXISTS events_tenant ON events(tenant, seq);
+ CREATE INDEX IF NOT EXISTS webhooks_tenant ON webhooks(tenant, id);`)
+	if err != nil {
+		return err
+	}
+
+	// Add the lifecycle status, every existing person being active, and create its history
+	if err = addColumn(db, "users", "status", fmt.Sprintf("VARCHAR(16) NOT NULL DEFAULT '%s'", storage.StatusActive)); err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS status_changes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  from_status VARCHAR(16) NOT NULL,
+  to_status VARCHAR(16) NOT NULL,
+  effective_date VARCHAR(10) NOT NULL,
+  reason VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL
+ );
+ CREATE INDEX IF NOT EXISTS status_changes_iin ON status_changes(tenant, iin, id);`)
+	if err != nil {
+		return err
+	}
+
+	// Create the identity documents, whose dates are stored as YYYY-MM-DD so that they compare as text
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS documents (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  type VARCHAR(16) NOT NULL,
+  number VARCHAR(32) NOT NULL,
+  issuing_authority VARCHAR(255) NOT NULL,
+  issue_date VARCHAR(10) NOT NULL,
+  expiry_date VARCHAR(10) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  UNIQUE (tenant, type, number)
+ );
+ CREATE INDEX IF NOT EXISTS documents_iin ON documents(tenant, iin, id);
+ CREATE INDEX IF NOT EXISTS documents_expiry ON documents(tenant, expiry_date);`)
+	if err != nil {
+		return err
+	}
+
+	// Create the photos, a single one per person, with their thumbnails
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS photos (
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  content_type VARCHAR(32) NOT NULL,
+  size INTEGER NOT NULL,
+  width INTEGER NOT NULL,
+  height INTEGER NOT NULL,
+  image BLOB NOT NULL,
+  thumbnail BLOB NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (tenant, iin)
+ );`)
+	if err != nil {
+		return err
+	}
+
+	// Create the relationships between people, directed from the parent or guardian
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS relationships (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  tenant VARCHAR(64) NOT NULL,
+  from_iin VARCHAR(14) NOT NULL,
+  type VARCHAR(16) NOT NULL,
+  to_iin VARCHAR(14) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  UNIQUE (tenant, from_iin, type, to_iin)
+ );
+ CREATE INDEX IF NOT EXISTS relationships_to ON relationships(tenant, to_iin, type);`)
+	if err != nil {
+		return err
+	}
+
+	// Record the guardian a minor was saved with, which outlives the record of the guardian
+	if err = addColumn(db, "users", "guardian_iin", "VARCHAR(14)"); err != nil {
+		return err
+	}
+
+	// Create the addresses, one of each type per person, with the region of their KATO code for the reports
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS addresses (
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  type VARCHAR(16) NOT NULL,
+  kato VARCHAR(9) NOT NULL,
+  region VARCHAR(9) NOT NULL,
+  locality VARCHAR(255) NOT NULL,
+  street VARCHAR(255) NOT NULL,
+  house VARCHAR(32) NOT NULL,
+  apartment VARCHAR(32) NOT NULL,
+  postal_code VARCHAR(6) NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (tenant, iin, type)
+ );
+ CREATE INDEX IF NOT EXISTS addresses_region ON addresses(tenant, type, region);`)
+	if err != nil {
+		return err
+	}
+
+	// Store the extension attributes of a person as a JSON object keyed by namespace
+	if err = addColumn(db, "users", "attributes", "TEXT"); err != nil {
+		return err
+	}
+
+	// Create the JSON Schemas the extension attributes are validated against, one per namespace
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS attribute_schemas (
+  tenant VARCHAR(64) NOT NULL,
+  namespace VARCHAR(32) NOT NULL,
+  schema TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (tenant, namespace)
+ );`)
+	if err != nil {
+		return err
+	}
+
+	// Create the organizations, keyed by BIN, and the periods people work at them.
+	// An ongoing employment has an empty end date, so that dates compare as text.
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS organizations (
+  tenant VARCHAR(64) NOT NULL,
+  bin VARCHAR(12) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (tenant, bin)
+ );
+ CREATE TABLE IF NOT EXISTS employments (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  bin VARCHAR(12) NOT NULL,
+  position VARCHAR(255) NOT NULL,
+  start_date VARCHAR(10) NOT NULL,
+  end_date VARCHAR(10) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+ );
+ CREATE INDEX IF NOT EXISTS employments_iin ON employments(tenant, iin, start_date);
+ CREATE INDEX IF NOT EXISTS employments_bin ON employments(tenant, bin, start_date);`)
+	if err != nil {
+		return err
+	}
+
+	// Index the first letters of the names, which the duplicate report pairs people by
+	_, err = db.Exec(`
+ CREATE INDEX IF NOT EXISTS users_name_block ON users(tenant, ` + nameBlock + `);`)
+	if err != nil {
+		return err
+	}
+
+	// Create the legal holds, at most one per person, and the log of every hold placed and released
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS legal_holds (
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  reason VARCHAR(255) NOT NULL,
+  case_number VARCHAR(64) NOT NULL,
+  placed_by VARCHAR(255) NOT NULL,
+  placed_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (tenant, iin)
+ );
+ CREATE TABLE IF NOT EXISTS legal_hold_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  action VARCHAR(16) NOT NULL,
+  reason VARCHAR(255) NOT NULL,
+  case_number VARCHAR(64) NOT NULL,
+  client VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL
+ );
+ CREATE INDEX IF NOT EXISTS legal_hold_log_iin ON legal_hold_log(tenant, iin, id);`)
+	if err != nil {
+		return err
+	}
+
+	// Record when every person was created, so that the history of a deleted record is not inherited
+	// by a new one under the same IIN. People saved before keep every row of their history.
+	if err = addColumn(db, "users", "created_at", "TIMESTAMP NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Record the last version of every deleted person, so that a new record under the same IIN
+	// carries on from it and the entity tags of the deleted record never match the new one.
+	// It is kept until the deleted person expires under the deleted_people retention rule
+	_, err = db.Exec(`
+ CREATE TABLE IF NOT EXISTS retired_versions (
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  version INTEGER NOT NULL,
+  PRIMARY KEY (tenant, iin)
+ );`)
+	if err != nil {
+		return err
+	}
+
+	// Remove the phones from the events and webhook deliveries stored while they were part of the payloads,
+	// as events reach partners whatever the consents of the person
+	_, err = db.Exec(`
+ UPDATE events SET payload = json_remove(payload, '$.phone') WHERE json_type(payload, '$.phone') IS NOT NULL;
+ UPDATE webhook_deliveries SET payload = json_remove(payload, '$.payload.phone')
+ WHERE json_type(payload, '$.payload.phone') IS NOT NULL;`)
+	if err != nil {
+		return err
+	}
+
+	return numberTenantEvents(db)
 }
 
-// SavePerson method saves a person's information in the database.
+// numberTenantEvents numbers the change events of every tenant on their own, without gaps,
+// next to the sequence number of the outbox shared by every tenant.
+// The events stored by an older version of the service keep their outbox numbers, which the cursors
+// of the clients and webhooks point at, and every tenant counts its next events from the last outbox number.
+func numberTenantEvents(db *sql.DB) error {
+	numbered, err := hasColumn(db, "events", "tenant_seq")
+	if err != nil || numbered {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+ ALTER TABLE events ADD COLUMN tenant_seq INTEGER NOT NULL DEFAULT 0;
+ UPDATE events SET tenant_seq = seq;
+ CREATE UNIQUE INDEX IF NOT EXISTS events_tenant_seq ON events(tenant, tenant_seq);
+ CREATE TABLE IF NOT EXISTS event_sequences (
+  tenant VARCHAR(64) PRIMARY KEY,
+  seq INTEGER NOT NULL
+ );
+ INSERT INTO event_sequences(tenant, seq)
+ SELECT tenant, (SELECT COALESCE(MAX(seq), 0) FROM sqlite_sequence WHERE name = 'events')
+ FROM (SELECT ? AS tenant UNION SELECT id FROM tenants UNION SELECT tenant FROM events);`, storage.DefaultTenant)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// partitionUsers rebuilds a users table created by an older version of the service,
+// whose IINs and phone numbers were unique across the whole table, with the keys of the current schema.
+// SQLite cannot change the constraints of an existing table, so the rows are copied to a new one
+// and assigned to the default tenant.
+func partitionUsers(db *sql.DB) error {
+	partitioned, err := hasColumn(db, "users", "tenant")
+	if err != nil || partitioned {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+ CREATE TABLE users_partitioned (
+  tenant VARCHAR(64) NOT NULL,
+  iin VARCHAR(14) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  phone VARCHAR(30) NOT NULL,
+  version INTEGER NOT NULL DEFAULT 1,
+  status VARCHAR(16) NOT NULL DEFAULT 'active',
+  PRIMARY KEY (tenant, iin),
+  UNIQUE (tenant, phone)
+ );
+ INSERT INTO users_partitioned(tenant, iin, name, phone, version) SELECT ?, iin, name, phone, version FROM users;
+ DROP TABLE users;
+ ALTER TABLE users_partitioned RENAME TO users;`, storage.DefaultTenant)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// addColumn adds a column to an existing table unless the table already has it.
+// It keeps databases created by older versions of the service up to date with the current schema.
+func addColumn(db *sql.DB, table string, column string, definition string) error {
+	exists, err := hasColumn(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition))
+	return err
+}
+
+// hasColumn reports whether the table has the column.
+func hasColumn(db *sql.DB, table string, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s');", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// prepare method prepares every statement used by the storage.
+func (s *Storage) prepare() error {
+	queries := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.stmts.savePerson, `
+ INSERT INTO users(tenant, iin, name, phone, created_at, version)
+ VALUES(?1, ?2, ?, ?, ?, COALESCE((SELECT version FROM retired_versions WHERE tenant = ?1 AND iin = ?2), 0) + 1)
+ RETURNING version;`},
+		{&s.stmts.getPersonByIIN, "SELECT " + personColumns + " FROM users WHERE tenant = ? AND iin = ? LIMIT 1;"},
+		{&s.stmts.getPersonByName, `
+ SELECT ` + personColumns + ` FROM users WHERE tenant = ? AND name LIKE ? AND (? = '' OR status = ?)
+ AND (? = '' OR EXISTS (SELECT 1 FROM addresses a WHERE a.tenant = users.tenant AND a.iin = users.iin AND a.region = ?))
+ AND NOT EXISTS (SELECT 1 FROM json_each(?) f
+  WHERE json_extract(users.attributes, '$.' || json_extract(f.value, '$.path')) IS NOT json_extract(f.value, '$.value'));`},
+		{&s.stmts.getAllPeople, "SELECT " + personColumns + " FROM users WHERE tenant = ? ORDER BY iin;"},
+		{&s.stmts.updatePerson, `
+ UPDATE users SET name = ?, phone = ?, version = version + 1
+ WHERE tenant = ? AND iin = ? AND ` + versionMatches + `
+ RETURNING version, status;`},
+		{&s.stmts.deletePerson, "DELETE FROM users WHERE tenant = ? AND iin = ? AND " + versionMatches + " RETURNING version;"},
+		{&s.stmts.retireVersion, `
+ INSERT INTO retired_versions(tenant, iin, version) VALUES(?, ?, ?)
+ ON CONFLICT(tenant, iin) DO UPDATE SET version = excluded.version;`},
+		{&s.stmts.personExists, "SELECT EXISTS(SELECT 1 FROM users WHERE tenant = ? AND iin = ?);"},
+		{&s.stmts.touchPerson, `
+ UPDATE users SET version = version + 1 WHERE tenant = ? AND iin = ? AND ` + versionMatches + `
+ RETURNING name, phone, version, status;`},
+		{&s.stmts.getNameAndPhone, "SELECT name, phone FROM users WHERE tenant = ? AND iin = ? LIMIT 1;"},
+		{&s.stmts.saveMergeRecord, `
+ INSERT INTO merge_log(tenant, source_iin, source_name, source_phone, target_iin, target_name, target_phone,
+  target_version, merged_at)
+ VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?);`},
+		{&s.stmts.getMergeLog, `
+ SELECT id, source_iin, source_name, source_phone, target_iin, target_name, target_phone, target_version, merged_at
+ FROM merge_log WHERE tenant = ? ORDER BY id DESC;`},
+		{&s.stmts.getCandidatePage, `
+ SELECT COUNT(*), COALESCE(MAX(iin), '') FROM (
+  SELECT iin FROM users WHERE tenant = ? AND (iin > ? OR iin = ?) ORDER BY iin LIMIT ?);`},
+		{&s.stmts.getCandidatePairs, candidatePairsQuery},
+		{&s.stmts.nextEventSeq, `
+ INSERT INTO event_sequences(tenant, seq) VALUES(?, 1) ON CONFLICT(tenant) DO UPDATE SET seq = seq + 1;`},
+		{&s.stmts.saveEvent, `
+ INSERT INTO events(tenant, tenant_seq, type, iin, payload, request_id, created_at)
+ VALUES(?1, (SELECT seq FROM event_sequences WHERE tenant = ?1), ?2, ?3, ?4, ?5, ?6);`},
+		{&s.stmts.getEvents, "SELECT " + eventColumns + " FROM events WHERE tenant = ? AND tenant_seq > ? ORDER BY tenant_seq LIMIT ?;"},
+		{&s.stmts.getOutboxEvents, "SELECT " + eventColumns + " FROM events WHERE seq > ? ORDER BY seq LIMIT ?;"},
+		{&s.stmts.getLastEventSeq, "SELECT COALESCE((SELECT seq FROM event_sequences WHERE tenant = ?), 0);"},
+		{&s.stmts.getOutboxCursor, "SELECT seq FROM outbox_cursors WHERE consumer = ?;"},
+		{&s.stmts.saveOutboxCursor, `
+ INSERT INTO outbox_cursors(consumer, seq) VALUES(?, ?)
+ ON CONFLICT(consumer) DO UPDATE SET seq = MAX(seq, excluded.seq);`},
+		{&s.stmts.saveConsent, `
+ INSERT INTO consents(tenant, iin, purpose, status, source, created_at) VALUES(?, ?, ?, ?, ?, ?)
+ RETURNING id;`},
+		{&s.stmts.getConsentStatus, `
+ SELECT status FROM consents WHERE tenant = ? AND iin = ? AND purpose = ? AND ` + sinceCreated("consents") + `
+ ORDER BY id DESC LIMIT 1;`},
+		{&s.stmts.getConsents, `
+ SELECT id, iin, purpose, status, source, created_at FROM consents c
+ WHERE tenant = ? AND iin = ?
+  AND id = (SELECT MAX(id) FROM consents WHERE tenant = c.tenant AND iin = c.iin AND purpose = c.purpose)
+  AND ` + sinceCreated("c") + `
+ ORDER BY purpose;`},
+		{&s.stmts.getConsentHistory, `
+ SELECT id, iin, purpose, status, source, created_at FROM consents
+ WHERE tenant = ? AND iin = ? AND ` + sinceCreated("consents") + ` ORDER BY id;`},
+		{&s.stmts.getPersonEvents, "SELECT " + eventColumns + " FROM events WHERE tenant = ? AND iin = ? ORDER BY tenant_seq;"},
+		{&s.stmts.getPersonMerges, `
+ SELECT id, source_iin, source_name, source_phone, target_iin, target_name, target_phone, target_version, merged_at
+ FROM merge_log WHERE tenant = ? AND (source_iin = ? OR target_iin = ?) ORDER BY id;`},
+		{&s.stmts.saveAccess, `
+ INSERT INTO access_log(tenant, iin, action, client, purpose, request_id, accessed_at) VALUES(?, ?, ?, ?, ?, ?, ?);`},
+		{&s.stmts.getAccessLog, `
+ SELECT id, iin, action, client, purpose, request_id, accessed_at FROM access_log WHERE tenant = ? AND iin = ? ORDER BY id;`},
+		{&s.stmts.getExpiredPeople, `
+ SELECT tenant, iin FROM events e
+ WHERE type = ? AND created_at < ? AND seq = (SELECT MAX(seq) FROM events WHERE tenant = e.tenant AND iin = e.iin)
+ AND ` + consumed("e") + `
+ ORDER BY seq;`},
+		{&s.stmts.getExpiredDeceased, `
+ SELECT u.tenant, u.iin FROM users u
+ WHERE u.status = ?1 AND (
+  SELECT effective_date FROM status_changes c WHERE c.tenant = u.tenant AND c.iin = u.iin AND c.to_status = ?1
+  ORDER BY c.id DESC LIMIT 1) < ?2
+ AND NOT EXISTS (SELECT 1 FROM legal_holds h WHERE h.tenant = u.tenant AND h.iin = u.iin)
+ ORDER BY u.tenant, u.iin;`},
+		{&s.stmts.purgePersonDeliveries, `
+ DELETE FROM webhook_deliveries WHERE tenant = ?1 AND event_seq IN (SELECT tenant_seq FROM events WHERE tenant = ?1 AND iin = ?2);`},
+		{&s.stmts.purgePersonMerges, "DELETE FROM merge_log WHERE tenant = ? AND (source_iin = ? OR target_iin = ?);"},
+		{&s.stmts.purgePersonEvents, "DELETE FROM events WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.purgePersonConsents, "DELETE FROM consents WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.purgePersonAccess, "DELETE FROM access_log WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.purgeRetiredVersion, "DELETE FROM retired_versions WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.countExpiredEvents, "SELECT COUNT(*) FROM events WHERE created_at < ? AND " + consumed("events") + ";"},
+		{&s.stmts.purgeExpiredEvents, "DELETE FROM events WHERE created_at < ? AND " + consumed("events") + ";"},
+		{&s.stmts.countExpiredMerges, "SELECT COUNT(*) FROM merge_log WHERE merged_at < ?;"},
+		{&s.stmts.purgeExpiredMerges, "DELETE FROM merge_log WHERE merged_at < ?;"},
+		{&s.stmts.countExpiredDeliveries, "SELECT COUNT(*) FROM webhook_deliveries WHERE status IN (?, ?) AND updated_at < ?;"},
+		{&s.stmts.purgeExpiredDeliveries, "DELETE FROM webhook_deliveries WHERE status IN (?, ?) AND updated_at < ?;"},
+		{&s.stmts.countExpiredAccess, "SELECT COUNT(*) FROM access_log WHERE accessed_at < ?;"},
+		{&s.stmts.purgeExpiredAccess, "DELETE FROM access_log WHERE accessed_at < ?;"},
+		{&s.stmts.saveWebhook, `
+ INSERT INTO webhooks(tenant, url, secret, events, active, last_seq, created_at)
+ VALUES(?1, ?2, ?3, ?4, 1, COALESCE((SELECT seq FROM event_sequences WHERE tenant = ?1), 0), ?5)
+ RETURNING id;`},
+		{&s.stmts.getWebhook, "SELECT " + webhookColumns + " FROM webhooks WHERE tenant = ? AND id = ?;"},
+		{&s.stmts.getWebhooks, "SELECT " + webhookColumns + " FROM webhooks WHERE tenant = ? ORDER BY id;"},
+		{&s.stmts.getActiveWebhooks, "SELECT " + webhookColumns + ", tenant, last_seq FROM webhooks WHERE active = 1 ORDER BY id;"},
+		{&s.stmts.updateWebhook, "UPDATE webhooks SET url = ?, events = ?, active = ? WHERE tenant = ? AND id = ?;"},
+		{&s.stmts.advanceWebhook, "UPDATE webhooks SET last_seq = ? WHERE id = ?;"},
+		{&s.stmts.deleteWebhook, "DELETE FROM webhooks WHERE tenant = ? AND id = ?;"},
+		{&s.stmts.deleteWebhookDeliveries, "DELETE FROM webhook_deliveries WHERE webhook_id = ?;"},
+		{&s.stmts.saveWebhookDelivery, `
+ INSERT INTO webhook_deliveries(tenant, webhook_id, event_seq, event_type, payload, status, next_attempt_at, created_at, updated_at)
+ VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?);`},
+		{&s.stmts.getDueWebhookDeliveries, `
+ SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+ WHERE status = ? AND next_attempt_at <= ?
+  AND webhook_id IN (SELECT id FROM webhooks WHERE active = 1)
+ ORDER BY id LIMIT ?;`},
+		{&s.stmts.getWebhookDeliveries, `
+ SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE tenant = ? AND webhook_id = ? ORDER BY id DESC LIMIT ?;`},
+		{&s.stmts.getDeadWebhookDeliveries, `
+ SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE tenant = ? AND status = ? ORDER BY id DESC LIMIT ?;`},
+		{&s.stmts.getWebhookDeliveryStatus, "SELECT status FROM webhook_deliveries WHERE tenant = ? AND id = ?;"},
+		{&s.stmts.updateWebhookDelivery, `
+ UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, response_status = ?, last_error = ?, updated_at = ?
+ WHERE id = ?;`},
+		{&s.stmts.saveTenant, "INSERT INTO tenants(id, name, created_at) VALUES(?, ?, ?);"},
+		{&s.stmts.getTenants, "SELECT id, name, created_at FROM tenants ORDER BY id;"},
+		{&s.stmts.tenantExists, "SELECT EXISTS(SELECT 1 FROM tenants WHERE id = ?);"},
+		{&s.stmts.saveCredential, `
+ INSERT INTO credentials(username, tenant, password_hash, created_at) VALUES(?, ?, ?, ?)
+ ON CONFLICT(username) DO UPDATE SET tenant = excluded.tenant, password_hash = excluded.password_hash,
+  created_at = excluded.created_at;`},
+		{&s.stmts.getCredential, "SELECT username, tenant, password_hash, created_at FROM credentials WHERE username = ?;"},
+		{&s.stmts.getPersonStatus, "SELECT status, version FROM users WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.setPersonStatus, `
+ UPDATE users SET status = ?, version = version + 1 WHERE tenant = ? AND iin = ?
+ RETURNING name, version;`},
+		{&s.stmts.saveStatusChange, `
+ INSERT INTO status_changes(tenant, iin, from_status, to_status, effective_date, reason, created_at)
+ VALUES(?, ?, ?, ?, ?, ?, ?)
+ RETURNING id;`},
+		{&s.stmts.getStatusHistory, `
+ SELECT id, iin, from_status, to_status, effective_date, reason, created_at FROM status_changes
+ WHERE tenant = ? AND iin = ? AND ` + sinceCreated("status_changes") + ` ORDER BY id;`},
+		{&s.stmts.purgePersonStatus, "DELETE FROM status_changes WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.saveDocument, `
+ INSERT INTO documents(tenant, iin, type, number, issuing_authority, issue_date, expiry_date, created_at, updated_at)
+ VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+ RETURNING id;`},
+		{&s.stmts.getDocument, "SELECT " + documentColumns + " FROM documents WHERE tenant = ? AND iin = ? AND id = ?;"},
+		{&s.stmts.getDocuments, "SELECT " + documentColumns + " FROM documents WHERE tenant = ? AND iin = ? ORDER BY id;"},
+		{&s.stmts.getExpiringDocuments, `
+ SELECT ` + documentColumns + ` FROM documents WHERE tenant = ? AND expiry_date BETWEEN ? AND ?
+ ORDER BY expiry_date, id;`},
+		{&s.stmts.updateDocument, `
+ UPDATE documents SET type = ?, number = ?, issuing_authority = ?, issue_date = ?, expiry_date = ?, updated_at = ?
+ WHERE tenant = ? AND iin = ? AND id = ?;`},
+		{&s.stmts.deleteDocument, "DELETE FROM documents WHERE tenant = ? AND iin = ? AND id = ?;"},
+		{&s.stmts.deletePersonDocuments, "DELETE FROM documents WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.moveDocuments, "UPDATE documents SET iin = ?, updated_at = ? WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.savePhoto, `
+ INSERT INTO photos(tenant, iin, content_type, size, width, height, image, thumbnail, updated_at)
+ VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+ ON CONFLICT(tenant, iin) DO UPDATE SET content_type = excluded.content_type, size = excluded.size,
+  width = excluded.width, height = excluded.height, image = excluded.image, thumbnail = excluded.thumbnail,
+  updated_at = excluded.updated_at;`},
+		{&s.stmts.getPhoto, "SELECT " + photoColumns + ", image FROM photos WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.getPhotoThumbnail, "SELECT " + photoColumns + ", thumbnail FROM photos WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.getPhotoDescription, "SELECT " + photoColumns + ", NULL FROM photos WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.deletePhoto, "DELETE FROM photos WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.movePhoto, "UPDATE OR IGNORE photos SET iin = ? WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.saveRelationship, `
+ INSERT INTO relationships(tenant, from_iin, type, to_iin, created_at) VALUES(?, ?, ?, ?, ?)
+ RETURNING id;`},
+		{&s.stmts.countParents, "SELECT COUNT(*) FROM relationships WHERE tenant = ? AND to_iin = ? AND type = ?;"},
+		{&s.stmts.getRelationships, `
+ SELECT ` + relationshipColumns + ` FROM relationships WHERE tenant = ? AND (from_iin = ? OR to_iin = ?) ORDER BY id;`},
+		{&s.stmts.deleteRelationship, "DELETE FROM relationships WHERE tenant = ? AND id = ?;"},
+		{&s.stmts.deletePersonRelationships, "DELETE FROM relationships WHERE tenant = ? AND (from_iin = ? OR to_iin = ?);"},
+		{&s.stmts.moveWards, `
+ UPDATE OR IGNORE relationships SET from_iin = ?1 WHERE tenant = ?2 AND from_iin = ?3 AND type = 'guardian' AND to_iin <> ?1;`},
+		{&s.stmts.moveGuardians, `
+ UPDATE OR IGNORE relationships SET to_iin = ?1 WHERE tenant = ?2 AND to_iin = ?3 AND type = 'guardian' AND from_iin <> ?1;`},
+		{&s.stmts.getHouseholdMembers, householdQuery + `
+ SELECT h.iin, u.name, MIN(h.hops) FROM household h JOIN users u ON u.tenant = ? AND u.iin = h.iin
+ GROUP BY h.iin ORDER BY MIN(h.hops), h.iin;`},
+		{&s.stmts.getHouseholdRelationships, householdQuery + `
+ SELECT ` + relationshipColumns + ` FROM relationships
+ WHERE tenant = ? AND from_iin IN (SELECT iin FROM household) AND to_iin IN (SELECT iin FROM household)
+ ORDER BY id;`},
+		{&s.stmts.setGuardian, "UPDATE users SET guardian_iin = ? WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.getGuardianIIN, "SELECT COALESCE(guardian_iin, '') FROM users WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.getMinorsWithoutGuardian, `
+ SELECT u.iin, u.name, COALESCE(u.guardian_iin, '') FROM users u
+ WHERE u.tenant = ?1 AND u.status = 'active' AND ` + dateOfBirth("u.iin") + ` > ?2
+ AND NOT EXISTS (
+  SELECT 1 FROM relationships r JOIN users g ON g.tenant = r.tenant AND g.iin = r.from_iin
+  WHERE r.tenant = u.tenant AND r.type = 'guardian' AND r.to_iin = u.iin AND g.status = 'active')
+ ORDER BY u.iin;`},
+		{&s.stmts.saveAddress, `
+ INSERT INTO addresses(tenant, iin, type, kato, region, locality, street, house, apartment, postal_code, updated_at)
+ VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ ON CONFLICT(tenant, iin, type) DO UPDATE SET kato = excluded.kato, region = excluded.region,
+  locality = excluded.locality, street = excluded.street, house = excluded.house, apartment = excluded.apartment,
+  postal_code = excluded.postal_code, updated_at = excluded.updated_at;`},
+		{&s.stmts.getAddresses, `
+ SELECT ` + addressColumns + ` FROM addresses WHERE tenant = ? AND iin = ? ORDER BY type DESC;`},
+		{&s.stmts.deleteAddress, "DELETE FROM addresses WHERE tenant = ? AND iin = ? AND type = ?;"},
+		{&s.stmts.deletePersonAddresses, "DELETE FROM addresses WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.moveAddresses, "UPDATE OR IGNORE addresses SET iin = ?, updated_at = ? WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.countByRegion, `
+ SELECT a.region, COUNT(*) FROM addresses a JOIN users u ON u.tenant = a.tenant AND u.iin = a.iin
+ WHERE a.tenant = ? AND a.type = ? AND (? = '' OR u.status = ?)
+ GROUP BY a.region ORDER BY a.region;`},
+		{&s.stmts.countWithoutAddress, `
+ SELECT COUNT(*) FROM users u WHERE u.tenant = ? AND (? = '' OR u.status = ?)
+ AND NOT EXISTS (SELECT 1 FROM addresses a WHERE a.tenant = u.tenant AND a.iin = u.iin AND a.type = ?);`},
+		{&s.stmts.setAttributes, "UPDATE users SET attributes = ? WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.moveAttributes, `
+ UPDATE users SET attributes = json_patch(
+  COALESCE((SELECT attributes FROM users s WHERE s.tenant = users.tenant AND s.iin = ?), '{}'), COALESCE(attributes, '{}'))
+ WHERE tenant = ? AND iin = ?
+ AND EXISTS (SELECT 1 FROM users s WHERE s.tenant = users.tenant AND s.iin = ? AND s.attributes IS NOT NULL);`},
+		{&s.stmts.saveAttributeSchema, `
+ INSERT INTO attribute_schemas(tenant, namespace, schema, created_at, updated_at) VALUES(?, ?, ?, ?, ?)
+ ON CONFLICT(tenant, namespace) DO UPDATE SET schema = excluded.schema, updated_at = excluded.updated_at
+ RETURNING created_at;`},
+		{&s.stmts.getAttributeSchema, `
+ SELECT ` + attributeSchemaColumns + ` FROM attribute_schemas WHERE tenant = ? AND namespace = ?;`},
+		{&s.stmts.getAttributeSchemas, `
+ SELECT ` + attributeSchemaColumns + ` FROM attribute_schemas WHERE tenant = ? ORDER BY namespace;`},
+		{&s.stmts.deleteAttributeSchema, "DELETE FROM attribute_schemas WHERE tenant = ? AND namespace = ?;"},
+		{&s.stmts.attributeInUse, `
+ SELECT EXISTS(SELECT 1 FROM users WHERE tenant = ? AND json_type(attributes, '$.' || ?) IS NOT NULL);`},
+		{&s.stmts.saveOrganization, "INSERT INTO organizations(tenant, bin, name, created_at) VALUES(?, ?, ?, ?);"},
+		{&s.stmts.getOrganization, "SELECT bin, name, created_at FROM organizations WHERE tenant = ? AND bin = ?;"},
+		{&s.stmts.getOrganizations, "SELECT bin, name, created_at FROM organizations WHERE tenant = ? ORDER BY bin;"},
+		{&s.stmts.organizationExists, "SELECT EXISTS(SELECT 1 FROM organizations WHERE tenant = ? AND bin = ?);"},
+		{&s.stmts.saveEmployment, `
+ INSERT INTO employments(tenant, iin, bin, position, start_date, end_date, created_at, updated_at)
+ VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+ RETURNING id;`},
+		{&s.stmts.getEmployment, "SELECT " + employmentColumns + " FROM employments WHERE tenant = ? AND iin = ? AND id = ?;"},
+		{&s.stmts.getEmployments, `
+ SELECT ` + employmentColumns + ` FROM employments WHERE tenant = ? AND iin = ? ORDER BY start_date, id;`},
+		{&s.stmts.employmentOverlaps, `
+ SELECT EXISTS(SELECT 1 FROM employments WHERE tenant = ? AND iin = ? AND bin = ? AND id != ?
+  AND start_date <= CASE ? WHEN '' THEN '9999-12-31' ELSE ? END AND (end_date = '' OR end_date >= ?));`},
+		{&s.stmts.updateEmployment, `
+ UPDATE employments SET position = ?, start_date = ?, end_date = ?, updated_at = ?
+ WHERE tenant = ? AND iin = ? AND id = ?;`},
+		{&s.stmts.deleteEmployment, "DELETE FROM employments WHERE tenant = ? AND iin = ? AND id = ?;"},
+		{&s.stmts.deletePersonEmployments, "DELETE FROM employments WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.moveEmployments, "UPDATE employments SET iin = ?, updated_at = ? WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.getEmployees, `
+ SELECT u.name, e.id, e.iin, e.bin, e.position, e.start_date, e.end_date, e.created_at, e.updated_at
+ FROM employments e JOIN users u ON u.tenant = e.tenant AND u.iin = e.iin
+ WHERE e.tenant = ? AND e.bin = ? AND (? = '' OR (e.start_date <= ? AND (e.end_date = '' OR e.end_date >= ?)))
+ ORDER BY u.name, e.iin, e.start_date;`},
+		{&s.stmts.placeLegalHold, `
+ INSERT INTO legal_holds(tenant, iin, reason, case_number, placed_by, placed_at) VALUES(?, ?, ?, ?, ?, ?);`},
+		{&s.stmts.getLegalHold, "SELECT " + legalHoldColumns + " FROM legal_holds WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.getLegalHolds, "SELECT " + legalHoldColumns + " FROM legal_holds WHERE tenant = ? ORDER BY placed_at, iin;"},
+		{&s.stmts.releaseLegalHold, "DELETE FROM legal_holds WHERE tenant = ? AND iin = ?;"},
+		{&s.stmts.holdExists, "SELECT EXISTS(SELECT 1 FROM legal_holds WHERE tenant = ? AND iin = ?);"},
+		{&s.stmts.relationshipHeld, `
+ SELECT EXISTS(SELECT 1 FROM relationships r JOIN legal_holds h ON h.tenant = r.tenant AND h.iin IN (r.from_iin, r.to_iin)
+  WHERE r.tenant = ? AND r.id = ?);`},
+		{&s.stmts.saveHoldLog, `
+ INSERT INTO legal_hold_log(tenant, iin, action, reason, case_number, client, created_at) VALUES(?, ?, ?, ?, ?, ?, ?)
+ RETURNING id;`},
+		{&s.stmts.getHoldLog, `
+ SELECT id, iin, action, reason, case_number, client, created_at FROM legal_hold_log
+ WHERE tenant = ? AND iin = ? AND ` + sinceCreated("legal_hold_log") + ` ORDER BY id;`},
+	}
+
+	for _, q := range queries {
+		stmt, err := s.db.Prepare(q.query)
+		if err != nil {
+			return fmt.Errorf("prepare %q: %w", strings.TrimSpace(q.query), err)
+		}
+		*q.stmt = stmt
+	}
+
+	return nil
+}
+
+// all returns every statement of the set.
+func (st *statements) all() []*sql.Stmt {
+	return []*sql.Stmt{
+		st.savePerson, st.retireVersion, st.getPersonByIIN, st.getPersonByName, st.getAllPeople,
+		st.updatePerson, st.deletePerson, st.personExists, st.touchPerson,
+		st.getNameAndPhone, st.saveMergeRecord, st.getMergeLog, st.getCandidatePage, st.getCandidatePairs,
+		st.nextEventSeq, st.saveEvent, st.getEvents, st.getOutboxEvents, st.getLastEventSeq,
+		st.getOutboxCursor, st.saveOutboxCursor,
+		st.saveConsent, st.getConsentStatus, st.getConsents,
+		st.getConsentHistory, st.getPersonEvents, st.getPersonMerges, st.saveAccess, st.getAccessLog,
+		st.getExpiredPeople, st.getExpiredDeceased, st.purgePersonDeliveries, st.purgePersonMerges, st.purgePersonEvents, st.purgePersonConsents,
+		st.purgePersonAccess, st.purgeRetiredVersion,
+		st.countExpiredEvents, st.purgeExpiredEvents, st.countExpiredMerges, st.purgeExpiredMerges,
+		st.countExpiredDeliveries, st.purgeExpiredDeliveries, st.countExpiredAccess, st.purgeExpiredAccess,
+		st.saveWebhook, st.getWebhook, st.getWebhooks, st.getActiveWebhooks,
+		st.updateWebhook, st.advanceWebhook, st.deleteWebhook, st.deleteWebhookDeliveries,
+		st.saveWebhookDelivery, st.getDueWebhookDeliveries, st.getWebhookDeliveries,
+		st.getDeadWebhookDeliveries, st.getWebhookDeliveryStatus, st.updateWebhookDelivery,
+		st.saveTenant, st.getTenants, st.tenantExists, st.saveCredential, st.getCredential,
+		st.getPersonStatus, st.setPersonStatus, st.saveStatusChange, st.getStatusHistory, st.purgePersonStatus,
+		st.saveDocument, st.getDocument, st.getDocuments, st.getExpiringDocuments,
+		st.updateDocument, st.deleteDocument, st.deletePersonDocuments, st.moveDocuments,
+		st.savePhoto, st.getPhoto, st.getPhotoThumbnail, st.getPhotoDescription, st.deletePhoto, st.movePhoto,
+		st.saveRelationship, st.countParents, st.getRelationships, st.deleteRelationship,
+		st.deletePersonRelationships, st.getHouseholdMembers, st.getHouseholdRelationships, st.moveWards, st.moveGuardians,
+		st.setGuardian, st.getGuardianIIN, st.getMinorsWithoutGuardian,
+		st.saveAddress, st.getAddresses, st.deleteAddress, st.deletePersonAddresses, st.moveAddresses,
+		st.countByRegion, st.countWithoutAddress,
+		st.setAttributes, st.moveAttributes, st.saveAttributeSchema, st.getAttributeSchema, st.getAttributeSchemas,
+		st.deleteAttributeSchema, st.attributeInUse,
+		st.saveOrganization, st.getOrganization, st.getOrganizations, st.organizationExists,
+		st.saveEmployment, st.getEmployment, st.getEmployments, st.employmentOverlaps, st.updateEmployment,
+		st.deleteEmployment, st.deletePersonEmployments, st.moveEmployments, st.getEmployees,
+		st.placeLegalHold, st.getLegalHold, st.getLegalHolds, st.releaseLegalHold, st.holdExists,
+		st.relationshipHeld, st.saveHoldLog, st.getHoldLog,
+	}
+}
+
+// stmt returns the prepared statement bound to the transaction, or the statement itself if tx is nil.
+func stmt(tx *sql.Tx, stmt *sql.Stmt) *sql.Stmt {
+	if tx == nil {
+		return stmt
+	}
+	return tx.Stmt(stmt)
+}
+
+// SavePerson method saves a person's information in the database under the tenant of the context.
 // It returns an error if the operation fails.
-func (s *Storage) SavePerson(iin string, name string, phone string) error {
+func (s *Storage) SavePerson(ctx context.Context, iin string, name string, phone string) error {
 	const op = "storage.sqlite.SavePerson"
 
-	// Prepare a SQL statement to insert a new user
-	stmt, err := s.db.Prepare("INSERT INTO users(iin, name, phone) VALUES(?, ?, ?)")
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		_, err := s.savePerson(ctx, tx, iin, name, phone)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	return nil
+}
+
+// SavePersonWithOptions method saves a person in the tenant of the context along with the given options,
+// as a single atomic write. A guardian must already be stored and active. It is recorded on the person, where it remains
+// after the guardian is deleted, and linked to them by a guardian relationship.
+// The extension attributes are stored as they are, their validation is up to the caller.
+// It returns an error, storage.ErrorGuardianNotFound if the guardian is not stored
+// and storage.ErrorGuardianInactive if they are deceased or emigrated.
+func (s *Storage) SavePersonWithOptions(ctx context.Context, iin string, name string, phone string, opts storage.SaveOptions) error {
+	const op = "storage.sqlite.SavePersonWithOptions"
+
+	tenant := storage.TenantID(ctx)
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if opts.GuardianIIN != "" {
+			if err := s.checkHold(ctx, tx, opts.GuardianIIN); err != nil {
+				return err
+			}
+			if err := s.checkGuardian(ctx, tx, opts.GuardianIIN); err != nil {
+				return err
+			}
+		}
+
+		if _, err := s.savePerson(ctx, tx, iin, name, phone); err != nil {
+			return err
+		}
+
+		if len(opts.Attributes) > 0 {
+			attributes, err := json.Marshal(opts.Attributes)
+			if err != nil {
+				return err
+			}
+			if _, err = tx.Stmt(s.stmts.setAttributes).Exec(string(attributes), tenant, iin); err != nil {
+				return err
+			}
+		}
+
+		if opts.GuardianIIN == "" {
+			return nil
+		}
+		if _, err := tx.Stmt(s.stmts.setGuardian).Exec(opts.GuardianIIN, tenant, iin); err != nil {
+			return err
+		}
+		var id int64
+		return tx.Stmt(s.stmts.saveRelationship).
+			QueryRow(tenant, opts.GuardianIIN, storage.RelationshipGuardian, iin, time.Now().UTC()).Scan(&id)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// savePerson method inserts a new person, records the creation event and returns the version of the created record.
+func (s *Storage) savePerson(ctx context.Context, tx *sql.Tx, iin string, name string, phone string) (int64, error) {
 	// Execute the SQL statement
-	_, err = stmt.Exec(iin, name, phone)
+	var version int64
+	err := stmt(tx, s.stmts.savePerson).QueryRow(storage.TenantID(ctx), iin, name, phone, time.Now().UTC()).Scan(&version)
 	if err != nil {
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) {
 			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintPrimaryKey) {
-				return fmt.Errorf("%s: %w", op, storage.ErrorIINExists)
+				return 0, storage.ErrorIINExists
 			}
 			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
-				return fmt.Errorf("%s: %w", op, storage.ErrorPhoneNumberExists)
+				return 0, storage.ErrorPhoneNumberExists
 			}
 
 		}
-		return fmt.Errorf("%s: %w", op, err)
+		return 0, err
 	}
 
-	return nil
+	err = s.saveEvent(ctx, tx, storage.EventPersonCreated, storage.EventPayload{
+		IIN: iin, Name: name, Version: version, Status: storage.StatusActive,
+	})
+	return version, err
 }
 
-// GetPersonByIIN method retrieves a person's information by their IIN.
+// GetPersonByIIN method retrieves a person's information by their IIN within the tenant of the context.
 // It returns a PersonInfo struct or an error.
-func (s *Storage) GetPersonByIIN(iin string) (storage.PersonInfo, error) {
+func (s *Storage) GetPersonByIIN(ctx context.Context, iin string) (storage.PersonInfo, error) {
 	const fn = "storage.sqlite.GetPersonByIIN"
 
-	// Prepare a SQL statement to select a user by IIN
-	stmt, err := s.db.Prepare("SELECT * FROM users WHERE iin = ? LIMIT 1;")
-	if err != nil {
-		return storage.PersonInfo{}, fmt.Errorf("%s: %w", fn, err)
-	}
-	person := storage.PersonInfo{}
-	err = stmt.QueryRow(iin).Scan(&person.IIN, &person.Name, &person.Phone)
+	person, err := scanPerson(s.stmts.getPersonByIIN.QueryRow(storage.TenantID(ctx), iin))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return storage.PersonInfo{}, fmt.Errorf("%s: %w", fn, storage.ErrorIINNotFound)
@@ -105,67 +1262,243 @@ func (s *Storage) GetPersonByIIN(iin string) (storage.PersonInfo, error) {
 	return person, nil
 }
 
-// GetPersonByName method retrieves all people with a name that matches the provided name.
+// GetPersonByName method retrieves all people of the tenant of the context with a name that matches the provided name
+// and who match every field of the filter: the status, an address in the region with the given KATO code,
+// and the values of the extension attributes.
 // It returns a slice of PersonInfo structs or an error.
-func (s *Storage) GetPersonByName(name string) ([]storage.PersonInfo, error) {
+func (s *Storage) GetPersonByName(ctx context.Context, name string, filter storage.PersonFilter) ([]storage.PersonInfo, error) {
 	const fn = "storage.sqlite.GetPersonByName"
-	var allMatchedPeople []storage.PersonInfo
 
-	// Prepare a SQL statement to select users by name
-	stmt, err := s.db.Prepare("SELECT * FROM users WHERE name LIKE ?;")
-	if err != nil {
-		return allMatchedPeople, fmt.Errorf("%s: %w", fn, err)
+	// The attribute filters are passed as a JSON array of objects with a path and a value
+	attributes := []byte("[]")
+	if len(filter.Attributes) > 0 {
+		var err error
+		if attributes, err = json.Marshal(filter.Attributes); err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
 	}
 
 	// Execute the SQL statement
-	rows, err := stmt.Query("%" + name + "%")
+	rows, err := s.stmts.getPersonByName.Query(storage.TenantID(ctx), "%"+name+"%", filter.Status, filter.Status,
+		filter.Region, filter.Region, string(attributes))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	allMatchedPeople, err := scanPeople(rows)
 	if err != nil {
 		return allMatchedPeople, fmt.Errorf("%s: %w", fn, err)
 	}
 
-	// Scan the result rows into PersonInfo structs
+	return allMatchedPeople, nil
+}
+
+// GetAllPeople method retrieves every person of the tenant of the context ordered by IIN.
+// It returns a slice of PersonInfo structs or an error.
+func (s *Storage) GetAllPeople(ctx context.Context) ([]storage.PersonInfo, error) {
+	const fn = "storage.sqlite.GetAllPeople"
+
+	rows, err := s.stmts.getAllPeople.Query(storage.TenantID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	people, err := scanPeople(rows)
+	if err != nil {
+		return people, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return people, nil
+}
+
+// scanPeople scans the result rows into PersonInfo structs and closes the rows.
+// It returns a nil slice if there are no rows.
+func scanPeople(rows *sql.Rows) ([]storage.PersonInfo, error) {
+	defer rows.Close()
+
+	var people []storage.PersonInfo
 	for rows.Next() {
-		person := storage.PersonInfo{}
-		err = rows.Scan(&person.IIN, &person.Name, &person.Phone)
+		person, err := scanPerson(rows)
 		if err != nil {
-			return allMatchedPeople, fmt.Errorf("%s: %w", fn, err)
+			return people, err
 		}
-		allMatchedPeople = append(allMatchedPeople, person)
+		people = append(people, person)
 	}
 
-	if err = rows.Err(); err != nil {
-		return allMatchedPeople, fmt.Errorf("%s: %w", fn, err)
+	return people, rows.Err()
+}
+
+// sinceCreated returns the condition limiting the rows of a history table, keyed by tenant and IIN,
+// to those recorded since the current record of the person was created, so that a person deleted
+// and saved again under the same IIN does not inherit the status changes, consents and legal hold log
+// of the previous record. Every row is kept while there is no current record.
+func sinceCreated(table string) string {
+	return fmt.Sprintf(`%[1]s.created_at >= COALESCE(
+  (SELECT u.created_at FROM users u WHERE u.tenant = %[1]s.tenant AND u.iin = %[1]s.iin), '')`, table)
+}
+
+// consumed returns the condition limiting the rows of the events table to those every consumer of the outbox
+// has read: the NATS publisher, once it has stored its cursor, and every webhook of the tenant of the event,
+// including the paused ones, which receive the events recorded meanwhile once active again.
+func consumed(table string) string {
+	return fmt.Sprintf(`%[1]s.seq <= (SELECT COALESCE(MIN(seq), %[2]d) FROM outbox_cursors)
+ AND %[1]s.tenant_seq <= (SELECT COALESCE(MIN(w.last_seq), %[2]d) FROM webhooks w WHERE w.tenant = %[1]s.tenant)`,
+		table, math.MaxInt64)
+}
+
+// dateOfBirth returns the expression of the date of birth, as YYYY-MM-DD, encoded in the IIN held by the column:
+// its first six digits followed by the century given by the seventh, as iin_validator.GetDateOfBirth reads it.
+func dateOfBirth(column string) string {
+	return fmt.Sprintf(`(CASE substr(%[1]s, 7, 1) WHEN '1' THEN '18' WHEN '2' THEN '18' WHEN '3' THEN '19' WHEN '4' THEN '19'
+  WHEN '5' THEN '20' WHEN '6' THEN '20' END
+  || substr(%[1]s, 1, 2) || '-' || substr(%[1]s, 3, 2) || '-' || substr(%[1]s, 5, 2))`, column)
+}
+
+// versionMatches is the condition of the conditional writes of a person, bound to the expected versions
+// as a JSON array, see versionsJSON. Zero and storage.AnyVersion match any version.
+const versionMatches = "EXISTS (SELECT 1 FROM json_each(?) WHERE value <= 0 OR value = users.version)"
+
+// versionsJSON returns the expected versions of a conditional write as bound to versionMatches,
+// zero if there are none, which matches any version.
+func versionsJSON(expectedVersions []int64) string {
+	if len(expectedVersions) == 0 {
+		return "[0]"
 	}
+	versions, _ := json.Marshal(expectedVersions)
+	return string(versions)
+}
 
-	return allMatchedPeople, nil
+// personColumns lists the columns scanPerson reads, in order.
+const personColumns = "iin, name, phone, version, status, attributes"
+
+// scanPerson scans a row of personColumns into a PersonInfo struct.
+func scanPerson(row rowScanner) (storage.PersonInfo, error) {
+	person := storage.PersonInfo{}
+	var attributes sql.NullString
+	err := row.Scan(&person.IIN, &person.Name, &person.Phone, &person.Version, &person.Status, &attributes)
+	if err != nil || !attributes.Valid {
+		return person, err
+	}
+	return person, json.Unmarshal([]byte(attributes.String), &person.Attributes)
+}
+
+// UpdatePerson method replaces the name and phone of the person with the given IIN.
+// If expected versions are given, the update only happens while the stored version is still one of them,
+// or, if it is storage.AnyVersion, while the person exists. Zero matches any version.
+// It returns the new version of the record or an error.
+func (s *Storage) UpdatePerson(ctx context.Context, iin string, name string, phone string, expectedVersions ...int64) (int64, error) {
+	const fn = "storage.sqlite.UpdatePerson"
+
+	var version int64
+	err := s.write(ctx, func(tx *sql.Tx) (err error) {
+		version, err = s.updatePerson(ctx, tx, iin, name, phone, expectedVersions)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return version, nil
+}
+
+// updatePerson method updates a person, bumps its version, records the update event and returns the new version.
+func (s *Storage) updatePerson(ctx context.Context, tx *sql.Tx, iin string, name string, phone string, expectedVersions []int64) (int64, error) {
+	if err := s.checkHold(ctx, tx, iin); err != nil {
+		return 0, err
+	}
+
+	var version int64
+	var status string
+	err := stmt(tx, s.stmts.updatePerson).QueryRow(name, phone, storage.TenantID(ctx), iin, versionsJSON(expectedVersions)).Scan(&version, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, s.missingOrStale(ctx, tx, iin, expectedVersions)
+	}
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+			return 0, storage.ErrorPhoneNumberExists
+		}
+		return 0, err
+	}
+
+	err = s.saveEvent(ctx, tx, storage.EventPersonUpdated, storage.EventPayload{
+		IIN: iin, Name: name, Version: version, Status: status,
+	})
+	return version, err
 }
 
 // DeletePersonByIIN method deletes a person's information by their IIN.
+// If expected versions are given, the person is only deleted while the stored version is still one of them,
+// or, if it is storage.AnyVersion, while the person exists. Zero matches any version.
 // It returns an error if the operation fails.
-func (s *Storage) DeletePersonByIIN(iin string) error {
+func (s *Storage) DeletePersonByIIN(ctx context.Context, iin string, expectedVersions ...int64) error {
 	const fn = "storage.sqlite.DeletePersonByIIN"
 
-	// Prepare a SQL statement to delete a user by IIN
-	stmt, err := s.db.Prepare("DELETE FROM users WHERE iin = ?")
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		return s.deletePerson(ctx, tx, iin, expectedVersions)
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", fn, err)
 	}
 
+	return nil
+}
+
+// deletePerson method deletes a person along with their documents, photo, relationships, addresses and employments
+// and records the deletion event.
+// It reports ErrorIINNotFound if no row was affected.
+func (s *Storage) deletePerson(ctx context.Context, tx *sql.Tx, iin string, expectedVersions []int64) error {
+	if err := s.checkHold(ctx, tx, iin); err != nil {
+		return err
+	}
+
 	// Execute the SQL statement
-	result, err := stmt.Exec(iin)
+	var version int64
+	err := stmt(tx, s.stmts.deletePerson).QueryRow(storage.TenantID(ctx), iin, versionsJSON(expectedVersions)).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.missingOrStale(ctx, tx, iin, expectedVersions)
+	}
 	if err != nil {
-		return fmt.Errorf("%s: %w", fn, err)
+		return err
 	}
 
-	// Check if any rows were affected
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("%s: %w", fn, err)
+	if _, err = stmt(tx, s.stmts.retireVersion).Exec(storage.TenantID(ctx), iin, version); err != nil {
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("%s: %w", fn, storage.ErrorIINNotFound)
+	if _, err = stmt(tx, s.stmts.deletePersonDocuments).Exec(storage.TenantID(ctx), iin); err != nil {
+		return err
+	}
+	if _, err = stmt(tx, s.stmts.deletePhoto).Exec(storage.TenantID(ctx), iin); err != nil {
+		return err
+	}
+	if _, err = stmt(tx, s.stmts.deletePersonRelationships).Exec(storage.TenantID(ctx), iin, iin); err != nil {
+		return err
+	}
+	if _, err = stmt(tx, s.stmts.deletePersonAddresses).Exec(storage.TenantID(ctx), iin); err != nil {
+		return err
+	}
+	if _, err = stmt(tx, s.stmts.deletePersonEmployments).Exec(storage.TenantID(ctx), iin); err != nil {
+		return err
 	}
 
-	return nil
+	return s.saveEvent(ctx, tx, storage.EventPersonDeleted, storage.EventPayload{IIN: iin})
+}
+
+// missingOrStale method explains why a conditional write of the person with the given IIN matched no rows.
+// It returns ErrorIINNotFound if the person does not exist and ErrorVersionMismatch otherwise,
+// or if the write expected storage.AnyVersion, which a missing person does not match.
+func (s *Storage) missingOrStale(ctx context.Context, tx *sql.Tx, iin string, expectedVersions []int64) error {
+	var exists bool
+	err := stmt(tx, s.stmts.personExists).QueryRow(storage.TenantID(ctx), iin).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists && slices.Contains(expectedVersions, storage.AnyVersion) {
+		return fmt.Errorf("%w: no current record", storage.ErrorVersionMismatch)
+	}
+	if !exists {
+		return storage.ErrorIINNotFound
+	}
+	return storage.ErrorVersionMismatch
 }
@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rollbackJournal reproduces the settings the storage ran with before WAL was enabled.
+var rollbackJournal = Options{JournalMode: "DELETE", Synchronous: "FULL"}
+
+// wal is the configuration shipped in config/prod.yaml.
+var wal = Options{JournalMode: "WAL", BusyTimeout: 5 * time.Second, Synchronous: "NORMAL", MaxOpenConns: 8, MaxIdleConns: 8}
+
+func newBenchmarkStorage(b *testing.B, opts Options, people int) *Storage {
+	b.Helper()
+
+	s, err := New(filepath.Join(b.TempDir(), "bench.db"), opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = s.Close() })
+
+	for i := 0; i < people; i++ {
+		if err := s.SavePerson(operator(), fmt.Sprintf("%012d", i), "Test Name", fmt.Sprintf("+7%010d", i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return s
+}
+
+// BenchmarkGetPersonByIIN compares preparing the statement on every call, as the storage used to do,
+// with reusing the statement prepared in New.
+func BenchmarkGetPersonByIIN(b *testing.B) {
+	const people = 1000
+
+	b.Run("prepare_per_call", func(b *testing.B) {
+		s := newBenchmarkStorage(b, wal, people)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// The same query as the prepared statement, scoped to the tenant
+			stmt, err := s.db.Prepare("SELECT " + personColumns + " FROM users WHERE tenant = ? AND iin = ? LIMIT 1;")
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err = scanPerson(stmt.QueryRow(storage.DefaultTenant, fmt.Sprintf("%012d", i%people))); err != nil {
+				b.Fatal(err)
+			}
+			_ = stmt.Close()
+		}
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		s := newBenchmarkStorage(b, wal, people)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := s.GetPersonByIIN(operator(), fmt.Sprintf("%012d", i%people)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkSavePerson compares inserts in the default rollback journal mode with WAL mode.
+func BenchmarkSavePerson(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts Options
+	}{
+		{"rollback_journal", rollbackJournal},
+		{"wal", wal},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s := newBenchmarkStorage(b, bc.opts, 0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := s.SavePerson(operator(), fmt.Sprintf("%012d", i), "Test Name", fmt.Sprintf("+7%010d", i)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetPersonByIINParallel compares concurrent reads in both journal modes.
+func BenchmarkGetPersonByIINParallel(b *testing.B) {
+	const people = 1000
+
+	for _, bc := range []struct {
+		name string
+		opts Options
+	}{
+		{"rollback_journal", rollbackJournal},
+		{"wal", wal},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s := newBenchmarkStorage(b, bc.opts, people)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if _, err := s.GetPersonByIIN(operator(), fmt.Sprintf("%012d", i%people)); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkSavePersonParallel measures concurrent inserts, which the writer commits in groups.
+func BenchmarkSavePersonParallel(b *testing.B) {
+	s := newBenchmarkStorage(b, wal, 0)
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := next.Add(1)
+			if err := s.SavePerson(operator(), fmt.Sprintf("%012d", i), "Test Name", fmt.Sprintf("+7%010d", i)); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
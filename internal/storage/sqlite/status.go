@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChangeStatus method moves the person stored under the IIN in the tenant of the context to the status,
+// if the transition from the current status is allowed, and records the change in the status history
+// along with an update event, as a single atomic write. The version of the person is bumped.
+// If expected versions are given, the change only happens while the stored version is still one of them,
+// or, if it is storage.AnyVersion, while the person exists. Zero matches any version.
+// It returns the recorded StatusChange struct or an error, storage.ErrorIINNotFound if there is no such person,
+// storage.ErrorVersionMismatch if the version differs and storage.ErrorStatusTransition if the transition is not allowed.
+func (s *Storage) ChangeStatus(ctx context.Context, iin string, status string, effectiveDate string, reason string, expectedVersions ...int64) (storage.StatusChange, error) {
+	const fn = "storage.sqlite.ChangeStatus"
+
+	var change storage.StatusChange
+	err := s.write(ctx, func(tx *sql.Tx) (err error) {
+		change, err = s.changeStatus(ctx, tx, iin, status, effectiveDate, reason, expectedVersions)
+		return err
+	})
+	if err != nil {
+		return storage.StatusChange{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return change, nil
+}
+
+// changeStatus method updates the status of a person and records the change within the transaction.
+// The expected version is checked before the transition, as a precondition of the request.
+func (s *Storage) changeStatus(ctx context.Context, tx *sql.Tx, iin string, status string, effectiveDate string, reason string, expectedVersions []int64) (storage.StatusChange, error) {
+	tenant := storage.TenantID(ctx)
+	change := storage.StatusChange{
+		IIN:           iin,
+		To:            status,
+		EffectiveDate: effectiveDate,
+		Reason:        reason,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	if err := s.checkHold(ctx, tx, iin); err != nil {
+		return change, err
+	}
+
+	var version int64
+	err := tx.Stmt(s.stmts.getPersonStatus).QueryRow(tenant, iin).Scan(&change.From, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return change, s.missingOrStale(ctx, tx, iin, expectedVersions)
+	}
+	if err != nil {
+		return change, err
+	}
+	if !storage.VersionMatches(version, expectedVersions...) {
+		return change, storage.ErrorVersionMismatch
+	}
+	if err = storage.ValidateStatusTransition(change.From, status); err != nil {
+		return change, err
+	}
+
+	payload := storage.EventPayload{IIN: iin, Status: status}
+	err = tx.Stmt(s.stmts.setPersonStatus).QueryRow(status, tenant, iin).Scan(&payload.Name, &payload.Version)
+	if err != nil {
+		return change, err
+	}
+	change.Version = payload.Version
+
+	err = tx.Stmt(s.stmts.saveStatusChange).QueryRow(tenant, iin, change.From, change.To,
+		change.EffectiveDate, change.Reason, change.CreatedAt).Scan(&change.ID)
+	if err != nil {
+		return change, err
+	}
+
+	return change, s.saveEvent(ctx, tx, storage.EventPersonUpdated, payload)
+}
+
+// GetStatusHistory method retrieves every status change of the IIN within the tenant of the context, oldest first.
+// It returns a slice of StatusChange structs or an error.
+func (s *Storage) GetStatusHistory(ctx context.Context, iin string) ([]storage.StatusChange, error) {
+	const fn = "storage.sqlite.GetStatusHistory"
+
+	changes := []storage.StatusChange{}
+	rows, err := s.stmts.getStatusHistory.Query(storage.TenantID(ctx), iin)
+	if err != nil {
+		return changes, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		change := storage.StatusChange{}
+		err = rows.Scan(&change.ID, &change.IIN, &change.From, &change.To, &change.EffectiveDate, &change.Reason, &change.CreatedAt)
+		if err != nil {
+			return changes, fmt.Errorf("%s: %w", fn, err)
+		}
+		changes = append(changes, change)
+	}
+	if err = rows.Err(); err != nil {
+		return changes, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SaveTenant method registers a tenant.
+// It returns the created Tenant struct or an error, storage.ErrorTenantExists if the ID is taken.
+func (s *Storage) SaveTenant(id string, name string) (storage.Tenant, error) {
+	const fn = "storage.sqlite.SaveTenant"
+
+	tenant := storage.Tenant{
+		ID:        id,
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+	}
+	err := s.write(context.Background(), func(tx *sql.Tx) error {
+		_, err := tx.Stmt(s.stmts.saveTenant).Exec(tenant.ID, tenant.Name, tenant.CreatedAt)
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintPrimaryKey) {
+			return storage.ErrorTenantExists
+		}
+		return err
+	})
+	if err != nil {
+		return storage.Tenant{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return tenant, nil
+}
+
+// GetTenants method retrieves every tenant ordered by ID.
+// It returns a slice of Tenant structs or an error.
+func (s *Storage) GetTenants() ([]storage.Tenant, error) {
+	const fn = "storage.sqlite.GetTenants"
+
+	tenants := []storage.Tenant{}
+	rows, err := s.stmts.getTenants.Query()
+	if err != nil {
+		return tenants, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tenant := storage.Tenant{}
+		if err = rows.Scan(&tenant.ID, &tenant.Name, &tenant.CreatedAt); err != nil {
+			return tenants, fmt.Errorf("%s: %w", fn, err)
+		}
+		tenants = append(tenants, tenant)
+	}
+	if err = rows.Err(); err != nil {
+		return tenants, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return tenants, nil
+}
+
+// SaveCredential method assigns a user to the tenant with the given password hash.
+// Assigning an existing user again moves it to the tenant and replaces its password.
+// It returns the saved Credential struct or an error, storage.ErrorTenantNotFound if there is no such tenant.
+func (s *Storage) SaveCredential(username string, tenant string, passwordHash string) (storage.Credential, error) {
+	const fn = "storage.sqlite.SaveCredential"
+
+	credential := storage.Credential{
+		Username:     username,
+		Tenant:       tenant,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now().UTC(),
+	}
+	err := s.write(context.Background(), func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Stmt(s.stmts.tenantExists).QueryRow(tenant).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrorTenantNotFound
+		}
+
+		_, err := tx.Stmt(s.stmts.saveCredential).Exec(username, tenant, passwordHash, credential.CreatedAt)
+		return err
+	})
+	if err != nil {
+		return storage.Credential{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return credential, nil
+}
+
+// GetCredential method retrieves the credential of a user.
+// It returns a Credential struct or an error, storage.ErrorCredentialNotFound if there is no such user.
+func (s *Storage) GetCredential(username string) (storage.Credential, error) {
+	const fn = "storage.sqlite.GetCredential"
+
+	credential := storage.Credential{}
+	err := s.stmts.getCredential.QueryRow(username).
+		Scan(&credential.Username, &credential.Tenant, &credential.PasswordHash, &credential.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Credential{}, fmt.Errorf("%s: %w", fn, storage.ErrorCredentialNotFound)
+	}
+	if err != nil {
+		return storage.Credential{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return credential, nil
+}
@@ -0,0 +1,162 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantsArePartitioned(t *testing.T) {
+	s := newTestStorage(t, wal)
+	_, err := s.SaveTenant("health", "Health Department")
+	require.NoError(t, err)
+
+	defaultTenant := operator()
+	health := storage.WithTenant(context.Background(), "health")
+
+	require.NoError(t, s.SavePerson(defaultTenant, "830218350074", "Default Person", "+77010000001"))
+	// The same IIN and phone number may be stored by another tenant, but not twice by the same one
+	require.NoError(t, s.SavePerson(health, "830218350074", "Health Person", "+77010000001"))
+	assert.ErrorIs(t, s.SavePerson(health, "830218350074", "Health Person", "+77010000002"), storage.ErrorIINExists)
+	assert.ErrorIs(t, s.SavePerson(health, "980301450725", "Health Person", "+77010000001"), storage.ErrorPhoneNumberExists)
+	require.NoError(t, s.SavePerson(health, "980301450725", "Health Person", "+77010000002"))
+
+	person, err := s.GetPersonByIIN(defaultTenant, "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, "Default Person", person.Name)
+	person, err = s.GetPersonByIIN(health, "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, "Health Person", person.Name)
+	_, err = s.GetPersonByIIN(defaultTenant, "980301450725")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	people, err := s.GetPersonByName(defaultTenant, "Person", storage.PersonFilter{})
+	require.NoError(t, err)
+	assert.Len(t, people, 1)
+
+	// Writes of one tenant leave the records of the others untouched
+	_, err = s.UpdatePerson(health, "830218350074", "New Name", "+77010000003", 0)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePersonByIIN(health, "980301450725", 0))
+	assert.ErrorIs(t, s.DeletePersonByIIN(defaultTenant, "980301450725", 0), storage.ErrorIINNotFound)
+	person, err = s.GetPersonByIIN(defaultTenant, "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, "Default Person", person.Name)
+
+	events, err := s.GetEvents(defaultTenant, 0, 100)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+	events, err = s.GetEvents(health, 0, 100)
+	require.NoError(t, err)
+	assert.Len(t, events, 4)
+	last, err := s.GetLastEventSeq(defaultTenant)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), last)
+
+	events, err = s.GetOutboxEvents(0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	assert.Equal(t, storage.DefaultTenant, events[0].Tenant)
+	assert.Equal(t, "health", events[1].Tenant)
+}
+
+func TestContextWithoutTenantIsRefused(t *testing.T) {
+	s := newTestStorage(t, wal)
+	require.NoError(t, s.SavePerson(operator(), "830218350074", "Test Name", "+77010000001"))
+
+	// Nothing falls back to the partition of the operator
+	assert.Panics(t, func() { _, _ = s.GetPersonByIIN(context.Background(), "830218350074") })
+	assert.Error(t, s.SavePerson(context.Background(), "980301450725", "Test Name", "+77010000002"))
+	_, err := s.GetPersonByIIN(operator(), "980301450725")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+}
+
+func TestWebhooksOnlyReceiveTheirTenant(t *testing.T) {
+	s := newTestStorage(t, wal)
+	_, err := s.SaveTenant("health", "Health Department")
+	require.NoError(t, err)
+	health := storage.WithTenant(context.Background(), "health")
+
+	webhook, err := s.SaveWebhook(health, "http://localhost/hook", "secret", []string{"created"})
+	require.NoError(t, err)
+	_, err = s.GetWebhook(operator(), webhook.ID)
+	assert.ErrorIs(t, err, storage.ErrorWebhookNotFound)
+	assert.ErrorIs(t, s.DeleteWebhook(operator(), webhook.ID), storage.ErrorWebhookNotFound)
+
+	require.NoError(t, s.SavePerson(operator(), "830218350074", "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(health, "980301450725", "Test Name", "+77010000001"))
+	queued, err := s.EnqueueWebhookDeliveries(100)
+	require.NoError(t, err)
+	assert.Equal(t, 1, queued)
+
+	deliveries, err := s.GetWebhookDeliveries(health, webhook.ID, 100)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "health", deliveries[0].Tenant)
+}
+
+func TestCredentials(t *testing.T) {
+	s := newTestStorage(t, wal)
+
+	_, err := s.SaveTenant(storage.DefaultTenant, "Default")
+	assert.ErrorIs(t, err, storage.ErrorTenantExists)
+	_, err = s.SaveTenant("health", "Health Department")
+	require.NoError(t, err)
+
+	tenants, err := s.GetTenants()
+	require.NoError(t, err)
+	require.Len(t, tenants, 2)
+	assert.Equal(t, storage.DefaultTenant, tenants[0].ID)
+	assert.Equal(t, "health", tenants[1].ID)
+
+	_, err = s.SaveCredential("clinic", "unknown", "hash")
+	assert.ErrorIs(t, err, storage.ErrorTenantNotFound)
+	_, err = s.GetCredential("clinic")
+	assert.ErrorIs(t, err, storage.ErrorCredentialNotFound)
+
+	_, err = s.SaveCredential("clinic", storage.DefaultTenant, "first")
+	require.NoError(t, err)
+	// Assigning the user again moves it
+	_, err = s.SaveCredential("clinic", "health", "second")
+	require.NoError(t, err)
+
+	credential, err := s.GetCredential("clinic")
+	require.NoError(t, err)
+	assert.Equal(t, "health", credential.Tenant)
+	assert.Equal(t, "second", credential.PasswordHash)
+}
+
+func TestMigratePartitionsUsers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// A database created before tenants were introduced
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+ CREATE TABLE users (
+  iin VARCHAR(14) PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  phone VARCHAR(30) NOT NULL UNIQUE
+ );
+ INSERT INTO users(iin, name, phone) VALUES('830218350074', 'Test Name', '+77010000001');`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	s, err := New(path, wal)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	person, err := s.GetPersonByIIN(operator(), "830218350074")
+	require.NoError(t, err)
+	assert.Equal(t, storage.PersonInfo{IIN: "830218350074", Name: "Test Name", Phone: "+77010000001", Version: 1, Status: storage.StatusActive}, person)
+
+	_, err = s.SaveTenant("health", "Health Department")
+	require.NoError(t, err)
+	require.NoError(t, s.SavePerson(storage.WithTenant(context.Background(), "health"), "830218350074", "Test Name", "+77010000001"))
+	assert.ErrorIs(t, s.SavePerson(operator(), "980301450725", "Test Name", "+77010000001"), storage.ErrorPhoneNumberExists)
+}
@@ -0,0 +1,386 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Columns read by scanWebhook and scanDeliveries.
+const (
+	webhookColumns  = "id, url, secret, events, active, created_at"
+	deliveryColumns = `id, tenant, webhook_id, event_seq, event_type, payload, status, attempts, next_attempt_at,
+ response_status, last_error, created_at, updated_at`
+)
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// SaveWebhook method registers a webhook of the tenant of the context for the given kinds of changes.
+// Only the changes of the tenant made after the registration are delivered to it.
+// It returns the created Webhook struct or an error.
+func (s *Storage) SaveWebhook(ctx context.Context, url string, secret string, events []string) (storage.Webhook, error) {
+	const fn = "storage.sqlite.SaveWebhook"
+
+	webhook := storage.Webhook{
+		URL:       url,
+		Secret:    secret,
+		Events:    events,
+		Active:    true,
+		CreatedAt: time.Now().UTC(),
+	}
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		return tx.Stmt(s.stmts.saveWebhook).
+			QueryRow(storage.TenantID(ctx), url, secret, strings.Join(events, ","), webhook.CreatedAt).
+			Scan(&webhook.ID)
+	})
+	if err != nil {
+		return storage.Webhook{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return webhook, nil
+}
+
+// GetWebhook method retrieves a webhook of the tenant of the context by its ID.
+// It returns a Webhook struct or an error.
+func (s *Storage) GetWebhook(ctx context.Context, id int64) (storage.Webhook, error) {
+	const fn = "storage.sqlite.GetWebhook"
+
+	webhook, err := scanWebhook(s.stmts.getWebhook.QueryRow(storage.TenantID(ctx), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Webhook{}, fmt.Errorf("%s: %w", fn, storage.ErrorWebhookNotFound)
+	}
+	if err != nil {
+		return storage.Webhook{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return webhook, nil
+}
+
+// GetWebhooks method retrieves every webhook registered by the tenant of the context.
+// It returns a slice of Webhook structs or an error.
+func (s *Storage) GetWebhooks(ctx context.Context) ([]storage.Webhook, error) {
+	const fn = "storage.sqlite.GetWebhooks"
+	webhooks := []storage.Webhook{}
+
+	rows, err := s.stmts.getWebhooks.Query(storage.TenantID(ctx))
+	if err != nil {
+		return webhooks, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return webhooks, fmt.Errorf("%s: %w", fn, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return webhooks, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return webhooks, nil
+}
+
+// UpdateWebhook method replaces the URL, the kinds of changes and the state of a webhook of the tenant of the context.
+// Changes made while a webhook is paused are still delivered once it is active again.
+// It returns the updated Webhook struct or an error.
+func (s *Storage) UpdateWebhook(ctx context.Context, id int64, url string, events []string, active bool) (storage.Webhook, error) {
+	const fn = "storage.sqlite.UpdateWebhook"
+
+	tenant := storage.TenantID(ctx)
+	var webhook storage.Webhook
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		result, err := tx.Stmt(s.stmts.updateWebhook).Exec(url, strings.Join(events, ","), active, tenant, id)
+		if err != nil {
+			return err
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return storage.ErrorWebhookNotFound
+		}
+
+		webhook, err = scanWebhook(tx.Stmt(s.stmts.getWebhook).QueryRow(tenant, id))
+		return err
+	})
+	if err != nil {
+		return storage.Webhook{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return webhook, nil
+}
+
+// DeleteWebhook method deletes a webhook of the tenant of the context along with its delivery history.
+// It returns an error if the operation fails.
+func (s *Storage) DeleteWebhook(ctx context.Context, id int64) error {
+	const fn = "storage.sqlite.DeleteWebhook"
+
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		result, err := tx.Stmt(s.stmts.deleteWebhook).Exec(storage.TenantID(ctx), id)
+		if err != nil {
+			return err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return storage.ErrorWebhookNotFound
+		}
+
+		_, err = tx.Stmt(s.stmts.deleteWebhookDeliveries).Exec(id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries method queues a delivery of every new change event to every active webhook
+// of its tenant subscribed to its kind, reading at most limit events per webhook.
+// It returns the number of queued deliveries or an error.
+func (s *Storage) EnqueueWebhookDeliveries(limit int) (int, error) {
+	const fn = "storage.sqlite.EnqueueWebhookDeliveries"
+
+	var queued int
+	err := s.write(context.Background(), func(tx *sql.Tx) (err error) {
+		queued, err = s.enqueueWebhookDeliveries(tx, limit)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return queued, nil
+}
+
+// enqueueWebhookDeliveries method fans the new events out to the deliveries of the webhooks within the transaction.
+func (s *Storage) enqueueWebhookDeliveries(tx *sql.Tx, limit int) (int, error) {
+	type cursor struct {
+		webhook storage.Webhook
+		tenant  string
+		lastSeq int64
+	}
+
+	rows, err := tx.Stmt(s.stmts.getActiveWebhooks).Query()
+	if err != nil {
+		return 0, err
+	}
+	var cursors []cursor
+	for rows.Next() {
+		var c cursor
+		var events string
+		err = rows.Scan(&c.webhook.ID, &c.webhook.URL, &c.webhook.Secret, &events,
+			&c.webhook.Active, &c.webhook.CreatedAt, &c.tenant, &c.lastSeq)
+		if err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		c.webhook.Events = splitEvents(events)
+		cursors = append(cursors, c)
+	}
+	if err = errors.Join(rows.Err(), rows.Close()); err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	now := time.Now().UTC()
+	for _, c := range cursors {
+		events, err := scanEvents(tx.Stmt(s.stmts.getEvents).Query(c.tenant, c.lastSeq, limit))
+		if err != nil {
+			return queued, err
+		}
+		if len(events) == 0 {
+			continue
+		}
+
+		for _, event := range events {
+			if !slices.Contains(c.webhook.Events, strings.TrimPrefix(event.Type, "person.")) {
+				continue
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return queued, err
+			}
+			_, err = tx.Stmt(s.stmts.saveWebhookDelivery).Exec(
+				c.tenant, c.webhook.ID, event.Seq, event.Type, string(payload), storage.DeliveryPending, now, now, now)
+			if err != nil {
+				return queued, err
+			}
+			queued++
+		}
+
+		if _, err = tx.Stmt(s.stmts.advanceWebhook).Exec(events[len(events)-1].Seq, c.webhook.ID); err != nil {
+			return queued, err
+		}
+	}
+
+	return queued, nil
+}
+
+// GetDueWebhookDeliveries method retrieves at most limit pending deliveries of the active webhooks of every tenant
+// whose next attempt is due at the given time, oldest first.
+// It returns a slice of WebhookDelivery structs or an error.
+func (s *Storage) GetDueWebhookDeliveries(now time.Time, limit int) ([]storage.WebhookDelivery, error) {
+	const fn = "storage.sqlite.GetDueWebhookDeliveries"
+
+	deliveries, err := scanDeliveries(s.stmts.getDueWebhookDeliveries.Query(storage.DeliveryPending, now.UTC(), limit))
+	if err != nil {
+		return deliveries, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return deliveries, nil
+}
+
+// GetWebhookDeliveries method retrieves the delivery history of a webhook of the tenant of the context,
+// most recent first.
+// It returns a slice of WebhookDelivery structs or an error.
+func (s *Storage) GetWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]storage.WebhookDelivery, error) {
+	const fn = "storage.sqlite.GetWebhookDeliveries"
+
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return []storage.WebhookDelivery{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	deliveries, err := scanDeliveries(s.stmts.getWebhookDeliveries.Query(storage.TenantID(ctx), webhookID, limit))
+	if err != nil {
+		return deliveries, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return deliveries, nil
+}
+
+// GetDeadWebhookDeliveries method retrieves the dead-letter list of the tenant of the context,
+// the deliveries every attempt of which failed, most recent first.
+// It returns a slice of WebhookDelivery structs or an error.
+func (s *Storage) GetDeadWebhookDeliveries(ctx context.Context, limit int) ([]storage.WebhookDelivery, error) {
+	const fn = "storage.sqlite.GetDeadWebhookDeliveries"
+
+	deliveries, err := scanDeliveries(s.stmts.getDeadWebhookDeliveries.Query(storage.TenantID(ctx), storage.DeliveryDead, limit))
+	if err != nil {
+		return deliveries, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery method saves the outcome of a delivery attempt:
+// its status, attempts, next attempt time, response status and error.
+// It returns an error if the operation fails.
+func (s *Storage) UpdateWebhookDelivery(delivery storage.WebhookDelivery) error {
+	const fn = "storage.sqlite.UpdateWebhookDelivery"
+
+	err := s.write(context.Background(), func(tx *sql.Tx) error {
+		return s.updateWebhookDelivery(tx, delivery)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// updateWebhookDelivery method saves the state of a delivery within the transaction.
+func (s *Storage) updateWebhookDelivery(tx *sql.Tx, delivery storage.WebhookDelivery) error {
+	result, err := tx.Stmt(s.stmts.updateWebhookDelivery).Exec(
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt.UTC(),
+		delivery.ResponseStatus, delivery.LastError, time.Now().UTC(), delivery.ID)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return storage.ErrorDeliveryNotFound
+	}
+	return nil
+}
+
+// RetryWebhookDelivery method moves a dead delivery of the tenant of the context back to the queue with a fresh set of attempts.
+// It returns an error if the delivery does not exist or is not dead.
+func (s *Storage) RetryWebhookDelivery(ctx context.Context, id int64) error {
+	const fn = "storage.sqlite.RetryWebhookDelivery"
+
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		var status string
+		err := tx.Stmt(s.stmts.getWebhookDeliveryStatus).QueryRow(storage.TenantID(ctx), id).Scan(&status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrorDeliveryNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if status != storage.DeliveryDead {
+			return storage.ErrorDeliveryNotDead
+		}
+
+		return s.updateWebhookDelivery(tx, storage.WebhookDelivery{
+			ID:            id,
+			Status:        storage.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// scanWebhook scans a row of webhookColumns into a Webhook struct.
+func scanWebhook(row rowScanner) (storage.Webhook, error) {
+	var webhook storage.Webhook
+	var events string
+	err := row.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &events, &webhook.Active, &webhook.CreatedAt)
+	webhook.Events = splitEvents(events)
+	return webhook, err
+}
+
+// scanDeliveries scans the result rows of deliveryColumns into WebhookDelivery structs and closes the rows.
+func scanDeliveries(rows *sql.Rows, err error) ([]storage.WebhookDelivery, error) {
+	deliveries := []storage.WebhookDelivery{}
+	if err != nil {
+		return deliveries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery storage.WebhookDelivery
+		var payload string
+		err = rows.Scan(&delivery.ID, &delivery.Tenant, &delivery.WebhookID, &delivery.EventSeq, &delivery.EventType, &payload,
+			&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.ResponseStatus,
+			&delivery.LastError, &delivery.CreatedAt, &delivery.UpdatedAt)
+		if err != nil {
+			return deliveries, err
+		}
+		delivery.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// splitEvents parses the comma separated kinds of changes of a webhook.
+func splitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+	return strings.Split(events, ",")
+}
@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Defaults of the writer used when the options leave them unset.
+const (
+	DefaultWriteQueueDepth     = 256
+	DefaultWriteBatchSize      = 64
+	DefaultAccessBufferSize    = 4096
+	DefaultAccessFlushInterval = time.Second
+)
+
+// writeJob struct is a write waiting in the queue of the writer goroutine.
+type writeJob struct {
+	ctx  context.Context
+	fn   func(tx *sql.Tx) error
+	done chan error
+}
+
+// write method hands the write over to the writer goroutine and waits until it has been committed.
+// The function runs inside a savepoint of a transaction shared with other queued writes,
+// so returning an error or panicking only rolls back its own changes.
+// It returns ErrorWriteQueueFull right away if the queue is full, and the error of the context
+// once it is done, in which case the write is skipped if the writer has not started it yet.
+func (s *Storage) write(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	job := writeJob{ctx: ctx, fn: fn, done: make(chan error, 1)}
+
+	s.writeMu.RLock()
+	if s.closed {
+		s.writeMu.RUnlock()
+		return storage.ErrorStorageClosed
+	}
+	select {
+	case s.writes <- job:
+		s.writeMu.RUnlock()
+	default:
+		s.writeMu.RUnlock()
+		return storage.ErrorWriteQueueFull
+	}
+
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runWriter method is the loop of the writer goroutine.
+// It takes every write waiting in the queue, up to the batch size, and commits them in a single transaction
+// along with the waiting access log entries, which are also committed on their own every flush interval
+// and once half of their buffer is taken.
+// Once the storage is closed, it commits the remaining writes and access log entries and exits.
+func (s *Storage) runWriter(batchSize int, flushInterval time.Duration) {
+	defer close(s.writerDone)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	jobs := make([]writeJob, 0, batchSize)
+	for {
+		jobs = jobs[:0]
+		select {
+		case job := <-s.writes:
+			jobs = append(jobs, job)
+		case <-ticker.C:
+		case <-s.access.flush:
+		case <-s.stopWriter:
+			for {
+				select {
+				case job := <-s.writes:
+					s.commit([]writeJob{job})
+				default:
+					s.commit(nil)
+					return
+				}
+			}
+		}
+
+	collect:
+		for len(jobs) < batchSize {
+			select {
+			case job := <-s.writes:
+				jobs = append(jobs, job)
+			default:
+				break collect
+			}
+		}
+
+		s.commit(jobs)
+	}
+}
+
+// commit method runs the writes in one transaction, each within its own savepoint,
+// and notifies every writer of its result once the transaction is committed.
+func (s *Storage) commit(jobs []writeJob) {
+	results := make([]error, len(jobs))
+
+	err := s.groupCommit(jobs, results)
+	for i, job := range jobs {
+		if err != nil {
+			job.done <- err
+			continue
+		}
+		job.done <- results[i]
+	}
+}
+
+// groupCommit method executes the writes in a single transaction, after writing the waiting access log entries,
+// and stores their results. Access log entries that could not be written wait for the next transaction.
+// It returns an error if the transaction itself fails, which fails every write in it.
+// A panic outside of the writes fails the transaction, so the writer goroutine outlives it.
+func (s *Storage) groupCommit(jobs []writeJob, results []error) (err error) {
+	entries := s.access.take()
+	if len(jobs) == 0 && len(entries) == 0 {
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			s.access.putBack(entries)
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("group commit panicked: %v", r)
+		}
+	}()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer func() { _ = tx.Rollback() }()
+
+	if len(entries) > 0 {
+		if _, err = tx.Exec("SAVEPOINT access_log;"); err != nil {
+			return err
+		}
+		if accessErr := s.saveAccess(tx, entries); accessErr != nil {
+			if _, err = tx.Exec("ROLLBACK TO access_log;"); err != nil {
+				return fmt.Errorf("rollback to savepoint: %w", errors.Join(accessErr, err))
+			}
+			s.access.putBack(entries)
+			entries = nil
+		}
+		if _, err = tx.Exec("RELEASE access_log;"); err != nil {
+			return err
+		}
+	}
+
+	for i, job := range jobs {
+		// The writer of a write whose context is done has stopped waiting for it
+		if results[i] = job.ctx.Err(); results[i] != nil {
+			continue
+		}
+
+		if _, err = tx.Exec("SAVEPOINT write_job;"); err != nil {
+			return err
+		}
+
+		results[i] = runJob(tx, job)
+		if results[i] != nil {
+			if _, err = tx.Exec("ROLLBACK TO write_job;"); err != nil {
+				return fmt.Errorf("rollback to savepoint: %w", errors.Join(results[i], err))
+			}
+		}
+
+		if _, err = tx.Exec("RELEASE write_job;"); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// runJob function runs the write within the transaction.
+// It returns the error of the write, or an error if it panicked.
+func runJob(tx *sql.Tx, job writeJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("write panicked: %v", r)
+		}
+	}()
+	return job.fn(tx)
+}
@@ -0,0 +1,194 @@
+package sqlite
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// operator returns a context scoped to the default tenant, like the requests of the operator.
+func operator() context.Context {
+	return storage.WithTenant(context.Background(), storage.DefaultTenant)
+}
+
+func newTestStorage(t *testing.T, opts Options) *Storage {
+	t.Helper()
+
+	s, err := New(filepath.Join(t.TempDir(), "test.db"), opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestWriteGroupCommitIsolatesFailures(t *testing.T) {
+	s := newTestStorage(t, wal)
+	ctx := operator()
+
+	const writers = 50
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every pair of writers competes for the same phone number
+			errs[i] = s.SavePerson(ctx, fmt.Sprintf("%012d", i), "Test Name", fmt.Sprintf("+7%010d", i/2))
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, storage.ErrorPhoneNumberExists)
+			failed++
+		}
+	}
+	assert.Equal(t, writers/2, failed)
+
+	people, err := s.GetAllPeople(ctx)
+	require.NoError(t, err)
+	assert.Len(t, people, writers/2)
+}
+
+func TestWriteQueueFull(t *testing.T) {
+	s := newTestStorage(t, Options{WriteQueueDepth: 1})
+
+	// Block the writer until the queue has been filled
+	started, release := make(chan struct{}), make(chan struct{})
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- s.write(context.Background(), func(tx *sql.Tx) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	queued := make(chan error, 1)
+	go func() {
+		queued <- s.SavePerson(operator(), "000000000001", "Test Name", "1")
+	}()
+	require.Eventually(t, func() bool { return len(s.writes) == 1 }, time.Second, time.Millisecond)
+
+	err := s.SavePerson(operator(), "000000000002", "Test Name", "2")
+	assert.ErrorIs(t, err, storage.ErrorWriteQueueFull)
+
+	close(release)
+	assert.NoError(t, <-blocked)
+	assert.NoError(t, <-queued)
+}
+
+func TestWritePanicFailsOnlyItself(t *testing.T) {
+	s := newTestStorage(t, Options{})
+	ctx := operator()
+
+	err := s.write(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("INSERT INTO tenants (id, name, created_at) VALUES ('panicked', 'Panicked', CURRENT_TIMESTAMP);"); err != nil {
+			return err
+		}
+		panic("broken write")
+	})
+	assert.ErrorContains(t, err, "broken write")
+
+	require.NoError(t, s.SavePerson(ctx, "000000000001", "Test Name", "1"))
+	tenants, err := s.GetTenants()
+	require.NoError(t, err)
+	for _, tenant := range tenants {
+		assert.NotEqual(t, "panicked", tenant.ID)
+	}
+}
+
+func TestWriteStopsWaitingOnDoneContext(t *testing.T) {
+	s := newTestStorage(t, Options{})
+
+	// Block the writer, a write whose context is cancelled meanwhile returns and is skipped
+	started, release := make(chan struct{}), make(chan struct{})
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- s.write(context.Background(), func(tx *sql.Tx) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(operator())
+	cancelled := make(chan error, 1)
+	go func() {
+		cancelled <- s.SavePerson(ctx, "000000000001", "Test Name", "1")
+	}()
+	require.Eventually(t, func() bool { return len(s.writes) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-cancelled, context.Canceled)
+
+	close(release)
+	require.NoError(t, <-blocked)
+	require.NoError(t, s.write(context.Background(), func(tx *sql.Tx) error { return nil }))
+	_, err := s.GetPersonByIIN(operator(), "000000000001")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "test.db"), Options{})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	assert.ErrorIs(t, s.SavePerson(operator(), "000000000001", "Test Name", "1"), storage.ErrorStorageClosed)
+}
+
+func TestRecordAccessDoesNotWaitForWriter(t *testing.T) {
+	s := newTestStorage(t, Options{WriteQueueDepth: 1, AccessBufferSize: 4})
+	ctx := operator()
+
+	// Block the writer, reads are still recorded without taking a place in the queue
+	started, release := make(chan struct{}), make(chan struct{})
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- s.write(ctx, func(tx *sql.Tx) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	entry := storage.AccessEntry{IIN: "830218350074", Action: storage.AccessRead}
+	require.NoError(t, s.RecordAccess(ctx, []storage.AccessEntry{entry, entry}))
+	require.NoError(t, s.RecordAccess(ctx, []storage.AccessEntry{entry}))
+	assert.Empty(t, s.writes)
+	assert.ErrorIs(t, s.RecordAccess(ctx, []storage.AccessEntry{entry, entry}), storage.ErrorAccessLogFull)
+
+	close(release)
+	require.NoError(t, <-blocked)
+
+	entries, err := s.GetAccessLog(ctx, "830218350074")
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestCloseWritesQueuedAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path, Options{AccessFlushInterval: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, s.RecordAccess(operator(), []storage.AccessEntry{{IIN: "830218350074", Action: storage.AccessRead}}))
+	require.NoError(t, s.Close())
+	assert.ErrorIs(t, s.RecordAccess(operator(), []storage.AccessEntry{{IIN: "830218350074"}}), storage.ErrorStorageClosed)
+
+	s, err = New(path, Options{})
+	require.NoError(t, err)
+	defer s.Close()
+	entries, err := s.GetAccessLog(operator(), "830218350074")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
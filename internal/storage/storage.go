@@ -1,16 +1,519 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
 
 var (
-	ErrorIINNotFound       = errors.New("IIN not found")
-	ErrorIINExists         = errors.New("IIN already exists")
-	ErrorNameNotFound      = errors.New("name not found")
-	ErrorPhoneNumberExists = errors.New("phone number already exists")
+	ErrorIINNotFound          = errors.New("IIN not found")
+	ErrorIINExists            = errors.New("IIN already exists")
+	ErrorNameNotFound         = errors.New("name not found")
+	ErrorPhoneNumberExists    = errors.New("phone number already exists")
+	ErrorVersionMismatch      = errors.New("version mismatch")
+	ErrorUnknownOperation     = errors.New("unknown operation")
+	ErrorWriteQueueFull       = errors.New("write queue is full")
+	ErrorAccessLogFull        = errors.New("access log buffer is full")
+	ErrorStorageClosed        = errors.New("storage is closed")
+	ErrorWebhookNotFound      = errors.New("webhook not found")
+	ErrorDeliveryNotFound     = errors.New("delivery not found")
+	ErrorDeliveryNotDead      = errors.New("delivery is not dead")
+	ErrorUnknownTarget        = errors.New("unknown retention target")
+	ErrorConsentNotFound      = errors.New("consent not found")
+	ErrorTenantExists         = errors.New("tenant already exists")
+	ErrorTenantNotFound       = errors.New("tenant not found")
+	ErrorCredentialNotFound   = errors.New("credential not found")
+	ErrorUnknownStatus        = errors.New("unknown status")
+	ErrorStatusTransition     = errors.New("status transition not allowed")
+	ErrorDocumentNotFound     = errors.New("document not found")
+	ErrorDocumentExists       = errors.New("document already exists")
+	ErrorPhotoNotFound        = errors.New("photo not found")
+	ErrorRelationshipNotFound = errors.New("relationship not found")
+	ErrorRelationshipExists   = errors.New("relationship already exists")
+	ErrorTooManyParents       = errors.New("person already has two parents")
+	ErrorGuardianNotFound     = errors.New("guardian not found")
+	ErrorGuardianInactive     = errors.New("guardian is deceased or emigrated")
+	ErrorAddressNotFound      = errors.New("address not found")
+	ErrorSchemaNotFound       = errors.New("attribute schema not found")
+	ErrorSchemaInUse          = errors.New("attribute schema is in use")
+	ErrorOrganizationNotFound = errors.New("organization not found")
+	ErrorOrganizationExists   = errors.New("organization already exists")
+	ErrorEmploymentNotFound   = errors.New("employment not found")
+	ErrorEmploymentOverlaps   = errors.New("employment overlaps another one at the same organization")
+	ErrorLegalHold            = errors.New("record is under legal hold")
+	ErrorHoldNotFound         = errors.New("legal hold not found")
+	ErrorHoldExists           = errors.New("legal hold already placed")
+)
+
+// DefaultTenant is the tenant of the operator and of the data stored before tenants were introduced.
+const DefaultTenant = "default"
+
+// Lifecycle statuses of a person.
+const (
+	StatusActive    = "active"
+	StatusDeceased  = "deceased"
+	StatusEmigrated = "emigrated"
+)
+
+// Statuses lists every lifecycle status.
+var Statuses = []string{StatusActive, StatusDeceased, StatusEmigrated}
+
+// statusTransitions lists the statuses a person may move to from every status.
+// Deceased is terminal, an emigrated person may return.
+var statusTransitions = map[string][]string{
+	StatusActive:    {StatusDeceased, StatusEmigrated},
+	StatusEmigrated: {StatusActive, StatusDeceased},
+	StatusDeceased:  {},
+}
+
+// ValidateStatusTransition reports whether a person may move from one status to another.
+// It returns ErrorUnknownStatus if either status is not known and ErrorStatusTransition if the move is not allowed.
+func ValidateStatusTransition(from string, to string) error {
+	allowed, ok := statusTransitions[from]
+	if !ok {
+		return fmt.Errorf("%q: %w", from, ErrorUnknownStatus)
+	}
+	if _, ok = statusTransitions[to]; !ok {
+		return fmt.Errorf("%q: %w", to, ErrorUnknownStatus)
+	}
+	if !slices.Contains(allowed, to) {
+		return fmt.Errorf("%s to %s: %w", from, to, ErrorStatusTransition)
+	}
+	return nil
+}
+
+// Kinds of operations in a batch.
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// Kinds of change events.
+const (
+	EventPersonCreated = "person.created"
+	EventPersonUpdated = "person.updated"
+	EventPersonDeleted = "person.deleted"
+)
+
+// Kinds of data removed by retention rules.
+const (
+	RetentionDeletedPeople     = "deleted_people"     // Change events, merge log entries, webhook deliveries, consents, access log entries and status changes of deleted people
+	RetentionDeceasedPeople    = "deceased_people"    // Records of deceased people, by their date of death
+	RetentionEvents            = "events"             // Change events read by every consumer of the outbox
+	RetentionMergeLog          = "merge_log"          // Merge log entries
+	RetentionWebhookDeliveries = "webhook_deliveries" // Delivered and dead webhook deliveries
+	RetentionAccessLog         = "access_log"         // Access log entries
+)
+
+// Kinds of access to the data of a person.
+const (
+	AccessRead          = "read"           // Read by IIN
+	AccessSearch        = "search"         // Returned by a search by name
+	AccessSubjectReport = "subject_report" // Included in a subject access report
+	AccessPhoto         = "photo"          // Photo or its thumbnail downloaded
+	AccessDocuments     = "documents"      // Identity documents read
+	AccessAddresses     = "addresses"      // Addresses read
+	AccessRelationships = "relationships"  // Taking part in a relationship that was read
+	AccessHousehold     = "household"      // Returned as a member of a household
+	AccessEmployments   = "employments"    // Employments read, or listed among the employees of an organization
+	AccessDuplicates    = "duplicates"     // Returned by the duplicates report
+	AccessMerges        = "merges"         // Merged, or read in the merge log
+)
+
+// Kinds of identity documents.
+const (
+	DocumentIDCard   = "id_card"
+	DocumentPassport = "passport"
+)
+
+// Kinds of relationships between people, read as "from is the <kind> of to".
+// Spouse relationships go both ways and are stored with the lower IIN first.
+const (
+	RelationshipParent   = "parent"
+	RelationshipSpouse   = "spouse"
+	RelationshipGuardian = "guardian"
+)
+
+// States of a consent.
+const (
+	ConsentGranted = "granted"
+	ConsentRevoked = "revoked"
+)
+
+// Actions recorded in the legal hold log.
+const (
+	HoldPlaced   = "placed"
+	HoldReleased = "released"
+)
+
+// States of a webhook delivery.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead" // Every attempt failed, the delivery is in the dead-letter list
 )
 
 type PersonInfo struct {
-	IIN   string
-	Name  string
-	Phone string
+	IIN     string
+	Name    string
+	Phone   string `json:"Phone,omitempty"` // Omitted when the purpose of the client lacks the consent of the person
+	Version int64  // Incremented on every update, used for optimistic concurrency control
+	Status  string // Lifecycle status, StatusActive, StatusDeceased or StatusEmigrated
+	// Extension attributes by namespace, each validated against the AttributeSchema of its namespace
+	Attributes map[string]json.RawMessage `json:"Attributes,omitempty"`
+}
+
+// SaveOptions holds what may be stored along with a new person.
+type SaveOptions struct {
+	GuardianIIN string                     // Adult responsible for the person, see Ward
+	Attributes  map[string]json.RawMessage // Already validated extension attributes
+}
+
+// PersonFilter narrows down a search of people by name. Empty fields match everyone.
+type PersonFilter struct {
+	Status     string
+	Region     string // KATO code of the region of the registered or actual address
+	Attributes []AttributeFilter
+}
+
+// AttributeFilter matches the people whose extension attribute at Path equals Value.
+type AttributeFilter struct {
+	Path  string          `json:"path"` // Dot separated, starting with the namespace, e.g. "benefits.category"
+	Value json.RawMessage `json:"value"`
+}
+
+// MergeRecord is an entry of the merge log, written when a duplicate record is merged into another one.
+type MergeRecord struct {
+	ID          int64  `json:"id"`
+	SourceIIN   string `json:"source_iin"`
+	SourceName  string `json:"source_name"`
+	SourcePhone string `json:"source_phone"`
+	TargetIIN   string `json:"target_iin"`
+	TargetName  string `json:"target_name"`
+	TargetPhone string `json:"target_phone"`
+	// Version of the target after the merge, zero for merges recorded before it was
+	TargetVersion int64     `json:"target_version,omitempty"`
+	MergedAt      time.Time `json:"merged_at"`
+}
+
+// AnyVersion is the expected version of a conditional write that only requires the record to exist,
+// whatever its version, as If-Match: * does. Zero matches any version and a missing record alike.
+const AnyVersion int64 = -1
+
+// VersionMatches reports whether a record at the version satisfies the expected versions of a conditional write.
+// No expected versions, zero and AnyVersion match any version, otherwise the version has to be one of them.
+func VersionMatches(version int64, expectedVersions ...int64) bool {
+	if len(expectedVersions) == 0 {
+		return true
+	}
+	for _, expected := range expectedVersions {
+		if expected <= 0 || expected == version {
+			return true
+		}
+	}
+	return false
+}
+
+// PersonPair is a pair of people who may describe the same citizen, the first one having the lower IIN.
+type PersonPair struct {
+	First  PersonInfo
+	Second PersonInfo
+}
+
+// BatchOperation is a single write of an atomic batch.
+// ExpectedVersion is only used by updates and deletes, zero matches any version.
+type BatchOperation struct {
+	Op              string
+	IIN             string
+	Name            string
+	Phone           string
+	ExpectedVersion int64
+}
+
+// BatchResult is the outcome of a single operation of an atomic batch.
+type BatchResult struct {
+	Index   int
+	Op      string
+	IIN     string
+	Version int64
+	Err     error
+}
+
+// Event is an entry of the change feed, written in the same transaction as the change it describes.
+// Every tenant numbers its events in commit order without gaps, while the outbox numbers the events
+// of every tenant together for the consumers publishing all of them.
+type Event struct {
+	Seq       int64           `json:"seq"`
+	OutboxSeq int64           `json:"-"` // Position of the event in the outbox of every tenant, see GetOutboxEvents
+	Tenant    string          `json:"-"`
+	Type      string          `json:"type"`
+	IIN       string          `json:"iin"`
+	Payload   json.RawMessage `json:"payload"`
+	RequestID string          `json:"request_id,omitempty"` // ID of the request that made the change, see WithRequestID
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// EventPayload is the payload of a change event, the state of the person after the change.
+// Deletion events only carry the IIN. The phone is left out, as events reach partners
+// whatever the consents of the person, see Consent.
+type EventPayload struct {
+	IIN     string `json:"iin"`
+	Name    string `json:"name,omitempty"`
+	Version int64  `json:"version,omitempty"`
+	Status  string `json:"status,omitempty"`
+}
+
+// Consent is an entry of the consent history of a person, granting or revoking
+// the sharing of their phone for a purpose.
+type Consent struct {
+	ID        int64     `json:"id"`
+	IIN       string    `json:"iin"`
+	Purpose   string    `json:"purpose"`
+	Status    string    `json:"status"` // ConsentGranted or ConsentRevoked
+	Source    string    `json:"source"` // Where the consent was given or withdrawn, e.g. a signed form or a portal
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StatusChange is an entry of the status history of a person, written whenever their lifecycle status changes.
+type StatusChange struct {
+	ID            int64     `json:"id"`
+	IIN           string    `json:"iin"`
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	EffectiveDate string    `json:"effective_date"`    // Day the change took effect, e.g. the date of death, as YYYY-MM-DD
+	Reason        string    `json:"reason"`            // Why the change was recorded, e.g. the certificate it is based on
+	Version       int64     `json:"version,omitempty"` // Version of the person after the change, only set on the change just made
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Document is an identity document issued to a person.
+// The type and number of a document are unique within a tenant.
+type Document struct {
+	ID               int64     `json:"id"`
+	IIN              string    `json:"iin"`
+	Type             string    `json:"type"` // DocumentIDCard or DocumentPassport
+	Number           string    `json:"number"`
+	IssuingAuthority string    `json:"issuing_authority"`
+	IssueDate        string    `json:"issue_date"`  // YYYY-MM-DD
+	ExpiryDate       string    `json:"expiry_date"` // YYYY-MM-DD
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Photo describes the photo of a person. The image itself and its thumbnail are read separately.
+type Photo struct {
+	IIN         string    `json:"iin"`
+	ContentType string    `json:"content_type"` // Sniffed from the image
+	Size        int64     `json:"size"`         // Bytes
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Relationship is a link between two people.
+type Relationship struct {
+	ID        int64     `json:"id"`
+	FromIIN   string    `json:"from_iin"`
+	Type      string    `json:"type"` // RelationshipParent, RelationshipSpouse or RelationshipGuardian
+	ToIIN     string    `json:"to_iin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Household is the set of people reachable from a person through relationships, and the relationships between them.
+type Household struct {
+	Members       []HouseholdMember `json:"members"`
+	Relationships []Relationship    `json:"relationships"`
+}
+
+// HouseholdMember is a member of a household.
+type HouseholdMember struct {
+	IIN  string `json:"iin"`
+	Name string `json:"name"`
+	Hops int    `json:"hops"` // Number of relationships between the member and the person the household is of
+}
+
+// Types of the addresses of a person, who has one of each at most.
+const (
+	AddressRegistered = "registered" // Address the person is officially registered at
+	AddressActual     = "actual"     // Address the person actually lives at
+)
+
+// Address is a postal address of a person, located by its KATO code.
+type Address struct {
+	IIN        string    `json:"iin"`
+	Type       string    `json:"type"`
+	KATO       string    `json:"kato"`
+	Region     string    `json:"region"` // KATO code of the region of the address
+	Locality   string    `json:"locality"`
+	Street     string    `json:"street"`
+	House      string    `json:"house"`
+	Apartment  string    `json:"apartment,omitempty"`
+	PostalCode string    `json:"postal_code,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// RegionCount is the number of people with an address in a region.
+type RegionCount struct {
+	Region string `json:"region"` // KATO code of the region
+	People int64  `json:"people"`
+}
+
+// RegionStatistics holds the number of people by the region of one of their addresses.
+type RegionStatistics struct {
+	Regions []RegionCount `json:"regions"`
+	Unknown int64         `json:"unknown"` // People without an address of the type
+}
+
+// Organization is a company people work at, identified by its BIN.
+type Organization struct {
+	BIN       string    `json:"bin"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Employment is a period during which a person works at an organization.
+// The periods of a person at the same organization do not overlap.
+type Employment struct {
+	ID        int64     `json:"id"`
+	IIN       string    `json:"iin"`
+	BIN       string    `json:"bin"`
+	Position  string    `json:"position,omitempty"`
+	StartDate string    `json:"start_date"`         // YYYY-MM-DD
+	EndDate   string    `json:"end_date,omitempty"` // YYYY-MM-DD, included, empty while the person still works there
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Employee is a person working at an organization, with the period they work there.
+type Employee struct {
+	Name string `json:"name"`
+	Employment
+}
+
+// LegalHold keeps the record of a person from being changed or deleted while it is under investigation.
+type LegalHold struct {
+	IIN        string    `json:"iin"`
+	Reason     string    `json:"reason"`
+	CaseNumber string    `json:"case_number"`
+	PlacedBy   string    `json:"placed_by"` // Client that placed the hold
+	PlacedAt   time.Time `json:"placed_at"`
+}
+
+// HoldLogEntry is an entry of the legal hold log, written whenever a hold is placed or released.
+type HoldLogEntry struct {
+	ID         int64     `json:"id"`
+	IIN        string    `json:"iin"`
+	Action     string    `json:"action"` // HoldPlaced or HoldReleased
+	Reason     string    `json:"reason"` // Why the hold was placed or released
+	CaseNumber string    `json:"case_number"`
+	Client     string    `json:"client"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Ward is a minor, or any person saved along with the adult responsible for them.
+type Ward struct {
+	IIN         string `json:"iin"`
+	Name        string `json:"name"`
+	GuardianIIN string `json:"guardian_iin,omitempty"` // Guardian the person was saved with, if any
+}
+
+// AttributeSchema is the JSON Schema the extension attributes of a namespace are validated against.
+type AttributeSchema struct {
+	Namespace string          `json:"namespace"`
+	Schema    json.RawMessage `json:"schema"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// AccessEntry is an entry of the access log, written whenever the data of a person is returned to a client.
+type AccessEntry struct {
+	ID         int64     `json:"id"`
+	Tenant     string    `json:"-"`
+	IIN        string    `json:"iin"`
+	Action     string    `json:"action"`
+	Client     string    `json:"client"`  // User the client authenticated as
+	Purpose    string    `json:"purpose"` // Purpose declared by the client, if any
+	RequestID  string    `json:"request_id"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// Webhook is a subscription of a partner service to change events.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`      // Key of the HMAC-SHA256 signature of every delivery
+	Events    []string  `json:"events"` // Kinds of changes delivered: created, updated or deleted
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is a change event queued for delivery to a webhook, along with its delivery state.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	Tenant         string          `json:"-"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventSeq       int64           `json:"event_seq"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"` // Body of the request, the Event as JSON
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus int             `json:"response_status"` // Status code of the last attempt, zero if no response
+	LastError      string          `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Tenant is a department hosted by the service, which only sees the people stored under it.
+type Tenant struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Credential is a user the clients of a tenant authenticate as.
+type Credential struct {
+	Username     string    `json:"username"`
+	Tenant       string    `json:"tenant"`
+	PasswordHash string    `json:"-"` // bcrypt hash of the password
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the context carrying the ID of the request making the changes,
+// which is recorded with their change events.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by the context, empty if there is none.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// tenantKey is the context key of the tenant.
+type tenantKey struct{}
+
+// WithTenant returns a copy of the context carrying the tenant of the authenticated client,
+// which scopes every read and write made with it.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantID returns the tenant carried by the context.
+// It panics if there is none, so that a code path that forgot to scope its context
+// fails instead of reading or writing the partition of the operator.
+func TenantID(ctx context.Context) string {
+	if tenant, _ := ctx.Value(tenantKey{}).(string); tenant != "" {
+		return tenant
+	}
+	panic("storage: the context carries no tenant")
 }
@@ -0,0 +1,131 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// address returns an address of the given type in the region of the KATO code.
+func address(iin string, addressType string, code string, street string) storage.Address {
+	return storage.Address{
+		IIN:        iin,
+		Type:       addressType,
+		KATO:       code,
+		Region:     code[:2] + "0000000",
+		Locality:   "City",
+		Street:     street,
+		House:      "1",
+		PostalCode: "050000",
+	}
+}
+
+func testAddresses(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Other Name", "+77010000002"))
+
+	_, err := s.SaveAddress(ctx, address(iin4, storage.AddressActual, "751110000", "Abai"))
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	assert.ErrorIs(t, s.DeleteAddress(ctx, iin1, storage.AddressActual), storage.ErrorAddressNotFound)
+
+	addresses, err := s.GetAddresses(ctx, iin1)
+	require.NoError(t, err)
+	assert.NotNil(t, addresses)
+	assert.Empty(t, addresses)
+
+	saved, err := s.SaveAddress(ctx, address(iin1, storage.AddressActual, "711110000", "Kabanbay"))
+	require.NoError(t, err)
+	assert.False(t, saved.UpdatedAt.IsZero())
+	_, err = s.SaveAddress(ctx, address(iin1, storage.AddressRegistered, "751110000", "Abai"))
+	require.NoError(t, err)
+
+	// The registered address comes first, and a new address replaces the previous one of its type
+	_, err = s.SaveAddress(ctx, address(iin1, storage.AddressRegistered, "751110000", "Dostyk"))
+	require.NoError(t, err)
+	addresses, err = s.GetAddresses(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, addresses, 2)
+	assert.Equal(t, storage.AddressRegistered, addresses[0].Type)
+	assert.Equal(t, "Dostyk", addresses[0].Street)
+	assert.Equal(t, "750000000", addresses[0].Region)
+	assert.Equal(t, storage.AddressActual, addresses[1].Type)
+	assert.Equal(t, "711110000", addresses[1].KATO)
+
+	// A merge moves the addresses the target has no address of the type of
+	_, err = s.SaveAddress(ctx, address(iin2, storage.AddressRegistered, "791110000", "Tauke Khan"))
+	require.NoError(t, err)
+	_, err = s.MergePeople(ctx, iin1, iin2, 0)
+	require.NoError(t, err)
+	addresses, err = s.GetAddresses(ctx, iin2)
+	require.NoError(t, err)
+	require.Len(t, addresses, 2)
+	assert.Equal(t, "Tauke Khan", addresses[0].Street)
+	assert.Equal(t, "Kabanbay", addresses[1].Street)
+	addresses, err = s.GetAddresses(ctx, iin1)
+	require.NoError(t, err)
+	assert.Empty(t, addresses)
+
+	require.NoError(t, s.DeleteAddress(ctx, iin2, storage.AddressActual))
+	addresses, err = s.GetAddresses(ctx, iin2)
+	require.NoError(t, err)
+	assert.Len(t, addresses, 1)
+
+	// A deletion removes them
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin2, 0))
+	addresses, err = s.GetAddresses(ctx, iin2)
+	require.NoError(t, err)
+	assert.Empty(t, addresses)
+}
+
+func testRegionFilters(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Sally", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Lilly", "+77010000002"))
+	require.NoError(t, s.SavePerson(ctx, iin3, "Molly", "+77010000003"))
+
+	// Sally is registered in Almaty, Lilly lives in Astana and Molly has no address
+	_, err := s.SaveAddress(ctx, address(iin1, storage.AddressRegistered, "751110000", "Abai"))
+	require.NoError(t, err)
+	_, err = s.SaveAddress(ctx, address(iin2, storage.AddressActual, "711110000", "Kabanbay"))
+	require.NoError(t, err)
+
+	people, err := s.GetPersonByName(ctx, "lly", storage.PersonFilter{Region: "750000000"})
+	require.NoError(t, err)
+	require.Len(t, people, 1)
+	assert.Equal(t, iin1, people[0].IIN)
+	people, err = s.GetPersonByName(ctx, "lly", storage.PersonFilter{Region: "710000000"})
+	require.NoError(t, err)
+	require.Len(t, people, 1)
+	assert.Equal(t, iin2, people[0].IIN)
+	people, err = s.GetPersonByName(ctx, "lly", storage.PersonFilter{Region: "790000000"})
+	require.NoError(t, err)
+	assert.Nil(t, people)
+
+	statistics, err := s.GetRegionStatistics(ctx, storage.AddressRegistered, "")
+	require.NoError(t, err)
+	assert.Equal(t, storage.RegionStatistics{
+		Regions: []storage.RegionCount{{Region: "750000000", People: 1}},
+		Unknown: 2,
+	}, statistics)
+	statistics, err = s.GetRegionStatistics(ctx, storage.AddressActual, "")
+	require.NoError(t, err)
+	assert.Equal(t, storage.RegionStatistics{
+		Regions: []storage.RegionCount{{Region: "710000000", People: 1}},
+		Unknown: 2,
+	}, statistics)
+
+	// Only the people with the status, if one is given
+	_, err = s.ChangeStatus(ctx, iin1, storage.StatusDeceased, "2024-01-31", "certificate", 0)
+	require.NoError(t, err)
+	statistics, err = s.GetRegionStatistics(ctx, storage.AddressRegistered, storage.StatusActive)
+	require.NoError(t, err)
+	assert.Equal(t, storage.RegionStatistics{Regions: []storage.RegionCount{}, Unknown: 2}, statistics)
+
+	// Tenants have their own addresses
+	statistics, err = s.GetRegionStatistics(tenant(t, s, "other"), storage.AddressRegistered, "")
+	require.NoError(t, err)
+	assert.Equal(t, storage.RegionStatistics{Regions: []storage.RegionCount{}}, statistics)
+}
@@ -0,0 +1,296 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testWebhooks(t *testing.T, s Storage) {
+	ctx := operator()
+
+	webhooks, err := s.GetWebhooks(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, webhooks)
+	assert.Empty(t, webhooks)
+
+	webhook, err := s.SaveWebhook(ctx, "http://localhost/hook", "secret", []string{"created", "deleted"})
+	require.NoError(t, err)
+	assert.NotZero(t, webhook.ID)
+	assert.True(t, webhook.Active)
+
+	got, err := s.GetWebhook(ctx, webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost/hook", got.URL)
+	assert.Equal(t, "secret", got.Secret)
+	assert.Equal(t, []string{"created", "deleted"}, got.Events)
+
+	updated, err := s.UpdateWebhook(ctx, webhook.ID, "http://localhost/other", []string{"updated"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost/other", updated.URL)
+	assert.Equal(t, []string{"updated"}, updated.Events)
+	assert.False(t, updated.Active)
+
+	webhooks, err = s.GetWebhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Equal(t, webhook.ID, webhooks[0].ID)
+
+	require.NoError(t, s.DeleteWebhook(ctx, webhook.ID))
+	_, err = s.GetWebhook(ctx, webhook.ID)
+	assert.ErrorIs(t, err, storage.ErrorWebhookNotFound)
+	_, err = s.UpdateWebhook(ctx, webhook.ID, "http://localhost/hook", []string{"created"}, true)
+	assert.ErrorIs(t, err, storage.ErrorWebhookNotFound)
+	assert.ErrorIs(t, s.DeleteWebhook(ctx, webhook.ID), storage.ErrorWebhookNotFound)
+	_, err = s.GetWebhookDeliveries(ctx, webhook.ID, 10)
+	assert.ErrorIs(t, err, storage.ErrorWebhookNotFound)
+}
+
+func testWebhookDeliveries(t *testing.T, s Storage) {
+	ctx := operator()
+
+	// Changes made before the registration are not delivered
+	require.NoError(t, s.SavePerson(ctx, iin2, "Test Name", "+77010000002"))
+	webhook, err := s.SaveWebhook(ctx, "http://localhost/hook", "secret", []string{"created", "deleted"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	_, err = s.UpdatePerson(ctx, iin1, "New Name", "+77010000001", 0)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin1, 0))
+
+	queued, err := s.EnqueueWebhookDeliveries(100)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued)
+	// Every event is queued once
+	queued, err = s.EnqueueWebhookDeliveries(100)
+	require.NoError(t, err)
+	assert.Zero(t, queued)
+
+	due, err := s.GetDueWebhookDeliveries(time.Now().Add(time.Second), 100)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, storage.EventPersonCreated, due[0].EventType)
+	assert.Equal(t, storage.EventPersonDeleted, due[1].EventType)
+	for _, delivery := range due {
+		assert.Equal(t, webhook.ID, delivery.WebhookID)
+		assert.Equal(t, storage.DeliveryPending, delivery.Status)
+		assert.Equal(t, storage.DefaultTenant, delivery.Tenant)
+	}
+
+	delivered, dead := due[0], due[1]
+	delivered.Status, delivered.Attempts, delivered.ResponseStatus = storage.DeliveryDelivered, 1, 200
+	require.NoError(t, s.UpdateWebhookDelivery(delivered))
+	dead.Status, dead.Attempts, dead.LastError = storage.DeliveryDead, 3, "unexpected status 500"
+	require.NoError(t, s.UpdateWebhookDelivery(dead))
+	assert.ErrorIs(t, s.UpdateWebhookDelivery(storage.WebhookDelivery{ID: dead.ID + 100}), storage.ErrorDeliveryNotFound)
+
+	due, err = s.GetDueWebhookDeliveries(time.Now().Add(time.Second), 100)
+	require.NoError(t, err)
+	assert.NotNil(t, due)
+	assert.Empty(t, due)
+
+	deadLetters, err := s.GetDeadWebhookDeliveries(ctx, 100)
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, dead.ID, deadLetters[0].ID)
+	assert.Equal(t, 3, deadLetters[0].Attempts)
+	assert.Equal(t, "unexpected status 500", deadLetters[0].LastError)
+
+	assert.ErrorIs(t, s.RetryWebhookDelivery(ctx, delivered.ID), storage.ErrorDeliveryNotDead)
+	assert.ErrorIs(t, s.RetryWebhookDelivery(ctx, dead.ID+100), storage.ErrorDeliveryNotFound)
+	require.NoError(t, s.RetryWebhookDelivery(ctx, dead.ID))
+	assert.ErrorIs(t, s.RetryWebhookDelivery(ctx, dead.ID), storage.ErrorDeliveryNotDead)
+
+	due, err = s.GetDueWebhookDeliveries(time.Now().Add(time.Second), 100)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, dead.ID, due[0].ID)
+	assert.Zero(t, due[0].Attempts)
+
+	// Most recent first
+	deliveries, err := s.GetWebhookDeliveries(ctx, webhook.ID, 100)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, dead.ID, deliveries[0].ID)
+	assert.Equal(t, delivered.ID, deliveries[1].ID)
+}
+
+func testRetention(t *testing.T, s Storage) {
+	ctx := operator()
+
+	require.NoError(t, s.SavePerson(ctx, iin1, "Deleted Person", "+77010000001"))
+	_, err := s.GrantConsent(ctx, iin1, "marketing", "form")
+	require.NoError(t, err)
+	require.NoError(t, s.RecordAccess(ctx, []storage.AccessEntry{{IIN: iin1, Action: storage.AccessRead}}))
+	_, err = s.ChangeStatus(ctx, iin1, storage.StatusEmigrated, "2024-01-31", "departure form", 0)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin1, 0))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Kept Person", "+77010000002"))
+
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	count, err := s.CountExpired(storage.RetentionDeletedPeople, past)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	count, err = s.CountExpired(storage.RetentionDeletedPeople, future)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = s.PurgeExpired(storage.RetentionDeletedPeople, future)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// The history of the deleted person is gone, the one of the others is kept
+	events, err := s.GetPersonEvents(ctx, iin1)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	consents, err := s.GetConsentHistory(ctx, iin1)
+	require.NoError(t, err)
+	assert.Empty(t, consents)
+	entries, err := s.GetAccessLog(ctx, iin1)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	changes, err := s.GetStatusHistory(ctx, iin1)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	events, err = s.GetPersonEvents(ctx, iin2)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	count, err = s.PurgeExpired(storage.RetentionEvents, future)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	// Purging events leaves the people themselves untouched
+	_, err = s.GetPersonByIIN(ctx, iin2)
+	require.NoError(t, err)
+
+	_, err = s.CountExpired("users", future)
+	assert.ErrorIs(t, err, storage.ErrorUnknownTarget)
+	_, err = s.PurgeExpired("users", future)
+	assert.ErrorIs(t, err, storage.ErrorUnknownTarget)
+}
+
+func testTenants(t *testing.T, s Storage) {
+	// The default tenant always exists
+	tenants, err := s.GetTenants()
+	require.NoError(t, err)
+	require.Len(t, tenants, 1)
+	assert.Equal(t, storage.DefaultTenant, tenants[0].ID)
+
+	_, err = s.SaveTenant(storage.DefaultTenant, "Default")
+	assert.ErrorIs(t, err, storage.ErrorTenantExists)
+	tenant, err := s.SaveTenant("health", "Health Department")
+	require.NoError(t, err)
+	assert.Equal(t, "health", tenant.ID)
+	assert.Equal(t, "Health Department", tenant.Name)
+
+	// Tenants are ordered by ID
+	tenants, err = s.GetTenants()
+	require.NoError(t, err)
+	require.Len(t, tenants, 2)
+	assert.Equal(t, storage.DefaultTenant, tenants[0].ID)
+	assert.Equal(t, "health", tenants[1].ID)
+
+	_, err = s.SaveCredential("clinic", "unknown", "hash")
+	assert.ErrorIs(t, err, storage.ErrorTenantNotFound)
+	_, err = s.GetCredential("clinic")
+	assert.ErrorIs(t, err, storage.ErrorCredentialNotFound)
+
+	_, err = s.SaveCredential("clinic", storage.DefaultTenant, "first")
+	require.NoError(t, err)
+	// Assigning the user again moves it and replaces its password
+	_, err = s.SaveCredential("clinic", "health", "second")
+	require.NoError(t, err)
+
+	credential, err := s.GetCredential("clinic")
+	require.NoError(t, err)
+	assert.Equal(t, "clinic", credential.Username)
+	assert.Equal(t, "health", credential.Tenant)
+	assert.Equal(t, "second", credential.PasswordHash)
+}
+
+func testTenantIsolation(t *testing.T, s Storage) {
+	defaultTenant := operator()
+	health := tenant(t, s, "health")
+
+	require.NoError(t, s.SavePerson(defaultTenant, iin1, "Default Person", "+77010000001"))
+	// The same IIN and phone number may be stored by another tenant, but not twice by the same one
+	require.NoError(t, s.SavePerson(health, iin1, "Health Person", "+77010000001"))
+	assert.ErrorIs(t, s.SavePerson(health, iin1, "Health Person", "+77010000002"), storage.ErrorIINExists)
+	assert.ErrorIs(t, s.SavePerson(health, iin2, "Health Person", "+77010000001"), storage.ErrorPhoneNumberExists)
+	require.NoError(t, s.SavePerson(health, iin2, "Health Person", "+77010000002"))
+
+	person, err := s.GetPersonByIIN(defaultTenant, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, "Default Person", person.Name)
+	_, err = s.GetPersonByIIN(defaultTenant, iin2)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	people, err := s.GetPersonByName(defaultTenant, "Person", storage.PersonFilter{})
+	require.NoError(t, err)
+	assert.Len(t, people, 1)
+	people, err = s.GetAllPeople(health)
+	require.NoError(t, err)
+	assert.Len(t, people, 2)
+
+	// Writes of one tenant leave the records of the others untouched
+	assert.ErrorIs(t, s.DeletePersonByIIN(defaultTenant, iin2, 0), storage.ErrorIINNotFound)
+	_, err = s.UpdatePerson(defaultTenant, iin2, "New Name", "+77010000003", 0)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.MergePeople(defaultTenant, iin2, iin1, 0)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.MergePeople(health, iin2, iin1, 0)
+	require.NoError(t, err)
+	person, err = s.GetPersonByIIN(health, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, "Health Person", person.Name)
+
+	merges, err := s.GetMergeLog(defaultTenant)
+	require.NoError(t, err)
+	assert.Empty(t, merges)
+	_, err = s.GrantConsent(health, iin1, "marketing", "form")
+	require.NoError(t, err)
+	granted, err := s.HasConsent(defaultTenant, iin1, "marketing")
+	require.NoError(t, err)
+	assert.False(t, granted)
+
+	// Every tenant numbers its own events without gaps, the outbox numbers the events of every tenant
+	events, err := s.GetEvents(defaultTenant, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	events, err = s.GetEvents(health, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	for i, event := range events {
+		assert.Equal(t, int64(i+1), event.Seq)
+	}
+	last, err := s.GetLastEventSeq(defaultTenant)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), last)
+	last, err = s.GetLastEventSeq(health)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), last)
+	events, err = s.GetOutboxEvents(0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	assert.Equal(t, storage.DefaultTenant, events[0].Tenant)
+	assert.Equal(t, "health", events[1].Tenant)
+	assert.Equal(t, int64(1), events[1].Seq)
+	assert.Equal(t, int64(2), events[1].OutboxSeq)
+
+	webhook, err := s.SaveWebhook(health, "http://localhost/hook", "secret", []string{"created"})
+	require.NoError(t, err)
+	_, err = s.GetWebhook(defaultTenant, webhook.ID)
+	assert.ErrorIs(t, err, storage.ErrorWebhookNotFound)
+	assert.ErrorIs(t, s.DeleteWebhook(defaultTenant, webhook.ID), storage.ErrorWebhookNotFound)
+}
+
+func testClose(t *testing.T, s Storage) {
+	require.NoError(t, s.Close())
+	// Closing again is harmless, and nothing is written after the close
+	require.NoError(t, s.Close())
+	assert.ErrorIs(t, s.SavePerson(operator(), iin1, "Test Name", "+77010000001"), storage.ErrorStorageClosed)
+}
@@ -0,0 +1,147 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/attributes"
+	"citizen_webservice/internal/storage"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAttributeSchemas(t *testing.T, s Storage) {
+	ctx := operator()
+	other := storage.WithTenant(ctx, "other")
+
+	_, err := s.GetAttributeSchema(ctx, "benefits")
+	assert.ErrorIs(t, err, storage.ErrorSchemaNotFound)
+	assert.ErrorIs(t, s.DeleteAttributeSchema(ctx, "benefits"), storage.ErrorSchemaNotFound)
+
+	schemas, err := s.GetAttributeSchemas(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, schemas)
+	assert.Empty(t, schemas)
+
+	saved, err := s.SaveAttributeSchema(ctx, "benefits", []byte(`{"type": "object"}`))
+	require.NoError(t, err)
+	assert.False(t, saved.CreatedAt.IsZero())
+	_, err = s.SaveAttributeSchema(ctx, "archive", []byte(`{"type": "string"}`))
+	require.NoError(t, err)
+
+	// A new schema replaces the previous one of the namespace, which keeps its creation time
+	_, err = s.SaveAttributeSchema(ctx, "benefits", []byte(`{"type": "object", "required": ["category"]}`))
+	require.NoError(t, err)
+	schema, err := s.GetAttributeSchema(ctx, "benefits")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "required": ["category"]}`, string(schema.Schema))
+	assert.True(t, saved.CreatedAt.Equal(schema.CreatedAt))
+	assert.False(t, schema.UpdatedAt.Before(schema.CreatedAt))
+
+	schemas, err = s.GetAttributeSchemas(ctx)
+	require.NoError(t, err)
+	require.Len(t, schemas, 2)
+	assert.Equal(t, "archive", schemas[0].Namespace)
+	assert.Equal(t, "benefits", schemas[1].Namespace)
+
+	// Tenants have their own schemas
+	_, err = s.GetAttributeSchema(other, "benefits")
+	assert.ErrorIs(t, err, storage.ErrorSchemaNotFound)
+
+	// A namespace cannot be removed while someone has a value in it, even a null one
+	err = s.SavePersonWithOptions(ctx, iin1, "Test Name", "+77010000001", storage.SaveOptions{
+		Attributes: map[string]json.RawMessage{"archive": json.RawMessage(`null`)},
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.DeleteAttributeSchema(ctx, "archive"), storage.ErrorSchemaInUse)
+
+	require.NoError(t, s.DeleteAttributeSchema(ctx, "benefits"))
+	_, err = s.GetAttributeSchema(ctx, "benefits")
+	assert.ErrorIs(t, err, storage.ErrorSchemaNotFound)
+
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin1, 0))
+	require.NoError(t, s.DeleteAttributeSchema(ctx, "archive"))
+}
+
+func testAttributes(t *testing.T, s Storage) {
+	ctx := operator()
+
+	_, err := s.SaveAttributeSchema(ctx, "benefits", []byte(`{"type": "object", "not": {"required": ["verified", "expired"]}}`))
+	require.NoError(t, err)
+	_, err = s.SaveAttributeSchema(ctx, "military", []byte(`{"type": "object"}`))
+	require.NoError(t, err)
+
+	err = s.SavePersonWithOptions(ctx, iin1, "Sally Veteran", "+77010000001", storage.SaveOptions{
+		Attributes: map[string]json.RawMessage{
+			"benefits": json.RawMessage(`{"category": "veteran", "since": 2001, "verified": true}`),
+			"military": json.RawMessage(`{"rank": "major"}`),
+		},
+	})
+	require.NoError(t, err)
+	err = s.SavePersonWithOptions(ctx, iin2, "Holly Student", "+77010000002", storage.SaveOptions{
+		Attributes: map[string]json.RawMessage{"benefits": json.RawMessage(`{"category": "student", "since": 2020}`)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.SavePerson(ctx, iin3, "Molly Plain", "+77010000003"))
+
+	// The attributes are returned alongside the person, whose version they do not bump
+	person, err := s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), person.Version)
+	require.Len(t, person.Attributes, 2)
+	assert.JSONEq(t, `{"category": "veteran", "since": 2001, "verified": true}`, string(person.Attributes["benefits"]))
+	person, err = s.GetPersonByIIN(ctx, iin3)
+	require.NoError(t, err)
+	assert.Nil(t, person.Attributes)
+
+	people, err := s.GetAllPeople(ctx)
+	require.NoError(t, err)
+	require.Len(t, people, 3)
+	assert.Equal(t, iin1, people[1].IIN)
+	assert.JSONEq(t, `{"rank": "major"}`, string(people[1].Attributes["military"]))
+
+	// Every filter must match, strings, numbers and booleans alike
+	search := func(filters ...storage.AttributeFilter) []string {
+		people, err := s.GetPersonByName(ctx, "lly", storage.PersonFilter{Attributes: filters})
+		require.NoError(t, err)
+		iins := []string{}
+		for _, person := range people {
+			iins = append(iins, person.IIN)
+		}
+		return iins
+	}
+	filter := func(path string, value string) storage.AttributeFilter {
+		return storage.AttributeFilter{Path: path, Value: json.RawMessage(value)}
+	}
+	assert.ElementsMatch(t, []string{iin1, iin2, iin3}, search())
+	assert.Equal(t, []string{iin1}, search(filter("benefits.category", `"veteran"`)))
+	assert.Equal(t, []string{iin2}, search(filter("benefits.since", `2020`)))
+	assert.Equal(t, []string{iin1}, search(filter("benefits.verified", `true`)))
+	assert.Equal(t, []string{iin1}, search(filter("benefits.category", `"veteran"`), filter("military.rank", `"major"`)))
+	assert.Empty(t, search(filter("benefits.category", `"veteran"`), filter("benefits.since", `2020`)))
+	assert.Empty(t, search(filter("benefits.since", `"2020"`)))
+
+	// Null matches the people without the field
+	assert.ElementsMatch(t, []string{iin2, iin3}, search(filter("military.rank", `null`)))
+
+	// A merge adds the attributes of the source to those of the target, which wins for the fields both have
+	_, err = s.MergePeople(ctx, iin1, iin2, 0)
+	require.NoError(t, err)
+	person, err = s.GetPersonByIIN(ctx, iin2)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"category": "student", "since": 2020, "verified": true}`, string(person.Attributes["benefits"]))
+	assert.JSONEq(t, `{"rank": "major"}`, string(person.Attributes["military"]))
+
+	// A merge whose combined attributes do not match their schema is rejected and changes nothing
+	err = s.SavePersonWithOptions(ctx, iin4, "Expired Student", "+77010000004", storage.SaveOptions{
+		Attributes: map[string]json.RawMessage{"benefits": json.RawMessage(`{"category": "student", "expired": true}`)},
+	})
+	require.NoError(t, err)
+	_, err = s.MergePeople(ctx, iin4, iin2, 0)
+	assert.ErrorIs(t, err, attributes.ErrorInvalidValue)
+	_, err = s.GetPersonByIIN(ctx, iin4)
+	require.NoError(t, err)
+	person, err = s.GetPersonByIIN(ctx, iin2)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"category": "student", "since": 2020, "verified": true}`, string(person.Attributes["benefits"]))
+}
@@ -0,0 +1,137 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDocuments(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Other Name", "+77010000002"))
+
+	idCard := storage.Document{
+		IIN: iin1, Type: storage.DocumentIDCard, Number: "012345678", IssuingAuthority: "MVD RK",
+		IssueDate: "2015-03-01", ExpiryDate: "2025-03-01",
+	}
+	_, err := s.SaveDocument(ctx, storage.Document{IIN: iin3, Type: storage.DocumentIDCard, Number: "1"})
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	saved, err := s.SaveDocument(ctx, idCard)
+	require.NoError(t, err)
+	assert.NotZero(t, saved.ID)
+	assert.False(t, saved.CreatedAt.IsZero())
+	assert.Equal(t, saved.CreatedAt, saved.UpdatedAt)
+
+	// Numbers are unique per type, whoever holds the document
+	_, err = s.SaveDocument(ctx, storage.Document{IIN: iin2, Type: storage.DocumentIDCard, Number: "012345678"})
+	assert.ErrorIs(t, err, storage.ErrorDocumentExists)
+	passport, err := s.SaveDocument(ctx, storage.Document{
+		IIN: iin1, Type: storage.DocumentPassport, Number: "012345678", IssuingAuthority: "MVD RK",
+		IssueDate: "2018-06-01", ExpiryDate: "2028-06-01",
+	})
+	require.NoError(t, err)
+
+	got, err := s.GetDocument(ctx, iin1, saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, idCard.Number, got.Number)
+	assert.Equal(t, idCard.IssuingAuthority, got.IssuingAuthority)
+	assert.Equal(t, idCard.IssueDate, got.IssueDate)
+	assert.Equal(t, idCard.ExpiryDate, got.ExpiryDate)
+	_, err = s.GetDocument(ctx, iin2, saved.ID)
+	assert.ErrorIs(t, err, storage.ErrorDocumentNotFound)
+
+	documents, err := s.GetDocuments(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, documents, 2)
+	assert.Equal(t, saved.ID, documents[0].ID)
+	assert.Equal(t, passport.ID, documents[1].ID)
+
+	renewed := idCard
+	renewed.ID = saved.ID
+	renewed.Number = "987654321"
+	renewed.IssueDate, renewed.ExpiryDate = "2025-02-15", "2035-02-15"
+	updated, err := s.UpdateDocument(ctx, renewed)
+	require.NoError(t, err)
+	assert.Equal(t, "987654321", updated.Number)
+	assert.Equal(t, "2035-02-15", updated.ExpiryDate)
+	assert.Equal(t, saved.CreatedAt.Unix(), updated.CreatedAt.Unix())
+
+	renewed.Type = storage.DocumentPassport
+	renewed.Number = passport.Number
+	_, err = s.UpdateDocument(ctx, renewed)
+	assert.ErrorIs(t, err, storage.ErrorDocumentExists)
+	renewed.IIN = iin2
+	_, err = s.UpdateDocument(ctx, renewed)
+	assert.ErrorIs(t, err, storage.ErrorDocumentNotFound)
+
+	require.NoError(t, s.DeleteDocument(ctx, iin1, passport.ID))
+	assert.ErrorIs(t, s.DeleteDocument(ctx, iin1, passport.ID), storage.ErrorDocumentNotFound)
+
+	// A merge moves the documents to the target, a deletion removes them
+	_, err = s.MergePeople(ctx, iin1, iin2, 0)
+	require.NoError(t, err)
+	documents, err = s.GetDocuments(ctx, iin1)
+	require.NoError(t, err)
+	assert.NotNil(t, documents)
+	assert.Empty(t, documents)
+	documents, err = s.GetDocuments(ctx, iin2)
+	require.NoError(t, err)
+	require.Len(t, documents, 1)
+	assert.Equal(t, saved.ID, documents[0].ID)
+	assert.Equal(t, iin2, documents[0].IIN)
+
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin2, 0))
+	documents, err = s.GetDocuments(ctx, iin2)
+	require.NoError(t, err)
+	assert.Empty(t, documents)
+
+	// The number of a removed document is free again
+	require.NoError(t, s.SavePerson(ctx, iin3, "Test Name", "+77010000003"))
+	_, err = s.SaveDocument(ctx, storage.Document{IIN: iin3, Type: storage.DocumentIDCard, Number: "987654321"})
+	assert.NoError(t, err)
+}
+
+func testExpiringDocuments(t *testing.T, s Storage) {
+	ctx := operator()
+	other := tenant(t, s, "other")
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Other Name", "+77010000002"))
+	require.NoError(t, s.SavePerson(other, iin1, "Test Name", "+77010000001"))
+
+	for _, document := range []struct {
+		ctx    context.Context
+		iin    string
+		number string
+		expiry string
+	}{
+		{ctx, iin1, "1", "2030-01-15"},
+		{ctx, iin2, "2", "2030-01-01"},
+		{ctx, iin1, "3", "2030-01-31"},
+		{ctx, iin2, "4", "2029-12-31"},
+		{ctx, iin2, "5", "2030-02-01"},
+		{other, iin1, "6", "2030-01-10"},
+	} {
+		_, err := s.SaveDocument(document.ctx, storage.Document{
+			IIN: document.iin, Type: storage.DocumentPassport, Number: document.number, IssueDate: "2020-01-01", ExpiryDate: document.expiry,
+		})
+		require.NoError(t, err)
+	}
+
+	// Both days are included, in expiry order, and other tenants are left out
+	documents, err := s.GetExpiringDocuments(ctx, "2030-01-01", "2030-01-31")
+	require.NoError(t, err)
+	require.Len(t, documents, 3)
+	for i, number := range []string{"2", "1", "3"} {
+		assert.Equal(t, number, documents[i].Number)
+	}
+
+	documents, err = s.GetExpiringDocuments(ctx, "2031-01-01", "2031-12-31")
+	require.NoError(t, err)
+	assert.NotNil(t, documents)
+	assert.Empty(t, documents)
+}
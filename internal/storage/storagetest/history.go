@@ -0,0 +1,185 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvents(t *testing.T, s Storage) {
+	ctx := operator()
+
+	events, err := s.GetEvents(ctx, 0, 100)
+	require.NoError(t, err)
+	assert.NotNil(t, events)
+	assert.Empty(t, events)
+	last, err := s.GetLastEventSeq(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, last)
+
+	require.NoError(t, s.SavePerson(storage.WithRequestID(ctx, "host/abc-000001"), iin1, "Test Name", "+77010000001"))
+	_, err = s.UpdatePerson(ctx, iin1, "New Name", "+77010000001", 1)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin1, 0))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Test Name", "+77010000001"))
+
+	// Failed writes record nothing
+	assert.ErrorIs(t, s.SavePerson(ctx, iin3, "Test Name", "+77010000001"), storage.ErrorPhoneNumberExists)
+	assert.ErrorIs(t, s.DeletePersonByIIN(ctx, iin1, 0), storage.ErrorIINNotFound)
+	_, err = s.UpdatePerson(ctx, iin2, "New Name", "+77010000001", 5)
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+
+	events, err = s.GetPersonEvents(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	for i, want := range []struct {
+		eventType string
+		payload   storage.EventPayload
+	}{
+		{storage.EventPersonCreated, storage.EventPayload{IIN: iin1, Name: "Test Name", Version: 1, Status: storage.StatusActive}},
+		{storage.EventPersonUpdated, storage.EventPayload{IIN: iin1, Name: "New Name", Version: 2, Status: storage.StatusActive}},
+		{storage.EventPersonDeleted, storage.EventPayload{IIN: iin1}},
+	} {
+		assert.Equal(t, want.eventType, events[i].Type)
+		assert.Equal(t, iin1, events[i].IIN)
+		assert.False(t, events[i].CreatedAt.IsZero())
+
+		var payload storage.EventPayload
+		require.NoError(t, json.Unmarshal(events[i].Payload, &payload))
+		assert.Equal(t, want.payload, payload)
+		// The phone is not shared with the consumers of the events
+		assert.NotContains(t, string(events[i].Payload), "phone")
+	}
+	assert.Equal(t, "host/abc-000001", events[0].RequestID)
+	assert.Empty(t, events[1].RequestID)
+
+	// Sequence numbers follow the commit order without gaps
+	events, err = s.GetEvents(ctx, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	for i, event := range events {
+		assert.Equal(t, int64(i+1), event.Seq)
+	}
+	events, err = s.GetEvents(ctx, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, int64(2), events[0].Seq)
+	assert.Equal(t, int64(3), events[1].Seq)
+
+	last, err = s.GetLastEventSeq(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), last)
+
+	events, err = s.GetOutboxEvents(3, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, iin2, events[0].IIN)
+	assert.Equal(t, storage.DefaultTenant, events[0].Tenant)
+}
+
+func testOutboxCursor(t *testing.T, s Storage) {
+	seq, err := s.GetOutboxCursor("nats")
+	require.NoError(t, err)
+	assert.Zero(t, seq)
+
+	require.NoError(t, s.SaveOutboxCursor("nats", 5))
+	// The cursor never moves back
+	require.NoError(t, s.SaveOutboxCursor("nats", 3))
+
+	seq, err = s.GetOutboxCursor("nats")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), seq)
+
+	seq, err = s.GetOutboxCursor("other")
+	require.NoError(t, err)
+	assert.Zero(t, seq)
+}
+
+func testConsents(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+
+	_, err := s.GrantConsent(ctx, iin2, "marketing", "form")
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.RevokeConsent(ctx, iin1, "marketing", "form")
+	assert.ErrorIs(t, err, storage.ErrorConsentNotFound)
+
+	granted, err := s.GrantConsent(ctx, iin1, "marketing", "form")
+	require.NoError(t, err)
+	assert.Equal(t, storage.ConsentGranted, granted.Status)
+	_, err = s.GrantConsent(ctx, iin1, "delivery", "portal")
+	require.NoError(t, err)
+
+	ok, err := s.HasConsent(ctx, iin1, "marketing")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	revoked, err := s.RevokeConsent(ctx, iin1, "marketing", "call center")
+	require.NoError(t, err)
+	assert.Equal(t, storage.ConsentRevoked, revoked.Status)
+	assert.Greater(t, revoked.ID, granted.ID)
+	_, err = s.RevokeConsent(ctx, iin1, "marketing", "call center")
+	assert.ErrorIs(t, err, storage.ErrorConsentNotFound)
+
+	for _, purpose := range []string{"marketing", "unknown"} {
+		ok, err = s.HasConsent(ctx, iin1, purpose)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	// The current consent for every purpose, in purpose order
+	consents, err := s.GetConsents(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, consents, 2)
+	assert.Equal(t, "delivery", consents[0].Purpose)
+	assert.Equal(t, storage.ConsentGranted, consents[0].Status)
+	assert.Equal(t, "marketing", consents[1].Purpose)
+	assert.Equal(t, storage.ConsentRevoked, consents[1].Status)
+	assert.Equal(t, "call center", consents[1].Source)
+
+	history, err := s.GetConsentHistory(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, granted.ID, history[0].ID)
+	assert.Equal(t, revoked.ID, history[2].ID)
+
+	for _, get := range []func(context.Context, string) ([]storage.Consent, error){s.GetConsents, s.GetConsentHistory} {
+		consents, err = get(ctx, iin2)
+		require.NoError(t, err)
+		assert.NotNil(t, consents)
+		assert.Empty(t, consents)
+	}
+}
+
+func testAccessLog(t *testing.T, s Storage) {
+	ctx := storage.WithRequestID(operator(), "host/abc-000001")
+
+	require.NoError(t, s.RecordAccess(ctx, []storage.AccessEntry{
+		{IIN: iin1, Action: storage.AccessSearch, Client: "user"},
+		{IIN: iin2, Action: storage.AccessSearch, Client: "user"},
+	}))
+	require.NoError(t, s.RecordAccess(operator(), []storage.AccessEntry{
+		{IIN: iin1, Action: storage.AccessRead, Client: "partner", Purpose: "marketing", RequestID: "own"},
+	}))
+	require.NoError(t, s.RecordAccess(ctx, nil))
+
+	entries, err := s.GetAccessLog(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, storage.AccessSearch, entries[0].Action)
+	assert.Equal(t, "user", entries[0].Client)
+	assert.Equal(t, "host/abc-000001", entries[0].RequestID)
+	assert.False(t, entries[0].AccessedAt.IsZero())
+	assert.Equal(t, storage.AccessRead, entries[1].Action)
+	assert.Equal(t, "marketing", entries[1].Purpose)
+	assert.Equal(t, "own", entries[1].RequestID)
+
+	entries, err = s.GetAccessLog(ctx, iin3)
+	require.NoError(t, err)
+	assert.NotNil(t, entries)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,160 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hold returns a legal hold on the person placed by the test client.
+func hold(iin string) storage.LegalHold {
+	return storage.LegalHold{IIN: iin, Reason: "Fraud investigation", CaseNumber: "2024-0117", PlacedBy: "investigator"}
+}
+
+func testLegalHolds(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Other Name", "+77010000002"))
+
+	_, err := s.PlaceLegalHold(ctx, hold(iin3))
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.GetLegalHold(ctx, iin1)
+	assert.ErrorIs(t, err, storage.ErrorHoldNotFound)
+	_, err = s.ReleaseLegalHold(ctx, iin1, "Case closed", "investigator")
+	assert.ErrorIs(t, err, storage.ErrorHoldNotFound)
+	holds, err := s.GetLegalHolds(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, holds)
+	assert.Empty(t, holds)
+
+	placed, err := s.PlaceLegalHold(ctx, hold(iin1))
+	require.NoError(t, err)
+	assert.False(t, placed.PlacedAt.IsZero())
+	_, err = s.PlaceLegalHold(ctx, hold(iin1))
+	assert.ErrorIs(t, err, storage.ErrorHoldExists)
+
+	current, err := s.GetLegalHold(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-0117", current.CaseNumber)
+	assert.Equal(t, "investigator", current.PlacedBy)
+	holds, err = s.GetLegalHolds(ctx)
+	require.NoError(t, err)
+	require.Len(t, holds, 1)
+	assert.Equal(t, iin1, holds[0].IIN)
+
+	// Holds belong to the tenant that placed them
+	other := tenant(t, s, "other")
+	_, err = s.GetLegalHold(other, iin1)
+	assert.ErrorIs(t, err, storage.ErrorHoldNotFound)
+
+	// Placing and releasing a hold are both logged, the release with the case number of the hold
+	released, err := s.ReleaseLegalHold(ctx, iin1, "Case closed", "supervisor")
+	require.NoError(t, err)
+	assert.Equal(t, storage.HoldReleased, released.Action)
+	assert.Equal(t, "2024-0117", released.CaseNumber)
+	_, err = s.GetLegalHold(ctx, iin1)
+	assert.ErrorIs(t, err, storage.ErrorHoldNotFound)
+
+	entries, err := s.GetLegalHoldLog(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, storage.HoldPlaced, entries[0].Action)
+	assert.Equal(t, "Fraud investigation", entries[0].Reason)
+	assert.Equal(t, "investigator", entries[0].Client)
+	assert.Equal(t, storage.HoldReleased, entries[1].Action)
+	assert.Equal(t, "Case closed", entries[1].Reason)
+	assert.Equal(t, "supervisor", entries[1].Client)
+	entries, err = s.GetLegalHoldLog(ctx, iin2)
+	require.NoError(t, err)
+	assert.NotNil(t, entries)
+	assert.Empty(t, entries)
+}
+
+func testLegalHoldWrites(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Other Name", "+77010000002"))
+	document, err := s.SaveDocument(ctx, storage.Document{
+		IIN: iin1, Type: storage.DocumentIDCard, Number: "012345678", IssuingAuthority: "MVD RK",
+		IssueDate: "2015-03-01", ExpiryDate: "2025-03-01",
+	})
+	require.NoError(t, err)
+	_, err = s.SaveAddress(ctx, address(iin1, storage.AddressRegistered, "751110000", "Abai"))
+	require.NoError(t, err)
+	relationship, err := s.SaveRelationship(ctx, iin1, storage.RelationshipSpouse, iin2)
+	require.NoError(t, err)
+	_, err = s.GrantConsent(ctx, iin1, "marketing", "portal")
+	require.NoError(t, err)
+	photo := storage.Photo{IIN: iin1, ContentType: "image/png", Size: 5, Width: 100, Height: 80}
+	_, err = s.SavePhoto(ctx, photo, []byte("image"), []byte("thumb"))
+	require.NoError(t, err)
+	_, err = s.PlaceLegalHold(ctx, hold(iin1))
+	require.NoError(t, err)
+
+	// Every write to the person or their data is refused
+	_, err = s.UpdatePerson(ctx, iin1, "New Name", "+77010000009", 0)
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	assert.ErrorIs(t, s.DeletePersonByIIN(ctx, iin1, 0), storage.ErrorLegalHold)
+	results, err := s.ExecuteBatch(ctx, []storage.BatchOperation{
+		{Op: storage.OperationUpdate, IIN: iin2, Name: "Batch Name", Phone: "+77010000002"},
+		{Op: storage.OperationDelete, IIN: iin1},
+	})
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	require.Len(t, results, 2)
+	assert.ErrorIs(t, results[1].Err, storage.ErrorLegalHold)
+	_, err = s.MergePeople(ctx, iin1, iin2, 0)
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	_, err = s.MergePeople(ctx, iin2, iin1, 0)
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	_, err = s.ChangeStatus(ctx, iin1, storage.StatusDeceased, "2024-01-01", "Certificate", 0)
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+
+	_, err = s.SaveDocument(ctx, storage.Document{
+		IIN: iin1, Type: storage.DocumentPassport, Number: "N1234567", IssuingAuthority: "MVD RK",
+		IssueDate: "2015-03-01", ExpiryDate: "2025-03-01",
+	})
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	document.Number = "876543210"
+	_, err = s.UpdateDocument(ctx, document)
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	assert.ErrorIs(t, s.DeleteDocument(ctx, iin1, document.ID), storage.ErrorLegalHold)
+	_, err = s.SaveAddress(ctx, address(iin1, storage.AddressActual, "711110000", "Kabanbay"))
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	assert.ErrorIs(t, s.DeleteAddress(ctx, iin1, storage.AddressRegistered), storage.ErrorLegalHold)
+	_, err = s.SaveRelationship(ctx, iin1, storage.RelationshipParent, iin3)
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	assert.ErrorIs(t, s.DeleteRelationship(ctx, relationship.ID), storage.ErrorLegalHold)
+	err = s.SavePersonWithOptions(ctx, iin3, "Ward Name", "+77010000003", storage.SaveOptions{GuardianIIN: iin1})
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	_, err = s.GrantConsent(ctx, iin1, "research", "portal")
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	_, err = s.RevokeConsent(ctx, iin1, "marketing", "portal")
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	_, err = s.SavePhoto(ctx, photo, []byte("new image"), []byte("new thumb"))
+	assert.ErrorIs(t, err, storage.ErrorLegalHold)
+	assert.ErrorIs(t, s.DeletePhoto(ctx, iin1), storage.ErrorLegalHold)
+
+	// Nothing was changed, and the other person can still be
+	person, err := s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Name", person.Name)
+	assert.Equal(t, storage.StatusActive, person.Status)
+	documents, err := s.GetDocuments(ctx, iin1)
+	require.NoError(t, err)
+	assert.Len(t, documents, 1)
+	_, data, err := s.GetPhoto(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("image"), data)
+	_, err = s.UpdatePerson(ctx, iin2, "Other Name", "+77010000008", 0)
+	require.NoError(t, err)
+
+	// Once released, the record can be changed again
+	_, err = s.ReleaseLegalHold(ctx, iin1, "Case closed", "investigator")
+	require.NoError(t, err)
+	_, err = s.UpdatePerson(ctx, iin1, "New Name", "+77010000009", 0)
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteRelationship(ctx, relationship.ID))
+	require.NoError(t, s.DeletePhoto(ctx, iin1))
+}
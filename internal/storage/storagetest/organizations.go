@@ -0,0 +1,150 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	bin1 = "040840001231"
+	bin2 = "150241003214"
+)
+
+// employment returns an employment of the person at the organization during the period.
+func employment(iin string, bin string, start string, end string) storage.Employment {
+	return storage.Employment{IIN: iin, BIN: bin, Position: "Engineer", StartDate: start, EndDate: end}
+}
+
+func testOrganizations(t *testing.T, s Storage) {
+	ctx := operator()
+	other := storage.WithTenant(ctx, "other")
+
+	_, err := s.GetOrganization(ctx, bin1)
+	assert.ErrorIs(t, err, storage.ErrorOrganizationNotFound)
+	organizations, err := s.GetOrganizations(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, organizations)
+	assert.Empty(t, organizations)
+
+	saved, err := s.SaveOrganization(ctx, bin2, "Second LLP")
+	require.NoError(t, err)
+	assert.False(t, saved.CreatedAt.IsZero())
+	_, err = s.SaveOrganization(ctx, bin1, "First JSC")
+	require.NoError(t, err)
+	_, err = s.SaveOrganization(ctx, bin1, "Another JSC")
+	assert.ErrorIs(t, err, storage.ErrorOrganizationExists)
+
+	organization, err := s.GetOrganization(ctx, bin1)
+	require.NoError(t, err)
+	assert.Equal(t, "First JSC", organization.Name)
+
+	organizations, err = s.GetOrganizations(ctx)
+	require.NoError(t, err)
+	require.Len(t, organizations, 2)
+	assert.Equal(t, bin1, organizations[0].BIN)
+	assert.Equal(t, bin2, organizations[1].BIN)
+
+	// Tenants have their own organizations
+	_, err = s.GetOrganization(other, bin1)
+	assert.ErrorIs(t, err, storage.ErrorOrganizationNotFound)
+	_, err = s.SaveOrganization(other, bin1, "First JSC")
+	require.NoError(t, err)
+}
+
+func testEmployments(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Other Name", "+77010000002"))
+	require.NoError(t, s.SavePerson(ctx, iin3, "Third Name", "+77010000003"))
+	_, err := s.SaveOrganization(ctx, bin1, "First JSC")
+	require.NoError(t, err)
+	_, err = s.SaveOrganization(ctx, bin2, "Second LLP")
+	require.NoError(t, err)
+
+	_, err = s.SaveEmployment(ctx, employment(iin4, bin1, "2010-01-01", ""))
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.SaveEmployment(ctx, employment(iin1, "121060007895", "2010-01-01", ""))
+	assert.ErrorIs(t, err, storage.ErrorOrganizationNotFound)
+	_, err = s.GetEmployees(ctx, "121060007895", "")
+	assert.ErrorIs(t, err, storage.ErrorOrganizationNotFound)
+
+	first, err := s.SaveEmployment(ctx, employment(iin1, bin1, "2010-01-01", "2015-12-31"))
+	require.NoError(t, err)
+	assert.NotZero(t, first.ID)
+	current, err := s.SaveEmployment(ctx, employment(iin1, bin1, "2018-03-01", ""))
+	require.NoError(t, err)
+	_, err = s.SaveEmployment(ctx, employment(iin1, bin2, "2016-01-01", "2018-02-28"))
+	require.NoError(t, err)
+	_, err = s.SaveEmployment(ctx, employment(iin2, bin1, "2012-06-01", "2019-06-01"))
+	require.NoError(t, err)
+
+	// The periods of a person at the same organization may not share a day, whether they end or not
+	_, err = s.SaveEmployment(ctx, employment(iin1, bin1, "2015-12-31", "2016-06-30"))
+	assert.ErrorIs(t, err, storage.ErrorEmploymentOverlaps)
+	_, err = s.SaveEmployment(ctx, employment(iin1, bin1, "2005-01-01", ""))
+	assert.ErrorIs(t, err, storage.ErrorEmploymentOverlaps)
+	_, err = s.SaveEmployment(ctx, employment(iin1, bin1, "2030-01-01", "2030-12-31"))
+	assert.ErrorIs(t, err, storage.ErrorEmploymentOverlaps)
+	_, err = s.SaveEmployment(ctx, employment(iin1, bin1, "2016-01-01", "2018-02-28"))
+	require.NoError(t, err)
+
+	employments, err := s.GetEmployments(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, employments, 4)
+	assert.Equal(t, first.ID, employments[0].ID)
+	assert.Equal(t, current.ID, employments[3].ID)
+
+	// Employees on a day, and everyone who has ever worked there
+	employees, err := s.GetEmployees(ctx, bin1, "2013-01-01")
+	require.NoError(t, err)
+	require.Len(t, employees, 2)
+	assert.Equal(t, "Other Name", employees[0].Name)
+	assert.Equal(t, "Test Name", employees[1].Name)
+	assert.Equal(t, first.ID, employees[1].ID)
+	employees, err = s.GetEmployees(ctx, bin1, "2019-06-02")
+	require.NoError(t, err)
+	require.Len(t, employees, 1)
+	assert.Equal(t, current.ID, employees[0].ID)
+	employees, err = s.GetEmployees(ctx, bin1, "")
+	require.NoError(t, err)
+	assert.Len(t, employees, 4)
+
+	// An update may end an employment but not make it overlap another one
+	current.EndDate = "2024-05-31"
+	updated, err := s.UpdateEmployment(ctx, current)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-05-31", updated.EndDate)
+	assert.Equal(t, bin1, updated.BIN)
+	current.StartDate = "2015-01-01"
+	_, err = s.UpdateEmployment(ctx, current)
+	assert.ErrorIs(t, err, storage.ErrorEmploymentOverlaps)
+	_, err = s.UpdateEmployment(ctx, storage.Employment{ID: current.ID, IIN: iin2, StartDate: "2020-01-01"})
+	assert.ErrorIs(t, err, storage.ErrorEmploymentNotFound)
+
+	assert.ErrorIs(t, s.DeleteEmployment(ctx, iin2, first.ID), storage.ErrorEmploymentNotFound)
+	require.NoError(t, s.DeleteEmployment(ctx, iin1, first.ID))
+
+	// A merge may not make the employments of the target overlap, and changes nothing then
+	_, err = s.MergePeople(ctx, iin2, iin1, 0)
+	assert.ErrorIs(t, err, storage.ErrorEmploymentOverlaps)
+	employments, err = s.GetEmployments(ctx, iin2)
+	require.NoError(t, err)
+	assert.Len(t, employments, 1)
+	person, err := s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), person.Version)
+
+	// A merge moves the employments of the source, and a deletion removes them
+	_, err = s.MergePeople(ctx, iin2, iin3, 0)
+	require.NoError(t, err)
+	employments, err = s.GetEmployments(ctx, iin3)
+	require.NoError(t, err)
+	assert.Len(t, employments, 1)
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin3, 0))
+	employments, err = s.GetEmployments(ctx, iin3)
+	require.NoError(t, err)
+	assert.Empty(t, employments)
+}
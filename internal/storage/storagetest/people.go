@@ -0,0 +1,504 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSavePerson(t *testing.T, s Storage) {
+	ctx := operator()
+
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	person, err := s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.PersonInfo{IIN: iin1, Name: "Test Name", Phone: "+77010000001", Version: 1, Status: storage.StatusActive}, person)
+
+	// Sentinel errors may be wrapped, the callers match them with errors.Is
+	assert.ErrorIs(t, s.SavePerson(ctx, iin1, "Other Name", "+77010000002"), storage.ErrorIINExists)
+	assert.ErrorIs(t, s.SavePerson(ctx, iin2, "Other Name", "+77010000001"), storage.ErrorPhoneNumberExists)
+
+	person, err = s.GetPersonByIIN(ctx, iin2)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	assert.Equal(t, storage.PersonInfo{}, person)
+}
+
+func testGetPersonByName(t *testing.T, s Storage) {
+	ctx := operator()
+
+	require.NoError(t, s.SavePerson(ctx, iin1, "Sally", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Lilly", "+77010000002"))
+	require.NoError(t, s.SavePerson(ctx, iin3, "Bob", "+77010000003"))
+
+	people, err := s.GetPersonByName(ctx, "ll", storage.PersonFilter{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []storage.PersonInfo{
+		{IIN: iin1, Name: "Sally", Phone: "+77010000001", Version: 1, Status: storage.StatusActive},
+		{IIN: iin2, Name: "Lilly", Phone: "+77010000002", Version: 1, Status: storage.StatusActive},
+	}, people)
+
+	// Only the people with the status, if one is given
+	_, err = s.ChangeStatus(ctx, iin2, storage.StatusDeceased, "2024-01-31", "certificate", 0)
+	require.NoError(t, err)
+	people, err = s.GetPersonByName(ctx, "ll", storage.PersonFilter{Status: storage.StatusActive})
+	require.NoError(t, err)
+	require.Len(t, people, 1)
+	assert.Equal(t, iin1, people[0].IIN)
+	people, err = s.GetPersonByName(ctx, "ll", storage.PersonFilter{Status: storage.StatusDeceased})
+	require.NoError(t, err)
+	require.Len(t, people, 1)
+	assert.Equal(t, iin2, people[0].IIN)
+	people, err = s.GetPersonByName(ctx, "ll", storage.PersonFilter{Status: storage.StatusEmigrated})
+	require.NoError(t, err)
+	assert.Nil(t, people)
+
+	// No match is not an error, and the people are nil, which the API returns as null
+	people, err = s.GetPersonByName(ctx, "qqqq", storage.PersonFilter{})
+	require.NoError(t, err)
+	assert.Nil(t, people)
+}
+
+func testGetAllPeople(t *testing.T, s Storage) {
+	ctx := operator()
+
+	people, err := s.GetAllPeople(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, people)
+
+	require.NoError(t, s.SavePerson(ctx, iin2, "Test Name", "+77010000002"))
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+
+	// People are ordered by IIN
+	people, err = s.GetAllPeople(ctx)
+	require.NoError(t, err)
+	require.Len(t, people, 2)
+	assert.Equal(t, iin1, people[0].IIN)
+	assert.Equal(t, iin2, people[1].IIN)
+}
+
+func testCandidatePairs(t *testing.T, s Storage) {
+	ctx := operator()
+
+	pairs, next, err := s.GetCandidatePairs(ctx, "", 10, 10)
+	require.NoError(t, err)
+	assert.NotNil(t, pairs)
+	assert.Empty(t, pairs)
+	assert.Empty(t, next)
+
+	// People born on the same day are paired, as are those whose names start with the same letters
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, "830218350084", "Other Person", "+77010000002"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Sally", "+77010000003"))
+	require.NoError(t, s.SavePerson(ctx, iin3, " sally smith", "+77010000004"))
+	require.NoError(t, s.SavePerson(ctx, iin4, "Bob", "+77010000005"))
+	require.NoError(t, s.SavePerson(storage.WithTenant(ctx, "other"), "830218350094", "Test Name", "+77010000001"))
+
+	pairs, next, err = s.GetCandidatePairs(ctx, "", 10, 10)
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, pairs, 2)
+	assert.Equal(t, iin3, pairs[0].First.IIN)
+	assert.Equal(t, iin2, pairs[0].Second.IIN)
+	assert.Equal(t, "Sally", pairs[0].Second.Name)
+	assert.Equal(t, iin1, pairs[1].First.IIN)
+	assert.Equal(t, "830218350084", pairs[1].Second.IIN)
+
+	// Every pair is returned on the page of its first person, the last page being short
+	var paged []storage.PersonPair
+	pages := 0
+	for after := ""; ; after = next {
+		pairs, next, err = s.GetCandidatePairs(ctx, after, 2, 10)
+		require.NoError(t, err)
+		paged = append(paged, pairs...)
+		pages++
+		if next == "" {
+			break
+		}
+	}
+	assert.Equal(t, 3, pages)
+	require.Len(t, paged, 2)
+	assert.Equal(t, iin3, paged[0].First.IIN)
+	assert.Equal(t, iin1, paged[1].First.IIN)
+
+	// A page is cut at the number of pairs, even within the pairs of a person, and the next one resumes after the last pair
+	require.NoError(t, s.SavePerson(ctx, "830218350094", "Tess", "+77010000006"))
+	pairs, next, err = s.GetCandidatePairs(ctx, "", 10, 2)
+	require.NoError(t, err)
+	require.Len(t, pairs, 2)
+	assert.Equal(t, iin1, pairs[1].First.IIN)
+	assert.Equal(t, "830218350084", pairs[1].Second.IIN)
+	assert.Equal(t, iin1+":830218350084", next)
+	pairs, next, err = s.GetCandidatePairs(ctx, next, 10, 2)
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, pairs, 2)
+	assert.Equal(t, iin1, pairs[0].First.IIN)
+	assert.Equal(t, "830218350094", pairs[0].Second.IIN)
+	assert.Equal(t, "830218350084", pairs[1].First.IIN)
+	assert.Equal(t, "830218350094", pairs[1].Second.IIN)
+}
+
+func testUpdatePerson(t *testing.T, s Storage) {
+	ctx := operator()
+
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Test Name", "+77010000002"))
+
+	version, err := s.UpdatePerson(ctx, iin1, "New Name", "+77010000003", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+
+	// A stale version does not change the person
+	_, err = s.UpdatePerson(ctx, iin1, "Stale Name", "+77010000003", 1)
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+	// Zero matches any version
+	version, err = s.UpdatePerson(ctx, iin1, "Newer Name", "+77010000003", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), version)
+
+	_, err = s.UpdatePerson(ctx, iin1, "Newer Name", "+77010000002", 0)
+	assert.ErrorIs(t, err, storage.ErrorPhoneNumberExists)
+	_, err = s.UpdatePerson(ctx, iin3, "Test Name", "+77010000004", 0)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.UpdatePerson(ctx, iin3, "Test Name", "+77010000004", 1)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	// AnyVersion matches any current version but not a missing person
+	version, err = s.UpdatePerson(ctx, iin2, "Test Name", "+77010000002", storage.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+	_, err = s.UpdatePerson(ctx, iin3, "Test Name", "+77010000004", storage.AnyVersion)
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+
+	// Any of several expected versions matches
+	_, err = s.UpdatePerson(ctx, iin1, "Newer Name", "+77010000003", 1, 2)
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+	version, err = s.UpdatePerson(ctx, iin1, "Newer Name", "+77010000003", 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), version)
+
+	person, err := s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.PersonInfo{IIN: iin1, Name: "Newer Name", Phone: "+77010000003", Version: 4, Status: storage.StatusActive}, person)
+}
+
+func testDeletePersonByIIN(t *testing.T, s Storage) {
+	ctx := operator()
+
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	_, err := s.UpdatePerson(ctx, iin1, "Test Name", "+77010000001", 0)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.DeletePersonByIIN(ctx, iin1, 1), storage.ErrorVersionMismatch)
+	_, err = s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin1, 2))
+	_, err = s.GetPersonByIIN(ctx, iin1)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	// Deleting a missing person affects no rows
+	assert.ErrorIs(t, s.DeletePersonByIIN(ctx, iin1, 0), storage.ErrorIINNotFound)
+	assert.ErrorIs(t, s.DeletePersonByIIN(ctx, iin1, 2), storage.ErrorIINNotFound)
+	assert.ErrorIs(t, s.DeletePersonByIIN(ctx, iin1, storage.AnyVersion), storage.ErrorVersionMismatch)
+
+	// The IIN and the phone number are free again
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+
+	// The new record carries on from the version of the deleted one, which no longer matches
+	person, err := s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), person.Version)
+	assert.ErrorIs(t, s.DeletePersonByIIN(ctx, iin1, 1, 2), storage.ErrorVersionMismatch)
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin1, storage.AnyVersion))
+}
+
+func testConcurrentSaves(t *testing.T, s Storage) {
+	ctx := operator()
+
+	const writers = 50
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every pair of writers competes for the same phone number
+			errs[i] = s.SavePerson(ctx, fmt.Sprintf("%012d", i), "Test Name", fmt.Sprintf("+7%010d", i/2))
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, storage.ErrorPhoneNumberExists)
+			failed++
+		}
+	}
+	assert.Equal(t, writers/2, failed)
+
+	people, err := s.GetAllPeople(ctx)
+	require.NoError(t, err)
+	assert.Len(t, people, writers/2)
+}
+
+func testConcurrentUpdates(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+
+	const writers = 20
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every writer read version 1, only one of them may update it
+			_, errs[i] = s.UpdatePerson(ctx, iin1, fmt.Sprintf("Name %d", i), "+77010000001", 1)
+		}(i)
+	}
+	wg.Wait()
+
+	updated := 0
+	for _, err := range errs {
+		if err == nil {
+			updated++
+			continue
+		}
+		assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+	}
+	assert.Equal(t, 1, updated)
+
+	person, err := s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), person.Version)
+}
+
+func testExecuteBatch(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+
+	results, err := s.ExecuteBatch(ctx, []storage.BatchOperation{
+		{Op: storage.OperationCreate, IIN: iin2, Name: "Test Name", Phone: "+77010000002"},
+		{Op: storage.OperationUpdate, IIN: iin1, Name: "New Name", Phone: "+77010000001", ExpectedVersion: 1},
+		{Op: storage.OperationDelete, IIN: iin2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []storage.BatchResult{
+		{Index: 0, Op: storage.OperationCreate, IIN: iin2, Version: 1},
+		{Index: 1, Op: storage.OperationUpdate, IIN: iin1, Version: 2},
+		{Index: 2, Op: storage.OperationDelete, IIN: iin2},
+	}, results)
+
+	// A failed operation rolls back the whole batch, the results end with it
+	results, err = s.ExecuteBatch(ctx, []storage.BatchOperation{
+		{Op: storage.OperationCreate, IIN: iin3, Name: "Test Name", Phone: "+77010000003"},
+		{Op: storage.OperationUpdate, IIN: iin1, Name: "Stale Name", Phone: "+77010000001", ExpectedVersion: 1},
+		{Op: storage.OperationDelete, IIN: iin1},
+	})
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+	require.Len(t, results, 2)
+	assert.ErrorIs(t, results[1].Err, storage.ErrorVersionMismatch)
+	_, err = s.GetPersonByIIN(ctx, iin3)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	results, err = s.ExecuteBatch(ctx, []storage.BatchOperation{{Op: "rename", IIN: iin1}})
+	assert.ErrorIs(t, err, storage.ErrorUnknownOperation)
+	require.Len(t, results, 1)
+	assert.ErrorIs(t, results[0].Err, storage.ErrorUnknownOperation)
+
+	person, err := s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.PersonInfo{IIN: iin1, Name: "New Name", Phone: "+77010000001", Version: 2, Status: storage.StatusActive}, person)
+}
+
+func testMergePeople(t *testing.T, s Storage) {
+	ctx := operator()
+
+	records, err := s.GetMergeLog(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, records)
+	assert.Empty(t, records)
+
+	require.NoError(t, s.SavePerson(ctx, iin1, "Source Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Target Name", "+77010000002"))
+
+	_, err = s.MergePeople(ctx, iin3, iin2, 0)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.MergePeople(ctx, iin1, iin3, 0)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.MergePeople(ctx, iin1, iin3, storage.AnyVersion)
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+	_, err = s.MergePeople(ctx, iin1, iin2, 2)
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+
+	record, err := s.MergePeople(ctx, iin1, iin2, 1)
+	require.NoError(t, err)
+	assert.NotZero(t, record.ID)
+	assert.Equal(t, "Source Name", record.SourceName)
+	assert.Equal(t, "+77010000001", record.SourcePhone)
+	assert.Equal(t, "Target Name", record.TargetName)
+	assert.Equal(t, "+77010000002", record.TargetPhone)
+	assert.Equal(t, int64(2), record.TargetVersion)
+
+	// The source is removed and the target keeps its data under a new version
+	_, err = s.GetPersonByIIN(ctx, iin1)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	person, err := s.GetPersonByIIN(ctx, iin2)
+	require.NoError(t, err)
+	assert.Equal(t, storage.PersonInfo{IIN: iin2, Name: "Target Name", Phone: "+77010000002", Version: 2, Status: storage.StatusActive}, person)
+
+	events, err := s.GetPersonEvents(ctx, iin2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, storage.EventPersonUpdated, events[1].Type)
+	var payload storage.EventPayload
+	require.NoError(t, json.Unmarshal(events[1].Payload, &payload))
+	assert.Equal(t, storage.EventPayload{IIN: iin2, Name: "Target Name", Version: 2, Status: storage.StatusActive}, payload)
+
+	records, err = s.GetMergeLog(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, record.ID, records[0].ID)
+	for _, iin := range []string{iin1, iin2} {
+		records, err = s.GetPersonMerges(ctx, iin)
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
+	}
+	records, err = s.GetPersonMerges(ctx, iin3)
+	require.NoError(t, err)
+	assert.NotNil(t, records)
+	assert.Empty(t, records)
+}
+
+func testChangeStatus(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+
+	changes, err := s.GetStatusHistory(ctx, iin1)
+	require.NoError(t, err)
+	assert.NotNil(t, changes)
+	assert.Empty(t, changes)
+
+	_, err = s.ChangeStatus(ctx, iin2, storage.StatusDeceased, "2024-01-31", "certificate", 0)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.ChangeStatus(ctx, iin1, storage.StatusActive, "2024-01-31", "certificate", 0)
+	assert.ErrorIs(t, err, storage.ErrorStatusTransition)
+	_, err = s.ChangeStatus(ctx, iin1, "missing", "2024-01-31", "certificate", 0)
+	assert.ErrorIs(t, err, storage.ErrorUnknownStatus)
+
+	// The expected version is checked before the transition
+	_, err = s.ChangeStatus(ctx, iin2, storage.StatusDeceased, "2024-01-31", "certificate", storage.AnyVersion)
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+	_, err = s.ChangeStatus(ctx, iin1, storage.StatusEmigrated, "2023-05-01", "departure form", 2)
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+	_, err = s.ChangeStatus(ctx, iin1, storage.StatusActive, "2023-05-01", "departure form", 2)
+	assert.ErrorIs(t, err, storage.ErrorVersionMismatch)
+
+	emigrated, err := s.ChangeStatus(ctx, iin1, storage.StatusEmigrated, "2023-05-01", "departure form", 1)
+	require.NoError(t, err)
+	assert.NotZero(t, emigrated.ID)
+	assert.Equal(t, int64(2), emigrated.Version)
+	assert.Equal(t, storage.StatusActive, emigrated.From)
+	assert.Equal(t, storage.StatusEmigrated, emigrated.To)
+	assert.Equal(t, "2023-05-01", emigrated.EffectiveDate)
+	assert.Equal(t, "departure form", emigrated.Reason)
+
+	// An emigrated person may return, a deceased one is final
+	_, err = s.ChangeStatus(ctx, iin1, storage.StatusActive, "2023-09-01", "arrival form", storage.AnyVersion)
+	require.NoError(t, err)
+	_, err = s.ChangeStatus(ctx, iin1, storage.StatusDeceased, "2024-01-31", "certificate", 0)
+	require.NoError(t, err)
+	for _, status := range storage.Statuses {
+		_, err = s.ChangeStatus(ctx, iin1, status, "2024-02-01", "correction", 0)
+		assert.ErrorIs(t, err, storage.ErrorStatusTransition)
+	}
+
+	// Every change bumps the version and records an update event
+	person, err := s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.PersonInfo{IIN: iin1, Name: "Test Name", Phone: "+77010000001", Version: 4, Status: storage.StatusDeceased}, person)
+	events, err := s.GetPersonEvents(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	assert.Equal(t, storage.EventPersonUpdated, events[3].Type)
+	var payload storage.EventPayload
+	require.NoError(t, json.Unmarshal(events[3].Payload, &payload))
+	assert.Equal(t, storage.EventPayload{IIN: iin1, Name: "Test Name", Version: 4, Status: storage.StatusDeceased}, payload)
+
+	changes, err = s.GetStatusHistory(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Equal(t, emigrated.ID, changes[0].ID)
+	assert.Equal(t, storage.StatusEmigrated, changes[1].From)
+	assert.Equal(t, storage.StatusDeceased, changes[2].To)
+
+	// Updates keep the status
+	_, err = s.UpdatePerson(ctx, iin1, "New Name", "+77010000001", 4)
+	require.NoError(t, err)
+	person, err = s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusDeceased, person.Status)
+}
+
+func testRecreatedPerson(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	_, err := s.ChangeStatus(ctx, iin1, storage.StatusEmigrated, "2023-05-01", "departure form", 0)
+	require.NoError(t, err)
+	_, err = s.GrantConsent(ctx, iin1, "marketing", "form")
+	require.NoError(t, err)
+	_, err = s.PlaceLegalHold(ctx, hold(iin1))
+	require.NoError(t, err)
+	_, err = s.ReleaseLegalHold(ctx, iin1, "Case closed", "investigator")
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin1, 0))
+
+	// The history of a deleted record is kept until it is purged
+	changes, err := s.GetStatusHistory(ctx, iin1)
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+	entries, err := s.GetLegalHoldLog(ctx, iin1)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// A new record under the same IIN starts without the status changes, consents and legal hold log of the previous one
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	changes, err = s.GetStatusHistory(ctx, iin1)
+	require.NoError(t, err)
+	assert.NotNil(t, changes)
+	assert.Empty(t, changes)
+	granted, err := s.HasConsent(ctx, iin1, "marketing")
+	require.NoError(t, err)
+	assert.False(t, granted)
+	consents, err := s.GetConsents(ctx, iin1)
+	require.NoError(t, err)
+	assert.Empty(t, consents)
+	consents, err = s.GetConsentHistory(ctx, iin1)
+	require.NoError(t, err)
+	assert.Empty(t, consents)
+	entries, err = s.GetLegalHoldLog(ctx, iin1)
+	require.NoError(t, err)
+	assert.NotNil(t, entries)
+	assert.Empty(t, entries)
+
+	// and records its own
+	_, err = s.ChangeStatus(ctx, iin1, storage.StatusDeceased, "2024-01-31", "certificate", 0)
+	require.NoError(t, err)
+	_, err = s.GrantConsent(ctx, iin1, "research", "form")
+	require.NoError(t, err)
+	changes, err = s.GetStatusHistory(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, storage.StatusActive, changes[0].From)
+	consents, err = s.GetConsents(ctx, iin1)
+	require.NoError(t, err)
+	require.Len(t, consents, 1)
+	assert.Equal(t, "research", consents[0].Purpose)
+}
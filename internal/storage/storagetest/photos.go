@@ -0,0 +1,86 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPhotos(t *testing.T, s Storage) {
+	ctx := operator()
+	require.NoError(t, s.SavePerson(ctx, iin1, "Test Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Other Name", "+77010000002"))
+	require.NoError(t, s.SavePerson(ctx, iin3, "Third Name", "+77010000003"))
+
+	photo := storage.Photo{IIN: iin1, ContentType: "image/png", Size: 5, Width: 100, Height: 80}
+	_, err := s.SavePhoto(ctx, storage.Photo{IIN: iin4}, []byte("image"), []byte("thumb"))
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, _, err = s.GetPhoto(ctx, iin1)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+	_, err = s.GetPhotoDescription(ctx, iin1)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+	assert.ErrorIs(t, s.DeletePhoto(ctx, iin1), storage.ErrorPhotoNotFound)
+
+	saved, err := s.SavePhoto(ctx, photo, []byte("image"), []byte("thumb"))
+	require.NoError(t, err)
+	assert.False(t, saved.UpdatedAt.IsZero())
+
+	got, data, err := s.GetPhoto(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, photo.ContentType, got.ContentType)
+	assert.Equal(t, photo.Size, got.Size)
+	assert.Equal(t, photo.Width, got.Width)
+	assert.Equal(t, photo.Height, got.Height)
+	assert.Equal(t, []byte("image"), data)
+	_, data, err = s.GetPhotoThumbnail(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("thumb"), data)
+	described, err := s.GetPhotoDescription(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, got, described)
+
+	// A new photo replaces the previous one
+	photo.ContentType = "image/jpeg"
+	_, err = s.SavePhoto(ctx, photo, []byte("new image"), []byte("new thumb"))
+	require.NoError(t, err)
+	got, data, err = s.GetPhoto(ctx, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", got.ContentType)
+	assert.Equal(t, []byte("new image"), data)
+
+	// A merge moves the photo to a target without one and drops it otherwise
+	_, err = s.MergePeople(ctx, iin1, iin2, 0)
+	require.NoError(t, err)
+	_, data, err = s.GetPhoto(ctx, iin2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new image"), data)
+	_, _, err = s.GetPhoto(ctx, iin1)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+
+	photo.IIN = iin3
+	_, err = s.SavePhoto(ctx, photo, []byte("third image"), []byte("third thumb"))
+	require.NoError(t, err)
+	_, err = s.MergePeople(ctx, iin2, iin3, 0)
+	require.NoError(t, err)
+	_, data, err = s.GetPhoto(ctx, iin3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("third image"), data)
+
+	// A deletion removes it, so that a person saved again under the IIN has none
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin3, 0))
+	_, _, err = s.GetPhoto(ctx, iin3)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+	require.NoError(t, s.SavePerson(ctx, iin3, "Third Name", "+77010000003"))
+	_, _, err = s.GetPhoto(ctx, iin3)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+
+	// and so does a deletion in a batch
+	_, err = s.SavePhoto(ctx, photo, []byte("third image"), []byte("third thumb"))
+	require.NoError(t, err)
+	_, err = s.ExecuteBatch(ctx, []storage.BatchOperation{{Op: storage.OperationDelete, IIN: iin3}})
+	require.NoError(t, err)
+	_, _, err = s.GetPhotoThumbnail(ctx, iin3)
+	assert.ErrorIs(t, err, storage.ErrorPhotoNotFound)
+}
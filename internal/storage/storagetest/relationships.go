@@ -0,0 +1,254 @@
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRelationships(t *testing.T, s Storage) {
+	ctx := operator()
+	for i, iin := range []string{iin1, iin2, iin3, iin4} {
+		require.NoError(t, s.SavePerson(ctx, iin, "Test Name", fmt.Sprintf("+7701000000%d", i+1)))
+	}
+
+	parent, err := s.SaveRelationship(ctx, iin4, storage.RelationshipParent, iin1)
+	require.NoError(t, err)
+	assert.Positive(t, parent.ID)
+	assert.False(t, parent.CreatedAt.IsZero())
+	_, err = s.SaveRelationship(ctx, iin4, storage.RelationshipParent, iin1)
+	assert.ErrorIs(t, err, storage.ErrorRelationshipExists)
+	_, err = s.SaveRelationship(ctx, iin3, storage.RelationshipParent, iin1)
+	require.NoError(t, err)
+	_, err = s.SaveRelationship(ctx, iin2, storage.RelationshipParent, iin1)
+	assert.ErrorIs(t, err, storage.ErrorTooManyParents)
+	_, err = s.SaveRelationship(ctx, "990109300285", storage.RelationshipGuardian, iin1)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	// Spouses are stored in the same order whichever order they are given in
+	spouse, err := s.SaveRelationship(ctx, iin2, storage.RelationshipSpouse, iin1)
+	require.NoError(t, err)
+	assert.Equal(t, iin1, spouse.FromIIN)
+	assert.Equal(t, iin2, spouse.ToIIN)
+	_, err = s.SaveRelationship(ctx, iin1, storage.RelationshipSpouse, iin2)
+	assert.ErrorIs(t, err, storage.ErrorRelationshipExists)
+
+	relationships, err := s.GetRelationships(ctx, iin1)
+	require.NoError(t, err)
+	assert.Len(t, relationships, 3)
+	relationships, err = s.GetRelationships(ctx, iin2)
+	require.NoError(t, err)
+	require.Len(t, relationships, 1)
+	assert.Equal(t, spouse.ID, relationships[0].ID)
+
+	// Tenants have their own relationships
+	relationships, err = s.GetRelationships(tenant(t, s, "other"), iin1)
+	require.NoError(t, err)
+	assert.NotNil(t, relationships)
+	assert.Empty(t, relationships)
+
+	require.NoError(t, s.DeleteRelationship(ctx, parent.ID))
+	assert.ErrorIs(t, s.DeleteRelationship(ctx, parent.ID), storage.ErrorRelationshipNotFound)
+
+	// A deletion removes the relationships of the person
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin1, 0))
+	relationships, err = s.GetRelationships(ctx, iin2)
+	require.NoError(t, err)
+	assert.Empty(t, relationships)
+}
+
+func testHousehold(t *testing.T, s Storage) {
+	ctx := operator()
+	for i, iin := range []string{iin1, iin2, iin3, iin4} {
+		require.NoError(t, s.SavePerson(ctx, iin, "Name "+string(rune('A'+i)), fmt.Sprintf("+7701000000%d", i+1)))
+	}
+
+	_, err := s.GetHousehold(ctx, "990109300285", 1)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	// A person without relationships is their own household
+	household, err := s.GetHousehold(ctx, iin1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.HouseholdMember{{IIN: iin1, Name: "Name A"}}, household.Members)
+	assert.NotNil(t, household.Relationships)
+	assert.Empty(t, household.Relationships)
+
+	// iin4 and iin3 are the parents of iin1, who is married to iin2
+	for _, link := range [][3]string{
+		{iin4, storage.RelationshipParent, iin1},
+		{iin3, storage.RelationshipParent, iin1},
+		{iin1, storage.RelationshipSpouse, iin2},
+	} {
+		_, err = s.SaveRelationship(ctx, link[0], link[1], link[2])
+		require.NoError(t, err)
+	}
+
+	household, err = s.GetHousehold(ctx, iin2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.HouseholdMember{
+		{IIN: iin2, Name: "Name B"},
+		{IIN: iin1, Name: "Name A", Hops: 1},
+	}, household.Members)
+	assert.Len(t, household.Relationships, 1)
+
+	household, err = s.GetHousehold(ctx, iin2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.HouseholdMember{
+		{IIN: iin2, Name: "Name B"},
+		{IIN: iin1, Name: "Name A", Hops: 1},
+		{IIN: iin4, Name: "Name D", Hops: 2},
+		{IIN: iin3, Name: "Name C", Hops: 2},
+	}, household.Members)
+	assert.Len(t, household.Relationships, 3)
+
+	// Following more relationships than there are does not repeat anyone
+	household, err = s.GetHousehold(ctx, iin2, 5)
+	require.NoError(t, err)
+	assert.Len(t, household.Members, 4)
+}
+
+func testGuardians(t *testing.T, s Storage) {
+	ctx := operator()
+
+	err := s.SavePersonWithOptions(ctx, iin2, "Ward Name", "+77010000002", storage.SaveOptions{GuardianIIN: iin4})
+	assert.ErrorIs(t, err, storage.ErrorGuardianNotFound)
+	_, err = s.GetPersonByIIN(ctx, iin2)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	require.NoError(t, s.SavePerson(ctx, iin4, "Guardian Name", "+77010000004"))
+	require.NoError(t, s.SavePersonWithOptions(ctx, iin2, "Ward Name", "+77010000002", storage.SaveOptions{GuardianIIN: iin4}))
+	person, err := s.GetPersonByIIN(ctx, iin2)
+	require.NoError(t, err)
+	assert.Equal(t, "Ward Name", person.Name)
+
+	relationships, err := s.GetRelationships(ctx, iin2)
+	require.NoError(t, err)
+	require.Len(t, relationships, 1)
+	assert.Equal(t, iin4, relationships[0].FromIIN)
+	assert.Equal(t, storage.RelationshipGuardian, relationships[0].Type)
+
+	guardianIIN, err := s.GetGuardianIIN(ctx, iin2)
+	require.NoError(t, err)
+	assert.Equal(t, iin4, guardianIIN)
+	guardianIIN, err = s.GetGuardianIIN(ctx, iin4)
+	require.NoError(t, err)
+	assert.Empty(t, guardianIIN)
+	_, err = s.GetGuardianIIN(ctx, iin1)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+
+	// Of the people born after the day, only those without an active guardian are reported,
+	// whether they were saved without one or lost them
+	const minor = "150505500008"
+	bornAfter := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	wards, err := s.GetMinorsWithoutGuardian(ctx, bornAfter)
+	require.NoError(t, err)
+	assert.NotNil(t, wards)
+	assert.Empty(t, wards)
+
+	require.NoError(t, s.SavePerson(ctx, minor, "Minor Name", "+77010000005"))
+	wards, err = s.GetMinorsWithoutGuardian(ctx, bornAfter)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.Ward{{IIN: minor, Name: "Minor Name"}}, wards)
+
+	_, err = s.SaveRelationship(ctx, iin4, storage.RelationshipGuardian, minor)
+	require.NoError(t, err)
+	wards, err = s.GetMinorsWithoutGuardian(ctx, bornAfter)
+	require.NoError(t, err)
+	assert.Empty(t, wards)
+
+	// The wards are reported once the guardian is deleted, and no longer once they are linked again
+	require.NoError(t, s.DeletePersonByIIN(ctx, iin4, 0))
+	wards, err = s.GetMinorsWithoutGuardian(ctx, bornAfter)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.Ward{
+		{IIN: minor, Name: "Minor Name"},
+		{IIN: iin2, Name: "Ward Name", GuardianIIN: iin4},
+	}, wards)
+
+	wards, err = s.GetMinorsWithoutGuardian(tenant(t, s, "other"), bornAfter)
+	require.NoError(t, err)
+	assert.Empty(t, wards)
+
+	require.NoError(t, s.SavePerson(ctx, iin4, "Guardian Name", "+77010000004"))
+	wards, err = s.GetMinorsWithoutGuardian(ctx, bornAfter)
+	require.NoError(t, err)
+	assert.Len(t, wards, 2)
+	for _, ward := range []string{minor, iin2} {
+		_, err = s.SaveRelationship(ctx, iin4, storage.RelationshipGuardian, ward)
+		require.NoError(t, err)
+	}
+	wards, err = s.GetMinorsWithoutGuardian(ctx, bornAfter)
+	require.NoError(t, err)
+	assert.Empty(t, wards)
+
+	// A deceased guardian no longer counts, and cannot be given to anyone
+	_, err = s.ChangeStatus(ctx, iin4, storage.StatusDeceased, "2024-01-01", "Certificate", 0)
+	require.NoError(t, err)
+	wards, err = s.GetMinorsWithoutGuardian(ctx, bornAfter)
+	require.NoError(t, err)
+	assert.Len(t, wards, 2)
+
+	require.NoError(t, s.SavePerson(ctx, iin1, "Other Ward", "+77010000001"))
+	err = s.SavePersonWithOptions(ctx, iin3, "Third Ward", "+77010000003", storage.SaveOptions{GuardianIIN: iin4})
+	assert.ErrorIs(t, err, storage.ErrorGuardianInactive)
+	_, err = s.GetPersonByIIN(ctx, iin3)
+	assert.ErrorIs(t, err, storage.ErrorIINNotFound)
+	_, err = s.SaveRelationship(ctx, iin4, storage.RelationshipGuardian, iin1)
+	assert.ErrorIs(t, err, storage.ErrorGuardianInactive)
+}
+
+func testMergedGuardians(t *testing.T, s Storage) {
+	ctx := operator()
+	const minor = "150505500008"
+	bornAfter := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, s.SavePerson(ctx, iin1, "Source Name", "+77010000001"))
+	require.NoError(t, s.SavePerson(ctx, iin2, "Target Name", "+77010000002"))
+	require.NoError(t, s.SavePerson(ctx, iin3, "Guardian Name", "+77010000003"))
+	require.NoError(t, s.SavePersonWithOptions(ctx, minor, "Minor Name", "+77010000005", storage.SaveOptions{GuardianIIN: iin1}))
+	_, err := s.SaveRelationship(ctx, iin3, storage.RelationshipGuardian, iin1)
+	require.NoError(t, err)
+	_, err = s.SaveRelationship(ctx, iin1, storage.RelationshipSpouse, iin3)
+	require.NoError(t, err)
+
+	// A merge moves the guardian relationships of the source to the target, the others being removed
+	_, err = s.MergePeople(ctx, iin1, iin2)
+	require.NoError(t, err)
+	relationships, err := s.GetRelationships(ctx, iin2)
+	require.NoError(t, err)
+	require.Len(t, relationships, 2)
+	assert.Equal(t, [2]string{iin2, minor}, [2]string{relationships[0].FromIIN, relationships[0].ToIIN})
+	assert.Equal(t, [2]string{iin3, iin2}, [2]string{relationships[1].FromIIN, relationships[1].ToIIN})
+	for _, relationship := range relationships {
+		assert.Equal(t, storage.RelationshipGuardian, relationship.Type)
+	}
+	wards, err := s.GetMinorsWithoutGuardian(ctx, bornAfter)
+	require.NoError(t, err)
+	assert.Empty(t, wards)
+
+	// A target already guarding the wards keeps a single relationship with each
+	require.NoError(t, s.SavePerson(ctx, iin1, "Source Name", "+77010000001"))
+	_, err = s.SaveRelationship(ctx, iin1, storage.RelationshipGuardian, minor)
+	require.NoError(t, err)
+	_, err = s.MergePeople(ctx, iin1, iin2)
+	require.NoError(t, err)
+	relationships, err = s.GetRelationships(ctx, minor)
+	require.NoError(t, err)
+	require.Len(t, relationships, 1)
+	assert.Equal(t, iin2, relationships[0].FromIIN)
+
+	// The wards of the source are not handed over to a deceased target, which refuses the merge
+	require.NoError(t, s.SavePerson(ctx, iin1, "Source Name", "+77010000001"))
+	_, err = s.SaveRelationship(ctx, iin1, storage.RelationshipGuardian, minor)
+	require.NoError(t, err)
+	_, err = s.ChangeStatus(ctx, iin3, storage.StatusDeceased, "2024-01-31", "certificate")
+	require.NoError(t, err)
+	_, err = s.MergePeople(ctx, iin1, iin3)
+	assert.ErrorIs(t, err, storage.ErrorGuardianInactive)
+	_, err = s.GetPersonByIIN(ctx, iin1)
+	require.NoError(t, err)
+}
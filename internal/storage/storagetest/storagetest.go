@@ -0,0 +1,201 @@
+// Package storagetest provides a conformance test suite of the storage contract,
+// which every storage backend runs to prove it behaves like the others.
+package storagetest
+
+import (
+	"citizen_webservice/internal/storage"
+	"context"
+	"testing"
+	"time"
+)
+
+// Storage is the contract of a storage backend, as relied upon by the handlers and the background workers.
+type Storage interface {
+	// People
+	SavePerson(ctx context.Context, iin string, name string, phone string) error
+	SavePersonWithOptions(ctx context.Context, iin string, name string, phone string, opts storage.SaveOptions) error
+	GetPersonByIIN(ctx context.Context, iin string) (storage.PersonInfo, error)
+	GetPersonByName(ctx context.Context, name string, filter storage.PersonFilter) ([]storage.PersonInfo, error)
+	GetAllPeople(ctx context.Context) ([]storage.PersonInfo, error)
+	GetCandidatePairs(ctx context.Context, after string, limit int, maxPairs int) ([]storage.PersonPair, string, error)
+	UpdatePerson(ctx context.Context, iin string, name string, phone string, expectedVersions ...int64) (int64, error)
+	DeletePersonByIIN(ctx context.Context, iin string, expectedVersions ...int64) error
+	ExecuteBatch(ctx context.Context, operations []storage.BatchOperation) ([]storage.BatchResult, error)
+	MergePeople(ctx context.Context, sourceIIN string, targetIIN string, expectedVersions ...int64) (storage.MergeRecord, error)
+	ChangeStatus(ctx context.Context, iin string, status string, effectiveDate string, reason string, expectedVersions ...int64) (storage.StatusChange, error)
+	GetStatusHistory(ctx context.Context, iin string) ([]storage.StatusChange, error)
+
+	// Legal holds
+	PlaceLegalHold(ctx context.Context, hold storage.LegalHold) (storage.LegalHold, error)
+	ReleaseLegalHold(ctx context.Context, iin string, reason string, client string) (storage.HoldLogEntry, error)
+	GetLegalHold(ctx context.Context, iin string) (storage.LegalHold, error)
+	GetLegalHolds(ctx context.Context) ([]storage.LegalHold, error)
+	GetLegalHoldLog(ctx context.Context, iin string) ([]storage.HoldLogEntry, error)
+
+	// Documents
+	SaveDocument(ctx context.Context, document storage.Document) (storage.Document, error)
+	GetDocument(ctx context.Context, iin string, id int64) (storage.Document, error)
+	GetDocuments(ctx context.Context, iin string) ([]storage.Document, error)
+	GetExpiringDocuments(ctx context.Context, from string, to string) ([]storage.Document, error)
+	UpdateDocument(ctx context.Context, document storage.Document) (storage.Document, error)
+	DeleteDocument(ctx context.Context, iin string, id int64) error
+
+	// Photos
+	SavePhoto(ctx context.Context, photo storage.Photo, image []byte, thumbnail []byte) (storage.Photo, error)
+	GetPhoto(ctx context.Context, iin string) (storage.Photo, []byte, error)
+	GetPhotoThumbnail(ctx context.Context, iin string) (storage.Photo, []byte, error)
+	GetPhotoDescription(ctx context.Context, iin string) (storage.Photo, error)
+	DeletePhoto(ctx context.Context, iin string) error
+
+	// Relationships
+	SaveRelationship(ctx context.Context, fromIIN string, relType string, toIIN string) (storage.Relationship, error)
+	GetRelationships(ctx context.Context, iin string) ([]storage.Relationship, error)
+	DeleteRelationship(ctx context.Context, id int64) error
+	GetHousehold(ctx context.Context, iin string, hops int) (storage.Household, error)
+	GetMinorsWithoutGuardian(ctx context.Context, bornAfter time.Time) ([]storage.Ward, error)
+	GetGuardianIIN(ctx context.Context, iin string) (string, error)
+
+	// Addresses
+	SaveAddress(ctx context.Context, address storage.Address) (storage.Address, error)
+	GetAddresses(ctx context.Context, iin string) ([]storage.Address, error)
+	DeleteAddress(ctx context.Context, iin string, addressType string) error
+	GetRegionStatistics(ctx context.Context, addressType string, status string) (storage.RegionStatistics, error)
+
+	// Attributes
+	SaveAttributeSchema(ctx context.Context, namespace string, schema []byte) (storage.AttributeSchema, error)
+	GetAttributeSchema(ctx context.Context, namespace string) (storage.AttributeSchema, error)
+	GetAttributeSchemas(ctx context.Context) ([]storage.AttributeSchema, error)
+	DeleteAttributeSchema(ctx context.Context, namespace string) error
+
+	// Organizations
+	SaveOrganization(ctx context.Context, bin string, name string) (storage.Organization, error)
+	GetOrganization(ctx context.Context, bin string) (storage.Organization, error)
+	GetOrganizations(ctx context.Context) ([]storage.Organization, error)
+	SaveEmployment(ctx context.Context, employment storage.Employment) (storage.Employment, error)
+	GetEmployments(ctx context.Context, iin string) ([]storage.Employment, error)
+	UpdateEmployment(ctx context.Context, employment storage.Employment) (storage.Employment, error)
+	DeleteEmployment(ctx context.Context, iin string, id int64) error
+	GetEmployees(ctx context.Context, bin string, on string) ([]storage.Employee, error)
+
+	// History
+	GetMergeLog(ctx context.Context) ([]storage.MergeRecord, error)
+	GetPersonMerges(ctx context.Context, iin string) ([]storage.MergeRecord, error)
+	GetEvents(ctx context.Context, after int64, limit int) ([]storage.Event, error)
+	GetOutboxEvents(after int64, limit int) ([]storage.Event, error)
+	GetPersonEvents(ctx context.Context, iin string) ([]storage.Event, error)
+	GetLastEventSeq(ctx context.Context) (int64, error)
+	GetOutboxCursor(consumer string) (int64, error)
+	SaveOutboxCursor(consumer string, seq int64) error
+
+	// Consents and access log
+	GrantConsent(ctx context.Context, iin string, purpose string, source string) (storage.Consent, error)
+	RevokeConsent(ctx context.Context, iin string, purpose string, source string) (storage.Consent, error)
+	HasConsent(ctx context.Context, iin string, purpose string) (bool, error)
+	GetConsents(ctx context.Context, iin string) ([]storage.Consent, error)
+	GetConsentHistory(ctx context.Context, iin string) ([]storage.Consent, error)
+	RecordAccess(ctx context.Context, entries []storage.AccessEntry) error
+	GetAccessLog(ctx context.Context, iin string) ([]storage.AccessEntry, error)
+
+	// Webhooks
+	SaveWebhook(ctx context.Context, url string, secret string, events []string) (storage.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (storage.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]storage.Webhook, error)
+	UpdateWebhook(ctx context.Context, id int64, url string, events []string, active bool) (storage.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	EnqueueWebhookDeliveries(limit int) (int, error)
+	GetDueWebhookDeliveries(now time.Time, limit int) ([]storage.WebhookDelivery, error)
+	GetWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]storage.WebhookDelivery, error)
+	GetDeadWebhookDeliveries(ctx context.Context, limit int) ([]storage.WebhookDelivery, error)
+	UpdateWebhookDelivery(delivery storage.WebhookDelivery) error
+	RetryWebhookDelivery(ctx context.Context, id int64) error
+
+	// Retention
+	CountExpired(target string, cutoff time.Time) (int64, error)
+	PurgeExpired(target string, cutoff time.Time) (int64, error)
+
+	// Tenants
+	SaveTenant(id string, name string) (storage.Tenant, error)
+	GetTenants() ([]storage.Tenant, error)
+	SaveCredential(username string, tenant string, passwordHash string) (storage.Credential, error)
+	GetCredential(username string) (storage.Credential, error)
+
+	Close() error
+}
+
+// Factory creates an empty storage for a single test.
+// It is responsible for closing the storage once the test is done, e.g. with t.Cleanup,
+// which must not fail if the test has closed it already.
+type Factory func(t *testing.T) Storage
+
+// Run runs the conformance test suite against the storages created by newStorage, each test with a new one.
+func Run(t *testing.T, newStorage Factory) {
+	for _, test := range []struct {
+		name string
+		fn   func(t *testing.T, s Storage)
+	}{
+		{"SavePerson", testSavePerson},
+		{"GetPersonByName", testGetPersonByName},
+		{"GetAllPeople", testGetAllPeople},
+		{"CandidatePairs", testCandidatePairs},
+		{"UpdatePerson", testUpdatePerson},
+		{"DeletePersonByIIN", testDeletePersonByIIN},
+		{"ConcurrentSaves", testConcurrentSaves},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"ExecuteBatch", testExecuteBatch},
+		{"MergePeople", testMergePeople},
+		{"ChangeStatus", testChangeStatus},
+		{"RecreatedPerson", testRecreatedPerson},
+		{"LegalHolds", testLegalHolds},
+		{"LegalHoldWrites", testLegalHoldWrites},
+		{"Documents", testDocuments},
+		{"ExpiringDocuments", testExpiringDocuments},
+		{"Photos", testPhotos},
+		{"Relationships", testRelationships},
+		{"Household", testHousehold},
+		{"Guardians", testGuardians},
+		{"MergedGuardians", testMergedGuardians},
+		{"Addresses", testAddresses},
+		{"RegionFilters", testRegionFilters},
+		{"AttributeSchemas", testAttributeSchemas},
+		{"Attributes", testAttributes},
+		{"Organizations", testOrganizations},
+		{"Employments", testEmployments},
+		{"Events", testEvents},
+		{"OutboxCursor", testOutboxCursor},
+		{"Consents", testConsents},
+		{"AccessLog", testAccessLog},
+		{"Webhooks", testWebhooks},
+		{"WebhookDeliveries", testWebhookDeliveries},
+		{"Retention", testRetention},
+		{"Tenants", testTenants},
+		{"TenantIsolation", testTenantIsolation},
+		{"Close", testClose},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newStorage(t))
+		})
+	}
+}
+
+// Valid IINs used by the tests.
+const (
+	iin1 = "830218350074"
+	iin2 = "980301450725"
+	iin3 = "790708301327"
+	iin4 = "600426400918"
+)
+
+// operator returns a context scoped to the default tenant, like the requests of the operator.
+func operator() context.Context {
+	return storage.WithTenant(context.Background(), storage.DefaultTenant)
+}
+
+// tenant returns a context scoped to a tenant registered for the test.
+func tenant(t *testing.T, s Storage, id string) context.Context {
+	t.Helper()
+
+	if _, err := s.SaveTenant(id, id); err != nil {
+		t.Fatalf("failed to save tenant %q: %v", id, err)
+	}
+	return storage.WithTenant(context.Background(), id)
+}
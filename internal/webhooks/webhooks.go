@@ -0,0 +1,362 @@
+// Package webhooks delivers change events to the webhooks registered by partner services.
+package webhooks
+
+import (
+	"bytes"
+	"citizen_webservice/internal/storage"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Headers of every delivery request.
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature" // "sha256=" followed by the hex encoded signature, see Sign
+)
+
+// Kinds of changes a webhook may subscribe to.
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// Defaults of the worker used when the options leave them unset.
+const (
+	DefaultPollInterval = time.Second
+	DefaultTimeout      = 5 * time.Second
+	DefaultMaxAttempts  = 8
+	DefaultBaseBackoff  = time.Second
+	DefaultMaxBackoff   = time.Hour
+	DefaultBatchSize    = 100
+	DefaultConcurrency  = 4
+)
+
+// ErrorURLNotAllowed is reported for webhook URLs that are not http or https URLs of a public host.
+var ErrorURLNotAllowed = errors.New("webhook URL must be an http or https URL of a public host")
+
+// Store is the storage of the webhooks and their deliveries.
+type Store interface {
+	GetWebhook(ctx context.Context, id int64) (storage.Webhook, error)
+	EnqueueWebhookDeliveries(limit int) (int, error)
+	GetDueWebhookDeliveries(now time.Time, limit int) ([]storage.WebhookDelivery, error)
+	UpdateWebhookDelivery(delivery storage.WebhookDelivery) error
+}
+
+// Options struct holds the worker settings.
+type Options struct {
+	PollInterval time.Duration // How often new events and due deliveries are looked up
+	Timeout      time.Duration // Timeout of a delivery request
+	MaxAttempts  int           // Attempts before a delivery is moved to the dead-letter list
+	BaseBackoff  time.Duration // Delay before the second attempt, doubled for every next one
+	MaxBackoff   time.Duration // Maximum delay between attempts
+	BatchSize    int           // Maximum number of events fanned out and deliveries attempted per poll
+	Concurrency  int           // Maximum number of deliveries POSTed to a single webhook at once
+	AllowPrivate bool          // Whether webhooks may be delivered to loopback and private addresses, see CheckURL
+}
+
+// Worker struct queues the deliveries of new change events and POSTs them to the webhooks,
+// retrying failed deliveries with exponential backoff.
+type Worker struct {
+	log    *slog.Logger
+	store  Store
+	client *http.Client
+	opts   Options
+}
+
+// NewWorker function creates a delivery worker.
+// It returns a pointer to a Worker struct.
+func NewWorker(log *slog.Logger, store Store, opts Options) *Worker {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = DefaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	return &Worker{
+		log:    log.With(slog.String("op", "webhooks.Worker")),
+		store:  store,
+		client: newClient(opts),
+		opts:   opts,
+	}
+}
+
+// newClient function creates the client of the deliveries. It never follows redirects and,
+// unless private addresses are allowed, only connects to public addresses whatever the host of the URL resolves to.
+func newClient(opts Options) *http.Client {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !isPublic(addr) {
+				return fmt.Errorf("%w: %s", ErrorURLNotAllowed, host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: opts.Timeout},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run method polls for new events and due deliveries until the context is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.RunOnce(ctx); err != nil {
+			w.log.Error("failed to deliver webhooks", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce method queues the deliveries of the new events and attempts every due delivery once.
+// It returns an error if the storage fails, failed deliveries are recorded and retried later.
+func (w *Worker) RunOnce(ctx context.Context) error {
+	const op = "webhooks.Worker.RunOnce"
+
+	if _, err := w.store.EnqueueWebhookDeliveries(w.opts.BatchSize); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := w.store.GetDueWebhookDeliveries(time.Now(), w.opts.BatchSize)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	// Deliveries are POSTed to every webhook at once, a few at a time per webhook,
+	// so that a slow webhook does not hold up the others
+	webhooks := make(map[int64]storage.Webhook)
+	queues := make(map[int64][]storage.WebhookDelivery)
+	var order []int64
+	for _, delivery := range deliveries {
+		if _, ok := webhooks[delivery.WebhookID]; !ok {
+			// Webhooks are read within the tenant of their deliveries
+			webhook, err := w.store.GetWebhook(storage.WithTenant(ctx, delivery.Tenant), delivery.WebhookID)
+			if errors.Is(err, storage.ErrorWebhookNotFound) {
+				// Deleted since the deliveries were read
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			webhooks[webhook.ID] = webhook
+			order = append(order, webhook.ID)
+		}
+		queues[delivery.WebhookID] = append(queues[delivery.WebhookID], delivery)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, id := range order {
+		webhook, slots := webhooks[id], make(chan struct{}, w.opts.Concurrency)
+		for _, delivery := range queues[id] {
+			wg.Add(1)
+			go func(delivery storage.WebhookDelivery) {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				if ctx.Err() != nil {
+					return
+				}
+
+				delivery = w.attempt(ctx, webhook, delivery)
+				if err := w.store.UpdateWebhookDelivery(delivery); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}(delivery)
+		}
+	}
+	wg.Wait()
+
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// attempt method POSTs the delivery to the webhook and returns the delivery updated with the outcome.
+func (w *Worker) attempt(ctx context.Context, webhook storage.Webhook, delivery storage.WebhookDelivery) storage.WebhookDelivery {
+	log := w.log.With(
+		slog.Int64("webhook_id", webhook.ID),
+		slog.Int64("delivery_id", delivery.ID),
+		slog.Int64("event_seq", delivery.EventSeq),
+	)
+
+	delivery.Attempts++
+	delivery.LastError = ""
+
+	status, err := w.post(ctx, webhook, delivery)
+	delivery.ResponseStatus = status
+	if err == nil {
+		log.Info("webhook delivered", slog.Int("attempts", delivery.Attempts))
+		delivery.Status = storage.DeliveryDelivered
+		return delivery
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= w.opts.MaxAttempts {
+		log.Error("webhook delivery failed, moved to dead letters",
+			slog.Int("attempts", delivery.Attempts), slog.String("error", err.Error()))
+		delivery.Status = storage.DeliveryDead
+		return delivery
+	}
+
+	delivery.NextAttemptAt = time.Now().Add(Backoff(w.opts.BaseBackoff, w.opts.MaxBackoff, delivery.Attempts))
+	log.Info("webhook delivery failed, will retry",
+		slog.Int("attempts", delivery.Attempts), slog.Time("next_attempt_at", delivery.NextAttemptAt),
+		slog.String("error", err.Error()))
+	return delivery
+}
+
+// post method sends the signed payload of the delivery.
+// It returns the response status code, zero if there is no response, and an error unless the status is 2xx.
+func (w *Worker) post(ctx context.Context, webhook storage.Webhook, delivery storage.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// CheckURL function checks that the URL of a webhook is an http or https URL whose host is a name
+// or a public IP address, or any IP address if private addresses are allowed.
+// Names are only resolved when a delivery connects to them, see Options.AllowPrivate.
+// It returns ErrorURLNotAllowed if the URL is not allowed.
+func CheckURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrorURLNotAllowed
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrorURLNotAllowed
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublic(addr) {
+		return ErrorURLNotAllowed
+	}
+	return nil
+}
+
+// isPublic function reports whether the address is reachable on the internet,
+// as opposed to loopback, private, link-local, shared or unspecified addresses.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is the range of addresses carrier-grade NATs use inside their networks.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Sign function computes the signature of a delivery, the hex encoded HMAC-SHA256
+// of the timestamp, a dot and the body, keyed with the secret of the webhook.
+// Receivers should recompute it and reject deliveries with an old timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff function returns the delay after the given number of failed attempts:
+// base, doubled after every attempt and capped at maxDelay.
+func Backoff(base time.Duration, maxDelay time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return min(delay, maxDelay)
+}
+
+// NewSecret function generates a random signing secret.
+// It returns the hex encoded secret or an error.
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
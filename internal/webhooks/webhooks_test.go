@@ -0,0 +1,285 @@
+package webhooks
+
+import (
+	"citizen_webservice/internal/storage"
+	"citizen_webservice/internal/storage/sqlite"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef"
+
+// receiver is a partner service recording the deliveries it accepts.
+type receiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   atomic.Int64
+}
+
+func newReceiver(t *testing.T) (*receiver, *httptest.Server) {
+	t.Helper()
+
+	rec := &receiver{}
+	rec.status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, r)
+		rec.bodies = append(rec.bodies, body)
+		rec.mu.Unlock()
+		w.WriteHeader(int(rec.status.Load()))
+	}))
+	t.Cleanup(server.Close)
+	return rec, server
+}
+
+func newTestStorage(t *testing.T) *sqlite.Storage {
+	t.Helper()
+
+	s, err := sqlite.New(filepath.Join(t.TempDir(), "test.db"), sqlite.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestDeliveriesAreFilteredAndSigned(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := storage.WithTenant(context.Background(), storage.DefaultTenant)
+	rec, server := newReceiver(t)
+
+	// Changes made before the registration are not delivered
+	require.NoError(t, s.SavePerson(ctx, "980301450725", "Test Name", "+77010000000"))
+
+	webhook, err := s.SaveWebhook(ctx, server.URL, testSecret, []string{EventCreated, EventDeleted})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+	_, err = s.UpdatePerson(ctx, "830218350074", "New Name", "+77010000001", 0)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePersonByIIN(ctx, "830218350074", 0))
+
+	worker := NewWorker(discard, s, Options{AllowPrivate: true})
+	require.NoError(t, worker.RunOnce(ctx))
+
+	// Deliveries to a webhook are sent at once, in no particular order
+	require.Len(t, rec.requests, 2)
+	var eventTypes []string
+	for i, req := range rec.requests {
+		body := rec.bodies[i]
+
+		timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		assert.Equal(t, "sha256="+Sign(testSecret, timestamp, body), req.Header.Get(HeaderSignature))
+
+		var event storage.Event
+		require.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, req.Header.Get(HeaderEvent), event.Type)
+		assert.Equal(t, "830218350074", event.IIN)
+		eventTypes = append(eventTypes, event.Type)
+	}
+	assert.ElementsMatch(t, []string{storage.EventPersonCreated, storage.EventPersonDeleted}, eventTypes)
+
+	deliveries, err := s.GetWebhookDeliveries(ctx, webhook.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	for _, delivery := range deliveries {
+		assert.Equal(t, storage.DeliveryDelivered, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, http.StatusOK, delivery.ResponseStatus)
+	}
+
+	// Nothing is delivered twice
+	require.NoError(t, worker.RunOnce(ctx))
+	assert.Len(t, rec.requests, 2)
+}
+
+func TestFailedDeliveriesAreRetriedThenDeadLettered(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := storage.WithTenant(context.Background(), storage.DefaultTenant)
+	rec, server := newReceiver(t)
+	rec.status.Store(http.StatusInternalServerError)
+
+	webhook, err := s.SaveWebhook(ctx, server.URL, testSecret, []string{EventCreated})
+	require.NoError(t, err)
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+
+	worker := NewWorker(discard, s, Options{AllowPrivate: true, MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	require.Eventually(t, func() bool {
+		require.NoError(t, worker.RunOnce(ctx))
+		dead, err := s.GetDeadWebhookDeliveries(ctx, 10)
+		require.NoError(t, err)
+		return len(dead) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	dead, err := s.GetDeadWebhookDeliveries(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, webhook.ID, dead[0].WebhookID)
+	assert.Equal(t, 3, dead[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, dead[0].ResponseStatus)
+	assert.Equal(t, "unexpected status 500", dead[0].LastError)
+	assert.Len(t, rec.requests, 3)
+
+	// Dead deliveries are not attempted again until retried
+	require.NoError(t, worker.RunOnce(ctx))
+	assert.Len(t, rec.requests, 3)
+
+	rec.status.Store(http.StatusNoContent)
+	require.NoError(t, s.RetryWebhookDelivery(ctx, dead[0].ID))
+	assert.ErrorIs(t, s.RetryWebhookDelivery(ctx, dead[0].ID), storage.ErrorDeliveryNotDead)
+	require.NoError(t, worker.RunOnce(ctx))
+
+	deliveries, err := s.GetWebhookDeliveries(ctx, webhook.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, storage.DeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+}
+
+func TestPausedWebhooksCatchUp(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := storage.WithTenant(context.Background(), storage.DefaultTenant)
+	rec, server := newReceiver(t)
+
+	webhook, err := s.SaveWebhook(ctx, server.URL, testSecret, []string{EventCreated})
+	require.NoError(t, err)
+	_, err = s.UpdateWebhook(ctx, webhook.ID, server.URL, webhook.Events, false)
+	require.NoError(t, err)
+
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+	worker := NewWorker(discard, s, Options{AllowPrivate: true})
+	require.NoError(t, worker.RunOnce(ctx))
+	assert.Empty(t, rec.requests)
+
+	_, err = s.UpdateWebhook(ctx, webhook.ID, server.URL, webhook.Events, true)
+	require.NoError(t, err)
+	require.NoError(t, worker.RunOnce(ctx))
+	assert.Len(t, rec.requests, 1)
+}
+
+func TestSlowWebhooksDoNotHoldUpOthers(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := storage.WithTenant(context.Background(), storage.DefaultTenant)
+	rec, server := newReceiver(t)
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+
+	_, err := s.SaveWebhook(ctx, slow.URL, testSecret, []string{EventCreated})
+	require.NoError(t, err)
+	_, err = s.SaveWebhook(ctx, server.URL, testSecret, []string{EventCreated})
+	require.NoError(t, err)
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+
+	worker := NewWorker(discard, s, Options{AllowPrivate: true, Timeout: time.Minute})
+	go func() { _ = worker.RunOnce(ctx) }()
+	require.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.requests) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestRedirectsAndPrivateAddressesAreRefused(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := storage.WithTenant(context.Background(), storage.DefaultTenant)
+	rec, server := newReceiver(t)
+
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(redirect.Close)
+
+	redirected, err := s.SaveWebhook(ctx, redirect.URL, testSecret, []string{EventCreated})
+	require.NoError(t, err)
+	require.NoError(t, s.SavePerson(ctx, "830218350074", "Test Name", "+77010000001"))
+
+	require.NoError(t, NewWorker(discard, s, Options{AllowPrivate: true}).RunOnce(ctx))
+	deliveries, err := s.GetWebhookDeliveries(ctx, redirected.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, storage.DeliveryPending, deliveries[0].Status)
+	assert.Equal(t, http.StatusTemporaryRedirect, deliveries[0].ResponseStatus)
+	assert.Empty(t, rec.requests)
+
+	// Registered before private addresses were refused, the receiver is never connected to
+	private, err := s.SaveWebhook(ctx, server.URL, testSecret, []string{EventCreated})
+	require.NoError(t, err)
+	require.NoError(t, s.SavePerson(ctx, "980301450725", "Test Name", "+77010000002"))
+
+	require.NoError(t, NewWorker(discard, s, Options{}).RunOnce(ctx))
+	deliveries, err = s.GetWebhookDeliveries(ctx, private.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, storage.DeliveryPending, deliveries[0].Status)
+	assert.Contains(t, deliveries[0].LastError, ErrorURLNotAllowed.Error())
+	assert.Empty(t, rec.requests)
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		private bool
+		allowed bool
+	}{
+		{"https://partner.example.com/hook", false, true},
+		{"http://203.0.113.10:8080/hook", false, true},
+		{"ftp://partner.example.com/hook", false, false},
+		{"/hook", false, false},
+		{"http://localhost/hook", false, false},
+		{"http://api.localhost/hook", false, false},
+		{"http://127.0.0.1/hook", false, false},
+		{"http://10.0.0.1/hook", false, false},
+		{"http://169.254.169.254/latest/meta-data", false, false},
+		{"http://[::1]/hook", false, false},
+		{"http://[::ffff:192.168.0.1]/hook", false, false},
+		{"http://100.64.0.1/hook", false, false},
+		{"http://0.0.0.0/hook", false, false},
+		{"http://127.0.0.1/hook", true, true},
+		{"ftp://127.0.0.1/hook", true, false},
+	}
+
+	for _, tt := range tests {
+		err := CheckURL(tt.url, tt.private)
+		if tt.allowed {
+			assert.NoError(t, err, tt.url)
+		} else {
+			assert.ErrorIs(t, err, ErrorURLNotAllowed, tt.url)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Backoff(time.Second, time.Minute, tt.attempts), "attempts %d", tt.attempts)
+	}
+}
@@ -1,11 +1,20 @@
 package tests
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/gavv/httpexpect/v2"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -276,3 +285,1937 @@ func TestGetPersonByNameEndpoint(t *testing.T) {
 		Expect().
 		Status(http.StatusOK)
 }
+
+func TestMergePeopleEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+
+	e := httpexpect.Default(t, u.String())
+
+	target_iin := "830218350074"
+	source_iin := "830218350084"
+
+	// 1) Create the same person twice, the second time under a mistyped IIN
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"iin":   target_iin,
+			"name":  "Test Name",
+			"phone": "87011234567",
+		}).
+		Expect().
+		Status(http.StatusOK)
+
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"iin":   source_iin,
+			"name":  "Test  Name",
+			"phone": "87011234568",
+		}).
+		Expect().
+		Status(http.StatusOK)
+
+	// 2) The pair is reported as a duplicate candidate
+	e.GET("/admin/people/duplicates").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ContainsKey("success").HasValue("success", true).
+		Value("candidates").Array().NotEmpty()
+
+	// 3) Merging a record into itself is rejected
+	e.POST("/admin/people/merge").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"source_iin": target_iin,
+			"target_iin": target_iin,
+		}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// 4) A merge conditional on a stale version of the target is rejected. The target starts at the first version,
+	// or carries on from the last version of a deleted record under the same IIN
+	version := int64(e.GET(fmt.Sprintf("/people/info/iin/%s", target_iin)).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("Version").Number().Raw())
+
+	e.POST("/admin/people/merge").
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", strconv.Quote(strconv.FormatInt(version+1, 10))).
+		WithJSON(map[string]interface{}{
+			"source_iin": source_iin,
+			"target_iin": target_iin,
+		}).
+		Expect().
+		Status(http.StatusPreconditionFailed)
+
+	// 5) Merge the source into the target, which gets a new version. Neither consents to the declared purpose,
+	// so their phones are omitted
+	resp := e.POST("/admin/people/merge").
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", strconv.Quote(strconv.FormatInt(version, 10))).
+		WithHeader("X-Purpose", "marketing").
+		WithJSON(map[string]interface{}{
+			"source_iin": source_iin,
+			"target_iin": target_iin,
+		}).
+		Expect().
+		Status(http.StatusOK)
+	resp.Header("ETag").IsEqual(strconv.Quote(strconv.FormatInt(version+1, 10)))
+	resp.JSON().Object().
+		ContainsKey("success").HasValue("success", true).
+		Value("merge").Object().
+		HasValue("source_iin", source_iin).
+		HasValue("target_iin", target_iin).
+		HasValue("target_version", version+1).
+		HasValue("source_phone", "").
+		HasValue("target_phone", "")
+
+	// 6) The source record is gone, so merging it again fails
+	e.GET(fmt.Sprintf("/people/info/iin/%s", source_iin)).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.POST("/admin/people/merge").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"source_iin": source_iin,
+			"target_iin": target_iin,
+		}).
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.GET("/admin/people/merges").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("merges").Array().NotEmpty()
+
+	// 7) Delete the remaining person
+	e.DELETE(fmt.Sprintf("/people/delete/%s", target_iin)).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestConditionalRequests(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+
+	e := httpexpect.Default(t, u.String())
+
+	test_iin := "980301450725"
+
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"iin":   test_iin,
+			"name":  "Test Name",
+			"phone": "1234567890",
+		}).
+		Expect().
+		Status(http.StatusOK)
+
+	// 1) Reading a record again with its ETag is not modified. A new record starts at the first version,
+	// or carries on from the last version of a deleted record under the same IIN
+	first := e.GET(fmt.Sprintf("/people/info/iin/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("Version").Number().Raw()
+	tag := func(version float64) string {
+		return strconv.Quote(strconv.FormatInt(int64(version), 10))
+	}
+
+	e.GET(fmt.Sprintf("/people/info/iin/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-None-Match", tag(first)).
+		Expect().
+		Status(http.StatusNotModified)
+
+	// 2) The first update wins, the second one based on the same version is rejected
+	e.PUT(fmt.Sprintf("/people/info/iin/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", tag(first)).
+		WithJSON(map[string]interface{}{
+			"name":  "First Operator",
+			"phone": "1234567890",
+		}).
+		Expect().
+		Status(http.StatusOK).
+		Header("ETag").IsEqual(tag(first + 1))
+
+	e.PUT(fmt.Sprintf("/people/info/iin/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", tag(first)).
+		WithJSON(map[string]interface{}{
+			"name":  "Second Operator",
+			"phone": "1234567890",
+		}).
+		Expect().
+		Status(http.StatusPreconditionFailed)
+
+	e.GET(fmt.Sprintf("/people/info/iin/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-None-Match", tag(first)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("Name", "First Operator")
+
+	// 3) Any entity tag of an If-Match list matches
+	e.PUT(fmt.Sprintf("/people/info/iin/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", tag(first)+", "+tag(first+1)).
+		WithJSON(map[string]interface{}{
+			"name":  "First Operator",
+			"phone": "1234567890",
+		}).
+		Expect().
+		Status(http.StatusOK).
+		Header("ETag").IsEqual(tag(first + 2))
+
+	// 4) Deleting with a stale version fails, deleting with the current one succeeds
+	e.DELETE(fmt.Sprintf("/people/delete/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", tag(first)).
+		Expect().
+		Status(http.StatusPreconditionFailed)
+
+	e.DELETE(fmt.Sprintf("/people/delete/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", tag(first+2)).
+		Expect().
+		Status(http.StatusOK)
+
+	// 5) If-Match: * requires a current record, so it fails once the person is deleted
+	e.PUT(fmt.Sprintf("/people/info/iin/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", "*").
+		WithJSON(map[string]interface{}{
+			"name":  "Third Operator",
+			"phone": "1234567890",
+		}).
+		Expect().
+		Status(http.StatusPreconditionFailed)
+
+	e.DELETE(fmt.Sprintf("/people/delete/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", "*").
+		Expect().
+		Status(http.StatusPreconditionFailed)
+
+	// 6) A record saved again under the same IIN does not match the entity tags of the deleted one
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"iin":   test_iin,
+			"name":  "Test Name",
+			"phone": "1234567890",
+		}).
+		Expect().
+		Status(http.StatusOK)
+
+	e.DELETE(fmt.Sprintf("/people/delete/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", tag(first)).
+		Expect().
+		Status(http.StatusPreconditionFailed)
+
+	e.DELETE(fmt.Sprintf("/people/delete/%s", test_iin)).
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", tag(first+3)).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestBatchEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+
+	e := httpexpect.Default(t, u.String())
+
+	// 1) A failing operation rolls back the whole batch
+	e.POST("/people/batch").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"operations": []map[string]interface{}{
+				{"op": "create", "iin": "980301450725", "name": "Sally", "phone": "1234567890"},
+				{"op": "create", "iin": "790708301327", "name": "Lilly", "phone": "1234567890"},
+				{"op": "delete", "iin": "980301450725"},
+			},
+		}).
+		Expect().
+		Status(http.StatusConflict).
+		JSON().Object().
+		ContainsKey("success").HasValue("success", false).
+		Value("results").Array().
+		ConsistsOf(
+			map[string]interface{}{"index": 0, "op": "create", "iin": "980301450725", "status": "rolled_back"},
+			map[string]interface{}{"index": 1, "op": "create", "iin": "790708301327", "status": "failed", "error": "phone number already exists"},
+			map[string]interface{}{"index": 2, "op": "delete", "iin": "980301450725", "status": "skipped"},
+		)
+
+	e.GET("/people/info/iin/980301450725").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// 2) Valid batches are committed as a whole. A re-created IIN carries on from the last version of the deleted
+	// record, so the update expects the version the create returned
+	results := e.POST("/people/batch").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"operations": []map[string]interface{}{
+				{"op": "create", "iin": "980301450725", "name": "Sally", "phone": "1234567890"},
+				{"op": "create", "iin": "790708301327", "name": "Lilly", "phone": "1234567891"},
+			},
+		}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ContainsKey("success").HasValue("success", true).
+		Value("results").Array()
+	results.Length().IsEqual(2)
+	version := results.Value(0).Object().Value("version").Number().Raw()
+
+	e.POST("/people/batch").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"operations": []map[string]interface{}{
+				{"op": "update", "iin": "980301450725", "name": "Sally", "phone": "1234567892", "version": version},
+				{"op": "delete", "iin": "790708301327"},
+			},
+		}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ContainsKey("success").HasValue("success", true).
+		Value("results").Array().Length().IsEqual(2)
+
+	e.GET("/people/info/iin/980301450725").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("Phone", "1234567892")
+
+	e.GET("/people/info/iin/790708301327").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// 3) Delete the created person
+	e.DELETE("/people/delete/980301450725").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestEventsEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+
+	e := httpexpect.Default(t, u.String())
+
+	// 1) Find the end of the feed
+	next := e.GET("/events").
+		WithBasicAuth("user", "password").
+		WithQuery("limit", 1000).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ContainsKey("success").HasValue("success", true).
+		Value("next").Number().Raw()
+
+	// 2) Create and delete a person
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": "990109300285", "name": "Molly", "phone": "1234567893"}).
+		Expect().
+		Status(http.StatusOK)
+
+	e.DELETE("/people/delete/990109300285").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+
+	// 3) Both changes are in the feed, in order
+	events := e.GET("/events").
+		WithBasicAuth("user", "password").
+		WithQuery("after", int64(next)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("next", next+2).
+		Value("events").Array()
+	events.Length().IsEqual(2)
+	events.Value(0).Object().
+		HasValue("seq", next+1).
+		HasValue("type", "person.created").
+		HasValue("iin", "990109300285").
+		Value("payload").Object().HasValue("name", "Molly")
+	events.Value(1).Object().
+		HasValue("seq", next+2).
+		HasValue("type", "person.deleted").
+		HasValue("iin", "990109300285")
+
+	// 4) Invalid parameters
+	e.GET("/events").
+		WithBasicAuth("user", "password").
+		WithQuery("after", -1).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.GET("/events").
+		WithBasicAuth("user", "password").
+		WithQuery("limit", 0).
+		Expect().
+		Status(http.StatusBadRequest)
+}
+
+func TestWebhooksEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+
+	e := httpexpect.Default(t, u.String())
+
+	// 1) Invalid subscriptions are rejected
+	e.POST("/admin/webhooks").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"url": "not a url", "events": []string{"created"}}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.POST("/admin/webhooks").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"url": "https://partner.example.com/hook", "events": []string{"renamed"}}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.POST("/admin/webhooks").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"url": "http://169.254.169.254/latest/meta-data", "events": []string{"created"}}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// 2) Register a webhook, its secret is only returned once
+	created := e.POST("/admin/webhooks").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"url": "https://partner.example.com/hook", "events": []string{"created", "deleted"}}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object()
+	created.HasValue("success", true)
+	created.Value("secret").String().Length().IsEqual(64)
+	webhook := created.Value("webhook").Object()
+	webhook.HasValue("url", "https://partner.example.com/hook").HasValue("active", true).NotContainsKey("secret")
+	id := int64(webhook.Value("id").Number().Raw())
+	path := "/admin/webhooks/" + strconv.FormatInt(id, 10)
+
+	e.GET(path).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		NotContainsKey("secret").
+		Value("webhook").Object().HasValue("events", []string{"created", "deleted"})
+
+	e.GET("/admin/webhooks").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("webhooks").Array().NotEmpty()
+
+	// 3) Pause it
+	e.PUT(path).
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"url": "https://partner.example.com/hook", "events": []string{"updated"}, "active": false}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("webhook").Object().HasValue("active", false).HasValue("events", []string{"updated"})
+
+	e.GET(path+"/deliveries").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("deliveries").Array().IsEmpty()
+
+	e.GET("/admin/webhooks/dead-letters").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+
+	e.POST("/admin/webhooks/deliveries/999999/retry").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// 4) Delete it
+	e.DELETE(path).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET(path).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestPeopleStreamEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+
+	e := httpexpect.Default(t, u.String())
+
+	// open starts a stream, resuming after lastEventID unless it is empty
+	open := func(lastEventID string) (*http.Response, *bufio.Scanner) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String()+"/people/stream", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("user", "password")
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp, bufio.NewScanner(resp.Body)
+	}
+
+	// next reads the fields of the next event, skipping comments and the retry message
+	next := func(scanner *bufio.Scanner) map[string]string {
+		fields := map[string]string{}
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				if fields["data"] != "" {
+					return fields
+				}
+				continue
+			}
+			if strings.HasPrefix(line, ":") {
+				continue
+			}
+			name, value, _ := strings.Cut(line, ": ")
+			fields[name] = value
+		}
+		t.Fatalf("stream ended: %v", scanner.Err())
+		return nil
+	}
+
+	// 1) The stream requires authentication
+	e.GET("/people/stream").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	// 2) A new stream starts with the next change
+	resp, scanner := open("")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": "990109300285", "name": "Molly", "phone": "1234567894"}).
+		Expect().
+		Status(http.StatusOK)
+
+	created := next(scanner)
+	if created["event"] != "person.created" || !strings.Contains(created["data"], `"iin":"990109300285"`) {
+		t.Fatalf("unexpected event: %v", created)
+	}
+	_ = resp.Body.Close()
+
+	// 3) A resumed stream sends the changes made since the last received event
+	e.DELETE("/people/delete/990109300285").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+
+	_, scanner = open(created["id"])
+	deleted := next(scanner)
+	if deleted["event"] != "person.deleted" || !strings.Contains(deleted["data"], `"iin":"990109300285"`) {
+		t.Fatalf("unexpected event: %v", deleted)
+	}
+	createdSeq, _ := strconv.ParseInt(created["id"], 10, 64)
+	if deleted["id"] != strconv.FormatInt(createdSeq+1, 10) {
+		t.Fatalf("unexpected event ID %s after %s", deleted["id"], created["id"])
+	}
+
+	// 4) Invalid Last-Event-ID
+	e.GET("/people/stream").
+		WithBasicAuth("user", "password").
+		WithHeader("Last-Event-ID", "abc").
+		Expect().
+		Status(http.StatusBadRequest)
+}
+
+func TestRetentionReportEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	report := e.GET("/admin/retention/report").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	report.HasValue("success", true)
+	for _, rule := range report.Value("rules").Array().Iter() {
+		rule.Object().ContainsKey("target").ContainsKey("max_age").ContainsKey("cutoff").ContainsKey("count")
+	}
+
+	e.GET("/admin/retention/report").
+		Expect().
+		Status(http.StatusUnauthorized)
+}
+
+func TestConsentsEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const iin = "990109300285"
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Consent Person", "phone": "1234567895"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+
+	getPerson := func(purpose string) *httpexpect.Object {
+		req := e.GET("/people/info/iin/"+iin).WithBasicAuth("user", "password")
+		if purpose != "" {
+			req = req.WithHeader("X-Purpose", purpose)
+		}
+		return req.Expect().Status(http.StatusOK).JSON().Object()
+	}
+
+	// 1) Without consent the phone is only returned to clients declaring no purpose
+	getPerson("").HasValue("Phone", "1234567895")
+	getPerson("marketing").NotContainsKey("Phone").HasValue("Name", "Consent Person")
+
+	// 2) Granting the consent shares the phone for that purpose only
+	e.POST("/admin/people/"+iin+"/consents/grant").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"purpose": "marketing", "source": "signed form"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		HasValue("success", true).
+		Value("consent").Object().HasValue("status", "granted").HasValue("purpose", "marketing")
+
+	getPerson("marketing").HasValue("Phone", "1234567895")
+	getPerson("credit_scoring").NotContainsKey("Phone")
+
+	people := e.GET("/people/info/name/Consent Person").
+		WithBasicAuth("user", "password").
+		WithHeader("X-Purpose", "marketing").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("people").Array()
+	people.Value(0).Object().HasValue("Phone", "1234567895")
+
+	// 3) Revoking it hides the phone again and the list shows the revocation
+	e.POST("/admin/people/"+iin+"/consents/revoke").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"purpose": "marketing", "source": "call center"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("consent").Object().HasValue("status", "revoked")
+
+	getPerson("marketing").NotContainsKey("Phone")
+
+	consents := e.GET("/admin/people/"+iin+"/consents").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("consents").Array()
+	consents.Length().IsEqual(1)
+	consents.Value(0).Object().HasValue("status", "revoked").HasValue("source", "call center")
+
+	// 4) Invalid requests
+	e.POST("/admin/people/"+iin+"/consents/revoke").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"purpose": "marketing", "source": "call center"}).
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.POST("/admin/people/600426400918/consents/grant").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"purpose": "marketing", "source": "signed form"}).
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.POST("/admin/people/"+iin+"/consents/grant").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"purpose": "marketing"}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.GET("/admin/people/123/consents").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusBadRequest)
+}
+
+func TestSubjectReportEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const (
+		iin      = "600426400918"
+		guardian = "980301450725"
+	)
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Report Person", "phone": "1234567896"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": guardian, "name": "Report Guardian", "phone": "1234567897"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+guardian).WithBasicAuth("user", "password").Expect()
+	e.POST("/people/relationships").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"from_iin": guardian, "type": "guardian", "to_iin": iin}).
+		Expect().
+		Status(http.StatusCreated)
+	e.GET("/people/info/iin/"+iin).
+		WithBasicAuth("user", "password").
+		WithHeader("X-Purpose", "delivery").
+		Expect().
+		Status(http.StatusOK)
+	e.GET("/people/info/"+iin+"/addresses").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+	e.POST("/admin/people/"+iin+"/consents/grant").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"purpose": "delivery", "source": "signed form"}).
+		Expect().
+		Status(http.StatusCreated)
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewGray(image.Rect(0, 0, 320, 240))); err != nil {
+		t.Fatal(err)
+	}
+	e.PUT("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		WithBytes(photo.Bytes()).
+		Expect().
+		Status(http.StatusOK)
+
+	resp := e.GET("/admin/people/"+iin+"/subject-report").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+	resp.Header("Content-Disposition").IsEqual(`attachment; filename="subject-report-` + iin + `.json"`)
+
+	report := resp.JSON().Object().HasValue("success", true).Value("report").Object()
+	report.HasValue("iin", iin)
+	report.Value("person").Object().HasValue("Name", "Report Person").HasValue("Phone", "1234567896")
+	report.Value("attributes").Object().HasValue("sex", "female").HasValue("date_of_birth", "26.04.1960")
+	report.Value("events").Array().NotEmpty()
+	report.Value("consents").Array().Length().IsEqual(1)
+	report.Value("merges").Array().IsEmpty()
+	relationships := report.Value("relationships").Array()
+	relationships.Length().IsEqual(1)
+	relationships.Value(0).Object().
+		HasValue("from_iin", guardian).HasValue("type", "guardian").HasValue("to_iin", iin)
+	reportedPhoto := report.Value("photo").Object()
+	reportedPhoto.HasValue("content_type", "image/png").HasValue("size", photo.Len()).
+		HasValue("width", 320).HasValue("height", 240)
+	reportedPhoto.Value("updated_at").String().AsDateTime(time.RFC3339Nano)
+	reportedPhoto.NotContainsKey("image")
+	accessLog := report.Value("access_log").Array()
+	accessLog.NotEmpty()
+	// Earlier runs may have left entries in the access log of the IIN
+	reads := accessLog.Filter(func(_ int, entry *httpexpect.Value) bool {
+		return entry.Object().Value("action").String().Raw() == "read"
+	})
+	reads.Last().Object().
+		HasValue("client", "user").
+		HasValue("purpose", "delivery")
+	accessLog.Last().Object().HasValue("action", "addresses")
+
+	// Deleted people are still reported with their history, the report itself is logged
+	e.DELETE("/people/delete/"+iin).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+
+	report = e.GET("/admin/people/"+iin+"/subject-report").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("report").Object()
+	report.Value("person").IsNull()
+	report.Value("photo").IsNull()
+	report.Value("relationships").Array().IsEmpty()
+	events := report.Value("events").Array()
+	events.Last().Object().HasValue("type", "person.deleted")
+	accessLog = report.Value("access_log").Array()
+	accessLog.Last().Object().HasValue("action", "subject_report")
+
+	e.GET("/admin/people/123/subject-report").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusBadRequest)
+}
+
+func TestTenantsEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	tenant := fmt.Sprintf("dept%d", time.Now().UnixNano())
+	user := tenant + "-client"
+	const iin, phone = "830218350084", "1234567896"
+
+	// 1) The operator creates a tenant and assigns a credential to it
+	e.POST("/admin/tenants").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"id": tenant, "name": "Health Department"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		HasValue("success", true).
+		Value("tenant").Object().HasValue("id", tenant)
+
+	e.POST("/admin/tenants").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"id": tenant, "name": "Health Department"}).
+		Expect().
+		Status(http.StatusConflict)
+
+	e.POST("/admin/tenants").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"id": "Not A Slug", "name": "Health Department"}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.GET("/admin/tenants").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("tenants").Array().NotEmpty()
+
+	e.POST("/admin/tenants/"+tenant+"/credentials").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"username": user, "password": "tenant-password"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		Value("credential").Object().
+		HasValue("username", user).HasValue("tenant", tenant).NotContainsKey("password_hash")
+
+	e.POST("/admin/tenants/unknown/credentials").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"username": user, "password": "tenant-password"}).
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.POST("/admin/tenants/"+tenant+"/credentials").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"username": "user", "password": "tenant-password"}).
+		Expect().
+		Status(http.StatusConflict)
+
+	// 2) The tenant only sees its own people, and may store the IIN and phone of a person of another tenant
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Default Person", "phone": phone}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+
+	e.GET("/people/info/iin/"+iin).
+		WithBasicAuth(user, "tenant-password").
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.POST("/people/info").
+		WithBasicAuth(user, "tenant-password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Tenant Person", "phone": phone}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth(user, "tenant-password").Expect()
+
+	e.GET("/people/info/iin/"+iin).
+		WithBasicAuth(user, "tenant-password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().HasValue("Name", "Tenant Person")
+
+	e.GET("/people/info/iin/"+iin).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().HasValue("Name", "Default Person")
+
+	// 3) Only the operator manages the tenants and the legal holds
+	e.GET("/admin/tenants").
+		WithBasicAuth(user, "tenant-password").
+		Expect().
+		Status(http.StatusForbidden)
+
+	e.POST("/admin/tenants/"+tenant+"/people/"+iin+"/legal-hold").
+		WithBasicAuth(user, "tenant-password").
+		WithJSON(map[string]interface{}{"reason": "Fraud investigation", "case_number": "CASE-1"}).
+		Expect().
+		Status(http.StatusForbidden)
+
+	e.POST("/admin/tenants/"+tenant+"/people/"+iin+"/legal-hold/release").
+		WithBasicAuth(user, "tenant-password").
+		WithJSON(map[string]interface{}{"reason": "Case closed"}).
+		Expect().
+		Status(http.StatusForbidden)
+
+	// 4) The operator places holds on the people of the tenant, which lock their writes there alone
+	e.POST("/admin/tenants/unknown/people/"+iin+"/legal-hold").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"reason": "Fraud investigation", "case_number": "CASE-1"}).
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.POST("/admin/tenants/"+tenant+"/people/"+iin+"/legal-hold").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"reason": "Fraud investigation", "case_number": "CASE-1"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	e.GET("/admin/tenants/"+tenant+"/legal-holds").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("holds").Array().Length().IsEqual(1)
+
+	e.PUT("/people/info/iin/"+iin).
+		WithBasicAuth(user, "tenant-password").
+		WithJSON(map[string]interface{}{"name": "Changed Name", "phone": phone}).
+		Expect().
+		Status(http.StatusLocked)
+
+	e.PUT("/people/info/iin/"+iin).
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"name": "Default Person", "phone": phone}).
+		Expect().
+		Status(http.StatusOK)
+
+	e.POST("/admin/tenants/"+tenant+"/people/"+iin+"/legal-hold/release").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"reason": "Case closed"}).
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/people/info/iin/"+iin).
+		WithBasicAuth(user, "wrong-password").
+		Expect().
+		Status(http.StatusUnauthorized)
+}
+
+func TestPersonStatusEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const iin = "600426400918"
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Status Person", "phone": "1234567897"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+
+	// 1) A new person is active. Their record starts at the first version,
+	// or carries on from the last version of a deleted record under the same IIN
+	person := e.GET("/people/info/iin/"+iin).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	person.HasValue("Status", "active")
+	version := int64(person.Value("Version").Number().Raw())
+
+	// 2) Record the death, conditional on the current version
+	e.POST("/admin/people/"+iin+"/status").
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", strconv.Quote(strconv.FormatInt(version+1, 10))).
+		WithJSON(map[string]interface{}{"status": "deceased", "effective_date": "2024-01-31", "reason": "death certificate"}).
+		Expect().
+		Status(http.StatusPreconditionFailed)
+
+	resp := e.POST("/admin/people/"+iin+"/status").
+		WithBasicAuth("user", "password").
+		WithHeader("If-Match", strconv.Quote(strconv.FormatInt(version, 10))).
+		WithJSON(map[string]interface{}{"status": "deceased", "effective_date": "2024-01-31", "reason": "death certificate"}).
+		Expect().
+		Status(http.StatusCreated)
+	resp.Header("ETag").IsEqual(strconv.Quote(strconv.FormatInt(version+1, 10)))
+	resp.JSON().Object().
+		HasValue("success", true).
+		Value("change").Object().
+		HasValue("from", "active").HasValue("to", "deceased").HasValue("effective_date", "2024-01-31").
+		HasValue("version", version+1)
+
+	e.GET("/people/info/iin/"+iin).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().HasValue("Status", "deceased").HasValue("Version", version+1)
+
+	// 3) Searches may be filtered by status
+	e.GET("/people/info/name/Status Person").
+		WithBasicAuth("user", "password").
+		WithQuery("status", "deceased").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("people").Array().Length().IsEqual(1)
+	e.GET("/people/info/name/Status Person").
+		WithBasicAuth("user", "password").
+		WithQuery("status", "active").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().HasValue("people", nil)
+	e.GET("/people/info/name/Status Person").
+		WithBasicAuth("user", "password").
+		WithQuery("status", "missing").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// 4) Deceased is final
+	e.POST("/admin/people/"+iin+"/status").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"status": "active", "effective_date": "2024-02-01", "reason": "mistake"}).
+		Expect().
+		Status(http.StatusConflict)
+
+	changes := e.GET("/admin/people/"+iin+"/status").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("changes").Array()
+	changes.Length().IsEqual(1)
+	changes.Value(0).Object().HasValue("reason", "death certificate")
+
+	// 5) Invalid requests
+	e.POST("/admin/people/830218350084/status").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"status": "deceased", "effective_date": "2024-01-31", "reason": "death certificate"}).
+		Expect().
+		Status(http.StatusNotFound)
+
+	for _, body := range []map[string]interface{}{
+		{"status": "missing", "effective_date": "2024-01-31", "reason": "death certificate"},
+		{"status": "deceased", "effective_date": "31.01.2024", "reason": "death certificate"},
+		{"status": "deceased", "effective_date": "2999-01-31", "reason": "death certificate"},
+		{"status": "deceased", "effective_date": "2024-01-31"},
+	} {
+		e.POST("/admin/people/"+iin+"/status").
+			WithBasicAuth("user", "password").
+			WithJSON(body).
+			Expect().
+			Status(http.StatusBadRequest)
+	}
+}
+
+func TestDocumentsEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const iin = "790708301327"
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Document Person", "phone": "1234567898"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+
+	expiry := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	document := map[string]interface{}{
+		"type":              "id_card",
+		"number":            "043000001",
+		"issuing_authority": "MVD RK",
+		"issue_date":        "2016-05-20",
+		"expiry_date":       expiry,
+	}
+
+	// 1) Save a document
+	id := e.POST("/people/info/"+iin+"/documents").
+		WithBasicAuth("user", "password").
+		WithJSON(document).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		HasValue("success", true).
+		Value("document").Object().
+		HasValue("iin", iin).HasValue("expiry_date", expiry).
+		Value("id").Number().Raw()
+	documentURL := fmt.Sprintf("/people/info/%s/documents/%d", iin, int64(id))
+
+	e.POST("/people/info/"+iin+"/documents").
+		WithBasicAuth("user", "password").
+		WithJSON(document).
+		Expect().
+		Status(http.StatusConflict)
+
+	e.GET("/people/info/"+iin+"/documents").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("documents").Array().Length().IsEqual(1)
+
+	// 2) It is listed as expiring within 30 days, but not within 5
+	e.GET("/people/documents/expiring").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("documents").Array().
+		Filter(func(_ int, value *httpexpect.Value) bool {
+			return value.Object().Value("number").String().Raw() == "043000001"
+		}).Length().IsEqual(1)
+	e.GET("/people/documents/expiring").
+		WithBasicAuth("user", "password").
+		WithQuery("days", 5).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("documents").Array().
+		Filter(func(_ int, value *httpexpect.Value) bool {
+			return value.Object().Value("number").String().Raw() == "043000001"
+		}).Length().IsEqual(0)
+	e.GET("/people/documents/expiring").
+		WithBasicAuth("user", "password").
+		WithQuery("days", -1).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// 3) Renew it
+	document["expiry_date"] = "2036-05-20"
+	e.PUT(documentURL).
+		WithBasicAuth("user", "password").
+		WithJSON(document).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("document").Object().HasValue("expiry_date", "2036-05-20")
+	e.GET(documentURL).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("document").Object().HasValue("expiry_date", "2036-05-20")
+
+	// 4) Invalid documents
+	for _, change := range []map[string]interface{}{
+		{"type": "driving_licence"},
+		{"expiry_date": "2016-05-20"},
+		{"issue_date": "1979-07-08"},
+		{"issue_date": "20.05.2016"},
+		{"issuing_authority": ""},
+	} {
+		invalid := map[string]interface{}{}
+		for key, value := range document {
+			invalid[key] = value
+		}
+		for key, value := range change {
+			invalid[key] = value
+		}
+		e.POST("/people/info/"+iin+"/documents").
+			WithBasicAuth("user", "password").
+			WithJSON(invalid).
+			Expect().
+			Status(http.StatusBadRequest)
+	}
+	e.POST("/people/info/830218350084/documents").
+		WithBasicAuth("user", "password").
+		WithJSON(document).
+		Expect().
+		Status(http.StatusNotFound)
+	e.GET(fmt.Sprintf("/people/info/%s/documents/%d", "830218350084", int64(id))).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// 5) Delete it
+	e.DELETE(documentURL).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+	e.GET(documentURL).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestPhotoEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const iin = "980301450725"
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Photo Person", "phone": "1234567899"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+
+	encode := func(width int, height int) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	photo := encode(320, 240)
+
+	// 1) Upload a photo, whatever content type is declared
+	e.PUT("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		WithHeader("Content-Type", "application/octet-stream").
+		WithBytes(photo).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("success", true).
+		Value("photo").Object().
+		HasValue("content_type", "image/png").HasValue("width", 320).HasValue("height", 240).HasValue("size", len(photo))
+
+	// 2) Download it and its thumbnail
+	downloaded := e.GET("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		HasContentType("image/png")
+	downloaded.Body().IsEqual(string(photo))
+	lastModified := downloaded.Header("Last-Modified").NotEmpty().Raw()
+
+	e.GET("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		WithHeader("If-Modified-Since", lastModified).
+		Expect().
+		Status(http.StatusNotModified)
+
+	thumbnail := e.GET("/people/info/"+iin+"/photo/thumbnail").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		HasContentType("image/jpeg").
+		Body().Raw()
+	config, err := jpeg.DecodeConfig(strings.NewReader(thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 160 || config.Height != 120 {
+		t.Errorf("thumbnail is %dx%d, want 160x120", config.Width, config.Height)
+	}
+
+	// 3) Invalid photos
+	e.PUT("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		WithBytes([]byte("%PDF-1.4 not a photo")).
+		Expect().
+		Status(http.StatusUnsupportedMediaType)
+	e.PUT("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		WithBytes(encode(32, 32)).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.PUT("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		WithBytes(make([]byte, 5<<20+1)).
+		Expect().
+		Status(http.StatusRequestEntityTooLarge)
+	e.PUT("/people/info/830218350084/photo").
+		WithBasicAuth("user", "password").
+		WithBytes(photo).
+		Expect().
+		Status(http.StatusNotFound)
+
+	// 4) Delete it
+	e.DELETE("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+	e.GET("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestRelationshipsEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const (
+		parent = "600426400918"
+		child  = "830218350074"
+		spouse = "990109300285"
+	)
+	for i, iin := range []string{parent, child, spouse} {
+		e.POST("/people/info").
+			WithBasicAuth("user", "password").
+			WithJSON(map[string]interface{}{"iin": iin, "name": "Family Person", "phone": fmt.Sprintf("123456788%d", i)}).
+			Expect().
+			Status(http.StatusOK)
+		defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+	}
+
+	// 1) Link the parent and the spouse of the child
+	id := e.POST("/people/relationships").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"from_iin": parent, "type": "parent", "to_iin": child}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		HasValue("success", true).
+		Value("relationship").Object().
+		HasValue("from_iin", parent).HasValue("type", "parent").
+		Value("id").Number().Raw()
+	e.POST("/people/relationships").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"from_iin": spouse, "type": "spouse", "to_iin": child}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Value("relationship").Object().HasValue("from_iin", child)
+
+	e.POST("/people/relationships").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"from_iin": parent, "type": "parent", "to_iin": child}).
+		Expect().
+		Status(http.StatusConflict)
+
+	// 2) A parent must be born before the child, and both must be stored
+	e.POST("/people/relationships").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"from_iin": spouse, "type": "parent", "to_iin": parent}).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/people/relationships").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"from_iin": parent, "type": "guardian", "to_iin": "830218350084"}).
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.GET("/people/info/"+child+"/relationships").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("relationships").Array().Length().IsEqual(2)
+
+	// 3) The parent reaches the spouse in two hops
+	e.GET("/people/info/"+parent+"/household").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("household").Object().Value("members").Array().Length().IsEqual(2)
+	household := e.GET("/people/info/"+parent+"/household").
+		WithBasicAuth("user", "password").
+		WithQuery("depth", 2).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("household").Object()
+	household.Value("members").Array().Length().IsEqual(3)
+	household.Value("members").Array().Value(2).Object().HasValue("iin", spouse).HasValue("hops", 2)
+	household.Value("relationships").Array().Length().IsEqual(2)
+
+	e.GET("/people/info/"+parent+"/household").
+		WithBasicAuth("user", "password").
+		WithQuery("depth", 0).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// 4) Remove the parent link
+	e.DELETE(fmt.Sprintf("/people/relationships/%d", int64(id))).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+	e.DELETE(fmt.Sprintf("/people/relationships/%d", int64(id))).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestGuardianEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const (
+		guardian = "830218350074"
+		minor    = "150101510009" // Born in 2015
+	)
+	minorWith := func(guardianIIN string) map[string]interface{} {
+		return map[string]interface{}{"iin": minor, "name": "Minor Person", "phone": "1234567884", "guardian_iin": guardianIIN}
+	}
+
+	// 1) A minor needs a stored adult guardian, and cannot be created in a batch
+	e.POST("/people/batch").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{
+			"operations": []map[string]interface{}{
+				{"op": "create", "iin": minor, "name": "Minor Person", "phone": "1234567884"},
+			},
+		}).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": minor, "name": "Minor Person", "phone": "1234567884"}).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(minorWith("120505510004")).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(minorWith(guardian)).
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": guardian, "name": "Guardian Person", "phone": "1234567883"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+guardian).WithBasicAuth("user", "password").Expect()
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(minorWith(guardian)).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+minor).WithBasicAuth("user", "password").Expect()
+
+	e.GET("/people/info/"+minor+"/relationships").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("relationships").Array().Value(0).Object().
+		HasValue("from_iin", guardian).HasValue("type", "guardian")
+
+	// 2) The minor is reported once they lose the link to their guardian, or the guardian is deleted or deceased
+	reported := func() *httpexpect.Array {
+		return e.GET("/admin/people/minors/without-guardian").
+			WithBasicAuth("user", "password").
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().HasValue("adult_age", 18).Value("minors").Array().
+			Filter(func(_ int, value *httpexpect.Value) bool {
+				return value.Object().Value("iin").String().Raw() == minor
+			})
+	}
+	reported().Length().IsEqual(0)
+
+	id := e.GET("/people/info/"+minor+"/relationships").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("relationships").Array().Value(0).Object().Value("id").Number().Raw()
+	e.DELETE(fmt.Sprintf("/people/relationships/%d", int64(id))).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+	reported().Length().IsEqual(1)
+
+	e.POST("/people/relationships").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"from_iin": guardian, "type": "guardian", "to_iin": minor}).
+		Expect().
+		Status(http.StatusCreated)
+	reported().Length().IsEqual(0)
+
+	e.POST("/admin/people/"+guardian+"/status").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"status": "deceased", "effective_date": "2024-01-01", "reason": "Certificate"}).
+		Expect().
+		Status(http.StatusCreated)
+	reported().Length().IsEqual(1)
+
+	e.DELETE("/people/delete/"+guardian).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+	reported().Value(0).Object().HasValue("guardian_iin", guardian)
+}
+
+func TestAddressesEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const iin = "790708301327"
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Address Person", "phone": "1234567885"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+
+	address := map[string]interface{}{
+		"kato":        "751110000",
+		"locality":    "Almaty",
+		"street":      "Abai Avenue",
+		"house":       "10",
+		"apartment":   "5",
+		"postal_code": "050000",
+	}
+
+	// 1) Save the registered address
+	e.PUT("/people/info/"+iin+"/addresses/registered").
+		WithBasicAuth("user", "password").
+		WithJSON(address).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("success", true).
+		Value("address").Object().
+		HasValue("type", "registered").HasValue("region", "750000000")
+	e.GET("/people/info/"+iin+"/addresses").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("addresses").Array().Length().IsEqual(1)
+
+	// 2) Codes are checked against the reference table
+	address["kato"] = "990000000"
+	e.PUT("/people/info/"+iin+"/addresses/actual").
+		WithBasicAuth("user", "password").
+		WithJSON(address).
+		Expect().
+		Status(http.StatusBadRequest)
+	address["kato"] = "7511"
+	e.PUT("/people/info/"+iin+"/addresses/actual").
+		WithBasicAuth("user", "password").
+		WithJSON(address).
+		Expect().
+		Status(http.StatusBadRequest)
+	address["kato"] = "751110000"
+	e.PUT("/people/info/"+iin+"/addresses/home").
+		WithBasicAuth("user", "password").
+		WithJSON(address).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.PUT("/people/info/830218350084/addresses/actual").
+		WithBasicAuth("user", "password").
+		WithJSON(address).
+		Expect().
+		Status(http.StatusNotFound)
+
+	// 3) Search and count by region
+	e.GET("/people/info/name/Address Person").
+		WithBasicAuth("user", "password").
+		WithQuery("region", "750000000").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("people").Array().Length().IsEqual(1)
+	e.GET("/people/info/name/Address Person").
+		WithBasicAuth("user", "password").
+		WithQuery("region", "751110000").
+		Expect().
+		Status(http.StatusBadRequest)
+	e.GET("/admin/people/statistics/regions").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("type", "registered").
+		Value("regions").Array().
+		Filter(func(_ int, value *httpexpect.Value) bool {
+			return value.Object().Value("region").String().Raw() == "750000000"
+		}).Value(0).Object().HasValue("name", "Almaty").HasValue("people", 1)
+	e.GET("/admin/people/statistics/regions").
+		WithBasicAuth("user", "password").
+		WithQuery("type", "home").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// 4) Delete it
+	e.DELETE("/people/info/"+iin+"/addresses/registered").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+	e.DELETE("/people/info/"+iin+"/addresses/registered").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestAttributesEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const iin = "600426400918"
+	const namespace = "it_benefits"
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"category": map[string]interface{}{"enum": []string{"veteran", "disability"}},
+			"since":    map[string]interface{}{"type": "integer"},
+		},
+		"required": []string{"category"},
+	}
+
+	// 1) Register the schema, which must be a valid JSON Schema
+	e.PUT("/admin/attributes/"+namespace).
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"schema": map[string]interface{}{"type": "date"}}).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.PUT("/admin/attributes/Benefits").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"schema": schema}).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.PUT("/admin/attributes/"+namespace).
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"schema": schema}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		HasValue("success", true).
+		Value("schema").Object().HasValue("namespace", namespace)
+	defer e.DELETE("/admin/attributes/"+namespace).WithBasicAuth("user", "password").Expect()
+	e.GET("/admin/attributes/"+namespace).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("schema").Object().Value("schema").Object().HasValue("type", "object")
+
+	// 2) Values are validated against it, and unknown namespaces are refused
+	person := map[string]interface{}{
+		"iin":        iin,
+		"name":       "Attribute Person",
+		"phone":      "1234567886",
+		"attributes": map[string]interface{}{namespace: map[string]interface{}{"category": "student"}},
+	}
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(person).
+		Expect().
+		Status(http.StatusBadRequest)
+	person["attributes"] = map[string]interface{}{"it_unknown": map[string]interface{}{"category": "veteran"}}
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(person).
+		Expect().
+		Status(http.StatusBadRequest)
+	person["attributes"] = map[string]interface{}{namespace: map[string]interface{}{"category": "veteran", "since": 2001}}
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(person).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+
+	// 3) They are returned with the person and can be searched by
+	e.GET("/people/info/iin/"+iin).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("Attributes").Object().
+		Value(namespace).Object().HasValue("category", "veteran").HasValue("since", 2001)
+	e.GET("/people/info/name/Attribute Person").
+		WithBasicAuth("user", "password").
+		WithQuery("attributes."+namespace+".category", "veteran").
+		WithQuery("attributes."+namespace+".since", "2001").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("people").Array().Length().IsEqual(1)
+	e.GET("/people/info/name/Attribute Person").
+		WithBasicAuth("user", "password").
+		WithQuery("attributes."+namespace+".category", "disability").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("people").IsNull()
+	e.GET("/people/info/name/Attribute Person").
+		WithBasicAuth("user", "password").
+		WithQuery("attributes."+namespace, "veteran").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// 4) A namespace in use cannot be removed
+	e.DELETE("/admin/attributes/"+namespace).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusConflict)
+}
+
+func TestOrganizationsEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const iin = "850512400125"
+	const bin = "071140023453"
+
+	// 1) Organizations are identified by a valid BIN
+	e.POST("/organizations").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"bin": "071140023454", "name": "Invalid LLP"}).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/organizations").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"bin": bin, "name": "Integration LLP"}).
+		Expect()
+	e.POST("/organizations").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"bin": bin, "name": "Integration LLP"}).
+		Expect().
+		Status(http.StatusConflict)
+	e.GET("/organizations/"+bin).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("organization").Object().HasValue("name", "Integration LLP")
+	e.GET("/organizations/200150001116").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// 2) Employments link people to organizations and may not overlap
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Employed Person", "phone": "1234567887"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+
+	e.POST("/people/info/"+iin+"/employments").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"bin": bin, "start_date": "1980-01-01"}).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/people/info/"+iin+"/employments").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"bin": bin, "start_date": "2010-01-01", "end_date": "2009-12-31"}).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/people/info/"+iin+"/employments").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"bin": "200150001116", "start_date": "2010-01-01"}).
+		Expect().
+		Status(http.StatusNotFound)
+	id := e.POST("/people/info/"+iin+"/employments").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"bin": bin, "position": "Accountant", "start_date": "2010-01-01"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		Value("employment").Object().Value("id").Number().Raw()
+	e.POST("/people/info/"+iin+"/employments").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"bin": bin, "start_date": "2015-01-01", "end_date": "2016-01-01"}).
+		Expect().
+		Status(http.StatusConflict)
+
+	// 3) The person is listed as an employee until the employment ends
+	e.GET("/organizations/"+bin+"/employees").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("employees").Array().Length().IsEqual(1)
+	e.PUT(fmt.Sprintf("/people/info/%s/employments/%d", iin, int64(id))).
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"position": "Accountant", "start_date": "2010-01-01", "end_date": "2020-06-30"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("employment").Object().HasValue("end_date", "2020-06-30").HasValue("bin", bin)
+	e.GET("/organizations/"+bin+"/employees").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("employees").Array().IsEmpty()
+	e.GET("/organizations/"+bin+"/employees").
+		WithBasicAuth("user", "password").
+		WithQuery("on", "2020-06-30").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("employees").Array().Value(0).Object().HasValue("name", "Employed Person")
+	e.GET("/organizations/"+bin+"/employees").
+		WithBasicAuth("user", "password").
+		WithQuery("on", "30.06.2020").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// 4) Employments are listed with the person and can be deleted
+	e.GET("/people/info/"+iin+"/employments").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("employments").Array().Length().IsEqual(1)
+	e.DELETE(fmt.Sprintf("/people/info/%s/employments/%d", iin, int64(id))).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+	e.DELETE(fmt.Sprintf("/people/info/%s/employments/%d", iin, int64(id))).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestLegalHoldEndpoint(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const iin = "770303400151"
+	e.POST("/people/info").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"iin": iin, "name": "Held Person", "phone": "1234567888"}).
+		Expect().
+		Status(http.StatusOK)
+	defer e.DELETE("/people/delete/"+iin).WithBasicAuth("user", "password").Expect()
+
+	// 1) Place the hold, which needs a reason and a case number
+	e.POST("/admin/tenants/default/people/"+iin+"/legal-hold").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"reason": "Fraud investigation"}).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/admin/tenants/default/people/"+iin+"/legal-hold").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"reason": "Fraud investigation", "case_number": "2024-0117"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		Value("hold").Object().HasValue("case_number", "2024-0117").HasValue("placed_by", "user")
+	defer e.POST("/admin/tenants/default/people/"+iin+"/legal-hold/release").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"reason": "Test cleanup"}).
+		Expect()
+	e.POST("/admin/tenants/default/people/"+iin+"/legal-hold").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"reason": "Fraud investigation", "case_number": "2024-0117"}).
+		Expect().
+		Status(http.StatusConflict)
+	e.GET("/admin/tenants/default/legal-holds").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("holds").Array().Length().IsEqual(1)
+
+	// 2) Every write is locked
+	e.PUT("/people/info/iin/"+iin).
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"name": "Changed Name", "phone": "1234567888"}).
+		Expect().
+		Status(http.StatusLocked)
+	e.DELETE("/people/delete/"+iin).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusLocked)
+	e.POST("/admin/people/"+iin+"/status").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"status": "emigrated", "effective_date": "2024-01-31", "reason": "notice"}).
+		Expect().
+		Status(http.StatusLocked)
+	e.DELETE("/people/info/"+iin+"/photo").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusLocked)
+	e.PUT("/people/info/"+iin+"/addresses/registered").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"kato": "751110000", "locality": "Almaty", "street": "Abai", "house": "1"}).
+		Expect().
+		Status(http.StatusLocked)
+	e.GET("/people/info/iin/"+iin).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().HasValue("Name", "Held Person")
+
+	// 3) Release the hold, after which the person can be deleted
+	e.POST("/admin/tenants/default/people/"+iin+"/legal-hold/release").
+		WithBasicAuth("user", "password").
+		WithJSON(map[string]interface{}{"reason": "Case closed"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("entry").Object().HasValue("action", "released").HasValue("case_number", "2024-0117")
+	e.GET("/admin/tenants/default/people/"+iin+"/legal-hold").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusNotFound)
+	e.GET("/admin/tenants/default/people/"+iin+"/legal-hold/log").
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("entries").Array().Length().IsEqual(2)
+	e.DELETE("/people/delete/"+iin).
+		WithBasicAuth("user", "password").
+		Expect().
+		Status(http.StatusOK)
+}